@@ -3,19 +3,33 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/backend/bleve"
+	"wikidocify/elasticsearch-service/internal/backend/memory"
+	"wikidocify/elasticsearch-service/internal/cache"
 	"wikidocify/elasticsearch-service/internal/config"
 	"wikidocify/elasticsearch-service/internal/elastic"
 	"wikidocify/elasticsearch-service/internal/handlers"
 	"wikidocify/elasticsearch-service/internal/kafka"
+	"wikidocify/elasticsearch-service/internal/leader"
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/notify"
+	"wikidocify/elasticsearch-service/internal/ratelimit"
 	"wikidocify/elasticsearch-service/internal/routes"
 	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -25,21 +39,79 @@ func main() {
 	// Load environment variables from .env file
 	_ = godotenv.Load()
 
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration: defaults, config file, environment and flags
+	args := os.Args[1:]
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatal("Failed to load config:", err)
+		fmt.Fprintf(os.Stderr, "search-service: %v\n", err)
+		os.Exit(2)
+	}
+	var liveConfig atomic.Pointer[config.Config]
+	liveConfig.Store(cfg)
+
+	// Initialize structured logging
+	if err := logging.Init(cfg.Log.Level); err != nil {
+		logging.Fatal("Failed to initialize logging", "error", err)
 	}
 
-	// Initialize Elasticsearch client
-	esClient, err := elastic.NewClient(
-		cfg.Elasticsearch.URL,
-		cfg.Elasticsearch.Index,
+	// Initialize tracing before any instrumented client is created
+	shutdownTracing, err := tracing.Init(
+		context.Background(),
+		cfg.Tracing.ServiceName,
+		cfg.Tracing.Exporter,
+		cfg.Tracing.SampleRatio,
 	)
 	if err != nil {
-		log.Fatal("Failed to create Elasticsearch client:", err)
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	slog.Info("Tracing initialized", "exporter", cfg.Tracing.Exporter)
+
+	// Initialize the search backend. Analytics, saved searches and synonyms
+	// are stored in Elasticsearch and only available with it.
+	var esClient *elastic.Client
+	var bleveBackend *bleve.Backend
+	var searchBackend backend.SearchBackend
+	switch cfg.SearchBackend {
+	case "elasticsearch":
+		esClient, err = elastic.NewClient(elastic.Connection{
+			Addresses:              cfg.Elasticsearch.URLs,
+			CloudID:                cfg.Elasticsearch.CloudID,
+			Flavor:                 cfg.Elasticsearch.Flavor,
+			Username:               cfg.Elasticsearch.Username,
+			Password:               cfg.Elasticsearch.Password,
+			APIKey:                 cfg.Elasticsearch.APIKey,
+			CACert:                 cfg.Elasticsearch.CACert,
+			CertificateFingerprint: cfg.Elasticsearch.CAFingerprint,
+			ClientCert:             cfg.Elasticsearch.ClientCert,
+			ClientKey:              cfg.Elasticsearch.ClientKey,
+			RetryOnStatus:          cfg.Elasticsearch.RetryOnStatus,
+			MaxRetries:             cfg.Elasticsearch.MaxRetries,
+			RetryBackoff:           cfg.Elasticsearch.RetryBackoff,
+			Compress:               cfg.Elasticsearch.Compress,
+			ConnectTimeout:         cfg.Elasticsearch.ConnectTimeout,
+		}, cfg.Elasticsearch.Index, cfg.Elasticsearch.TenantRouting)
+		if err != nil {
+			logging.Fatal("Failed to create Elasticsearch client", "error", err)
+		}
+		esClient.SetRanking(newRanking(cfg))
+		searchBackend = esClient
+		slog.Info("Elasticsearch client initialized", "flavor", esClient.Flavor(), "default_rank", cfg.Ranking.DefaultMode)
+	case "bleve":
+		bleveBackend, err = bleve.Open(cfg.Bleve.Path)
+		if err != nil {
+			logging.Fatal("Failed to open Bleve index", "error", err)
+		}
+		searchBackend = bleveBackend
+		slog.Warn("Using the Bleve search backend; analytics, saved searches and synonyms are disabled", "path", cfg.Bleve.Path)
+	case "memory":
+		searchBackend = memory.New()
+		slog.Warn("Using the in-memory search backend; documents are lost on restart and analytics, saved searches and synonyms are disabled")
+	default:
+		logging.Fatal("Unknown search backend", "backend", cfg.SearchBackend)
 	}
-	log.Println("Elasticsearch client initialized")
 
 	// Initialize document service client
 	docServiceClient := services.NewDocServiceClient(
@@ -47,37 +119,230 @@ func main() {
 		cfg.DocService.APIKey,
 		cfg.DocService.Timeout,
 	)
-	log.Println("Document service client initialized")
+	slog.Info("Document service client initialized")
 
 	// Initialize search service
 	searchService := services.NewSearchService(
-		esClient,
+		searchBackend,
 		docServiceClient,
 		cfg.Sync.SyncInterval,
 		cfg.Sync.BatchSize,
 		cfg.Sync.EnableSync,
 	)
-	log.Println("Search service initialized")
+	if cfg.Cache.Enabled {
+		searchService.SetResultCache(cache.NewLRU(cfg.Cache.MaxEntries), cfg.Cache.TTL)
+		slog.Info("Search result cache enabled", "max_entries", cfg.Cache.MaxEntries, "ttl", cfg.Cache.TTL.String())
+	}
+	// Keep full sync runs in Elasticsearch so an interrupted sync resumes
+	// after a restart; the other backends keep them in memory
+	if esClient != nil {
+		runs, err := services.NewElasticsearchSyncRunStore(context.Background(), esClient, cfg.Sync.RunsIndex)
+		if err != nil {
+			logging.Fatal("Failed to initialize sync runs", "error", err)
+		}
+		searchService.SetSyncRunStore(runs, cfg.Sync.ResumeMaxAge)
+	} else {
+		searchService.SetSyncRunStore(services.NewMemorySyncRunStore(), cfg.Sync.ResumeMaxAge)
+	}
+	if err := searchService.LoadLastSync(context.Background()); err != nil {
+		slog.Warn("Failed to load the last sync run", "error", err)
+	}
+	slog.Info("Search service initialized")
 
-	// Start Kafka consumer for real-time sync
-	go kafka.StartConsumer(searchService)
+	// Start Kafka consumer for real-time sync. Without a broker, documents
+	// are only indexed by full syncs.
+	var consumer *kafka.Consumer
+	if cfg.Kafka.Broker != "" {
+		consumer = kafka.NewConsumer(searchService, cfg.Kafka.Broker, cfg.Kafka.Topic, cfg.Kafka.GroupID)
+	} else {
+		slog.Warn("KAFKA_BROKER is not set; documents are indexed by full syncs only")
+	}
+
+	// Initialize saved searches; documents indexed by the consumer are
+	// percolated against them
+	var savedSearchService *services.SavedSearchService
+	var savedSearchHandler *handlers.SavedSearchHandler
+	if cfg.SavedSearches.Enabled && esClient != nil {
+		notifier, err := newNotifier(cfg)
+		if err != nil {
+			logging.Fatal("Failed to initialize saved search alerts", "error", err)
+		}
+		savedSearchService = services.NewSavedSearchService(
+			esClient,
+			cfg.SavedSearches.Index,
+			cfg.SavedSearches.AlertsIndex,
+			cfg.SavedSearches.MaxPerOwner,
+			notifier,
+		)
+		if err := savedSearchService.Start(context.Background()); err != nil {
+			logging.Fatal("Failed to start saved searches", "error", err)
+		}
+		if consumer != nil {
+			consumer.SetSavedSearches(savedSearchService)
+		}
+		savedSearchHandler = handlers.NewSavedSearchHandler(savedSearchService)
+		slog.Info("Saved searches enabled", "index", cfg.SavedSearches.Index, "notifier", cfg.SavedSearches.Notifier)
+	}
+	// A nil *kafka.Consumer must not become a non-nil interface
+	var consumerStatus services.ConsumerStatusProvider
+	if consumer != nil {
+		go consumer.Run()
+		consumerStatus = consumer
+	}
+
+	// Initialize health checks
+	healthService := services.NewHealthService(
+		searchBackend,
+		docServiceClient,
+		searchService,
+		consumerStatus,
+		cfg.Health.CacheTTL,
+		cfg.Health.CheckTimeout,
+	)
+
+	// Elect the replica that runs the scheduled syncs
+	var elector *leader.Elector
+	if cfg.Sync.EnableSync && cfg.LeaderElection.Backend != "none" {
+		lock, err := newLeaderLock(cfg, esClient)
+		if err != nil {
+			logging.Fatal("Failed to initialize leader election", "error", err)
+		}
+		elector = leader.NewElector(
+			lock,
+			cfg.LeaderElection.Backend,
+			cfg.LeaderElection.ReplicaID,
+			cfg.LeaderElection.LeaseDuration,
+			cfg.LeaderElection.RenewInterval,
+		)
+		elector.Start()
+		searchService.SetLeaderElector(elector)
+		slog.Info("Leader election started",
+			"backend", cfg.LeaderElection.Backend,
+			"replica", cfg.LeaderElection.ReplicaID,
+			"leader", elector.Status().Leader,
+		)
+	}
 
 	// Perform initial full sync if enabled
 	if cfg.Sync.EnableSync {
-		log.Println("Starting initial full sync...")
-		if err := searchService.FullSync(); err != nil {
-			log.Printf("  Initial sync failed: %v", err)
-		} else {
-			log.Println(" Initial sync completed")
+		slog.Info("Starting initial full sync")
+		ran, err := searchService.ScheduledSync(context.Background())
+		switch {
+		case err != nil:
+			slog.Error("Initial sync failed", "error", err)
+		case !ran:
+			slog.Info("Initial sync skipped; another replica leads", "leader", searchService.LeaderStatus().Leader)
+		default:
+			slog.Info("Initial sync completed")
 		}
 
 		// Start periodic sync
 		searchService.StartPeriodicSync()
-		log.Println(" Periodic sync started")
+		slog.Info("Periodic sync started")
+	}
+
+	// Initialize search analytics
+	var analyticsService *services.AnalyticsService
+	var analyticsHandler *handlers.AnalyticsHandler
+	if cfg.Analytics.Enabled && esClient != nil {
+		analyticsService = services.NewAnalyticsService(
+			esClient,
+			cfg.Analytics.Index,
+			cfg.Analytics.ClicksIndex,
+			cfg.Analytics.BufferSize,
+			cfg.Analytics.BatchSize,
+			cfg.Analytics.FlushInterval,
+		)
+		if err := analyticsService.Start(context.Background()); err != nil {
+			logging.Fatal("Failed to start search analytics", "error", err)
+		}
+		analyticsHandler = handlers.NewAnalyticsHandler(analyticsService)
+		slog.Info("Search analytics enabled", "index", cfg.Analytics.Index, "clicks_index", cfg.Analytics.ClicksIndex)
+
+		popularityService := services.NewPopularityService(
+			esClient,
+			cfg.Analytics.ClicksIndex,
+			cfg.Analytics.Index,
+			cfg.Analytics.PopularityWindow,
+			cfg.Analytics.PopularityInterval,
+		)
+		if elector != nil {
+			popularityService.SetLeaderElector(elector)
+		}
+		popularityService.Start()
+	}
+
+	// Initialize synonyms management
+	var synonymsHandler *handlers.SynonymsHandler
+	if esClient != nil && !esClient.SupportsSynonyms() {
+		slog.Warn("Synonyms management is disabled; the cluster has no synonyms API", "flavor", esClient.Flavor())
+	}
+	if esClient != nil && esClient.SupportsSynonyms() {
+		synonymService := services.NewSynonymService(esClient, cfg.Elasticsearch.SynonymVersionsIndex)
+		if err := synonymService.Start(context.Background()); err != nil {
+			logging.Fatal("Failed to start synonyms management", "error", err)
+		}
+		synonymsHandler = handlers.NewSynonymsHandler(synonymService)
+	}
+
+	// Index administration and consistency checks need Elasticsearch
+	var indexAdminHandler *handlers.IndexAdminHandler
+	var consistencyHandler *handlers.ConsistencyHandler
+	if esClient != nil {
+		indexAdminService := services.NewIndexAdminService(esClient, docServiceClient)
+		if savedSearchService != nil {
+			indexAdminService.SetSavedSearchIndex(cfg.SavedSearches.Index)
+		}
+		indexAdminHandler = handlers.NewIndexAdminHandler(indexAdminService)
+		consistencyHandler = handlers.NewConsistencyHandler(
+			services.NewConsistencyService(esClient, docServiceClient, searchService, cfg.Sync.BatchSize),
+		)
 	}
 
 	// Initialize handlers
-	searchHandler := handlers.NewSearchHandler(searchService)
+	routeHandlers := routes.Handlers{
+		Search:        handlers.NewSearchHandler(searchService, analyticsService),
+		Health:        handlers.NewHealthHandler(healthService),
+		Sync:          handlers.NewSyncHandler(searchService),
+		Config:        handlers.NewConfigHandler(liveConfig.Load),
+		IndexAdmin:    indexAdminHandler,
+		Consistency:   consistencyHandler,
+		Synonyms:      synonymsHandler,
+		Analytics:     analyticsHandler,
+		SavedSearches: savedSearchHandler,
+	}
+
+	// Initialize authentication
+	authMiddleware, err := newAuthMiddleware(cfg)
+	if err != nil {
+		logging.Fatal("Failed to initialize authentication", "error", err)
+	}
+	middleware := routes.Middleware{Auth: authMiddleware}
+
+	// Initialize rate limiting
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		defaultLimit, routeLimits, err := parseRateLimits(cfg)
+		if err != nil {
+			logging.Fatal("Failed to initialize rate limiting", "error", err)
+		}
+		limiter = ratelimit.NewLimiter(defaultLimit, routeLimits)
+		concurrency := ratelimit.NewConcurrencyLimiter(
+			cfg.RateLimit.MaxConcurrent,
+			cfg.RateLimit.ShedConcurrent,
+			cfg.RateLimit.LatencyThreshold,
+		)
+		if esClient != nil {
+			esClient.SetLatencyObserver(concurrency.ObserveLatency)
+		}
+		middleware.RateLimit = limiter.Middleware()
+		middleware.SearchConcurrency = concurrency.Middleware()
+		slog.Info("Rate limiting enabled",
+			"default", cfg.RateLimit.Default,
+			"routes", cfg.RateLimit.Routes,
+			"max_concurrent", cfg.RateLimit.MaxConcurrent,
+		)
+	}
 
 	// Setup Gin router
 	if os.Getenv("GIN_MODE") == "release" {
@@ -85,7 +350,7 @@ func main() {
 	}
 	
 	router := gin.New()
-	routes.SetupRoutes(router, searchHandler)
+	routes.SetupRoutes(router, routeHandlers, middleware)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -98,13 +363,24 @@ func main() {
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf(" Server starting on port %s", cfg.Server.Port)
-		log.Printf(" Elasticsearch: %s", cfg.Elasticsearch.URL)
-		log.Printf(" Document Service: %s", cfg.DocService.BaseURL)
-		log.Printf(" Sync enabled: %v", cfg.Sync.EnableSync)
-		
+		slog.Info("Server starting",
+			"port", cfg.Server.Port,
+			"elasticsearch", cfg.Elasticsearch.URLs,
+			"doc_service", cfg.DocService.BaseURL,
+			"sync_enabled", cfg.Sync.EnableSync,
+		)
+
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server:", err)
+			logging.Fatal("Failed to start server", "error", err)
+		}
+	}()
+
+	// Reload the log level, rate limits and ranking on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloadConfig(&liveConfig, args, limiter, esClient)
 		}
 	}()
 
@@ -113,7 +389,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	
-	log.Println(" Shutting down server...")
+	slog.Info("Shutting down server")
 
 	// Give outstanding requests a deadline for completion
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -121,8 +397,184 @@ func main() {
 
 	// Attempt graceful shutdown
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		logging.Fatal("Server forced to shutdown", "error", err)
 	}
 
-	log.Println(" Server exited")
-}
\ No newline at end of file
+	// Hand the sync leadership over
+	if elector != nil {
+		if err := elector.Stop(ctx); err != nil {
+			slog.Error("Failed to release sync leadership", "error", err)
+		}
+	}
+
+	// Write the search events still buffered
+	if analyticsService != nil {
+		analyticsService.Close()
+	}
+
+	// Flush pending saved search alerts
+	if savedSearchService != nil {
+		if err := savedSearchService.Close(); err != nil {
+			slog.Error("Failed to close saved search notifier", "error", err)
+		}
+	}
+
+	// Persist the last writes to the Bleve index
+	if bleveBackend != nil {
+		if err := bleveBackend.Close(); err != nil {
+			slog.Error("Failed to close Bleve index", "error", err)
+		}
+	}
+
+	// Flush any buffered spans
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to shut down tracing", "error", err)
+	}
+
+	slog.Info("Server exited")
+}
+
+// newAuthMiddleware builds the API authentication middleware from config.
+// When auth is disabled every caller is treated as an anonymous admin.
+func newAuthMiddleware(cfg *config.Config) (gin.HandlerFunc, error) {
+	if !cfg.Auth.Enabled {
+		slog.Warn("Authentication is disabled; the API is open to everyone")
+		return auth.Anonymous(auth.ScopeSearch, auth.ScopeAdmin), nil
+	}
+
+	var chain auth.Chain
+	if len(cfg.Auth.APIKeys) > 0 {
+		apiKeys, err := auth.NewAPIKeyAuthenticator(cfg.Auth.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, apiKeys)
+		slog.Info("API key authentication enabled", "keys", apiKeys.Len())
+	}
+	if cfg.Auth.JWTHMACSecret != "" || cfg.Auth.JWTJWKSFile != "" {
+		jwtAuth, err := auth.NewJWTAuthenticator(auth.JWTConfig{
+			HMACSecret: cfg.Auth.JWTHMACSecret,
+			JWKSFile:   cfg.Auth.JWTJWKSFile,
+			Issuer:     cfg.Auth.JWTIssuer,
+			Audience:   cfg.Auth.JWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, jwtAuth)
+		slog.Info("JWT authentication enabled", "jwks_file", cfg.Auth.JWTJWKSFile, "hmac", cfg.Auth.JWTHMACSecret != "")
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("AUTH_ENABLED is true but neither AUTH_API_KEYS nor AUTH_JWT_HMAC_SECRET/AUTH_JWT_JWKS_FILE is set")
+	}
+	return auth.Middleware(chain), nil
+}
+
+// newNotifier builds the saved search alert notifier from config.
+func newNotifier(cfg *config.Config) (notify.Notifier, error) {
+	switch cfg.SavedSearches.Notifier {
+	case "kafka":
+		if cfg.Kafka.Broker == "" {
+			return nil, fmt.Errorf("SAVED_SEARCHES_NOTIFIER is kafka but KAFKA_BROKER is not set")
+		}
+		return notify.NewKafka(cfg.Kafka.Broker, cfg.SavedSearches.Topic), nil
+	case "webhook":
+		if cfg.SavedSearches.WebhookURL == "" {
+			return nil, fmt.Errorf("SAVED_SEARCHES_NOTIFIER is webhook but SAVED_SEARCHES_WEBHOOK_URL is not set")
+		}
+		return notify.NewWebhook(cfg.SavedSearches.WebhookURL, cfg.SavedSearches.WebhookSecret, cfg.SavedSearches.WebhookTimeout), nil
+	case "log":
+		return notify.Log{}, nil
+	default:
+		return nil, fmt.Errorf("unknown saved search notifier %q", cfg.SavedSearches.Notifier)
+	}
+}
+
+// syncLeaseName names the lease of the scheduled sync leader
+const syncLeaseName = "wikidocify-search-sync"
+
+// newLeaderLock builds the leader election lock from config. esClient is
+// nil unless the search backend is Elasticsearch.
+func newLeaderLock(cfg *config.Config, esClient *elastic.Client) (leader.Lock, error) {
+	switch cfg.LeaderElection.Backend {
+	case "elasticsearch":
+		if esClient == nil {
+			return nil, fmt.Errorf("LEADER_ELECTION_BACKEND is elasticsearch but SEARCH_BACKEND is %s", cfg.SearchBackend)
+		}
+		if err := esClient.EnsureLeaseIndex(context.Background(), cfg.LeaderElection.Index); err != nil {
+			return nil, err
+		}
+		return leader.NewElasticsearch(esClient, cfg.LeaderElection.Index, syncLeaseName), nil
+	case "postgres":
+		return leader.NewPostgres(cfg.LeaderElection.PostgresURL, syncLeaseName), nil
+	default:
+		return nil, fmt.Errorf("unknown leader election backend %q", cfg.LeaderElection.Backend)
+	}
+}
+
+// parseRateLimits parses the default and per-route rate limits of config.
+func parseRateLimits(cfg *config.Config) (ratelimit.Limit, map[string]ratelimit.Limit, error) {
+	defaultLimit, err := ratelimit.ParseLimit(cfg.RateLimit.Default)
+	if err != nil {
+		return ratelimit.Limit{}, nil, err
+	}
+	routeLimits, err := ratelimit.ParseRoutes(cfg.RateLimit.Routes)
+	if err != nil {
+		return ratelimit.Limit{}, nil, err
+	}
+	return defaultLimit, routeLimits, nil
+}
+
+// newRanking builds the Elasticsearch ranking from config.
+func newRanking(cfg *config.Config) elastic.Ranking {
+	return elastic.Ranking{
+		DefaultMode:      cfg.Ranking.DefaultMode,
+		PopularityWeight: cfg.Ranking.PopularityWeight,
+		PopularityFactor: cfg.Ranking.PopularityFactor,
+		BoostRecent:      cfg.Ranking.BoostRecent,
+		RecencyWeight:    cfg.Ranking.RecencyWeight,
+		RecencyOrigin:    cfg.Ranking.RecencyOrigin,
+		RecencyScale:     cfg.Ranking.RecencyScale,
+		RecencyDecay:     cfg.Ranking.RecencyDecay,
+		BoostMode:        cfg.Ranking.BoostMode,
+	}
+}
+
+// reloadConfig loads the configuration again and applies the settings that
+// can change at runtime: the log level, the rate limits and the ranking. An
+// invalid configuration is logged and the current one kept. limiter and
+// esClient are nil when rate limiting or Elasticsearch aren't used.
+func reloadConfig(live *atomic.Pointer[config.Config], args []string, limiter *ratelimit.Limiter, esClient *elastic.Client) {
+	cfg, restart, err := config.Reload(live.Load(), args)
+	if err != nil {
+		slog.Error("Failed to reload config, keeping the current one", "error", err)
+		return
+	}
+
+	if err := logging.SetLevel(cfg.Log.Level); err != nil {
+		slog.Error("Failed to reload config, keeping the current one", "error", err)
+		return
+	}
+	if limiter != nil {
+		defaultLimit, routeLimits, err := parseRateLimits(cfg)
+		if err != nil {
+			slog.Error("Failed to reload rate limits", "error", err)
+		} else {
+			limiter.SetLimits(defaultLimit, routeLimits)
+		}
+	}
+	if esClient != nil {
+		esClient.SetRanking(newRanking(cfg))
+	}
+	live.Store(cfg)
+
+	slog.Info("Config reloaded",
+		"log_level", cfg.Log.Level,
+		"rate_limit_default", cfg.RateLimit.Default,
+		"rate_limit_routes", cfg.RateLimit.Routes,
+		"default_rank", cfg.Ranking.DefaultMode,
+	)
+	if len(restart) > 0 {
+		slog.Warn("Config changes that need a restart were ignored", "sections", restart)
+	}
+}
@@ -0,0 +1,81 @@
+// internal/auth/api_key.go
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header carrying a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator validates static API keys from the X-API-Key header or
+// an "Authorization: ApiKey <key>" header. Keys are kept only as SHA-256
+// digests.
+type APIKeyAuthenticator struct {
+	keys map[[sha256.Size]byte]*Identity
+}
+
+// NewAPIKeyAuthenticator parses key specs of the form
+// "name:key:scope1|scope2[:tenant]". The name becomes the identity subject so
+// the key itself is never logged. Keys with a tenant may only access it.
+func NewAPIKeyAuthenticator(specs []string) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{keys: make(map[[sha256.Size]byte]*Identity)}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.SplitN(spec, ":", 4)
+		if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid API key spec for %q: expected name:key:scopes[:tenant]", parts[0])
+		}
+		var tenant string
+		if len(parts) == 4 {
+			tenant = parts[3]
+		}
+		scopes := strings.Split(parts[2], "|")
+		for _, scope := range scopes {
+			if scope != ScopeSearch && scope != ScopeAdmin {
+				return nil, fmt.Errorf("API key %q has unknown scope %q", parts[0], scope)
+			}
+		}
+		digest := sha256.Sum256([]byte(parts[1]))
+		if _, exists := a.keys[digest]; exists {
+			return nil, fmt.Errorf("API key %q duplicates another key", parts[0])
+		}
+		a.keys[digest] = &Identity{
+			Subject: parts[0],
+			Method:  "api_key",
+			Scopes:  scopes,
+			Tenant:  tenant,
+		}
+	}
+	return a, nil
+}
+
+// Len returns the number of configured keys.
+func (a *APIKeyAuthenticator) Len() int {
+	return len(a.keys)
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		if value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+			key = strings.TrimSpace(value)
+		}
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	identity, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	copied := *identity
+	return &copied, nil
+}
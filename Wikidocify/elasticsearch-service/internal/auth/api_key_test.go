@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewAPIKeyAuthenticator(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		wantLen int
+		wantErr string
+	}{
+		{name: "search key", specs: []string{"frontend:k1:search"}, wantLen: 1},
+		{name: "several scopes and a tenant", specs: []string{"ops:k1:search|admin", "acme:k2:search:acme"}, wantLen: 2},
+		{name: "blank entries", specs: []string{" ", "", " frontend:k1:search "}, wantLen: 1},
+		{name: "missing scopes", specs: []string{"frontend:k1"}, wantErr: "expected name:key:scopes[:tenant]"},
+		{name: "empty key", specs: []string{"frontend::search"}, wantErr: "expected name:key:scopes[:tenant]"},
+		{name: "unknown scope", specs: []string{"frontend:k1:write"}, wantErr: `unknown scope "write"`},
+		{name: "duplicate key", specs: []string{"a:k1:search", "b:k1:admin"}, wantErr: `"b" duplicates another key`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAPIKeyAuthenticator(tt.specs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewAPIKeyAuthenticator: %v", err)
+			}
+			if a.Len() != tt.wantLen {
+				t.Errorf("expected %d keys, got %d", tt.wantLen, a.Len())
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	a, err := NewAPIKeyAuthenticator([]string{"ops:k1:search|admin", "acme:k2:search:acme"})
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuthenticator: %v", err)
+	}
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantErr     error
+		wantSubject string
+		wantScopes  []string
+		wantTenant  string
+	}{
+		{name: "X-API-Key", header: APIKeyHeader, value: "k1", wantSubject: "ops", wantScopes: []string{ScopeSearch, ScopeAdmin}},
+		{name: "Authorization ApiKey", header: "Authorization", value: "ApiKey k2", wantSubject: "acme", wantScopes: []string{ScopeSearch}, wantTenant: "acme"},
+		{name: "unknown key", header: APIKeyHeader, value: "k3", wantErr: ErrInvalidCredentials},
+		{name: "bearer token", header: "Authorization", value: "Bearer k1", wantErr: ErrNoCredentials},
+		{name: "no header", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			identity, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if identity.Subject != tt.wantSubject || identity.Method != "api_key" ||
+				!slices.Equal(identity.Scopes, tt.wantScopes) || identity.Tenant != tt.wantTenant {
+				t.Errorf("unexpected identity: %+v", identity)
+			}
+		})
+	}
+}
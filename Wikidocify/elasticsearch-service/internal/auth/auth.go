@@ -0,0 +1,71 @@
+// internal/auth/auth.go
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+// Scopes understood by the search service.
+const (
+	ScopeSearch = "search"
+	ScopeAdmin  = "admin"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request does
+	// not carry the kind of credentials it handles, so the next one is tried.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are present but rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity describes an authenticated caller.
+type Identity struct {
+	Subject string   `json:"subject"`
+	Method  string   `json:"method"` // "api_key", "jwt" or "anonymous"
+	Scopes  []string `json:"scopes"`
+	Groups  []string `json:"groups,omitempty"`
+	Tenant  string   `json:"tenant,omitempty"` // set when the credentials are bound to one tenant
+}
+
+// HasScope reports whether the identity was granted scope.
+func (i *Identity) HasScope(scope string) bool {
+	return i != nil && slices.Contains(i.Scopes, scope)
+}
+
+// Authenticator resolves the caller of an HTTP request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Chain tries each authenticator in order and returns the first identity.
+// Authenticators that find no credentials of their kind are skipped; any
+// other error stops the chain.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Identity, error) {
+	for _, a := range c {
+		identity, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return identity, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the caller identity stored in ctx, or nil.
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
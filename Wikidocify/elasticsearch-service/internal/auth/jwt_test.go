@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "hmac-secret"
+
+func signHMAC(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func signEC(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// writeJWKS writes the public keys by kid to a JWKS file
+func writeJWKS(t *testing.T, keys map[string]*ecdsa.PrivateKey) string {
+	t.Helper()
+	coordinate := func(n interface{ FillBytes([]byte) []byte }) string {
+		return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32)))
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jsonWebKey{Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256", X: coordinate(key.X), Y: coordinate(key.Y)})
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub": "alice",
+		"iss": "https://idp.example.com",
+		"aud": "wikidocify",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func authenticateBearer(a *JWTAuthenticator, token string) (*Identity, error) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return a.Authenticate(r)
+}
+
+func TestJWTHMAC(t *testing.T) {
+	a, err := NewJWTAuthenticator(JWTConfig{HMACSecret: testSecret, Issuer: "https://idp.example.com", Audience: "wikidocify"})
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+	wrongSecret, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil)).SignedString([]byte("other"))
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantErr    error
+		wantScopes []string
+		wantGroups []string
+		wantTenant string
+	}{
+		{name: "scope claim", token: signHMAC(t, claims(jwt.MapClaims{"scope": "search admin", "groups": []string{"eng"}})),
+			wantScopes: []string{ScopeSearch, ScopeAdmin}, wantGroups: []string{"eng"}},
+		{name: "scopes array and tenant", token: signHMAC(t, claims(jwt.MapClaims{"scopes": []string{"search"}, "tenant_id": "acme"})),
+			wantScopes: []string{ScopeSearch}, wantTenant: "acme"},
+		{name: "no token", wantErr: ErrNoCredentials},
+		{name: "expired", token: signHMAC(t, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), wantErr: ErrInvalidCredentials},
+		{name: "no expiry", token: signHMAC(t, claims(jwt.MapClaims{"exp": nil})), wantErr: ErrInvalidCredentials},
+		{name: "wrong issuer", token: signHMAC(t, claims(jwt.MapClaims{"iss": "https://evil.example.com"})), wantErr: ErrInvalidCredentials},
+		{name: "wrong audience", token: signHMAC(t, claims(jwt.MapClaims{"aud": "billing"})), wantErr: ErrInvalidCredentials},
+		{name: "no subject", token: signHMAC(t, claims(jwt.MapClaims{"sub": nil})), wantErr: ErrInvalidCredentials},
+		{name: "wrong secret", token: wrongSecret, wantErr: ErrInvalidCredentials},
+		{name: "unsigned", token: unsigned, wantErr: ErrInvalidCredentials},
+		{name: "EC token without keys", token: signEC(t, newECKey(t), "", claims(nil)), wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := authenticateBearer(a, tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if identity.Subject != "alice" || identity.Method != "jwt" || !slices.Equal(identity.Scopes, tt.wantScopes) ||
+				!slices.Equal(identity.Groups, tt.wantGroups) || identity.Tenant != tt.wantTenant {
+				t.Errorf("unexpected identity: %+v", identity)
+			}
+		})
+	}
+}
+
+func TestJWTJWKS(t *testing.T) {
+	first, second, unknown := newECKey(t), newECKey(t), newECKey(t)
+	single, err := NewJWTAuthenticator(JWTConfig{JWKSFile: writeJWKS(t, map[string]*ecdsa.PrivateKey{"k1": first})})
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+	several, err := NewJWTAuthenticator(JWTConfig{JWKSFile: writeJWKS(t, map[string]*ecdsa.PrivateKey{"k1": first, "k2": second})})
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authenticator *JWTAuthenticator
+		token         string
+		wantErr       bool
+	}{
+		{"kid", several, signEC(t, second, "k2", claims(nil)), false},
+		{"no kid with a single key", single, signEC(t, first, "", claims(nil)), false},
+		{"no kid with several keys", several, signEC(t, first, "", claims(nil)), true},
+		{"unknown kid", several, signEC(t, unknown, "k3", claims(nil)), true},
+		{"kid of another key", several, signEC(t, unknown, "k1", claims(nil)), true},
+		{"HMAC token", single, signHMAC(t, claims(nil)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := authenticateBearer(tt.authenticator, tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("expected invalid credentials, got %v", err)
+				}
+				return
+			}
+			if err != nil || identity.Subject != "alice" {
+				t.Fatalf("expected alice, got %+v, %v", identity, err)
+			}
+		})
+	}
+}
+
+func TestNewJWTAuthenticatorErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name string
+		cfg  JWTConfig
+	}{
+		{"no keys", JWTConfig{Issuer: "https://idp.example.com"}},
+		{"missing file", JWTConfig{JWKSFile: filepath.Join(dir, "missing.json")}},
+		{"not JSON", JWTConfig{JWKSFile: write("invalid.json", "keys")}},
+		{"encryption keys only", JWTConfig{JWKSFile: write("enc.json", `{"keys": [{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`)}},
+		{"unsupported curve", JWTConfig{JWKSFile: write("curve.json", `{"keys": [{"kty": "EC", "crv": "P-192", "x": "AA", "y": "AA"}]}`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewJWTAuthenticator(tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
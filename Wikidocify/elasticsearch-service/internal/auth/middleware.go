@@ -0,0 +1,69 @@
+// internal/auth/middleware.go
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware authenticates every request with authenticator and stores the
+// caller identity in the request context. Requests without valid credentials
+// are rejected with 401.
+func Middleware(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			if !errors.Is(err, ErrNoCredentials) {
+				slog.WarnContext(c.Request.Context(), "Authentication failed",
+					"route", c.FullPath(), "error", err)
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="wikidocify-search"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Authentication required",
+			})
+			return
+		}
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// Anonymous grants every request the given scopes without checking
+// credentials. It is used when authentication is disabled.
+func Anonymous(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := &Identity{Subject: "anonymous", Method: "anonymous", Scopes: scopes}
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+// RequireAllTenants rejects requests whose credentials are bound to one
+// tenant with 403. It guards settings that every tenant shares.
+func RequireAllTenants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identity := IdentityFromContext(c.Request.Context()); identity == nil || identity.Tenant != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Credentials bound to a tenant cannot change settings shared by every tenant",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose identity lacks scope with 403.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IdentityFromContext(c.Request.Context()).HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Missing required scope: " + scope,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve runs a request through handlers with identity in its context and
+// returns the response status
+func serve(identity *Identity, handlers ...gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if identity != nil {
+			c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		}
+	})
+	router.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code
+}
+
+func TestRequireAllTenants(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *Identity
+		want     int
+	}{
+		{"unbound", &Identity{Subject: "ops", Scopes: []string{ScopeAdmin}}, http.StatusOK},
+		{"bound to a tenant", &Identity{Subject: "acme-admin", Scopes: []string{ScopeAdmin}, Tenant: "acme"}, http.StatusForbidden},
+		{"no identity", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.identity, RequireAllTenants()); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := NewAPIKeyAuthenticator([]string{"frontend:k1:search"})
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuthenticator: %v", err)
+	}
+	jwts, err := NewJWTAuthenticator(JWTConfig{HMACSecret: testSecret})
+	if err != nil {
+		t.Fatalf("NewJWTAuthenticator: %v", err)
+	}
+	chain := Chain{keys, jwts}
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		want        int
+		wantSubject string
+	}{
+		{name: "API key", header: APIKeyHeader, value: "k1", want: http.StatusOK, wantSubject: "frontend"},
+		{name: "bearer token", header: "Authorization", value: "Bearer " + signHMAC(t, claims(nil)), want: http.StatusOK, wantSubject: "alice"},
+		{name: "invalid API key", header: APIKeyHeader, value: "k2", want: http.StatusUnauthorized},
+		{name: "invalid bearer token", header: "Authorization", value: "Bearer not-a-token", want: http.StatusUnauthorized},
+		{name: "no credentials", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject string
+			router := gin.New()
+			router.GET("/", Middleware(chain), func(c *gin.Context) {
+				subject = IdentityFromContext(c.Request.Context()).Subject
+				c.Status(http.StatusOK)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.want || subject != tt.wantSubject {
+				t.Fatalf("expected %d for %q, got %d for %q", tt.want, tt.wantSubject, w.Code, subject)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *Identity
+		before   []gin.HandlerFunc
+		scope    string
+		want     int
+	}{
+		{"granted", &Identity{Subject: "frontend", Scopes: []string{ScopeSearch}}, nil, ScopeSearch, http.StatusOK},
+		{"one of several", &Identity{Subject: "ops", Scopes: []string{ScopeSearch, ScopeAdmin}}, nil, ScopeAdmin, http.StatusOK},
+		{"missing scope", &Identity{Subject: "frontend", Scopes: []string{ScopeSearch}}, nil, ScopeAdmin, http.StatusForbidden},
+		{"no scopes", &Identity{Subject: "alice"}, nil, ScopeSearch, http.StatusForbidden},
+		{"no identity", nil, nil, ScopeSearch, http.StatusForbidden},
+		{"anonymous", nil, []gin.HandlerFunc{Anonymous(ScopeSearch, ScopeAdmin)}, ScopeAdmin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.identity, append(tt.before, RequireScope(tt.scope))...); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// internal/backend/backend.go
+package backend
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// ErrNoIdentity is returned by Search when the context carries no caller
+// identity. Searching without one would have no ACL to apply, so it fails
+// closed instead.
+var ErrNoIdentity = errors.New("search requires an authenticated identity")
+
+// ErrNoTenant is returned by Search when the request names no tenant.
+var ErrNoTenant = errors.New("search requires a tenant")
+
+// SearchBackend stores the searchable documents. elastic.Client is the
+// production implementation; memory.Backend keeps documents in process for
+// tests and local development.
+//
+// Every implementation must restrict Search results to req.TenantID and to
+// the documents the identity in ctx may read (see Readable), returning
+// ErrNoIdentity or ErrNoTenant instead of searching without them.
+type SearchBackend interface {
+	// Name identifies the backend in health reports
+	Name() string
+
+	// IndexDocument adds or replaces a document. Documents without a tenant
+	// belong to the default tenant; fields the document doesn't carry, such
+	// as the popularity score, are kept.
+	IndexDocument(ctx context.Context, doc *models.SearchDocument) error
+	// BulkIndex indexes several documents like IndexDocument
+	BulkIndex(ctx context.Context, docs []*models.SearchDocument) error
+	DeleteDocument(ctx context.Context, tenantID string, id uint32) error
+	Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResult, error)
+
+	// HealthCheck reports whether the backend is reachable and IndexExists
+	// whether the default tenant's index exists
+	HealthCheck(ctx context.Context) error
+	IndexExists(ctx context.Context) error
+
+	// Generation returns a value that changes after every write by any
+	// replica sharing the index, so anything derived from search results is
+	// stale once it has moved on
+	Generation(ctx context.Context) (uint64, error)
+}
+
+// Readable reports whether identity may read doc:
+//   - public documents
+//   - documents the caller owns or is listed in allowed_users
+//   - team documents shared with one of the caller's groups
+//
+// The anonymous identity used when auth is disabled only reads public
+// documents. The Elasticsearch ACL filter implements the same rules.
+func Readable(identity *auth.Identity, doc *models.SearchDocument) bool {
+	if doc.Visibility == models.VisibilityPublic {
+		return true
+	}
+	if identity.Method != "anonymous" && identity.Subject != "" {
+		if doc.Owner == identity.Subject || slices.Contains(doc.AllowedUsers, identity.Subject) {
+			return true
+		}
+	}
+	if doc.Visibility == models.VisibilityTeam {
+		for _, group := range identity.Groups {
+			if slices.Contains(doc.AllowedGroups, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
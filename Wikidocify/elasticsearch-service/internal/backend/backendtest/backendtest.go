@@ -0,0 +1,235 @@
+// internal/backend/backendtest/backendtest.go
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// Run runs the search behavior tests every backend.SearchBackend must pass.
+// newBackend returns an empty backend; it is called once per test.
+func Run(t *testing.T, newBackend func(t *testing.T) backend.SearchBackend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b backend.SearchBackend)
+	}{
+		{"RanksTitleMatchesFirst", testRanksTitleMatchesFirst},
+		{"FiltersAndPaginates", testFiltersAndPaginates},
+		{"AppliesACL", testAppliesACL},
+		{"RequiresIdentityAndTenant", testRequiresIdentityAndTenant},
+		{"ReplacesAndDeletes", testReplacesAndDeletes},
+		{"FiltersByLanguage", testFiltersByLanguage},
+		{"Highlights", testHighlights},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+var alice = &auth.Identity{Subject: "alice", Method: "jwt", Groups: []string{"platform"}}
+
+func index(t *testing.T, b backend.SearchBackend, docs ...*models.SearchDocument) {
+	t.Helper()
+	if err := b.BulkIndex(context.Background(), docs); err != nil {
+		t.Fatalf("BulkIndex: %v", err)
+	}
+}
+
+func search(t *testing.T, b backend.SearchBackend, identity *auth.Identity, req *models.SearchRequest) ([]models.SearchDocument, int64) {
+	t.Helper()
+	if req.TenantID == "" {
+		req.TenantID = "default"
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+	result, err := b.Search(auth.WithIdentity(context.Background(), identity), req)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	return result.Documents, result.Total
+}
+
+func ids(docs []models.SearchDocument) []uint32 {
+	out := []uint32{}
+	for _, doc := range docs {
+		out = append(out, doc.ID)
+	}
+	return out
+}
+
+func equalIDs(docs []models.SearchDocument, want ...uint32) bool {
+	got := ids(docs)
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func public(id uint32, title, content string) *models.SearchDocument {
+	return &models.SearchDocument{ID: id, Title: title, Content: content, Visibility: models.VisibilityPublic}
+}
+
+func testRanksTitleMatchesFirst(t *testing.T, b backend.SearchBackend) {
+	index(t, b,
+		public(1, "Meeting notes", "We discussed the deployment roadmap for the platform."),
+		public(2, "Deployment roadmap", "Steps for the next release of the platform."),
+		public(3, "Lunch menu", "Pasta and salad are served in the canteen today."),
+	)
+
+	docs, total := search(t, b, alice, &models.SearchRequest{Query: "Roadmap"})
+	if total != 2 || !equalIDs(docs, 2, 1) {
+		t.Fatalf("expected documents 2 then 1, got %v (total %d)", ids(docs), total)
+	}
+	if docs[0].Score <= docs[1].Score {
+		t.Fatalf("expected the title match to score higher: %v", docs)
+	}
+
+	docs, _ = search(t, b, alice, &models.SearchRequest{Query: "roadmap", Type: "content"})
+	if !equalIDs(docs, 1) {
+		t.Fatalf("content search returned %v, want [1]", ids(docs))
+	}
+	docs, _ = search(t, b, alice, &models.SearchRequest{Query: "roadmap", Type: "title"})
+	if !equalIDs(docs, 2) {
+		t.Fatalf("title search returned %v, want [2]", ids(docs))
+	}
+}
+
+func testFiltersAndPaginates(t *testing.T, b backend.SearchBackend) {
+	for i := uint32(1); i <= 5; i++ {
+		doc := public(i, "Runbook", "What to do when you are on call.")
+		doc.Author = "bob"
+		if i%2 == 0 {
+			doc.Author = "carol"
+		}
+		index(t, b, doc)
+	}
+	other := public(6, "Runbook", "What to do when you are on call.")
+	other.TenantID = "acme"
+	index(t, b, other)
+
+	docs, total := search(t, b, alice, &models.SearchRequest{Query: "runbook", Author: "bob", Limit: 2, Offset: 1})
+	if total != 3 {
+		t.Fatalf("expected 3 documents by bob in the default tenant, got %d", total)
+	}
+	if !equalIDs(docs, 3, 5) {
+		t.Fatalf("second page returned %v, want [3 5]", ids(docs))
+	}
+
+	docs, _ = search(t, b, alice, &models.SearchRequest{Query: "", TenantID: "acme"})
+	if !equalIDs(docs, 6) {
+		t.Fatalf("empty query in tenant acme returned %v, want [6]", ids(docs))
+	}
+}
+
+func testAppliesACL(t *testing.T, b backend.SearchBackend) {
+	index(t, b,
+		&models.SearchDocument{ID: 1, Title: "Plan", Owner: "alice", Visibility: models.VisibilityPrivate},
+		&models.SearchDocument{ID: 2, Title: "Plan", Owner: "bob", Visibility: models.VisibilityPrivate},
+		&models.SearchDocument{ID: 3, Title: "Plan", Owner: "bob", Visibility: models.VisibilityPrivate, AllowedUsers: []string{"alice"}},
+		&models.SearchDocument{ID: 4, Title: "Plan", Owner: "bob", Visibility: models.VisibilityTeam, AllowedGroups: []string{"platform"}},
+		&models.SearchDocument{ID: 5, Title: "Plan", Owner: "bob", Visibility: models.VisibilityTeam, AllowedGroups: []string{"sales"}},
+		&models.SearchDocument{ID: 6, Title: "Plan", Owner: "bob", Visibility: models.VisibilityPublic},
+	)
+
+	docs, _ := search(t, b, alice, &models.SearchRequest{Query: "plan"})
+	if !equalIDs(docs, 1, 3, 4, 6) {
+		t.Fatalf("alice can read %v, want [1 3 4 6]", ids(docs))
+	}
+
+	anonymous := &auth.Identity{Subject: "alice", Method: "anonymous"}
+	docs, _ = search(t, b, anonymous, &models.SearchRequest{Query: "plan"})
+	if !equalIDs(docs, 6) {
+		t.Fatalf("anonymous caller can read %v, want [6]", ids(docs))
+	}
+}
+
+func testRequiresIdentityAndTenant(t *testing.T, b backend.SearchBackend) {
+	if _, err := b.Search(context.Background(), &models.SearchRequest{Query: "x", TenantID: "default"}); !errors.Is(err, backend.ErrNoIdentity) {
+		t.Fatalf("expected ErrNoIdentity, got %v", err)
+	}
+	ctx := auth.WithIdentity(context.Background(), alice)
+	if _, err := b.Search(ctx, &models.SearchRequest{Query: "x"}); !errors.Is(err, backend.ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+}
+
+func testReplacesAndDeletes(t *testing.T, b backend.SearchBackend) {
+	ctx := context.Background()
+	index(t, b, public(1, "Old title", ""))
+
+	generation, err := b.Generation(ctx)
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+	if err := b.IndexDocument(ctx, public(1, "New title", "")); err != nil {
+		t.Fatalf("IndexDocument: %v", err)
+	}
+	if next, err := b.Generation(ctx); err != nil || next == generation {
+		t.Fatal("expected indexing to change the generation")
+	}
+
+	if docs, _ := search(t, b, alice, &models.SearchRequest{Query: "old"}); len(docs) != 0 {
+		t.Fatalf("replaced title still matches: %v", docs)
+	}
+	docs, _ := search(t, b, alice, &models.SearchRequest{Query: "new"})
+	if len(docs) != 1 || docs[0].Title != "New title" || docs[0].TenantID != "default" || docs[0].Language == "" {
+		t.Fatalf("expected the new document in the default tenant with a language, got %+v", docs)
+	}
+
+	if err := b.DeleteDocument(ctx, "default", 1); err != nil {
+		t.Fatalf("DeleteDocument: %v", err)
+	}
+	if docs, _ := search(t, b, alice, &models.SearchRequest{Query: "new"}); len(docs) != 0 {
+		t.Fatalf("deleted document still matches: %v", docs)
+	}
+}
+
+func testFiltersByLanguage(t *testing.T, b backend.SearchBackend) {
+	index(t, b,
+		public(1, "Release plan", "The release of the new version is planned for next week with many changes."),
+		public(2, "Release Plan", "Die Veröffentlichung der neuen Version ist für die nächste Woche geplant."),
+	)
+	docs, _ := search(t, b, alice, &models.SearchRequest{Query: "release", Lang: "de"})
+	if !equalIDs(docs, 2) {
+		t.Fatalf("German search returned %v, want [2]", ids(docs))
+	}
+	docs, _ = search(t, b, alice, &models.SearchRequest{Query: "release"})
+	if len(docs) != 2 {
+		t.Fatalf("search in every language returned %v, want both documents", ids(docs))
+	}
+}
+
+func testHighlights(t *testing.T, b backend.SearchBackend) {
+	index(t, b, public(1, "Deployment roadmap", "The roadmap lists every deployment planned for this year."))
+
+	docs, _ := search(t, b, alice, &models.SearchRequest{Query: "roadmap"})
+	if len(docs) != 1 || docs[0].Highlights != nil {
+		t.Fatalf("expected no highlights unless asked for, got %+v", docs)
+	}
+
+	docs, _ = search(t, b, alice, &models.SearchRequest{Query: "roadmap", Highlight: true})
+	if len(docs) != 1 {
+		t.Fatalf("expected one hit, got %v", ids(docs))
+	}
+	marked := models.HighlightPreTag + "roadmap" + models.HighlightPostTag
+	for _, field := range []string{"title", "content"} {
+		fragments := docs[0].Highlights[field]
+		if len(fragments) == 0 || !strings.Contains(strings.ToLower(fragments[0]), marked) {
+			t.Fatalf("expected %s fragments marking the match, got %v", field, docs[0].Highlights)
+		}
+	}
+}
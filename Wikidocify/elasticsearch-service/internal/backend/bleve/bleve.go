@@ -0,0 +1,396 @@
+// internal/backend/bleve/bleve.go
+package bleve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/language"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ar"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// titleBoost weighs title matches like the title^2 field of the
+// Elasticsearch query
+const titleBoost = 2
+
+// sourceField stores the indexed models.SearchDocument as JSON, like the
+// Elasticsearch _source
+const sourceField = "source"
+
+// analyzers are the bleve analyzers of the supported languages
+var analyzers = map[string]string{
+	language.English: en.AnalyzerName,
+	language.German:  de.AnalyzerName,
+	language.Arabic:  ar.AnalyzerName,
+}
+
+// keywordFields are matched exactly, like the keyword fields of the
+// Elasticsearch mapping
+var keywordFields = []string{
+	"tenant_id", "author", "language",
+	"owner", "visibility", "allowed_users", "allowed_groups",
+}
+
+// Backend keeps documents in an on-disk Bleve index, so the service runs as
+// a single binary. Every tenant shares the index; searches are filtered by
+// tenant and ACL like with Elasticsearch. Ranking modes and recency boosts
+// are not supported and searches always rank by relevance.
+type Backend struct {
+	index bleve.Index
+
+	mu         sync.Mutex // serializes writes, which read the popularity first
+	generation atomic.Uint64
+}
+
+var _ backend.SearchBackend = (*Backend)(nil)
+
+// Open opens the index at path, creating it if it doesn't exist
+func Open(path string) (*Backend, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, indexMapping())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bleve index %s: %w", path, err)
+	}
+	return &Backend{index: index}, nil
+}
+
+// indexMapping mirrors the Elasticsearch mapping: keyword fields for
+// filters, and title and content analyzed in the document's language under
+// title.<lang> and content.<lang>. Only the sub-field of the document's
+// language is indexed; it is stored with term vectors for highlighting.
+func indexMapping() mapping.IndexMapping {
+	doc := bleve.NewDocumentStaticMapping()
+	for _, field := range keywordFields {
+		fm := bleve.NewKeywordFieldMapping()
+		fm.IncludeInAll = false
+		doc.AddFieldMappingsAt(field, fm)
+	}
+
+	id := bleve.NewNumericFieldMapping()
+	id.IncludeInAll = false
+	doc.AddFieldMappingsAt("id", id)
+
+	for _, field := range []string{"title", "content"} {
+		byLanguage := bleve.NewDocumentStaticMapping()
+		for _, lang := range language.Supported {
+			fm := bleve.NewTextFieldMapping()
+			fm.Analyzer = analyzers[lang]
+			fm.Store = true
+			fm.IncludeTermVectors = true
+			fm.IncludeInAll = false
+			byLanguage.AddFieldMappingsAt(lang, fm)
+		}
+		doc.AddSubDocumentMapping(field, byLanguage)
+	}
+
+	source := bleve.NewTextFieldMapping()
+	source.Index = false
+	source.Store = true
+	source.IncludeInAll = false
+	source.DocValues = false
+	doc.AddFieldMappingsAt(sourceField, source)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.IndexDynamic = false
+	m.StoreDynamic = false
+	m.DocValuesDynamic = false
+	return m
+}
+
+func (b *Backend) Name() string {
+	return "bleve"
+}
+
+// Generation counts the writes; only this process writes to the index
+func (b *Backend) Generation(context.Context) (uint64, error) {
+	return b.generation.Load(), nil
+}
+
+// Close closes the index
+func (b *Backend) Close() error {
+	return b.index.Close()
+}
+
+// docID is the index ID of a tenant's document
+func docID(tenantID string, id uint32) string {
+	return fmt.Sprintf("%s/%d", tenantID, id)
+}
+
+// IndexDocument adds or replaces doc, keeping the popularity of the document
+// it replaces. Documents without a tenant belong to the default tenant.
+func (b *Backend) IndexDocument(ctx context.Context, doc *models.SearchDocument) error {
+	return b.BulkIndex(ctx, []*models.SearchDocument{doc})
+}
+
+// BulkIndex indexes docs like IndexDocument in one batch
+func (b *Backend) BulkIndex(ctx context.Context, docs []*models.SearchDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.generation.Add(1)
+
+	batch := b.index.NewBatch()
+	for _, doc := range docs {
+		if doc.TenantID == "" {
+			doc.TenantID = tenant.Default
+		}
+		if doc.Language == "" {
+			doc.Language = language.Detect(doc.Title + "\n" + doc.Content)
+		}
+		id := docID(doc.TenantID, doc.ID)
+		previous, err := b.stored(id)
+		if err != nil {
+			return err
+		}
+		fields, err := indexedFields(doc, previous)
+		if err != nil {
+			return err
+		}
+		if err := batch.Index(id, fields); err != nil {
+			return fmt.Errorf("failed to index document %d: %w", doc.ID, err)
+		}
+	}
+	if err := b.index.Batch(batch); err != nil {
+		return fmt.Errorf("failed to index documents: %w", err)
+	}
+	return nil
+}
+
+// stored returns the stored document with index ID id, or nil
+func (b *Backend) stored(id string) (*models.SearchDocument, error) {
+	doc, err := b.index.Document(id)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	var source []byte
+	doc.VisitFields(func(field index.Field) {
+		if field.Name() == sourceField {
+			source = field.Value()
+		}
+	})
+	if source == nil {
+		return nil, nil
+	}
+	var stored models.SearchDocument
+	if err := json.Unmarshal(source, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// indexedFields is the object indexed for doc. The popularity belongs to the
+// popularity job and is carried over from previous.
+func indexedFields(doc *models.SearchDocument, previous *models.SearchDocument) (map[string]interface{}, error) {
+	source := *doc
+	source.Popularity = 0
+	if previous != nil {
+		source.Popularity = previous.Popularity
+	}
+	source.Score = 0
+	source.Explanation = nil
+	source.Highlights = nil
+	sourceJSON, err := json.Marshal(source)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":             float64(doc.ID),
+		"tenant_id":      doc.TenantID,
+		"author":         doc.Author,
+		"language":       doc.Language,
+		"owner":          doc.Owner,
+		"visibility":     doc.Visibility,
+		"allowed_users":  doc.AllowedUsers,
+		"allowed_groups": doc.AllowedGroups,
+		"title":          map[string]interface{}{doc.Language: doc.Title},
+		"content":        map[string]interface{}{doc.Language: doc.Content},
+		sourceField:      string(sourceJSON),
+	}, nil
+}
+
+func (b *Backend) DeleteDocument(ctx context.Context, tenantID string, id uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.generation.Add(1)
+	if err := b.index.Delete(docID(tenantID, id)); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// Search runs req against the index. Results are always restricted to
+// req.TenantID and to the documents readable by the identity in ctx.
+func (b *Backend) Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResult, error) {
+	identity := auth.IdentityFromContext(ctx)
+	if identity == nil {
+		return nil, backend.ErrNoIdentity
+	}
+	if req.TenantID == "" {
+		return nil, backend.ErrNoTenant
+	}
+
+	filters := []query.Query{
+		term("tenant_id", req.TenantID),
+		aclQuery(identity),
+	}
+	if req.Author != "" {
+		filters = append(filters, term("author", req.Author))
+	}
+	if req.Lang != "" {
+		filters = append(filters, term("language", req.Lang))
+	}
+
+	var match query.Query = bleve.NewMatchAllQuery()
+	if strings.TrimSpace(req.Query) != "" {
+		langs := language.Supported
+		if req.Lang != "" {
+			langs = []string{req.Lang}
+		}
+		match = textQuery(req.Query, req.Type, langs)
+	}
+
+	size := req.Limit
+	if size <= 0 {
+		count, err := b.index.DocCount()
+		if err != nil {
+			return nil, err
+		}
+		size = int(count)
+	}
+	searchReq := bleve.NewSearchRequestOptions(
+		bleve.NewConjunctionQuery(append([]query.Query{match}, filters...)...),
+		size, max(req.Offset, 0), req.Explain,
+	)
+	searchReq.Fields = []string{sourceField}
+	searchReq.SortBy([]string{"-_score", "id"})
+	if req.Highlight {
+		searchReq.Highlight = bleve.NewHighlight()
+	}
+
+	result, err := b.index.SearchInContext(ctx, searchReq)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	docs := make([]models.SearchDocument, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		source, _ := hit.Fields[sourceField].(string)
+		var doc models.SearchDocument
+		if err := json.Unmarshal([]byte(source), &doc); err != nil {
+			return nil, fmt.Errorf("invalid stored document %s: %w", hit.ID, err)
+		}
+		doc.Score = hit.Score
+		if hit.Expl != nil {
+			doc.Explanation = explanation(hit.Expl)
+		}
+		if len(hit.Fragments) > 0 {
+			doc.Highlights = highlights(hit.Fragments)
+		}
+		docs = append(docs, doc)
+	}
+	return &models.SearchResult{Documents: docs, Total: int64(result.Total), Took: result.Took}, nil
+}
+
+// textQuery matches text in the fields of searchType, in the sub-fields of
+// each language in langs
+func textQuery(text, searchType string, langs []string) query.Query {
+	var clauses []query.Query
+	for _, lang := range langs {
+		if searchType != "content" {
+			title := bleve.NewMatchQuery(text)
+			title.SetField("title." + lang)
+			title.SetBoost(titleBoost)
+			clauses = append(clauses, title)
+		}
+		if searchType != "title" {
+			content := bleve.NewMatchQuery(text)
+			content.SetField("content." + lang)
+			clauses = append(clauses, content)
+		}
+	}
+	return bleve.NewDisjunctionQuery(clauses...)
+}
+
+// aclQuery matches only the documents identity may read, following the
+// rules of backend.Readable
+func aclQuery(identity *auth.Identity) query.Query {
+	readable := []query.Query{term("visibility", models.VisibilityPublic)}
+	if identity.Method != "anonymous" && identity.Subject != "" {
+		readable = append(readable,
+			term("owner", identity.Subject),
+			term("allowed_users", identity.Subject),
+		)
+	}
+	if len(identity.Groups) > 0 {
+		groups := make([]query.Query, 0, len(identity.Groups))
+		for _, group := range identity.Groups {
+			groups = append(groups, term("allowed_groups", group))
+		}
+		readable = append(readable, bleve.NewConjunctionQuery(
+			term("visibility", models.VisibilityTeam),
+			bleve.NewDisjunctionQuery(groups...),
+		))
+	}
+	return bleve.NewDisjunctionQuery(readable...)
+}
+
+// term is a filter clause matching value exactly. Bleve has no filter
+// context, so its boost is 0 to leave scoring to the text query.
+func term(field, value string) *query.TermQuery {
+	q := bleve.NewTermQuery(value)
+	q.SetField(field)
+	q.SetBoost(0)
+	return q
+}
+
+// highlights merges the fragments of the language sub-fields ("title.en")
+// into their parent field
+func highlights(fragments search.FieldFragmentMap) map[string][]string {
+	result := map[string][]string{}
+	for field, list := range fragments {
+		field, _, _ = strings.Cut(field, ".")
+		result[field] = append(result[field], list...)
+	}
+	return result
+}
+
+func explanation(expl *search.Explanation) *models.ScoreExplanation {
+	e := &models.ScoreExplanation{Value: expl.Value, Description: expl.Message}
+	for _, child := range expl.Children {
+		e.Details = append(e.Details, *explanation(child))
+	}
+	return e
+}
+
+// HealthCheck reports whether the index can be read
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	_, err := b.index.DocCount()
+	return err
+}
+
+// IndexExists always succeeds; the index is created when opened
+func (b *Backend) IndexExists(ctx context.Context) error {
+	return nil
+}
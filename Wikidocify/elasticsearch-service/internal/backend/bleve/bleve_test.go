@@ -0,0 +1,52 @@
+package bleve
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/backend/backendtest"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func open(t *testing.T, path string) *Backend {
+	t.Helper()
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return b
+}
+
+func TestBackend(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) backend.SearchBackend {
+		b := open(t, filepath.Join(t.TempDir(), "search.bleve"))
+		t.Cleanup(func() { b.Close() })
+		return b
+	})
+}
+
+func TestIndexSurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.bleve")
+	b := open(t, path)
+	doc := &models.SearchDocument{ID: 7, Title: "Onboarding guide", Author: "bob", Visibility: models.VisibilityPublic}
+	if err := b.IndexDocument(context.Background(), doc); err != nil {
+		t.Fatalf("IndexDocument: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b = open(t, path)
+	defer b.Close()
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+	result, err := b.Search(ctx, &models.SearchRequest{Query: "onboarding", TenantID: "default", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if docs := result.Documents; result.Total != 1 || docs[0].ID != 7 || docs[0].Author != "bob" {
+		t.Fatalf("expected document 7 after reopening, got %+v", docs)
+	}
+}
@@ -0,0 +1,324 @@
+// internal/backend/memory/memory.go
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/language"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
+)
+
+// titleBoost weighs title matches like the title^2 field of the
+// Elasticsearch query
+const titleBoost = 2
+
+// Highlighted fragments are about fragmentRunes long and start up to
+// fragmentLead runes before their first match; at most maxFragments are
+// returned per field
+const (
+	fragmentRunes = 100
+	fragmentLead  = 20
+	maxFragments  = 3
+)
+
+// Backend keeps documents in memory. Text is lowercased and split on
+// anything but letters and digits, without stemming or synonyms, and hits
+// are scored with a plain tf-idf sum. It is meant for tests and local
+// development, not for production data.
+type Backend struct {
+	mu   sync.RWMutex
+	docs map[string]map[uint32]*models.SearchDocument // tenant -> id -> doc
+
+	generation atomic.Uint64
+}
+
+var _ backend.SearchBackend = (*Backend)(nil)
+
+// New returns an empty in-memory backend
+func New() *Backend {
+	return &Backend{docs: map[string]map[uint32]*models.SearchDocument{}}
+}
+
+func (b *Backend) Name() string {
+	return "memory"
+}
+
+// Generation counts the writes; only this process writes to the index
+func (b *Backend) Generation(context.Context) (uint64, error) {
+	return b.generation.Load(), nil
+}
+
+// IndexDocument stores a copy of doc, keeping the popularity of the
+// document it replaces
+func (b *Backend) IndexDocument(ctx context.Context, doc *models.SearchDocument) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.put(doc)
+	b.generation.Add(1)
+	return nil
+}
+
+// BulkIndex stores copies of docs like IndexDocument
+func (b *Backend) BulkIndex(ctx context.Context, docs []*models.SearchDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, doc := range docs {
+		b.put(doc)
+	}
+	b.generation.Add(1)
+	return nil
+}
+
+func (b *Backend) put(doc *models.SearchDocument) {
+	if doc.TenantID == "" {
+		doc.TenantID = tenant.Default
+	}
+	if doc.Language == "" {
+		doc.Language = language.Detect(doc.Title + "\n" + doc.Content)
+	}
+
+	stored := copyDocument(doc)
+	stored.Popularity = 0
+	stored.Score = 0
+	stored.Explanation = nil
+
+	docs := b.docs[doc.TenantID]
+	if docs == nil {
+		docs = map[uint32]*models.SearchDocument{}
+		b.docs[doc.TenantID] = docs
+	}
+	if previous, ok := docs[doc.ID]; ok {
+		stored.Popularity = previous.Popularity
+	}
+	docs[doc.ID] = stored
+}
+
+func (b *Backend) DeleteDocument(ctx context.Context, tenantID string, id uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.docs[tenantID], id)
+	b.generation.Add(1)
+	return nil
+}
+
+// Search returns the documents of req.TenantID readable by the identity in
+// ctx that match req, best first. Every query term is optional; a document
+// matching more terms, or rarer ones, scores higher. An empty query matches
+// every document with a score of 0.
+func (b *Backend) Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResult, error) {
+	identity := auth.IdentityFromContext(ctx)
+	if identity == nil {
+		return nil, backend.ErrNoIdentity
+	}
+	if req.TenantID == "" {
+		return nil, backend.ErrNoTenant
+	}
+	started := time.Now()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var candidates []*models.SearchDocument
+	for _, doc := range b.docs[req.TenantID] {
+		if !backend.Readable(identity, doc) {
+			continue
+		}
+		if req.Author != "" && doc.Author != req.Author {
+			continue
+		}
+		if req.Lang != "" && doc.Language != req.Lang {
+			continue
+		}
+		candidates = append(candidates, doc)
+	}
+
+	terms := tokenize(req.Query)
+	var hits []models.SearchDocument
+	if len(terms) == 0 {
+		for _, doc := range candidates {
+			hits = append(hits, *copyDocument(doc))
+		}
+	} else {
+		hits = score(candidates, terms, req.Type)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	total := int64(len(hits))
+	start := min(max(req.Offset, 0), len(hits))
+	end := len(hits)
+	if req.Limit > 0 {
+		end = min(start+req.Limit, len(hits))
+	}
+	hits = hits[start:end]
+
+	if req.Highlight && len(terms) > 0 {
+		for i := range hits {
+			hits[i].Highlights = highlights(&hits[i], terms, req.Type)
+		}
+	}
+	return &models.SearchResult{Documents: hits, Total: total, Took: time.Since(started)}, nil
+}
+
+// highlights returns the fragments of the searched fields matching terms
+func highlights(doc *models.SearchDocument, terms []string, searchType string) map[string][]string {
+	matched := map[string]bool{}
+	for _, t := range terms {
+		matched[t] = true
+	}
+	result := map[string][]string{}
+	if searchType != "content" {
+		if fragments := highlight(doc.Title, matched); len(fragments) > 0 {
+			result["title"] = fragments
+		}
+	}
+	if searchType != "title" {
+		if fragments := highlight(doc.Content, matched); len(fragments) > 0 {
+			result["content"] = fragments
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// highlight cuts text into fragments around the tokens in matched, with
+// those tokens wrapped in the highlight tags
+func highlight(text string, matched map[string]bool) []string {
+	runes := []rune(text)
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(runes); {
+		if !isTokenRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isTokenRune(runes[j]) {
+			j++
+		}
+		if matched[strings.ToLower(string(runes[i:j]))] {
+			spans = append(spans, span{i, j})
+		}
+		i = j
+	}
+
+	var fragments []string
+	for i := 0; i < len(spans) && len(fragments) < maxFragments; {
+		start := max(spans[i].start-fragmentLead, 0)
+		end := min(max(start+fragmentRunes, spans[i].end), len(runes))
+
+		var b strings.Builder
+		pos := start
+		for ; i < len(spans) && spans[i].end <= end; i++ {
+			b.WriteString(string(runes[pos:spans[i].start]))
+			b.WriteString(models.HighlightPreTag)
+			b.WriteString(string(runes[spans[i].start:spans[i].end]))
+			b.WriteString(models.HighlightPostTag)
+			pos = spans[i].end
+		}
+		b.WriteString(string(runes[pos:end]))
+		fragments = append(fragments, strings.TrimSpace(b.String()))
+	}
+	return fragments
+}
+
+// score returns copies of the candidates matching at least one term, with
+// their tf-idf score. Document frequencies are counted among candidates.
+func score(candidates []*models.SearchDocument, terms []string, searchType string) []models.SearchDocument {
+	type fields struct {
+		title, content map[string]int
+	}
+	counted := make([]fields, len(candidates))
+	df := map[string]int{}
+	for i, doc := range candidates {
+		if searchType != "content" {
+			counted[i].title = termCounts(doc.Title)
+		}
+		if searchType != "title" {
+			counted[i].content = termCounts(doc.Content)
+		}
+		for _, t := range terms {
+			if counted[i].title[t] > 0 || counted[i].content[t] > 0 {
+				df[t]++
+			}
+		}
+	}
+
+	var hits []models.SearchDocument
+	n := float64(len(candidates))
+	for i, doc := range candidates {
+		total := 0.0
+		for _, t := range terms {
+			tf := float64(titleBoost*counted[i].title[t] + counted[i].content[t])
+			if tf == 0 {
+				continue
+			}
+			idf := 1 + math.Log(n/float64(df[t]))
+			total += math.Sqrt(tf) * idf
+		}
+		if total == 0 {
+			continue
+		}
+		hit := copyDocument(doc)
+		hit.Score = total
+		hits = append(hits, *hit)
+	}
+	return hits
+}
+
+func termCounts(text string) map[string]int {
+	counts := map[string]int{}
+	for _, t := range tokenize(text) {
+		counts[t]++
+	}
+	return counts
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isTokenRune(r)
+	})
+}
+
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// HealthCheck always succeeds
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// IndexExists always succeeds; tenants need no index
+func (b *Backend) IndexExists(ctx context.Context) error {
+	return nil
+}
+
+// copyDocument copies doc so callers can't modify stored documents
+func copyDocument(doc *models.SearchDocument) *models.SearchDocument {
+	c := *doc
+	c.AllowedUsers = append([]string(nil), doc.AllowedUsers...)
+	c.AllowedGroups = append([]string(nil), doc.AllowedGroups...)
+	return &c
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/backend/backendtest"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestBackend(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T) backend.SearchBackend {
+		return New()
+	})
+}
+
+func TestIndexDocumentKeepsPopularity(t *testing.T) {
+	b := New()
+	ctx := context.Background()
+	doc := &models.SearchDocument{ID: 1, Title: "Roadmap", Visibility: models.VisibilityPublic}
+	if err := b.IndexDocument(ctx, doc); err != nil {
+		t.Fatalf("IndexDocument: %v", err)
+	}
+	b.docs["default"][1].Popularity = 0.5
+
+	if err := b.IndexDocument(ctx, &models.SearchDocument{ID: 1, Title: "Roadmap v2", Visibility: models.VisibilityPublic}); err != nil {
+		t.Fatalf("IndexDocument: %v", err)
+	}
+	ctx = auth.WithIdentity(ctx, &auth.Identity{Subject: "alice", Method: "jwt"})
+	result, err := b.Search(ctx, &models.SearchRequest{Query: "roadmap", TenantID: "default"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Documents) != 1 || result.Documents[0].Popularity != 0.5 {
+		t.Fatalf("expected the popularity to be kept, got %+v", result.Documents)
+	}
+}
+
+func TestHighlightCutsFragments(t *testing.T) {
+	text := "Alpha " + strings.Repeat("filler ", 40) + "alpha end"
+	fragments := highlight(text, map[string]bool{"alpha": true})
+	if len(fragments) != 2 {
+		t.Fatalf("expected a fragment per distant match, got %q", fragments)
+	}
+	if !strings.HasPrefix(fragments[0], models.HighlightPreTag+"Alpha"+models.HighlightPostTag) {
+		t.Fatalf("expected the first fragment to start with the match, got %q", fragments[0])
+	}
+}
@@ -0,0 +1,18 @@
+// internal/cache/cache.go
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores encoded search results. Implementations must be safe for
+// concurrent use. External caches (Redis, memcached, ...) implement the same
+// interface; a failed Get is reported as a miss and a failed Set is ignored,
+// so a cache outage only costs performance.
+type Cache interface {
+	// Get returns the value stored under key, if present and not expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores value under key for at most ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
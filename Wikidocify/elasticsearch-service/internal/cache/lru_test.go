@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	if _, ok := c.Get(ctx, "a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok := c.Get(ctx, "b"); ok {
+		t.Error("expected b, the least recently used, to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(ctx, key); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+	c.Set(ctx, "short", []byte("1"), time.Millisecond)
+	c.Set(ctx, "long", []byte("2"), time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get(ctx, "short"); ok {
+		t.Error("expected the expired entry to be a miss")
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected the expired entry to be removed, got %d entries", c.Len())
+	}
+	if value, ok := c.Get(ctx, "long"); !ok || string(value) != "2" {
+		t.Errorf("expected the live entry, got %q, %v", value, ok)
+	}
+
+	// Setting again renews the TTL
+	c.Set(ctx, "short", []byte("3"), time.Minute)
+	if value, ok := c.Get(ctx, "short"); !ok || string(value) != "3" {
+		t.Errorf("expected the renewed entry, got %q, %v", value, ok)
+	}
+}
+
+func TestLRUKeepsKeysApart(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(100)
+	for i := range 10 {
+		c.Set(ctx, fmt.Sprint("key-", i), []byte(fmt.Sprint(i)), time.Minute)
+	}
+	c.Set(ctx, "key-3", []byte("replaced"), time.Minute)
+
+	for i := range 10 {
+		want := fmt.Sprint(i)
+		if i == 3 {
+			want = "replaced"
+		}
+		if value, ok := c.Get(ctx, fmt.Sprint("key-", i)); !ok || string(value) != want {
+			t.Errorf("key-%d: got %q, %v, want %q", i, value, ok, want)
+		}
+	}
+	if _, ok := c.Get(ctx, "key-"); ok {
+		t.Error("expected a key that was never set to miss")
+	}
+	if c.Len() != 10 {
+		t.Errorf("expected replacing a key to keep one entry, got %d", c.Len())
+	}
+}
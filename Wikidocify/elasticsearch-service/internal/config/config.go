@@ -2,102 +2,615 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the configuration of the search service. The json/yaml keys
+// are those of the configuration file and of the /api/v1/admin/config view.
 type Config struct {
 	Server struct {
-		Port         string        `json:"port"`
-		ReadTimeout  time.Duration `json:"read_timeout"`
-		WriteTimeout time.Duration `json:"write_timeout"`
-		IdleTimeout  time.Duration `json:"idle_timeout"`
-	} `json:"server"`
+		Port         string        `json:"port" yaml:"port"`
+		ReadTimeout  time.Duration `json:"read_timeout" yaml:"read_timeout"`
+		WriteTimeout time.Duration `json:"write_timeout" yaml:"write_timeout"`
+		IdleTimeout  time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	} `json:"server" yaml:"server"`
+
+	// SearchBackend is "elasticsearch", "bleve" (an on-disk index, for
+	// single-binary deployments) or "memory" (documents kept in process, for
+	// tests and local development without Elasticsearch)
+	SearchBackend string `json:"search_backend" yaml:"search_backend"`
+
+	Bleve struct {
+		Path string `json:"path" yaml:"path"` // directory of the index, created if missing
+	} `json:"bleve" yaml:"bleve"`
 
 	Elasticsearch struct {
-		URL      string `json:"url"`
-		Username string `json:"username"`
-		Password string `json:"password"`
-		Index    string `json:"index"`
-	} `json:"elasticsearch"`
+		// URLs are the cluster nodes; CloudID, if set, replaces them
+		URLs     []string `json:"urls" yaml:"urls"`
+		CloudID  string   `json:"cloud_id" yaml:"cloud_id"`
+		Username string   `json:"username" yaml:"username"`
+		Password string   `json:"password" yaml:"password"`
+		APIKey   string   `json:"api_key" yaml:"api_key"`
+		// CACert and the client certificate and key are PEM file paths;
+		// CAFingerprint is the SHA-256 fingerprint of the server certificate
+		// or of its CA, trusted instead of CACert and the system CAs
+		CACert        string `json:"ca_cert" yaml:"ca_cert"`
+		CAFingerprint string `json:"ca_fingerprint" yaml:"ca_fingerprint"`
+		ClientCert    string `json:"client_cert" yaml:"client_cert"`
+		ClientKey     string `json:"client_key" yaml:"client_key"`
+		// Requests answered with one of RetryOnStatus are retried up to
+		// MaxRetries times with exponential backoff starting at RetryBackoff
+		RetryOnStatus []int         `json:"retry_on_status" yaml:"retry_on_status"`
+		MaxRetries    int           `json:"max_retries" yaml:"max_retries"`
+		RetryBackoff  time.Duration `json:"retry_backoff" yaml:"retry_backoff"`
+		Compress      bool          `json:"compress" yaml:"compress"`
+		// ConnectTimeout is how long startup waits for the cluster
+		ConnectTimeout time.Duration `json:"connect_timeout" yaml:"connect_timeout"`
+		Index          string        `json:"index" yaml:"index"`
+		// Flavor is "elasticsearch" or "opensearch"
+		Flavor string `json:"flavor" yaml:"flavor"`
+		// TenantRouting is "shared" (one index, filtered by tenant) or
+		// "index" (one index per tenant, named "<index>-<tenant>")
+		TenantRouting string `json:"tenant_routing" yaml:"tenant_routing"`
+		// SynonymVersionsIndex keeps every version of the synonym rules
+		SynonymVersionsIndex string `json:"synonym_versions_index" yaml:"synonym_versions_index"`
+	} `json:"elasticsearch" yaml:"elasticsearch"`
 
 	DocService struct {
-		BaseURL string        `json:"base_url"`
-		Timeout time.Duration `json:"timeout"`
-		APIKey  string        `json:"api_key"`
-	} `json:"doc_service"`
+		BaseURL string        `json:"base_url" yaml:"base_url"`
+		Timeout time.Duration `json:"timeout" yaml:"timeout"`
+		APIKey  string        `json:"api_key" yaml:"api_key"`
+	} `json:"doc_service" yaml:"doc_service"`
 
 	Sync struct {
-		BatchSize    int           `json:"batch_size"`
-		SyncInterval time.Duration `json:"sync_interval"`
-		EnableSync   bool          `json:"enable_sync"`
-	} `json:"sync"`
+		BatchSize    int           `json:"batch_size" yaml:"batch_size"`
+		SyncInterval time.Duration `json:"sync_interval" yaml:"sync_interval"`
+		EnableSync   bool          `json:"enable_sync" yaml:"enable_sync"`
+		// RunsIndex keeps the full sync runs and their checkpoints. A run
+		// that failed is resumed by the next one if it was checkpointed
+		// within ResumeMaxAge; 0 always starts over.
+		RunsIndex    string        `json:"runs_index" yaml:"runs_index"`
+		ResumeMaxAge time.Duration `json:"resume_max_age" yaml:"resume_max_age"`
+	} `json:"sync" yaml:"sync"`
+
+	// LeaderElection picks the replica that runs the scheduled syncs.
+	// Backend is "none" (every replica runs them), "elasticsearch" (a lease
+	// document in Index) or "postgres" (an advisory lock in the database at
+	// PostgresURL). A leader that stops renewing loses the lease after
+	// LeaseDuration.
+	LeaderElection struct {
+		Backend       string        `json:"backend" yaml:"backend"`
+		ReplicaID     string        `json:"replica_id" yaml:"replica_id"` // the hostname by default
+		LeaseDuration time.Duration `json:"lease_duration" yaml:"lease_duration"`
+		RenewInterval time.Duration `json:"renew_interval" yaml:"renew_interval"`
+		Index         string        `json:"index" yaml:"index"`
+		PostgresURL   string        `json:"postgres_url" yaml:"postgres_url"`
+	} `json:"leader_election" yaml:"leader_election"`
+
+	// Kafka carries the document events, consumed from Topic in consumer
+	// group GroupID, and the saved search alerts of the kafka notifier. The
+	// consumer only starts when Broker is set.
+	Kafka struct {
+		Broker  string `json:"broker" yaml:"broker"`
+		Topic   string `json:"topic" yaml:"topic"`
+		GroupID string `json:"group_id" yaml:"group_id"`
+	} `json:"kafka" yaml:"kafka"`
+
+	Auth struct {
+		Enabled       bool     `json:"enabled" yaml:"enabled"`
+		APIKeys       []string `json:"api_keys" yaml:"api_keys"` // "name:key:scope1|scope2[:tenant]"
+		JWTHMACSecret string   `json:"jwt_hmac_secret" yaml:"jwt_hmac_secret"`
+		JWTJWKSFile   string   `json:"jwt_jwks_file" yaml:"jwt_jwks_file"`
+		JWTIssuer     string   `json:"jwt_issuer" yaml:"jwt_issuer"`
+		JWTAudience   string   `json:"jwt_audience" yaml:"jwt_audience"`
+	} `json:"auth" yaml:"auth"`
+
+	RateLimit struct {
+		Enabled bool     `json:"enabled" yaml:"enabled"`
+		Default string   `json:"default" yaml:"default"` // "rate:burst" for routes not listed in Routes
+		Routes  []string `json:"routes" yaml:"routes"`   // "route=rate:burst", e.g. "/api/v1/search=20:40"
+		// Concurrent Elasticsearch-bound requests, reduced to ShedConcurrent
+		// while the average Elasticsearch latency is above LatencyThreshold
+		MaxConcurrent    int           `json:"max_concurrent" yaml:"max_concurrent"`
+		ShedConcurrent   int           `json:"shed_concurrent" yaml:"shed_concurrent"`
+		LatencyThreshold time.Duration `json:"latency_threshold" yaml:"latency_threshold"`
+	} `json:"rate_limit" yaml:"rate_limit"`
+
+	Cache struct {
+		Enabled    bool          `json:"enabled" yaml:"enabled"`
+		MaxEntries int           `json:"max_entries" yaml:"max_entries"`
+		TTL        time.Duration `json:"ttl" yaml:"ttl"`
+	} `json:"cache" yaml:"cache"`
+
+	Analytics struct {
+		Enabled       bool          `json:"enabled" yaml:"enabled"`
+		Index         string        `json:"index" yaml:"index"`
+		ClicksIndex   string        `json:"clicks_index" yaml:"clicks_index"`
+		BufferSize    int           `json:"buffer_size" yaml:"buffer_size"`
+		BatchSize     int           `json:"batch_size" yaml:"batch_size"`
+		FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+		// Popularity scores count the clicks over PopularityWindow and are
+		// refreshed every PopularityInterval
+		PopularityWindow   time.Duration `json:"popularity_window" yaml:"popularity_window"`
+		PopularityInterval time.Duration `json:"popularity_interval" yaml:"popularity_interval"`
+	} `json:"analytics" yaml:"analytics"`
+
+	Ranking struct {
+		DefaultMode      string  `json:"default_mode" yaml:"default_mode"` // "relevance" or "blended"
+		PopularityWeight float64 `json:"popularity_weight" yaml:"popularity_weight"`
+		PopularityFactor float64 `json:"popularity_factor" yaml:"popularity_factor"`
+		BoostRecent      bool    `json:"boost_recent" yaml:"boost_recent"` // recency decay outside blended mode
+		RecencyWeight    float64 `json:"recency_weight" yaml:"recency_weight"`
+		RecencyOrigin    string  `json:"recency_origin" yaml:"recency_origin"` // Elasticsearch date math, e.g. "now"
+		RecencyScale     string  `json:"recency_scale" yaml:"recency_scale"`   // Elasticsearch duration, e.g. "30d"
+		RecencyDecay     float64 `json:"recency_decay" yaml:"recency_decay"`
+		BoostMode        string  `json:"boost_mode" yaml:"boost_mode"`
+	} `json:"ranking" yaml:"ranking"`
+
+	SavedSearches struct {
+		Enabled     bool   `json:"enabled" yaml:"enabled"`
+		Index       string `json:"index" yaml:"index"`               // percolator index holding the saved searches
+		AlertsIndex string `json:"alerts_index" yaml:"alerts_index"` // alerts sent, for de-duplication
+		MaxPerOwner int    `json:"max_per_owner" yaml:"max_per_owner"`
+		// Notifier is "kafka" (publish to Topic on the Kafka broker),
+		// "webhook" (POST to WebhookURL) or "log"
+		Notifier       string        `json:"notifier" yaml:"notifier"`
+		Topic          string        `json:"topic" yaml:"topic"`
+		WebhookURL     string        `json:"webhook_url" yaml:"webhook_url"`
+		WebhookSecret  string        `json:"webhook_secret" yaml:"webhook_secret"` // signs webhook bodies with HMAC-SHA256
+		WebhookTimeout time.Duration `json:"webhook_timeout" yaml:"webhook_timeout"`
+	} `json:"saved_searches" yaml:"saved_searches"`
+
+	Health struct {
+		CacheTTL     time.Duration `json:"cache_ttl" yaml:"cache_ttl"`
+		CheckTimeout time.Duration `json:"check_timeout" yaml:"check_timeout"`
+	} `json:"health" yaml:"health"`
+
+	Log struct {
+		Level string `json:"level" yaml:"level"` // "debug", "info", "warn" or "error"
+	} `json:"log" yaml:"log"`
+
+	Tracing struct {
+		ServiceName string  `json:"service_name" yaml:"service_name"`
+		Exporter    string  `json:"exporter" yaml:"exporter"` // "none", "stdout" or "otlp"
+		SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+	} `json:"tracing" yaml:"tracing"`
+
+	// file is the configuration as the file left it, before the
+	// environment and flags, so Reload can tell which settings the file
+	// changed. It is nil without a file.
+	file *Config
 }
 
-func Load() (*Config, error) {
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the YAML file named by the -config flag or CONFIG_FILE, the
+// environment (including a .env file) and the command line flags args. The
+// result is validated, and the error lists every invalid setting.
+func Load(args []string) (*Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaults()
+	path := flags.configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		file := *cfg
+		cfg.file = &file
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment:\n%w", err)
+	}
+	flags.apply(cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// defaults returns the configuration used when nothing else is set
+func defaults() *Config {
 	cfg := &Config{}
 
 	// Server config
-	cfg.Server.Port = getEnv("PORT", "8080")
-	cfg.Server.ReadTimeout = getDurationEnv("READ_TIMEOUT", 10*time.Second)
-	cfg.Server.WriteTimeout = getDurationEnv("WRITE_TIMEOUT", 10*time.Second)
-	cfg.Server.IdleTimeout = getDurationEnv("IDLE_TIMEOUT", 60*time.Second)
+	cfg.Server.Port = "8080"
+	cfg.Server.ReadTimeout = 10 * time.Second
+	cfg.Server.WriteTimeout = 10 * time.Second
+	cfg.Server.IdleTimeout = 60 * time.Second
+
+	cfg.SearchBackend = "elasticsearch"
+	cfg.Bleve.Path = "data/search.bleve"
 
 	// Elasticsearch config
-	cfg.Elasticsearch.URL = getEnv("ELASTICSEARCH_URL", "http://localhost:9200")
-	cfg.Elasticsearch.Username = getEnv("ELASTICSEARCH_USERNAME", "")
-	cfg.Elasticsearch.Password = getEnv("ELASTICSEARCH_PASSWORD", "")
-	cfg.Elasticsearch.Index = getEnv("ELASTICSEARCH_INDEX", "wikidocify_documents")
+	cfg.Elasticsearch.URLs = []string{"http://localhost:9200"}
+	cfg.Elasticsearch.RetryOnStatus = []int{502, 503, 504, 429}
+	cfg.Elasticsearch.MaxRetries = 3
+	cfg.Elasticsearch.RetryBackoff = 100 * time.Millisecond
+	cfg.Elasticsearch.ConnectTimeout = 2 * time.Minute
+	cfg.Elasticsearch.Index = "wikidocify_documents"
+	cfg.Elasticsearch.Flavor = "elasticsearch"
+	cfg.Elasticsearch.TenantRouting = "shared"
+	cfg.Elasticsearch.SynonymVersionsIndex = "wikidocify_synonym_versions"
 
 	// Doc service config
-	cfg.DocService.BaseURL = getEnv("DOC_SERVICE_URL", "http://file-upload-service:8081")
-	cfg.DocService.Timeout = getDurationEnv("DOC_SERVICE_TIMEOUT", 30*time.Second)
-	cfg.DocService.APIKey = getEnv("DOC_SERVICE_API_KEY", "")
+	cfg.DocService.BaseURL = "http://file-upload-service:8081"
+	cfg.DocService.Timeout = 30 * time.Second
 
 	// Sync config
-	cfg.Sync.BatchSize = getIntEnv("SYNC_BATCH_SIZE", 100)
-	cfg.Sync.SyncInterval = getDurationEnv("SYNC_INTERVAL", 5*time.Minute)
-	cfg.Sync.EnableSync = getBoolEnv("ENABLE_SYNC", true)
+	cfg.Sync.BatchSize = 100
+	cfg.Sync.SyncInterval = 5 * time.Minute
+	cfg.Sync.EnableSync = true
+	cfg.Sync.RunsIndex = "wikidocify_sync_runs"
+	cfg.Sync.ResumeMaxAge = 24 * time.Hour
 
-	return cfg, nil
+	// Leader election config
+	cfg.LeaderElection.Backend = "none"
+	cfg.LeaderElection.ReplicaID, _ = os.Hostname()
+	cfg.LeaderElection.LeaseDuration = 30 * time.Second
+	cfg.LeaderElection.RenewInterval = 10 * time.Second
+	cfg.LeaderElection.Index = "wikidocify_leases"
+
+	// Kafka config
+	cfg.Kafka.Topic = "document-events"
+	cfg.Kafka.GroupID = "search-service-group"
+
+	// Auth config
+	cfg.Auth.Enabled = true
+
+	// Rate limit config
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Default = "5:10"
+	cfg.RateLimit.Routes = []string{"/api/v1/search=20:40", "/api/v1/sync/full=0.1:2"}
+	cfg.RateLimit.MaxConcurrent = 64
+	cfg.RateLimit.ShedConcurrent = 8
+	cfg.RateLimit.LatencyThreshold = 500 * time.Millisecond
+
+	// Search result cache config
+	cfg.Cache.Enabled = true
+	cfg.Cache.MaxEntries = 1000
+	cfg.Cache.TTL = time.Minute
+
+	// Search analytics config
+	cfg.Analytics.Enabled = true
+	cfg.Analytics.Index = "wikidocify_search_analytics"
+	cfg.Analytics.ClicksIndex = "wikidocify_search_clicks"
+	cfg.Analytics.BufferSize = 10000
+	cfg.Analytics.BatchSize = 500
+	cfg.Analytics.FlushInterval = 5 * time.Second
+	cfg.Analytics.PopularityWindow = 30 * 24 * time.Hour
+	cfg.Analytics.PopularityInterval = 10 * time.Minute
+
+	// Ranking config
+	cfg.Ranking.DefaultMode = "relevance"
+	cfg.Ranking.PopularityWeight = 1
+	cfg.Ranking.PopularityFactor = 1
+	cfg.Ranking.RecencyWeight = 1
+	cfg.Ranking.RecencyOrigin = "now"
+	cfg.Ranking.RecencyScale = "30d"
+	cfg.Ranking.RecencyDecay = 0.5
+	cfg.Ranking.BoostMode = "sum"
+
+	// Saved search config
+	cfg.SavedSearches.Enabled = true
+	cfg.SavedSearches.Index = "wikidocify_saved_searches"
+	cfg.SavedSearches.AlertsIndex = "wikidocify_saved_search_alerts"
+	cfg.SavedSearches.MaxPerOwner = 100
+	cfg.SavedSearches.Notifier = "kafka"
+	cfg.SavedSearches.Topic = "search.match"
+	cfg.SavedSearches.WebhookTimeout = 5 * time.Second
+
+	// Health check config
+	cfg.Health.CacheTTL = 5 * time.Second
+	cfg.Health.CheckTimeout = 2 * time.Second
+
+	// Logging config
+	cfg.Log.Level = "info"
+
+	// Tracing config
+	cfg.Tracing.ServiceName = "wikidocify-search-service"
+	cfg.Tracing.Exporter = "none"
+	cfg.Tracing.SampleRatio = 1.0
+
+	return cfg
+}
+
+// loadFile overrides cfg with the settings of a YAML file. Its keys are the
+// json keys of Config; unknown keys are rejected so typos don't go unnoticed.
+func (cfg *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with the environment variables that are set
+func (cfg *Config) loadEnv() error {
+	env := &envReader{}
+
+	// Server config
+	cfg.Server.Port = env.string("PORT", cfg.Server.Port)
+	cfg.Server.ReadTimeout = env.duration("READ_TIMEOUT", cfg.Server.ReadTimeout)
+	cfg.Server.WriteTimeout = env.duration("WRITE_TIMEOUT", cfg.Server.WriteTimeout)
+	cfg.Server.IdleTimeout = env.duration("IDLE_TIMEOUT", cfg.Server.IdleTimeout)
+
+	cfg.SearchBackend = env.string("SEARCH_BACKEND", cfg.SearchBackend)
+	cfg.Bleve.Path = env.string("BLEVE_PATH", cfg.Bleve.Path)
+
+	// Elasticsearch config
+	cfg.Elasticsearch.URLs = env.list("ELASTICSEARCH_URL", cfg.Elasticsearch.URLs)
+	cfg.Elasticsearch.CloudID = env.string("ELASTICSEARCH_CLOUD_ID", cfg.Elasticsearch.CloudID)
+	cfg.Elasticsearch.Username = env.string("ELASTICSEARCH_USERNAME", cfg.Elasticsearch.Username)
+	cfg.Elasticsearch.Password = env.string("ELASTICSEARCH_PASSWORD", cfg.Elasticsearch.Password)
+	cfg.Elasticsearch.APIKey = env.string("ELASTICSEARCH_API_KEY", cfg.Elasticsearch.APIKey)
+	cfg.Elasticsearch.CACert = env.string("ELASTICSEARCH_CA_CERT", cfg.Elasticsearch.CACert)
+	cfg.Elasticsearch.CAFingerprint = env.string("ELASTICSEARCH_CA_FINGERPRINT", cfg.Elasticsearch.CAFingerprint)
+	cfg.Elasticsearch.ClientCert = env.string("ELASTICSEARCH_CLIENT_CERT", cfg.Elasticsearch.ClientCert)
+	cfg.Elasticsearch.ClientKey = env.string("ELASTICSEARCH_CLIENT_KEY", cfg.Elasticsearch.ClientKey)
+	cfg.Elasticsearch.RetryOnStatus = env.intList("ELASTICSEARCH_RETRY_ON_STATUS", cfg.Elasticsearch.RetryOnStatus)
+	cfg.Elasticsearch.MaxRetries = env.int("ELASTICSEARCH_MAX_RETRIES", cfg.Elasticsearch.MaxRetries)
+	cfg.Elasticsearch.RetryBackoff = env.duration("ELASTICSEARCH_RETRY_BACKOFF", cfg.Elasticsearch.RetryBackoff)
+	cfg.Elasticsearch.Compress = env.bool("ELASTICSEARCH_COMPRESS", cfg.Elasticsearch.Compress)
+	cfg.Elasticsearch.ConnectTimeout = env.duration("ELASTICSEARCH_CONNECT_TIMEOUT", cfg.Elasticsearch.ConnectTimeout)
+	cfg.Elasticsearch.Index = env.string("ELASTICSEARCH_INDEX", cfg.Elasticsearch.Index)
+	cfg.Elasticsearch.Flavor = env.string("ELASTICSEARCH_FLAVOR", cfg.Elasticsearch.Flavor)
+	cfg.Elasticsearch.TenantRouting = env.string("TENANT_ROUTING", cfg.Elasticsearch.TenantRouting)
+	cfg.Elasticsearch.SynonymVersionsIndex = env.string("SYNONYM_VERSIONS_INDEX", cfg.Elasticsearch.SynonymVersionsIndex)
+
+	// Doc service config
+	cfg.DocService.BaseURL = env.string("DOC_SERVICE_URL", cfg.DocService.BaseURL)
+	cfg.DocService.Timeout = env.duration("DOC_SERVICE_TIMEOUT", cfg.DocService.Timeout)
+	cfg.DocService.APIKey = env.string("DOC_SERVICE_API_KEY", cfg.DocService.APIKey)
+
+	// Sync config
+	cfg.Sync.BatchSize = env.int("SYNC_BATCH_SIZE", cfg.Sync.BatchSize)
+	cfg.Sync.SyncInterval = env.duration("SYNC_INTERVAL", cfg.Sync.SyncInterval)
+	cfg.Sync.EnableSync = env.bool("ENABLE_SYNC", cfg.Sync.EnableSync)
+	cfg.Sync.RunsIndex = env.string("SYNC_RUNS_INDEX", cfg.Sync.RunsIndex)
+	cfg.Sync.ResumeMaxAge = env.duration("SYNC_RESUME_MAX_AGE", cfg.Sync.ResumeMaxAge)
+
+	// Leader election config
+	cfg.LeaderElection.Backend = env.string("LEADER_ELECTION_BACKEND", cfg.LeaderElection.Backend)
+	cfg.LeaderElection.ReplicaID = env.string("LEADER_ELECTION_REPLICA_ID", cfg.LeaderElection.ReplicaID)
+	cfg.LeaderElection.LeaseDuration = env.duration("LEADER_ELECTION_LEASE_DURATION", cfg.LeaderElection.LeaseDuration)
+	cfg.LeaderElection.RenewInterval = env.duration("LEADER_ELECTION_RENEW_INTERVAL", cfg.LeaderElection.RenewInterval)
+	cfg.LeaderElection.Index = env.string("LEADER_ELECTION_INDEX", cfg.LeaderElection.Index)
+	cfg.LeaderElection.PostgresURL = env.string("LEADER_ELECTION_POSTGRES_URL", cfg.LeaderElection.PostgresURL)
+
+	// Kafka config
+	cfg.Kafka.Broker = env.string("KAFKA_BROKER", cfg.Kafka.Broker)
+	cfg.Kafka.Topic = env.string("KAFKA_TOPIC", cfg.Kafka.Topic)
+	cfg.Kafka.GroupID = env.string("KAFKA_GROUP_ID", cfg.Kafka.GroupID)
+
+	// Auth config
+	cfg.Auth.Enabled = env.bool("AUTH_ENABLED", cfg.Auth.Enabled)
+	cfg.Auth.APIKeys = env.list("AUTH_API_KEYS", cfg.Auth.APIKeys)
+	cfg.Auth.JWTHMACSecret = env.string("AUTH_JWT_HMAC_SECRET", cfg.Auth.JWTHMACSecret)
+	cfg.Auth.JWTJWKSFile = env.string("AUTH_JWT_JWKS_FILE", cfg.Auth.JWTJWKSFile)
+	cfg.Auth.JWTIssuer = env.string("AUTH_JWT_ISSUER", cfg.Auth.JWTIssuer)
+	cfg.Auth.JWTAudience = env.string("AUTH_JWT_AUDIENCE", cfg.Auth.JWTAudience)
+
+	// Rate limit config
+	cfg.RateLimit.Enabled = env.bool("RATE_LIMIT_ENABLED", cfg.RateLimit.Enabled)
+	cfg.RateLimit.Default = env.string("RATE_LIMIT_DEFAULT", cfg.RateLimit.Default)
+	cfg.RateLimit.Routes = env.list("RATE_LIMIT_ROUTES", cfg.RateLimit.Routes)
+	cfg.RateLimit.MaxConcurrent = env.int("SEARCH_MAX_CONCURRENT", cfg.RateLimit.MaxConcurrent)
+	cfg.RateLimit.ShedConcurrent = env.int("SEARCH_SHED_CONCURRENT", cfg.RateLimit.ShedConcurrent)
+	cfg.RateLimit.LatencyThreshold = env.duration("SEARCH_LATENCY_THRESHOLD", cfg.RateLimit.LatencyThreshold)
+
+	// Search result cache config
+	cfg.Cache.Enabled = env.bool("SEARCH_CACHE_ENABLED", cfg.Cache.Enabled)
+	cfg.Cache.MaxEntries = env.int("SEARCH_CACHE_MAX_ENTRIES", cfg.Cache.MaxEntries)
+	cfg.Cache.TTL = env.duration("SEARCH_CACHE_TTL", cfg.Cache.TTL)
+
+	// Search analytics config
+	cfg.Analytics.Enabled = env.bool("ANALYTICS_ENABLED", cfg.Analytics.Enabled)
+	cfg.Analytics.Index = env.string("ANALYTICS_INDEX", cfg.Analytics.Index)
+	cfg.Analytics.BufferSize = env.int("ANALYTICS_BUFFER_SIZE", cfg.Analytics.BufferSize)
+	cfg.Analytics.BatchSize = env.int("ANALYTICS_BATCH_SIZE", cfg.Analytics.BatchSize)
+	cfg.Analytics.FlushInterval = env.duration("ANALYTICS_FLUSH_INTERVAL", cfg.Analytics.FlushInterval)
+	cfg.Analytics.ClicksIndex = env.string("ANALYTICS_CLICKS_INDEX", cfg.Analytics.ClicksIndex)
+	cfg.Analytics.PopularityWindow = env.duration("POPULARITY_WINDOW", cfg.Analytics.PopularityWindow)
+	cfg.Analytics.PopularityInterval = env.duration("POPULARITY_INTERVAL", cfg.Analytics.PopularityInterval)
+
+	// Ranking config
+	cfg.Ranking.DefaultMode = env.string("RANK_DEFAULT_MODE", cfg.Ranking.DefaultMode)
+	cfg.Ranking.PopularityWeight = env.float("RANK_POPULARITY_WEIGHT", cfg.Ranking.PopularityWeight)
+	cfg.Ranking.PopularityFactor = env.float("RANK_POPULARITY_FACTOR", cfg.Ranking.PopularityFactor)
+	cfg.Ranking.BoostRecent = env.bool("RANK_BOOST_RECENT", cfg.Ranking.BoostRecent)
+	cfg.Ranking.RecencyWeight = env.float("RANK_RECENCY_WEIGHT", cfg.Ranking.RecencyWeight)
+	cfg.Ranking.RecencyOrigin = env.string("RANK_RECENCY_ORIGIN", cfg.Ranking.RecencyOrigin)
+	cfg.Ranking.RecencyScale = env.string("RANK_RECENCY_SCALE", cfg.Ranking.RecencyScale)
+	cfg.Ranking.RecencyDecay = env.float("RANK_RECENCY_DECAY", cfg.Ranking.RecencyDecay)
+	cfg.Ranking.BoostMode = env.string("RANK_BOOST_MODE", cfg.Ranking.BoostMode)
+
+	// Saved search config
+	cfg.SavedSearches.Enabled = env.bool("SAVED_SEARCHES_ENABLED", cfg.SavedSearches.Enabled)
+	cfg.SavedSearches.Index = env.string("SAVED_SEARCHES_INDEX", cfg.SavedSearches.Index)
+	cfg.SavedSearches.AlertsIndex = env.string("SAVED_SEARCHES_ALERTS_INDEX", cfg.SavedSearches.AlertsIndex)
+	cfg.SavedSearches.MaxPerOwner = env.int("SAVED_SEARCHES_MAX_PER_OWNER", cfg.SavedSearches.MaxPerOwner)
+	cfg.SavedSearches.Notifier = env.string("SAVED_SEARCHES_NOTIFIER", cfg.SavedSearches.Notifier)
+	cfg.SavedSearches.Topic = env.string("SAVED_SEARCHES_TOPIC", cfg.SavedSearches.Topic)
+	cfg.SavedSearches.WebhookURL = env.string("SAVED_SEARCHES_WEBHOOK_URL", cfg.SavedSearches.WebhookURL)
+	cfg.SavedSearches.WebhookSecret = env.string("SAVED_SEARCHES_WEBHOOK_SECRET", cfg.SavedSearches.WebhookSecret)
+	cfg.SavedSearches.WebhookTimeout = env.duration("SAVED_SEARCHES_WEBHOOK_TIMEOUT", cfg.SavedSearches.WebhookTimeout)
+
+	// Health check config
+	cfg.Health.CacheTTL = env.duration("HEALTH_CACHE_TTL", cfg.Health.CacheTTL)
+	cfg.Health.CheckTimeout = env.duration("HEALTH_CHECK_TIMEOUT", cfg.Health.CheckTimeout)
+
+	// Logging config
+	cfg.Log.Level = env.string("LOG_LEVEL", cfg.Log.Level)
+
+	// Tracing config (OTLP endpoint is read from OTEL_EXPORTER_OTLP_ENDPOINT)
+	cfg.Tracing.ServiceName = env.string("OTEL_SERVICE_NAME", cfg.Tracing.ServiceName)
+	cfg.Tracing.Exporter = env.string("OTEL_TRACES_EXPORTER", cfg.Tracing.Exporter)
+	cfg.Tracing.SampleRatio = env.float("OTEL_TRACES_SAMPLE_RATIO", cfg.Tracing.SampleRatio)
+
+	return errors.Join(env.errs...)
+}
+
+// commandLine holds the flags, which override the most common settings
+type commandLine struct {
+	set        *flag.FlagSet
+	configFile string
+	port       string
+	backend    string
+	logLevel   string
+	enableSync bool
+}
+
+func parseFlags(args []string) (*commandLine, error) {
+	cl := &commandLine{set: flag.NewFlagSet("search-service", flag.ContinueOnError)}
+	cl.set.StringVar(&cl.configFile, "config", "", "YAML configuration file (default $CONFIG_FILE)")
+	cl.set.StringVar(&cl.port, "port", "", "HTTP port (overrides PORT)")
+	cl.set.StringVar(&cl.backend, "search-backend", "", "elasticsearch, bleve or memory (overrides SEARCH_BACKEND)")
+	cl.set.StringVar(&cl.logLevel, "log-level", "", "debug, info, warn or error (overrides LOG_LEVEL)")
+	cl.set.BoolVar(&cl.enableSync, "enable-sync", true, "sync with the document service (overrides ENABLE_SYNC)")
+	if err := cl.set.Parse(args); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// apply overrides cfg with the flags given on the command line
+func (cl *commandLine) apply(cfg *Config) {
+	cl.set.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = cl.port
+		case "search-backend":
+			cfg.SearchBackend = cl.backend
+		case "log-level":
+			cfg.Log.Level = cl.logLevel
+		case "enable-sync":
+			cfg.Sync.EnableSync = cl.enableSync
+		}
+	})
 }
 
-func getEnv(key, defaultValue string) string {
+// envReader reads typed environment variables, keeping the current value
+// when a variable is unset and collecting the values that don't parse
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) invalid(key, value string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, value, err))
+}
+
+func (e *envReader) string(key, current string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
-	return defaultValue
+	return current
 }
 
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+func (e *envReader) list(key string, current []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
 		}
 	}
-	return defaultValue
+	if len(values) == 0 {
+		return current
+	}
+	return values
 }
 
-func getBoolEnv(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+func (e *envReader) intList(key string, current []int) []int {
+	values := e.list(key, nil)
+	if len(values) == 0 {
+		return current
+	}
+	ints := make([]int, 0, len(values))
+	for _, value := range values {
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			e.invalid(key, os.Getenv(key), errors.New("expected a comma-separated list of integers"))
+			return current
 		}
+		ints = append(ints, intValue)
 	}
-	return defaultValue
+	return ints
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
+func (e *envReader) int(key string, current int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		e.invalid(key, value, errors.New("expected an integer"))
+		return current
+	}
+	return intValue
+}
+
+func (e *envReader) float(key string, current float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.invalid(key, value, errors.New("expected a number"))
+		return current
+	}
+	return floatValue
+}
+
+func (e *envReader) bool(key string, current bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
 	}
-	return defaultValue
-}
\ No newline at end of file
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		e.invalid(key, value, errors.New("expected true or false"))
+		return current
+	}
+	return boolValue
+}
+
+func (e *envReader) duration(key string, current time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return current
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		e.invalid(key, value, errors.New("expected a duration such as 30s or 5m"))
+		return current
+	}
+	return duration
+}
@@ -0,0 +1,142 @@
+// internal/config/reload.go
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces the value of secrets in Redacted
+const redacted = "REDACTED"
+
+// Redacted returns a copy of cfg that is safe to show: passwords, keys and
+// secrets are replaced by "REDACTED" when set, and credentials are removed
+// from URLs. API keys keep their name and scopes.
+func (cfg *Config) Redacted() *Config {
+	c := *cfg
+
+	c.Elasticsearch.URLs = make([]string, len(cfg.Elasticsearch.URLs))
+	for i, u := range cfg.Elasticsearch.URLs {
+		c.Elasticsearch.URLs[i] = redactURL(u)
+	}
+	c.Elasticsearch.Password = redactSecret(c.Elasticsearch.Password)
+	c.Elasticsearch.APIKey = redactSecret(c.Elasticsearch.APIKey)
+	c.DocService.APIKey = redactSecret(c.DocService.APIKey)
+	c.DocService.BaseURL = redactURL(c.DocService.BaseURL)
+	c.LeaderElection.PostgresURL = redactURL(c.LeaderElection.PostgresURL)
+
+	// "name:key:scopes[:tenant]"
+	c.Auth.APIKeys = make([]string, len(cfg.Auth.APIKeys))
+	for i, spec := range cfg.Auth.APIKeys {
+		parts := strings.Split(spec, ":")
+		if len(parts) > 1 {
+			parts[1] = redacted
+		}
+		c.Auth.APIKeys[i] = strings.Join(parts, ":")
+	}
+	c.Auth.JWTHMACSecret = redactSecret(c.Auth.JWTHMACSecret)
+
+	c.SavedSearches.WebhookURL = redactURL(c.SavedSearches.WebhookURL)
+	c.SavedSearches.WebhookSecret = redactSecret(c.SavedSearches.WebhookSecret)
+	return &c
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL removes the password and query string of a URL, which may carry
+// a token
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return redactSecret(value)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	return u.Redacted()
+}
+
+// Reload loads the configuration again, with the same args as at startup,
+// and returns current with the settings that can change at runtime (log
+// level, rate limits and ranking) taken from the new configuration. The
+// other settings need a restart; the sections in which they changed are
+// returned so the caller can warn about them.
+//
+// The environment and flags can't change in a running process, so a
+// reloadable setting the file changed takes the file's value even when an
+// environment variable or flag set it at startup; the others are kept.
+func Reload(current *Config, args []string) (*Config, []string, error) {
+	next, err := Load(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reloaded := *current
+	reloaded.file = next.file
+	settings := reloadableSettings(&reloaded)
+	for i, value := range reloadableSettings(current) {
+		if changedInFile(current.file, next.file, i) {
+			value = reloadableSettings(next.file)[i]
+		}
+		settings[i].Set(value)
+	}
+	if err := reloaded.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	// What's left of next once the reloadable settings are put back is
+	// what needs a restart
+	pinned := *next
+	for i, value := range reloadableSettings(current) {
+		reloadableSettings(&pinned)[i].Set(value)
+	}
+	return &reloaded, changedSections(current, &pinned), nil
+}
+
+// reloadableSettings returns the settings Reload applies, in the same
+// order for every cfg: the log and ranking settings and the rate limits
+func reloadableSettings(cfg *Config) []reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	var settings []reflect.Value
+	for _, section := range []string{"Log", "Ranking"} {
+		fields := v.FieldByName(section)
+		for i := 0; i < fields.NumField(); i++ {
+			settings = append(settings, fields.Field(i))
+		}
+	}
+	rateLimit := v.FieldByName("RateLimit")
+	return append(settings, rateLimit.FieldByName("Default"), rateLimit.FieldByName("Routes"))
+}
+
+// changedInFile reports whether the i-th reloadable setting differs
+// between two reads of the file
+func changedInFile(before, after *Config, i int) bool {
+	if before == nil || after == nil {
+		return false
+	}
+	a, b := reloadableSettings(before)[i], reloadableSettings(after)[i]
+	return !reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// changedSections returns the keys of the top-level sections that differ
+// between a and b
+func changedSections(a, b *Config) []string {
+	var changed []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		if !va.Type().Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, va.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	return changed
+}
@@ -0,0 +1,195 @@
+// internal/config/validate.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"wikidocify/elasticsearch-service/internal/ratelimit"
+)
+
+// maxSyncBatchSize is the largest page the doc service's versions listing
+// returns and the largest search size of the index, 10000
+const maxSyncBatchSize = 10000
+
+// Validate checks the settings the service can't start without, or would
+// misbehave with, and returns one error per invalid setting. Settings that
+// only matter to a disabled feature are not checked.
+func (cfg *Config) Validate() error {
+	v := &validator{}
+
+	port, err := strconv.Atoi(cfg.Server.Port)
+	v.check(err == nil && port > 0 && port < 65536, "server.port", "PORT", "must be a port number, got %q", cfg.Server.Port)
+	v.check(cfg.Server.ReadTimeout >= 0, "server.read_timeout", "READ_TIMEOUT", "must not be negative")
+	v.check(cfg.Server.WriteTimeout >= 0, "server.write_timeout", "WRITE_TIMEOUT", "must not be negative")
+	v.check(cfg.Server.IdleTimeout >= 0, "server.idle_timeout", "IDLE_TIMEOUT", "must not be negative")
+
+	switch cfg.SearchBackend {
+	case "elasticsearch":
+		cfg.validateElasticsearch(v)
+	case "bleve":
+		v.check(cfg.Bleve.Path != "", "bleve.path", "BLEVE_PATH", "is required with the bleve backend")
+	case "memory":
+	default:
+		v.fail("search_backend", "SEARCH_BACKEND", "must be elasticsearch, bleve or memory, got %q", cfg.SearchBackend)
+	}
+
+	v.checkURL(cfg.DocService.BaseURL, "doc_service.base_url", "DOC_SERVICE_URL")
+	v.check(cfg.DocService.Timeout > 0, "doc_service.timeout", "DOC_SERVICE_TIMEOUT", "must be positive")
+	v.check(cfg.Sync.BatchSize > 0 && cfg.Sync.BatchSize <= maxSyncBatchSize, "sync.batch_size", "SYNC_BATCH_SIZE",
+		"must be between 1 and %d, got %d", maxSyncBatchSize, cfg.Sync.BatchSize)
+	v.check(cfg.Sync.RunsIndex != "", "sync.runs_index", "SYNC_RUNS_INDEX", "must not be empty")
+	v.check(cfg.Sync.ResumeMaxAge >= 0, "sync.resume_max_age", "SYNC_RESUME_MAX_AGE", "must not be negative")
+	if cfg.Sync.EnableSync {
+		v.check(cfg.Sync.SyncInterval > 0, "sync.sync_interval", "SYNC_INTERVAL", "must be positive")
+		cfg.validateLeaderElection(v)
+	}
+
+	if cfg.Kafka.Broker != "" {
+		v.check(cfg.Kafka.Topic != "", "kafka.topic", "KAFKA_TOPIC", "is required with a broker")
+		v.check(cfg.Kafka.GroupID != "", "kafka.group_id", "KAFKA_GROUP_ID", "is required with a broker")
+	}
+
+	if cfg.Auth.Enabled {
+		v.check(len(cfg.Auth.APIKeys) > 0 || cfg.Auth.JWTHMACSecret != "" || cfg.Auth.JWTJWKSFile != "",
+			"auth.enabled", "AUTH_ENABLED", "is true but no API key, JWT HMAC secret or JWKS file is set")
+	}
+
+	if cfg.RateLimit.Enabled {
+		_, err := ratelimit.ParseLimit(cfg.RateLimit.Default)
+		v.check(err == nil, "rate_limit.default", "RATE_LIMIT_DEFAULT", "is invalid: %v", err)
+		_, err = ratelimit.ParseRoutes(cfg.RateLimit.Routes)
+		v.check(err == nil, "rate_limit.routes", "RATE_LIMIT_ROUTES", "is invalid: %v", err)
+		v.check(cfg.RateLimit.MaxConcurrent > 0, "rate_limit.max_concurrent", "SEARCH_MAX_CONCURRENT", "must be positive")
+		v.check(cfg.RateLimit.ShedConcurrent > 0 && cfg.RateLimit.ShedConcurrent <= cfg.RateLimit.MaxConcurrent,
+			"rate_limit.shed_concurrent", "SEARCH_SHED_CONCURRENT", "must be between 1 and the max concurrent searches")
+	}
+
+	if cfg.Cache.Enabled {
+		v.check(cfg.Cache.MaxEntries > 0, "cache.max_entries", "SEARCH_CACHE_MAX_ENTRIES", "must be positive")
+		v.check(cfg.Cache.TTL > 0, "cache.ttl", "SEARCH_CACHE_TTL", "must be positive")
+	}
+
+	if cfg.Analytics.Enabled {
+		v.check(cfg.Analytics.BufferSize > 0, "analytics.buffer_size", "ANALYTICS_BUFFER_SIZE", "must be positive")
+		v.check(cfg.Analytics.BatchSize > 0, "analytics.batch_size", "ANALYTICS_BATCH_SIZE", "must be positive")
+		v.check(cfg.Analytics.FlushInterval > 0, "analytics.flush_interval", "ANALYTICS_FLUSH_INTERVAL", "must be positive")
+		v.check(cfg.Analytics.PopularityWindow > 0, "analytics.popularity_window", "POPULARITY_WINDOW", "must be positive")
+		v.check(cfg.Analytics.PopularityInterval > 0, "analytics.popularity_interval", "POPULARITY_INTERVAL", "must be positive")
+	}
+
+	cfg.validateRanking(v)
+
+	// Saved searches are stored in Elasticsearch
+	if cfg.SavedSearches.Enabled && cfg.SearchBackend == "elasticsearch" {
+		switch cfg.SavedSearches.Notifier {
+		case "kafka":
+			v.check(cfg.Kafka.Broker != "", "kafka.broker", "KAFKA_BROKER", "is required with the kafka notifier")
+		case "webhook":
+			v.checkURL(cfg.SavedSearches.WebhookURL, "saved_searches.webhook_url", "SAVED_SEARCHES_WEBHOOK_URL")
+		case "log":
+		default:
+			v.fail("saved_searches.notifier", "SAVED_SEARCHES_NOTIFIER", "must be kafka, webhook or log, got %q", cfg.SavedSearches.Notifier)
+		}
+		v.check(cfg.SavedSearches.MaxPerOwner > 0, "saved_searches.max_per_owner", "SAVED_SEARCHES_MAX_PER_OWNER", "must be positive")
+	}
+
+	var level slog.Level
+	v.check(level.UnmarshalText([]byte(strings.ToUpper(cfg.Log.Level))) == nil,
+		"log.level", "LOG_LEVEL", "must be debug, info, warn or error, got %q", cfg.Log.Level)
+
+	v.check(slices.Contains([]string{"none", "stdout", "otlp"}, cfg.Tracing.Exporter),
+		"tracing.exporter", "OTEL_TRACES_EXPORTER", "must be none, stdout or otlp, got %q", cfg.Tracing.Exporter)
+	v.check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio", "OTEL_TRACES_SAMPLE_RATIO", "must be between 0 and 1")
+
+	return errors.Join(v.errs...)
+}
+
+func (cfg *Config) validateElasticsearch(v *validator) {
+	es := &cfg.Elasticsearch
+	if es.CloudID == "" {
+		v.check(len(es.URLs) > 0, "elasticsearch.urls", "ELASTICSEARCH_URL", "is required unless a cloud ID is set")
+		for _, u := range es.URLs {
+			v.checkURL(u, "elasticsearch.urls", "ELASTICSEARCH_URL")
+		}
+	}
+	v.check(es.Flavor == "elasticsearch" || es.Flavor == "opensearch",
+		"elasticsearch.flavor", "ELASTICSEARCH_FLAVOR", "must be elasticsearch or opensearch, got %q", es.Flavor)
+	v.check(es.TenantRouting == "shared" || es.TenantRouting == "index",
+		"elasticsearch.tenant_routing", "TENANT_ROUTING", "must be shared or index, got %q", es.TenantRouting)
+	v.check(es.Index != "", "elasticsearch.index", "ELASTICSEARCH_INDEX", "is required")
+	v.check(es.CACert == "" || es.CAFingerprint == "",
+		"elasticsearch.ca_fingerprint", "ELASTICSEARCH_CA_FINGERPRINT", "can't be set with a CA certificate")
+	v.check((es.ClientCert == "") == (es.ClientKey == ""),
+		"elasticsearch.client_cert", "ELASTICSEARCH_CLIENT_CERT", "and the client key must be set together")
+	v.check(es.MaxRetries >= 0, "elasticsearch.max_retries", "ELASTICSEARCH_MAX_RETRIES", "must not be negative")
+	for _, status := range es.RetryOnStatus {
+		v.check(status >= 100 && status < 600, "elasticsearch.retry_on_status", "ELASTICSEARCH_RETRY_ON_STATUS", "has %d, which is not an HTTP status", status)
+	}
+	v.check(es.ConnectTimeout >= 0, "elasticsearch.connect_timeout", "ELASTICSEARCH_CONNECT_TIMEOUT", "must not be negative")
+}
+
+func (cfg *Config) validateLeaderElection(v *validator) {
+	le := &cfg.LeaderElection
+	switch le.Backend {
+	case "none":
+		return
+	case "elasticsearch":
+		v.check(cfg.SearchBackend == "elasticsearch", "leader_election.backend", "LEADER_ELECTION_BACKEND", "is elasticsearch but the search backend is %s", cfg.SearchBackend)
+		v.check(le.Index != "", "leader_election.index", "LEADER_ELECTION_INDEX", "is required with the elasticsearch backend")
+	case "postgres":
+		u, err := url.Parse(le.PostgresURL)
+		v.check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") && u.Host != "",
+			"leader_election.postgres_url", "LEADER_ELECTION_POSTGRES_URL", "must be a postgres:// URL, got %q", redactURL(le.PostgresURL))
+	default:
+		v.fail("leader_election.backend", "LEADER_ELECTION_BACKEND", "must be none, elasticsearch or postgres, got %q", le.Backend)
+		return
+	}
+	v.check(le.ReplicaID != "", "leader_election.replica_id", "LEADER_ELECTION_REPLICA_ID", "is required")
+	v.check(le.RenewInterval > 0 && le.RenewInterval < le.LeaseDuration,
+		"leader_election.renew_interval", "LEADER_ELECTION_RENEW_INTERVAL", "must be positive and shorter than the lease duration")
+}
+
+// validateRanking checks the settings SIGHUP can reload, so a bad reload is
+// rejected like a bad startup
+func (cfg *Config) validateRanking(v *validator) {
+	r := &cfg.Ranking
+	v.check(r.DefaultMode == "relevance" || r.DefaultMode == "blended",
+		"ranking.default_mode", "RANK_DEFAULT_MODE", "must be relevance or blended, got %q", r.DefaultMode)
+	v.check(r.PopularityWeight >= 0, "ranking.popularity_weight", "RANK_POPULARITY_WEIGHT", "must not be negative")
+	v.check(r.PopularityFactor >= 0, "ranking.popularity_factor", "RANK_POPULARITY_FACTOR", "must not be negative")
+	v.check(r.RecencyWeight >= 0, "ranking.recency_weight", "RANK_RECENCY_WEIGHT", "must not be negative")
+	v.check(r.RecencyDecay > 0 && r.RecencyDecay < 1, "ranking.recency_decay", "RANK_RECENCY_DECAY", "must be between 0 and 1 exclusive")
+	v.check(r.RecencyScale != "", "ranking.recency_scale", "RANK_RECENCY_SCALE", "is required")
+	v.check(r.RecencyOrigin != "", "ranking.recency_origin", "RANK_RECENCY_ORIGIN", "is required")
+	v.check(slices.Contains([]string{"multiply", "replace", "sum", "avg", "max", "min"}, r.BoostMode),
+		"ranking.boost_mode", "RANK_BOOST_MODE", "must be multiply, replace, sum, avg, max or min, got %q", r.BoostMode)
+}
+
+// validator collects the invalid settings, naming each by its file key and
+// environment variable
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(key, env, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s (%s) %s", key, env, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) check(ok bool, key, env, format string, args ...any) {
+	if !ok {
+		v.fail(key, env, format, args...)
+	}
+}
+
+func (v *validator) checkURL(value, key, env string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		key, env, "must be an http(s) URL, got %q", redactURL(value))
+}
@@ -0,0 +1,66 @@
+// internal/elastic/acl.go
+package elastic
+
+import (
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// ErrNoIdentity is returned by Search when the context carries no caller
+// identity; see backend.ErrNoIdentity.
+var ErrNoIdentity = backend.ErrNoIdentity
+
+// aclMappingProperties are the index fields used by the ACL filter. They
+// must be keywords so term queries match the stored values exactly.
+var aclMappingProperties = map[string]interface{}{
+	"owner":          map[string]interface{}{"type": "keyword"},
+	"visibility":     map[string]interface{}{"type": "keyword"},
+	"allowed_users":  map[string]interface{}{"type": "keyword"},
+	"allowed_groups": map[string]interface{}{"type": "keyword"},
+}
+
+// aclFilter returns a query clause matching only the documents identity may
+// read, following the same rules as backend.Readable:
+//   - public documents
+//   - documents the caller owns or is listed in allowed_users
+//   - team documents shared with one of the caller's groups
+//
+// The anonymous identity used when auth is disabled only sees public
+// documents.
+func aclFilter(identity *auth.Identity) map[string]interface{} {
+	readable := []interface{}{
+		term("visibility", models.VisibilityPublic),
+	}
+	if identity.Method != "anonymous" && identity.Subject != "" {
+		readable = append(readable,
+			term("owner", identity.Subject),
+			term("allowed_users", identity.Subject),
+		)
+	}
+	if len(identity.Groups) > 0 {
+		readable = append(readable, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					term("visibility", models.VisibilityTeam),
+					map[string]interface{}{
+						"terms": map[string]interface{}{"allowed_groups": identity.Groups},
+					},
+				},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should":               readable,
+			"minimum_should_match": 1,
+		},
+	}
+}
+
+func term(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]interface{}{field: value},
+	}
+}
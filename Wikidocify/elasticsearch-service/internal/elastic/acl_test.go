@@ -0,0 +1,184 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// roundTrip marshals and unmarshals v so queries can be compared as plain JSON
+func roundTrip(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return out
+}
+
+// queryFilters returns the bool filter clauses of a built search query
+func queryFilters(t *testing.T, query interface{}) []interface{} {
+	t.Helper()
+	q, _ := roundTrip(t, query).(map[string]interface{})
+	boolQuery, _ := q["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	filters, ok := boolQuery["filter"].([]interface{})
+	if !ok {
+		t.Fatalf("query has no filter clauses: %v", q)
+	}
+	return filters
+}
+
+func TestBuildSearchQueryRequiresIdentity(t *testing.T) {
+	_, err := buildSearchQuery(&models.SearchRequest{Query: "roadmap", TenantID: "default"}, nil)
+	if !errors.Is(err, ErrNoIdentity) {
+		t.Fatalf("expected ErrNoIdentity, got %v", err)
+	}
+}
+
+func TestBuildSearchQueryAlwaysAppliesACL(t *testing.T) {
+	identity := &auth.Identity{Subject: "alice", Method: "jwt", Groups: []string{"platform"}}
+	want := roundTrip(t, aclFilter(identity))
+
+	tests := []struct {
+		name string
+		req  models.SearchRequest
+	}{
+		{"all fields", models.SearchRequest{Query: "roadmap", TenantID: "default"}},
+		{"title only", models.SearchRequest{Query: "roadmap", Type: "title", TenantID: "default"}},
+		{"content only", models.SearchRequest{Query: "roadmap", Type: "content", TenantID: "default"}},
+		{"author filter", models.SearchRequest{Query: "roadmap", Author: "bob", TenantID: "default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := buildSearchQuery(&tt.req, identity)
+			if err != nil {
+				t.Fatalf("buildSearchQuery: %v", err)
+			}
+			filters := queryFilters(t, query)
+			if !reflect.DeepEqual(filters[0], want) {
+				t.Fatalf("first filter is not the ACL filter:\n got %v\nwant %v", filters[0], want)
+			}
+			if tt.req.Author != "" && len(filters) != 3 {
+				t.Fatalf("author filter should be added next to the ACL and tenant filters, got %v", filters)
+			}
+		})
+	}
+}
+
+func TestACLFilter(t *testing.T) {
+	public := map[string]interface{}{"term": map[string]interface{}{"visibility": "public"}}
+	owner := map[string]interface{}{"term": map[string]interface{}{"owner": "alice"}}
+	allowedUser := map[string]interface{}{"term": map[string]interface{}{"allowed_users": "alice"}}
+	team := map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"visibility": "team"}},
+		map[string]interface{}{"terms": map[string]interface{}{"allowed_groups": []interface{}{"platform", "docs"}}},
+	}}}
+
+	tests := []struct {
+		name     string
+		identity *auth.Identity
+		want     []interface{}
+	}{
+		{
+			name:     "anonymous sees only public documents",
+			identity: &auth.Identity{Subject: "anonymous", Method: "anonymous", Scopes: []string{auth.ScopeSearch}},
+			want:     []interface{}{public},
+		},
+		{
+			name:     "user without groups",
+			identity: &auth.Identity{Subject: "alice", Method: "api_key"},
+			want:     []interface{}{public, owner, allowedUser},
+		},
+		{
+			name:     "user with groups",
+			identity: &auth.Identity{Subject: "alice", Method: "jwt", Groups: []string{"platform", "docs"}},
+			want:     []interface{}{public, owner, allowedUser, team},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := roundTrip(t, aclFilter(tt.identity)).(map[string]interface{})
+			boolQuery, _ := got["bool"].(map[string]interface{})
+			if boolQuery["minimum_should_match"] != float64(1) {
+				t.Fatalf("ACL filter must require a matching clause, got %v", got)
+			}
+			if !reflect.DeepEqual(boolQuery["should"], roundTrip(t, tt.want)) {
+				t.Fatalf("unexpected clauses:\n got %v\nwant %v", boolQuery["should"], roundTrip(t, tt.want))
+			}
+		})
+	}
+}
+
+// newTestClient returns a Client talking to a fake Elasticsearch that records
+// every search request body
+func newTestClient(t *testing.T) (*Client, *atomic.Int32, *atomic.Value) {
+	return newTestClientWithRouting(t, RoutingShared, "/test-index/_search")
+}
+
+// newTestClientWithRouting is newTestClient for a given tenant routing mode;
+// only requests to searchPath are counted as searches
+func newTestClientWithRouting(t *testing.T, routing, searchPath string) (*Client, *atomic.Int32, *atomic.Value) {
+	t.Helper()
+	var searches atomic.Int32
+	var lastBody atomic.Value
+
+	client := newCannedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == searchPath {
+			searches.Add(1)
+			lastBody.Store(body)
+			_, _ = w.Write([]byte(`{"took":1,"hits":{"total":{"value":0},"hits":[]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	client.routing = routing
+	client.SetRanking(Ranking{})
+	return client, &searches, &lastBody
+}
+
+func TestSearchWithoutIdentityNeverQueriesElasticsearch(t *testing.T) {
+	client, searches, _ := newTestClient(t)
+
+	_, err := client.Search(context.Background(), &models.SearchRequest{Query: "roadmap", Limit: 10, TenantID: "default"})
+	if !errors.Is(err, ErrNoIdentity) {
+		t.Fatalf("expected ErrNoIdentity, got %v", err)
+	}
+	if n := searches.Load(); n != 0 {
+		t.Fatalf("expected no search requests, got %d", n)
+	}
+}
+
+func TestSearchSendsACLFilterForCaller(t *testing.T) {
+	client, searches, lastBody := newTestClient(t)
+	identity := &auth.Identity{Subject: "bob", Method: "jwt", Groups: []string{"platform"}}
+	ctx := auth.WithIdentity(context.Background(), identity)
+
+	if _, err := client.Search(ctx, &models.SearchRequest{Query: "roadmap", Limit: 10, Author: "alice", TenantID: "default"}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if n := searches.Load(); n != 1 {
+		t.Fatalf("expected one search request, got %d", n)
+	}
+
+	var sent map[string]interface{}
+	if err := json.Unmarshal(lastBody.Load().([]byte), &sent); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+	filters := queryFilters(t, sent)
+	if want := roundTrip(t, aclFilter(identity)); !reflect.DeepEqual(filters[0], want) {
+		t.Fatalf("request is missing the ACL filter:\n got %v\nwant %v", filters[0], want)
+	}
+}
@@ -0,0 +1,428 @@
+// internal/elastic/admin.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// MappingVersion is the version of the document mapping this client puts on
+// the indexes it creates or updates, stored in the mapping's
+// _meta.mapping_version. Bump it whenever documentMappingProperties or the
+// analysis settings change.
+const MappingVersion = 1
+
+// ErrEmptyQuery is returned by CountByQuery and DeleteByQuery when no query
+// is given, so that deleting every document takes an explicit match_all
+var ErrEmptyQuery = errors.New("query is required")
+
+// ErrIndexNotFound is returned by the index admin methods when the tenant's
+// index doesn't exist, which with index routing means nothing was ever
+// written for the tenant
+var ErrIndexNotFound = errors.New("index not found")
+
+func mappingMeta() map[string]interface{} {
+	return map[string]interface{}{"mapping_version": MappingVersion}
+}
+
+// IndexStats returns the size of tenantID's index, the version of its
+// mapping and the aliases pointing at it
+func (c *Client) IndexStats(ctx context.Context, tenantID string) (*models.IndexStats, error) {
+	index := c.IndexFor(tenantID)
+	stats := &models.IndexStats{
+		Index:                  index,
+		ExpectedMappingVersion: MappingVersion,
+		Aliases:                map[string][]string{},
+	}
+
+	res, err := c.es.Indices.Stats(
+		c.es.Indices.Stats.WithContext(ctx),
+		c.es.Indices.Stats.WithIndex(index),
+		c.es.Indices.Stats.WithMetric("docs", "store", "segments"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, index)
+	}
+	if res.IsError() {
+		return nil, responseError(res, fmt.Sprintf("failed to get stats of index %s", index))
+	}
+	type indexTotals struct {
+		Docs struct {
+			Count   int64 `json:"count"`
+			Deleted int64 `json:"deleted"`
+		} `json:"docs"`
+		Store struct {
+			SizeInBytes int64 `json:"size_in_bytes"`
+		} `json:"store"`
+		Segments struct {
+			Count int64 `json:"count"`
+		} `json:"segments"`
+	}
+	var statsBody struct {
+		All struct {
+			Primaries indexTotals `json:"primaries"`
+			Total     indexTotals `json:"total"`
+		} `json:"_all"`
+		Indices map[string]json.RawMessage `json:"indices"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&statsBody); err != nil {
+		return nil, fmt.Errorf("failed to decode index stats: %w", err)
+	}
+	stats.Documents = statsBody.All.Primaries.Docs.Count
+	stats.DeletedDocuments = statsBody.All.Primaries.Docs.Deleted
+	stats.PrimarySizeBytes = statsBody.All.Primaries.Store.SizeInBytes
+	stats.SizeBytes = statsBody.All.Total.Store.SizeInBytes
+	stats.Segments = statsBody.All.Total.Segments.Count
+	for name := range statsBody.Indices {
+		stats.Indices = append(stats.Indices, name)
+	}
+	sort.Strings(stats.Indices)
+
+	if stats.MappingVersion, err = c.mappingVersion(ctx, index); err != nil {
+		return nil, err
+	}
+	if stats.Aliases, err = c.aliasTargets(ctx, index); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// mappingVersion returns the lowest mapping version of the indexes behind
+// index, 0 for an index created before mapping versions were recorded
+func (c *Client) mappingVersion(ctx context.Context, index string) (int, error) {
+	res, err := c.es.Indices.GetMapping(
+		c.es.Indices.GetMapping.WithContext(ctx),
+		c.es.Indices.GetMapping.WithIndex(index),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, responseError(res, fmt.Sprintf("failed to get mapping of index %s", index))
+	}
+	var mappings map[string]struct {
+		Mappings struct {
+			Meta struct {
+				MappingVersion int `json:"mapping_version"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&mappings); err != nil {
+		return 0, fmt.Errorf("failed to decode mapping: %w", err)
+	}
+	version := -1
+	for _, m := range mappings {
+		if version < 0 || m.Mappings.Meta.MappingVersion < version {
+			version = m.Mappings.Meta.MappingVersion
+		}
+	}
+	return max(version, 0), nil
+}
+
+// aliasTargets returns every alias of index, or of the indexes index is an
+// alias of, with all the indexes each one points at
+func (c *Client) aliasTargets(ctx context.Context, index string) (map[string][]string, error) {
+	aliases, err := c.getAliases(ctx, index, nil)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, indexAliases := range aliases {
+		names = append(names, indexAliases...)
+	}
+	targets := map[string][]string{}
+	if len(names) == 0 {
+		return targets, nil
+	}
+
+	// The aliases may point at other indexes too
+	all, err := c.getAliases(ctx, "", names)
+	if err != nil {
+		return nil, err
+	}
+	for target, indexAliases := range all {
+		for _, alias := range indexAliases {
+			targets[alias] = append(targets[alias], target)
+		}
+	}
+	for _, t := range targets {
+		sort.Strings(t)
+	}
+	return targets, nil
+}
+
+// getAliases returns the aliases of index, or of every index if it is empty,
+// limited to names if given, keyed by index
+func (c *Client) getAliases(ctx context.Context, index string, names []string) (map[string][]string, error) {
+	opts := []func(*esapi.IndicesGetAliasRequest){c.es.Indices.GetAlias.WithContext(ctx)}
+	if index != "" {
+		opts = append(opts, c.es.Indices.GetAlias.WithIndex(index))
+	}
+	if len(names) > 0 {
+		opts = append(opts, c.es.Indices.GetAlias.WithName(names...))
+	}
+	res, err := c.es.Indices.GetAlias(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	// An index without aliases is not an error, but some versions answer
+	// 404 when names match nothing
+	if res.StatusCode == 404 && len(names) > 0 {
+		return map[string][]string{}, nil
+	}
+	if res.IsError() {
+		return nil, responseError(res, "failed to get aliases")
+	}
+	var body map[string]struct {
+		Aliases map[string]json.RawMessage `json:"aliases"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode aliases: %w", err)
+	}
+	aliases := map[string][]string{}
+	for name, entry := range body {
+		for alias := range entry.Aliases {
+			aliases[name] = append(aliases[name], alias)
+		}
+	}
+	return aliases, nil
+}
+
+// GetIndexedDocument returns the copy of a document in tenantID's index, or
+// nil if it isn't indexed. With shared routing every tenant's documents are
+// in one index, so a document of another tenant is treated as missing.
+func (c *Client) GetIndexedDocument(ctx context.Context, tenantID string, id uint32) (*models.SearchDocument, error) {
+	res, err := c.es.Get(c.IndexFor(tenantID), fmt.Sprint(id), c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, responseError(res, "failed to get document")
+	}
+	var hit searchHit
+	if err := json.NewDecoder(res.Body).Decode(&hit); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+	doc := hit.document()
+	docTenant := doc.TenantID
+	if docTenant == "" {
+		docTenant = tenant.Default
+	}
+	if docTenant != tenantID {
+		return nil, nil
+	}
+	return &doc, nil
+}
+
+// Refresh makes every write to tenantID's index visible to searches
+func (c *Client) Refresh(ctx context.Context, tenantID string) error {
+	index := c.IndexFor(tenantID)
+	res, err := c.es.Indices.Refresh(
+		c.es.Indices.Refresh.WithContext(ctx),
+		c.es.Indices.Refresh.WithIndex(index),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, index)
+	}
+	if res.IsError() {
+		return responseError(res, fmt.Sprintf("failed to refresh index %s", index))
+	}
+	return nil
+}
+
+// ForceMerge merges the segments of tenantID's index down to maxSegments,
+// or only expunges deleted documents if onlyExpungeDeletes is set. A zero
+// maxSegments lets the cluster decide. It returns once the merge is done,
+// which can take long on a large index.
+func (c *Client) ForceMerge(ctx context.Context, tenantID string, maxSegments int, onlyExpungeDeletes bool) error {
+	index := c.IndexFor(tenantID)
+	opts := []func(*esapi.IndicesForcemergeRequest){
+		c.es.Indices.Forcemerge.WithContext(ctx),
+		c.es.Indices.Forcemerge.WithIndex(index),
+	}
+	if maxSegments > 0 {
+		opts = append(opts, c.es.Indices.Forcemerge.WithMaxNumSegments(maxSegments))
+	}
+	if onlyExpungeDeletes {
+		opts = append(opts, c.es.Indices.Forcemerge.WithOnlyExpungeDeletes(true))
+	}
+	res, err := c.es.Indices.Forcemerge(opts...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, index)
+	}
+	if res.IsError() {
+		return responseError(res, fmt.Sprintf("failed to force-merge index %s", index))
+	}
+	return nil
+}
+
+// tenantQuery restricts query to tenantID's documents, which matters with
+// shared routing
+func tenantQuery(tenantID string, query json.RawMessage) (map[string]interface{}, error) {
+	var clauses map[string]json.RawMessage
+	if err := json.Unmarshal(query, &clauses); err != nil || len(clauses) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   []interface{}{query},
+				"filter": []interface{}{term("tenant_id", tenantID)},
+			},
+		},
+	}, nil
+}
+
+// CountByQuery returns the number of tenantID's documents matching query, a
+// query DSL object, which is what DeleteByQuery would delete
+func (c *Client) CountByQuery(ctx context.Context, tenantID string, query json.RawMessage) (int64, error) {
+	body, err := tenantQuery(tenantID, query)
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	index := c.IndexFor(tenantID)
+	res, err := c.es.Count(
+		c.es.Count.WithContext(ctx),
+		c.es.Count.WithIndex(index),
+		c.es.Count.WithBody(bytes.NewReader(data)),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return 0, fmt.Errorf("%w: %s", ErrIndexNotFound, index)
+	}
+	if res.IsError() {
+		return 0, responseError(res, "count failed")
+	}
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode count: %w", err)
+	}
+	return result.Count, nil
+}
+
+// DeleteByQuery deletes tenantID's documents matching query, a query DSL
+// object, and returns the number deleted. Documents changed while the
+// deletion runs are skipped rather than failing it.
+func (c *Client) DeleteByQuery(ctx context.Context, tenantID string, query json.RawMessage) (int64, error) {
+	body, err := tenantQuery(tenantID, query)
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	index := c.IndexFor(tenantID)
+	defer c.bumpGeneration(ctx)
+	res, err := c.es.DeleteByQuery(
+		[]string{index},
+		bytes.NewReader(data),
+		c.es.DeleteByQuery.WithContext(ctx),
+		c.es.DeleteByQuery.WithConflicts("proceed"),
+		c.es.DeleteByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return 0, fmt.Errorf("%w: %s", ErrIndexNotFound, index)
+	}
+	if res.IsError() {
+		return 0, responseError(res, "delete by query failed")
+	}
+	var result struct {
+		Deleted  int64             `json:"deleted"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode delete by query response: %w", err)
+	}
+	if len(result.Failures) > 0 {
+		return result.Deleted, fmt.Errorf("delete by query deleted %d documents and failed on %d: %s",
+			result.Deleted, len(result.Failures), result.Failures[0])
+	}
+	return result.Deleted, nil
+}
+
+// DocumentVersions returns the versions of up to limit of tenantID's
+// indexed documents with an ID greater than afterID, in ID order. A tenant
+// without an index has no documents.
+func (c *Client) DocumentVersions(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{term("tenant_id", tenantID)},
+			},
+		},
+		"sort":    []interface{}{map[string]interface{}{"id": "asc"}},
+		"size":    limit,
+		"_source": []string{"id", "tenant_id", "updated_at"},
+	}
+	if afterID > 0 {
+		query["search_after"] = []interface{}{afterID}
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(c.IndexFor(tenantID)),
+		c.es.Search.WithBody(bytes.NewReader(body)),
+		c.es.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res, "failed to list document versions")
+	}
+	var result searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid search response: %w", err)
+	}
+	versions := make([]models.DocumentVersion, 0, len(result.Hits.Hits))
+	for i := range result.Hits.Hits {
+		doc := result.Hits.Hits[i].document()
+		versions = append(versions, models.DocumentVersion{ID: doc.ID, TenantID: doc.TenantID, UpdatedAt: doc.UpdatedAt})
+	}
+	return versions, nil
+}
@@ -0,0 +1,177 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
+)
+
+func newAdminTestClient(t *testing.T, routing string) (*Client, *fakeCluster) {
+	t.Helper()
+	fake, server := newFakeClusterWithState(t, FlavorElasticsearch)
+	client, err := NewClient(Connection{Addresses: []string{server.URL}, Flavor: FlavorElasticsearch}, "test-index", routing)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client, fake
+}
+
+func indexTestDocument(t *testing.T, client *Client, id uint32, tenantID, author string) {
+	t.Helper()
+	doc := &models.SearchDocument{
+		ID:         id,
+		TenantID:   tenantID,
+		Title:      "Document",
+		Author:     author,
+		Visibility: models.VisibilityPublic,
+		UpdatedAt:  time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := client.IndexDocument(context.Background(), doc); err != nil {
+		t.Fatalf("IndexDocument: %v", err)
+	}
+}
+
+func TestIndexStats(t *testing.T) {
+	client, fake := newAdminTestClient(t, RoutingShared)
+	indexTestDocument(t, client, 1, tenant.Default, "alice")
+	indexTestDocument(t, client, 2, tenant.Default, "bob")
+
+	fake.mu.Lock()
+	fake.indexes["test-index-old"] = map[string]map[string]interface{}{}
+	fake.aliases["test-index"] = []string{"docs"}
+	fake.aliases["test-index-old"] = []string{"docs", "archive"}
+	fake.mu.Unlock()
+
+	stats, err := client.IndexStats(context.Background(), tenant.Default)
+	if err != nil {
+		t.Fatalf("IndexStats: %v", err)
+	}
+	if stats.Documents != 2 || stats.Segments != 1 || stats.SizeBytes == 0 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.MappingVersion != MappingVersion || stats.ExpectedMappingVersion != MappingVersion {
+		t.Errorf("expected mapping version %d, got %d", MappingVersion, stats.MappingVersion)
+	}
+	if len(stats.Aliases) != 1 || !slices.Equal(stats.Aliases["docs"], []string{"test-index", "test-index-old"}) {
+		t.Errorf("expected the docs alias with both its targets, got %v", stats.Aliases)
+	}
+
+	fake.mu.Lock()
+	delete(fake.meta, "test-index")
+	fake.mu.Unlock()
+	if stats, err := client.IndexStats(context.Background(), tenant.Default); err != nil || stats.MappingVersion != 0 {
+		t.Errorf("expected version 0 for an index without one, got %v, %v", stats, err)
+	}
+}
+
+func TestIndexAdminMissingTenantIndex(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingIndex)
+	if _, err := client.IndexStats(context.Background(), "acme"); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("expected ErrIndexNotFound from IndexStats, got %v", err)
+	}
+	if err := client.Refresh(context.Background(), "acme"); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("expected ErrIndexNotFound from Refresh, got %v", err)
+	}
+	doc, err := client.GetIndexedDocument(context.Background(), "acme", 1)
+	if err != nil || doc != nil {
+		t.Errorf("expected no document, got %v, %v", doc, err)
+	}
+}
+
+func TestGetIndexedDocument(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingShared)
+	indexTestDocument(t, client, 7, "acme", "alice")
+
+	doc, err := client.GetIndexedDocument(context.Background(), "acme", 7)
+	if err != nil {
+		t.Fatalf("GetIndexedDocument: %v", err)
+	}
+	if doc == nil || doc.ID != 7 || doc.Author != "alice" || !doc.UpdatedAt.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if doc, err := client.GetIndexedDocument(context.Background(), "acme", 8); err != nil || doc != nil {
+		t.Fatalf("expected no document, got %v, %v", doc, err)
+	}
+}
+
+func TestGetIndexedDocumentOfAnotherTenant(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingShared)
+	indexTestDocument(t, client, 7, "acme", "alice")
+
+	// The shared index holds acme's document under the bare ID
+	doc, err := client.GetIndexedDocument(context.Background(), "globex", 7)
+	if err != nil || doc != nil {
+		t.Fatalf("expected acme's document to be missing for globex, got %+v, %v", doc, err)
+	}
+}
+
+func TestDeleteByQueryIsScopedToTenant(t *testing.T) {
+	client, fake := newAdminTestClient(t, RoutingShared)
+	indexTestDocument(t, client, 1, "acme", "bob")
+	indexTestDocument(t, client, 2, "acme", "alice")
+	indexTestDocument(t, client, 3, "globex", "bob")
+	query := json.RawMessage(`{"term": {"author": "bob"}}`)
+
+	count, err := client.CountByQuery(context.Background(), "acme", query)
+	if err != nil || count != 1 {
+		t.Fatalf("expected one of acme's documents to match, got %d, %v", count, err)
+	}
+	generation, _ := client.Generation(context.Background())
+	deleted, err := client.DeleteByQuery(context.Background(), "acme", query)
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected one document deleted, got %d, %v", deleted, err)
+	}
+	if next, _ := client.Generation(context.Background()); next == generation {
+		t.Error("expected the generation to move on")
+	}
+
+	fake.mu.Lock()
+	_, kept := fake.indexes["test-index"]["3"]
+	fake.mu.Unlock()
+	if !kept {
+		t.Error("delete by query removed another tenant's document")
+	}
+
+	for _, empty := range []string{``, `{}`, `null`} {
+		if _, err := client.DeleteByQuery(context.Background(), "acme", json.RawMessage(empty)); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("%q: expected ErrEmptyQuery, got %v", empty, err)
+		}
+	}
+}
+
+func TestDocumentVersions(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingShared)
+	for _, id := range []uint32{12, 3, 7} {
+		indexTestDocument(t, client, id, "acme", "alice")
+	}
+	indexTestDocument(t, client, 5, "globex", "alice")
+
+	first, err := client.DocumentVersions(context.Background(), "acme", 0, 2)
+	if err != nil {
+		t.Fatalf("DocumentVersions: %v", err)
+	}
+	second, err := client.DocumentVersions(context.Background(), "acme", first[len(first)-1].ID, 2)
+	if err != nil {
+		t.Fatalf("DocumentVersions: %v", err)
+	}
+	var ids []uint32
+	for _, v := range append(first, second...) {
+		ids = append(ids, v.ID)
+		if v.TenantID != "acme" || v.UpdatedAt.IsZero() {
+			t.Errorf("unexpected version %+v", v)
+		}
+	}
+	if !slices.Equal(ids, []uint32{3, 7, 12}) {
+		t.Fatalf("expected acme's documents in ID order, got %v", ids)
+	}
+
+	if versions, err := client.DocumentVersions(context.Background(), "initech", 0, 2); err != nil || len(versions) != 0 {
+		t.Fatalf("expected no versions for a tenant without documents, got %v, %v", versions, err)
+	}
+}
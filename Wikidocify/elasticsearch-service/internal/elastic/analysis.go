@@ -0,0 +1,246 @@
+// internal/elastic/analysis.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"wikidocify/elasticsearch-service/internal/language"
+)
+
+// searchAnalyzer is the search-time analyzer of title and content. It is the
+// standard analyzer plus the synonyms set, so synonyms apply to queries
+// without reindexing.
+const searchAnalyzer = "wikidocify_search"
+
+// synonymsFilter is the updateable filter reading the synonyms set
+const synonymsFilter = "wikidocify_synonyms"
+
+// languageFilters are the token filters of each language analyzer, applied
+// after the standard tokenizer. They mirror the built-in english, german
+// and arabic analyzers, which can't be extended with synonyms.
+var languageFilters = map[string][]string{
+	language.English: {"wikidocify_en_possessive", "lowercase", "wikidocify_en_stop", "wikidocify_en_stemmer"},
+	language.German:  {"lowercase", "wikidocify_de_stop", "german_normalization", "wikidocify_de_stemmer"},
+	language.Arabic:  {"lowercase", "decimal_digit", "wikidocify_ar_stop", "arabic_normalization", "wikidocify_ar_stemmer"},
+}
+
+// languageFilterDefinitions defines the custom filters used in languageFilters
+var languageFilterDefinitions = map[string]interface{}{
+	"wikidocify_en_possessive": map[string]interface{}{"type": "stemmer", "language": "possessive_english"},
+	"wikidocify_en_stop":       map[string]interface{}{"type": "stop", "stopwords": "_english_"},
+	"wikidocify_en_stemmer":    map[string]interface{}{"type": "stemmer", "language": "english"},
+	"wikidocify_de_stop":       map[string]interface{}{"type": "stop", "stopwords": "_german_"},
+	"wikidocify_de_stemmer":    map[string]interface{}{"type": "stemmer", "language": "light_german"},
+	"wikidocify_ar_stop":       map[string]interface{}{"type": "stop", "stopwords": "_arabic_"},
+	"wikidocify_ar_stemmer":    map[string]interface{}{"type": "stemmer", "language": "arabic"},
+}
+
+// languageAnalyzer is the index-time analyzer of lang; the search-time one
+// is the same with synonyms added
+func languageAnalyzer(lang string) string {
+	return "wikidocify_" + lang
+}
+
+func languageSearchAnalyzer(lang string) string {
+	return "wikidocify_" + lang + "_search"
+}
+
+// textField is the mapping of title and content: the standard analyzer for
+// documents of any language, plus one sub-field per supported language.
+// Searches use the sub-field of the document's language.
+func textField() map[string]interface{} {
+	fields := map[string]interface{}{}
+	for _, lang := range language.Supported {
+		fields[lang] = map[string]interface{}{
+			"type":            "text",
+			"analyzer":        languageAnalyzer(lang),
+			"search_analyzer": languageSearchAnalyzer(lang),
+		}
+	}
+	return map[string]interface{}{
+		"type":            "text",
+		"analyzer":        "standard",
+		"search_analyzer": searchAnalyzer,
+		"fields":          fields,
+	}
+}
+
+// analysisSettings defines searchAnalyzer and the language analyzers. The
+// synonym_graph filter reads its rules from the synonyms set and is
+// updateable, so changing the set reloads it in place. OpenSearch has no
+// synonyms sets; there the search analyzers are the index analyzers under
+// their own names, so the mapping is the same for both flavors.
+func (c *Client) analysisSettings() map[string]interface{} {
+	filters := map[string]interface{}{}
+	for name, filter := range languageFilterDefinitions {
+		filters[name] = filter
+	}
+	searchFilters := []string{"lowercase"}
+	if c.SupportsSynonyms() {
+		filters[synonymsFilter] = map[string]interface{}{
+			"type":         "synonym_graph",
+			"synonyms_set": c.synonymsSet(),
+			"updateable":   true,
+		}
+		searchFilters = append(searchFilters, synonymsFilter)
+	}
+
+	analyzers := map[string]interface{}{
+		searchAnalyzer: map[string]interface{}{
+			"tokenizer": "standard",
+			"filter":    searchFilters,
+		},
+	}
+	for lang, chain := range languageFilters {
+		analyzers[languageAnalyzer(lang)] = map[string]interface{}{
+			"tokenizer": "standard",
+			"filter":    chain,
+		}
+		// Synonyms go right after lowercasing so the terms they produce
+		// are stemmed like indexed ones
+		withSynonyms := []string{}
+		for _, filter := range chain {
+			withSynonyms = append(withSynonyms, filter)
+			if filter == "lowercase" && c.SupportsSynonyms() {
+				withSynonyms = append(withSynonyms, synonymsFilter)
+			}
+		}
+		analyzers[languageSearchAnalyzer(lang)] = map[string]interface{}{
+			"tokenizer": "standard",
+			"filter":    withSynonyms,
+		}
+	}
+
+	return map[string]interface{}{
+		"analysis": map[string]interface{}{
+			"filter":   filters,
+			"analyzer": analyzers,
+		},
+	}
+}
+
+// analyzerNames lists every analyzer defined by analysisSettings
+func analyzerNames() []string {
+	names := []string{searchAnalyzer}
+	for _, lang := range language.Supported {
+		names = append(names, languageAnalyzer(lang), languageSearchAnalyzer(lang))
+	}
+	return names
+}
+
+// hasAnalysis reports whether index defines every analyzer of
+// analysisSettings. Indexes created before they were introduced don't, and
+// can't map the title and content fields until MigrateAnalysis adds them.
+func (c *Client) hasAnalysis(ctx context.Context, index string) (bool, error) {
+	res, err := c.es.Indices.GetSettings(
+		c.es.Indices.GetSettings.WithContext(ctx),
+		c.es.Indices.GetSettings.WithIndex(index),
+		c.es.Indices.GetSettings.WithName("index.analysis.analyzer.*"),
+		c.es.Indices.GetSettings.WithFlatSettings(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, responseError(res, fmt.Sprintf("failed to get settings of index %s", index))
+	}
+	var settings map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&settings); err != nil {
+		return false, err
+	}
+	for _, name := range analyzerNames() {
+		if _, ok := settings[index].Settings["index.analysis.analyzer."+name+".tokenizer"]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MigrateIndexAnalysis adds the analyzers and the fields using them to
+// tenantID's index if it was created before they were introduced, and
+// reports whether it had to. Analysis settings can only change on a closed
+// index, so searches and writes to the index fail for the moment it is
+// closed; it is an explicit admin operation for a quiet time, never done on
+// startup.
+func (c *Client) MigrateIndexAnalysis(ctx context.Context, tenantID string) (bool, error) {
+	index := c.IndexFor(tenantID)
+	migrated, err := c.migrateAnalysis(ctx, index)
+	if err != nil || !migrated {
+		return migrated, err
+	}
+	return true, c.putMapping(ctx, index, true)
+}
+
+// MigrateSavedSearchAnalysis is MigrateIndexAnalysis for the saved searches
+// percolator index
+func (c *Client) MigrateSavedSearchAnalysis(ctx context.Context, index string) (bool, error) {
+	migrated, err := c.migrateAnalysis(ctx, index)
+	if err != nil || !migrated {
+		return migrated, err
+	}
+	return true, c.putSavedSearchMapping(ctx, index, true)
+}
+
+// migrateAnalysis closes index, adds the analysis settings and reopens it,
+// unless the analyzers are there already
+func (c *Client) migrateAnalysis(ctx context.Context, index string) (bool, error) {
+	ok, err := c.hasAnalysis(ctx, index)
+	if err != nil || ok {
+		return false, err
+	}
+
+	slog.WarnContext(ctx, "Closing index to add analyzers", "index", index)
+	res, err := c.es.Indices.Close([]string{index}, c.es.Indices.Close.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	if res.IsError() {
+		return false, responseError(res, fmt.Sprintf("failed to close index %s", index))
+	}
+
+	body, err := json.Marshal(c.analysisSettings())
+	if err != nil {
+		return false, err
+	}
+	res, putErr := c.es.Indices.PutSettings(bytes.NewReader(body),
+		c.es.Indices.PutSettings.WithContext(ctx),
+		c.es.Indices.PutSettings.WithIndex(index),
+	)
+	if putErr == nil {
+		res.Body.Close()
+		if res.IsError() {
+			putErr = responseError(res, fmt.Sprintf("failed to add analyzers to index %s", index))
+		}
+	}
+
+	// Reopen even if the settings were rejected, and even if ctx is done
+	res, err = c.es.Indices.Open([]string{index}, c.es.Indices.Open.WithContext(context.WithoutCancel(ctx)))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	if res.IsError() {
+		return false, responseError(res, fmt.Sprintf("failed to reopen index %s", index))
+	}
+	if putErr != nil {
+		return false, putErr
+	}
+	slog.InfoContext(ctx, "Added analyzers to index", "index", index)
+	return true, nil
+}
+
+// withoutAnalyzedText drops the title and content mappings, which refer to
+// the analyzers, from properties
+func withoutAnalyzedText(properties map[string]interface{}) map[string]interface{} {
+	delete(properties, "title")
+	delete(properties, "content")
+	return properties
+}
@@ -0,0 +1,214 @@
+// internal/elastic/analytics.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// analyticsMapping matches models.SearchEvent
+var analyticsMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"query_id":      map[string]interface{}{"type": "keyword"},
+			"timestamp":     map[string]interface{}{"type": "date"},
+			"tenant_id":     map[string]interface{}{"type": "keyword"},
+			"query":         map[string]interface{}{"type": "keyword"},
+			"type":          map[string]interface{}{"type": "keyword"},
+			"author":        map[string]interface{}{"type": "keyword"},
+			"limit":         map[string]interface{}{"type": "integer"},
+			"offset":        map[string]interface{}{"type": "integer"},
+			"hits":          map[string]interface{}{"type": "long"},
+			"latency_ms":    map[string]interface{}{"type": "long"},
+			"caller":        map[string]interface{}{"type": "keyword"},
+			"caller_method": map[string]interface{}{"type": "keyword"},
+			"result_ids":    map[string]interface{}{"type": "long", "index": false},
+		},
+	},
+}
+
+// EnsureAnalyticsIndex creates the search analytics index if it doesn't exist
+func (c *Client) EnsureAnalyticsIndex(ctx context.Context, index string) error {
+	return c.ensureAppendOnlyIndex(ctx, index, analyticsMapping)
+}
+
+// EnsureClicksIndex creates the click index if it doesn't exist
+func (c *Client) EnsureClicksIndex(ctx context.Context, index string) error {
+	return c.ensureAppendOnlyIndex(ctx, index, clicksMapping)
+}
+
+func (c *Client) ensureAppendOnlyIndex(ctx context.Context, index string, mapping map[string]interface{}) error {
+	res, err := c.es.Indices.Exists([]string{index}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 200 {
+		return nil
+	}
+
+	body, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	req := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  bytes.NewReader(body),
+	}
+	res, err = req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		if err := responseError(res, fmt.Sprintf("failed to create index %s", index)); !isAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendEvents stores events (search or click events) in index with one
+// bulk request
+func (c *Client) AppendEvents(ctx context.Context, index string, events []interface{}) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		body.WriteString(`{"index":{}}` + "\n")
+		if err := json.NewEncoder(&body).Encode(event); err != nil {
+			return err
+		}
+	}
+
+	res, err := c.es.Bulk(&body, c.es.Bulk.WithContext(ctx), c.es.Bulk.WithIndex(index))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, "failed to index events")
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Errors {
+		return fmt.Errorf("some events were rejected")
+	}
+	return nil
+}
+
+// TopQueries returns the most frequent queries in the window
+func (c *Client) TopQueries(ctx context.Context, index string, q *models.AnalyticsQuery) ([]models.QueryStats, error) {
+	return c.queryStats(ctx, index, q, nil, map[string]interface{}{"_count": "desc"})
+}
+
+// ZeroResultQueries returns the most frequent queries that found nothing
+func (c *Client) ZeroResultQueries(ctx context.Context, index string, q *models.AnalyticsQuery) ([]models.QueryStats, error) {
+	return c.queryStats(ctx, index, q, term("hits", 0), map[string]interface{}{"_count": "desc"})
+}
+
+// SlowQueries returns the queries with the highest latency among searches
+// slower than q.MinLatencyMs
+func (c *Client) SlowQueries(ctx context.Context, index string, q *models.AnalyticsQuery) ([]models.QueryStats, error) {
+	slow := map[string]interface{}{
+		"range": map[string]interface{}{
+			"latency_ms": map[string]interface{}{"gte": q.MinLatencyMs},
+		},
+	}
+	return c.queryStats(ctx, index, q, slow, map[string]interface{}{"max_latency": "desc"})
+}
+
+// queryStats aggregates the searches in the window, optionally narrowed by
+// filter, per query
+func (c *Client) queryStats(ctx context.Context, index string, q *models.AnalyticsQuery, filter map[string]interface{}, order map[string]interface{}) ([]models.QueryStats, error) {
+	filters := []interface{}{
+		term("tenant_id", q.TenantID),
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				"timestamp": map[string]interface{}{
+					"gte": q.From.Format(time.RFC3339),
+					"lte": q.To.Format(time.RFC3339),
+				},
+			},
+		},
+	}
+	if filter != nil {
+		filters = append(filters, filter)
+	}
+
+	esQuery := map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": filters},
+		},
+		"aggs": map[string]interface{}{
+			"queries": map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": "query",
+					"size":  q.Limit,
+					"order": order,
+				},
+				"aggs": map[string]interface{}{
+					"avg_hits":    map[string]interface{}{"avg": map[string]interface{}{"field": "hits"}},
+					"avg_latency": map[string]interface{}{"avg": map[string]interface{}{"field": "latency_ms"}},
+					"max_latency": map[string]interface{}{"max": map[string]interface{}{"field": "latency_ms"}},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(esQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(index),
+		c.es.Search.WithBody(bytes.NewReader(body)),
+		c.es.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res, "analytics query failed")
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	stats := []models.QueryStats{}
+	aggs, _ := result["aggregations"].(map[string]interface{})
+	queries, _ := aggs["queries"].(map[string]interface{})
+	buckets, _ := queries["buckets"].([]interface{})
+	for _, b := range buckets {
+		bucket, _ := b.(map[string]interface{})
+		var s models.QueryStats
+		s.Query, _ = bucket["key"].(string)
+		if count, ok := bucket["doc_count"].(float64); ok {
+			s.Count = int64(count)
+		}
+		s.AvgHits = aggValue(bucket, "avg_hits")
+		s.AvgLatencyMs = aggValue(bucket, "avg_latency")
+		s.MaxLatencyMs = aggValue(bucket, "max_latency")
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+func aggValue(bucket map[string]interface{}, name string) float64 {
+	agg, _ := bucket[name].(map[string]interface{})
+	value, _ := agg["value"].(float64)
+	return value
+}
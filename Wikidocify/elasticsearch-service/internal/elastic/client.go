@@ -4,23 +4,63 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/language"
 	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
+// Tenant routing modes
+const (
+    // RoutingShared keeps every tenant in one index; searches are always
+    // filtered by tenant_id.
+    RoutingShared = "shared"
+    // RoutingIndex gives each tenant its own index, named
+    // "<index>-<tenant>" and created the first time it is written to.
+    RoutingIndex = "index"
+)
+
+// ErrNoTenant is returned by Search when the request names no tenant.
+var ErrNoTenant = backend.ErrNoTenant
+
+var _ backend.SearchBackend = (*Client)(nil)
+
 type Client struct {
-    es    *elasticsearch.Client
-    index string
+    es      *elasticsearch.Client
+    flavor  string
+    index   string
+    routing string
+    ensured sync.Map // index names known to exist with an up to date mapping
+
+    observeLatency func(time.Duration)
+    ranking        atomic.Pointer[Ranking]
 }
 
-func NewClient(url, index string) (*Client, error) {
-    cfg := elasticsearch.Config{
-        Addresses: []string{url},
+// NewClient connects to the cluster described by conn, waiting up to
+// conn.ConnectTimeout for it to be reachable
+func NewClient(conn Connection, index, routing string) (*Client, error) {
+    if routing != RoutingShared && routing != RoutingIndex {
+        return nil, fmt.Errorf("unknown tenant routing %q", routing)
+    }
+    if conn.Flavor != FlavorElasticsearch && conn.Flavor != FlavorOpenSearch {
+        return nil, fmt.Errorf("unknown cluster flavor %q", conn.Flavor)
+    }
+
+    cfg, err := conn.esConfig()
+    if err != nil {
+        return nil, err
     }
     es, err := elasticsearch.NewClient(cfg)
     if err != nil {
@@ -28,56 +68,113 @@ func NewClient(url, index string) (*Client, error) {
     }
 
     client := &Client{
-        es:    es,
-        index: index,
+        es:      es,
+        flavor:  conn.Flavor,
+        index:   index,
+        routing: routing,
     }
+    client.SetRanking(DefaultRanking)
 
     // Test connection
-    if err := client.ping(); err != nil {
+    ctx := context.Background()
+    if conn.ConnectTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, conn.ConnectTimeout)
+        defer cancel()
+    }
+    if err := client.connect(ctx); err != nil {
         return nil, fmt.Errorf("elasticsearch connection failed: %w", err)
     }
 
-    // Create index if it doesn't exist
-    if err := client.createIndexIfNotExists(); err != nil {
+    // Indexes refer to the synonyms set, so it must exist first
+    if client.SupportsSynonyms() {
+        if err := client.ensureSynonymsSet(context.Background()); err != nil {
+            return nil, fmt.Errorf("failed to create synonyms set: %w", err)
+        }
+    }
+
+    // Search results cached by any replica are keyed on the shared generation
+    if err := client.ensureAppendOnlyIndex(context.Background(), client.generationIndex(), generationMapping); err != nil {
+        return nil, fmt.Errorf("failed to create generation index: %w", err)
+    }
+
+    // Create the default tenant's index if it doesn't exist
+    if err := client.ensureIndex(context.Background(), client.IndexFor(tenant.Default)); err != nil {
         return nil, fmt.Errorf("failed to create index: %w", err)
     }
 
     return client, nil
 }
 
-func (c *Client) ping() error {
-    res, err := c.es.Info()
+// SetLatencyObserver registers fn to be called with the duration of every
+// search request. It must be called before the client is used.
+func (c *Client) SetLatencyObserver(fn func(time.Duration)) {
+    c.observeLatency = fn
+}
+
+// IndexFor returns the name of the index holding tenantID's documents
+func (c *Client) IndexFor(tenantID string) string {
+    if c.routing == RoutingIndex {
+        return c.index + "-" + tenantID
+    }
+    return c.index
+}
+
+// Name identifies the backend in health reports
+func (c *Client) Name() string {
+    return c.Flavor()
+}
+
+func (c *Client) ping(ctx context.Context) error {
+    res, err := c.es.Info(c.es.Info.WithContext(ctx))
     if err != nil {
         return err
     }
     defer res.Body.Close()
     if res.IsError() {
-        return fmt.Errorf("elasticsearch ping failed: %s", res.Status())
+        return responseError(res, "elasticsearch ping failed")
     }
     return nil
 }
 
-func (c *Client) createIndexIfNotExists() error {
-    res, err := c.es.Indices.Exists([]string{c.index})
+// ensureIndex creates index if it doesn't exist, or adds the fields introduced
+// since it was created. The result is remembered so each index is only
+// checked once per process.
+func (c *Client) ensureIndex(ctx context.Context, index string) error {
+    if _, ok := c.ensured.Load(index); ok {
+        return nil
+    }
+
+    res, err := c.es.Indices.Exists([]string{index}, c.es.Indices.Exists.WithContext(ctx))
     if err != nil {
         return err
     }
     defer res.Body.Close()
     if res.StatusCode == 200 {
-        return nil // Index exists
+        // Index exists; make sure indexes created before ACLs and tenants
+        // have their fields. Indexes created before the analyzers keep
+        // their title and content mappings until MigrateIndexAnalysis, as
+        // adding analyzers means closing the index.
+        analyzed, err := c.hasAnalysis(ctx, index)
+        if err != nil {
+            return err
+        }
+        if !analyzed {
+            slog.WarnContext(ctx, "Index lacks the synonym and language analyzers; run the analysis migration",
+                "index", index, "endpoint", "POST /api/v1/admin/index/migrate-analysis")
+        }
+        if err := c.putMapping(ctx, index, analyzed); err != nil {
+            return err
+        }
+        c.ensured.Store(index, struct{}{})
+        return nil
     }
 
-    // Mapping matches SearchDocument fields
     mapping := map[string]interface{}{
+        "settings": c.analysisSettings(),
         "mappings": map[string]interface{}{
-            "properties": map[string]interface{}{
-                "id":        map[string]interface{}{"type": "integer"},
-                "title":     map[string]interface{}{"type": "text", "analyzer": "standard"},
-                "content":   map[string]interface{}{"type": "text", "analyzer": "standard"},
-                "author":    map[string]interface{}{"type": "keyword"},
-                "created_at": map[string]interface{}{"type": "date"},
-                "updated_at": map[string]interface{}{"type": "date"},
-            },
+            "_meta":      mappingMeta(),
+            "properties": documentMappingProperties(),
         },
     }
     mappingJSON, err := json.Marshal(mapping)
@@ -86,168 +183,424 @@ func (c *Client) createIndexIfNotExists() error {
     }
 
     req := esapi.IndicesCreateRequest{
-        Index: c.index,
+        Index: index,
         Body:  bytes.NewReader(mappingJSON),
     }
-    res, err = req.Do(context.Background(), c.es)
+    res, err = req.Do(ctx, c.es)
     if err != nil {
         return err
     }
     defer res.Body.Close()
+    // Another instance may have created the index in the meantime
     if res.IsError() {
-        return fmt.Errorf("failed to create index: %s", res.Status())
+        if err := responseError(res, fmt.Sprintf("failed to create index %s", index)); !isAlreadyExists(err) {
+            return err
+        }
     }
+    c.ensured.Store(index, struct{}{})
     return nil
 }
 
-// IndexDocument indexes a SearchDocument in Elasticsearch
-func (c *Client) IndexDocument(doc *models.SearchDocument) error {
-    docJSON, err := json.Marshal(doc)
+// documentMappingProperties matches SearchDocument fields
+func documentMappingProperties() map[string]interface{} {
+    properties := map[string]interface{}{
+        "id":         map[string]interface{}{"type": "integer"},
+        "title":      textField(),
+        "content":    textField(),
+        "author":     map[string]interface{}{"type": "keyword"},
+        "created_at": map[string]interface{}{"type": "date"},
+        "updated_at": map[string]interface{}{"type": "date"},
+    }
+    for field, fieldMapping := range addedMappingProperties() {
+        properties[field] = fieldMapping
+    }
+    return properties
+}
+
+// addedMappingProperties are the fields added after the original mapping.
+// They are put on existing indexes so term filters on them keep working.
+func addedMappingProperties() map[string]interface{} {
+    properties := map[string]interface{}{
+        "tenant_id":  map[string]interface{}{"type": "keyword"},
+        "popularity": map[string]interface{}{"type": "float"},
+        "language":   map[string]interface{}{"type": "keyword"},
+    }
+    for field, fieldMapping := range aclMappingProperties {
+        properties[field] = fieldMapping
+    }
+    return properties
+}
+
+// putMapping adds the fields from addedMappingProperties to an existing
+// index and records the mapping version
+func (c *Client) putMapping(ctx context.Context, index string, analyzed bool) error {
+    // The mapping version is only recorded once every field is mapped
+    mapping := map[string]interface{}{
+        "_meta":      mappingMeta(),
+        "properties": documentMappingProperties(),
+    }
+    if !analyzed {
+        mapping = map[string]interface{}{"properties": withoutAnalyzedText(documentMappingProperties())}
+    }
+    body, err := json.Marshal(mapping)
+    if err != nil {
+        return err
+    }
+    res, err := c.es.Indices.PutMapping([]string{index}, bytes.NewReader(body), c.es.Indices.PutMapping.WithContext(ctx))
+    if err != nil {
+        return err
+    }
+    defer res.Body.Close()
+    if res.IsError() {
+        return responseError(res, fmt.Sprintf("failed to update mapping of index %s", index))
+    }
+    return nil
+}
+
+// isAlreadyExists reports whether err is the response to creating an index
+// that exists
+func isAlreadyExists(err error) bool {
+    var resErr *ResponseError
+    return errors.As(err, &resErr) && resErr.Type == "resource_already_exists_exception"
+}
+
+// IndexDocument upserts a SearchDocument in its tenant's index, creating
+// the index first if needed. Fields the document doesn't carry, such as the
+// popularity score, are kept. Documents without a tenant belong to the
+// default tenant.
+func (c *Client) IndexDocument(ctx context.Context, doc *models.SearchDocument) error {
+    prepareDocument(doc)
+    index := c.IndexFor(doc.TenantID)
+    if err := c.ensureIndex(ctx, index); err != nil {
+        return err
+    }
+    // Bumped even on failure since the write may have partly applied
+    defer c.bumpGeneration(ctx)
+
+    docJSON, err := upsertBody(doc)
     if err != nil {
         return err
     }
-    req := esapi.IndexRequest{
-        Index:      c.index,
+    req := esapi.UpdateRequest{
+        Index:      index,
         DocumentID: fmt.Sprint(doc.ID),
         Body:       bytes.NewReader(docJSON),
         Refresh:    "true",
     }
-    res, err := req.Do(context.Background(), c.es)
+    res, err := req.Do(ctx, c.es)
     if err != nil {
         return err
     }
     defer res.Body.Close()
     if res.IsError() {
-        return fmt.Errorf("failed to index document: %s", res.Status())
+        return responseError(res, "failed to index document")
     }
     return nil
 }
 
-// DeleteDocument deletes a document by ID from Elasticsearch
-func (c *Client) DeleteDocument(id uint32) error {
+// BulkIndex upserts documents like IndexDocument with one bulk request
+func (c *Client) BulkIndex(ctx context.Context, docs []*models.SearchDocument) error {
+    if len(docs) == 0 {
+        return nil
+    }
+    var body bytes.Buffer
+    for _, doc := range docs {
+        prepareDocument(doc)
+        index := c.IndexFor(doc.TenantID)
+        if err := c.ensureIndex(ctx, index); err != nil {
+            return err
+        }
+        action, err := json.Marshal(map[string]interface{}{
+            "update": map[string]interface{}{"_index": index, "_id": fmt.Sprint(doc.ID)},
+        })
+        if err != nil {
+            return err
+        }
+        docJSON, err := upsertBody(doc)
+        if err != nil {
+            return err
+        }
+        body.Write(action)
+        body.WriteByte('\n')
+        body.Write(docJSON)
+        body.WriteByte('\n')
+    }
+    defer c.bumpGeneration(ctx)
+
+    res, err := c.es.Bulk(&body, c.es.Bulk.WithContext(ctx), c.es.Bulk.WithRefresh("true"))
+    if err != nil {
+        return err
+    }
+    defer res.Body.Close()
+    if res.IsError() {
+        return responseError(res, "bulk indexing failed")
+    }
+    var result struct {
+        Errors bool `json:"errors"`
+        Items  []map[string]struct {
+            ID    string `json:"_id"`
+            Error struct {
+                Reason string `json:"reason"`
+            } `json:"error"`
+        } `json:"items"`
+    }
+    if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+        return err
+    }
+    if result.Errors {
+        for _, item := range result.Items {
+            for _, op := range item {
+                if op.Error.Reason != "" {
+                    return fmt.Errorf("failed to index document %s: %s", op.ID, op.Error.Reason)
+                }
+            }
+        }
+        return fmt.Errorf("bulk indexing failed")
+    }
+    return nil
+}
+
+// prepareDocument fills in the defaults of a document about to be indexed
+func prepareDocument(doc *models.SearchDocument) {
+    if doc.TenantID == "" {
+        doc.TenantID = tenant.Default
+    }
+    if doc.Language == "" {
+        doc.Language = language.Detect(doc.Title + "\n" + doc.Content)
+    }
+}
+
+// upsertBody is the update request body indexing doc. Popularity belongs to
+// the popularity job; it is zeroed so omitempty drops it and the indexed
+// score is kept.
+func upsertBody(doc *models.SearchDocument) ([]byte, error) {
+    upsert := *doc
+    upsert.Popularity = 0
+    return json.Marshal(map[string]interface{}{
+        "doc":           upsert,
+        "doc_as_upsert": true,
+    })
+}
+
+// DeleteDocument deletes a document by ID from its tenant's index
+func (c *Client) DeleteDocument(ctx context.Context, tenantID string, id uint32) error {
+    defer c.bumpGeneration(ctx)
     req := esapi.DeleteRequest{
-        Index:      c.index,
+        Index:      c.IndexFor(tenantID),
         DocumentID: fmt.Sprint(id),
         Refresh:    "true",
     }
-    res, err := req.Do(context.Background(), c.es)
+    res, err := req.Do(ctx, c.es)
     if err != nil {
         return err
     }
     defer res.Body.Close()
     if res.IsError() && res.StatusCode != 404 {
-        return fmt.Errorf("failed to delete document: %s", res.Status())
+        return responseError(res, "failed to delete document")
     }
     return nil
 }
 
-// Search performs a search query with filters and pagination
-func (c *Client) Search(req *models.SearchRequest) ([]models.SearchDocument, int64, error) {
-    queryFields := []string{}
-    switch req.Type {
-    case "title":
-        queryFields = []string{"title"}
-    case "content":
-        queryFields = []string{"content"}
+// buildSearchQuery builds the Elasticsearch query for req. The tenant and
+// ACL filters for identity are always part of the filter context, alongside
+// any optional filters. A nil identity is rejected with ErrNoIdentity and a
+// request without a tenant with ErrNoTenant.
+func buildSearchQuery(req *models.SearchRequest, identity *auth.Identity) (map[string]interface{}, error) {
+    if identity == nil {
+        return nil, ErrNoIdentity
+    }
+    if req.TenantID == "" {
+        return nil, ErrNoTenant
+    }
+
+    // The tenant filter is only strictly needed with shared routing, but
+    // keeping it in per-tenant indexes too costs nothing
+    filters := []interface{}{
+        aclFilter(identity),
+        term("tenant_id", req.TenantID),
+    }
+
+    // Optional author filter
+    if req.Author != "" {
+        filters = append(filters, term("author", req.Author))
+    }
+
+    // Saved searches may filter by author alone
+    match := map[string]interface{}{"match_all": map[string]interface{}{}}
+    switch {
+    case req.Query == "":
+    case req.Lang != "":
+        match = multiMatch(req.Query, queryFields(req.Type, req.Lang))
+        filters = append(filters, term("language", req.Lang))
     default:
-        queryFields = []string{"title^2", "content"}
+        match = anyLanguageMatch(req.Query, req.Type)
     }
 
     esQuery := map[string]interface{}{
         "query": map[string]interface{}{
             "bool": map[string]interface{}{
-                "must": []interface{}{
-                    map[string]interface{}{
-                        "multi_match": map[string]interface{}{
-                            "query":  req.Query,
-                            "fields": queryFields,
-                        },
-                    },
-                },
+                "must":   []interface{}{match},
+                "filter": filters,
             },
         },
         "from": req.Offset,
         "size": req.Limit,
     }
+    return esQuery, nil
+}
 
-    // Optional author filter
-    if req.Author != "" {
-        boolQuery := esQuery["query"].(map[string]interface{})["bool"].(map[string]interface{})
-        boolQuery["filter"] = []interface{}{
-            map[string]interface{}{
-                "term": map[string]interface{}{
-                    "author": req.Author,
-                },
+// queryFields returns the fields searched for a search type ("title",
+// "content" or all) in the sub-fields of lang, or in the standard-analyzed
+// fields if lang is empty
+func queryFields(searchType, lang string) []string {
+    suffix := ""
+    if lang != "" {
+        suffix = "." + lang
+    }
+    switch searchType {
+    case "title":
+        return []string{"title" + suffix}
+    case "content":
+        return []string{"content" + suffix}
+    default:
+        return []string{"title" + suffix + "^2", "content" + suffix}
+    }
+}
+
+func multiMatch(query string, fields []string) map[string]interface{} {
+    return map[string]interface{}{
+        "multi_match": map[string]interface{}{
+            "query":  query,
+            "fields": fields,
+        },
+    }
+}
+
+// anyLanguageMatch matches query against every document in the fields of
+// the document's own language, so each document is scored with its
+// language's stemming and stopwords. Documents indexed before languages
+// were detected have no language and are matched on the standard fields.
+func anyLanguageMatch(query, searchType string) map[string]interface{} {
+    clauses := []interface{}{}
+    for _, lang := range language.Supported {
+        clauses = append(clauses, map[string]interface{}{
+            "bool": map[string]interface{}{
+                "must":   []interface{}{multiMatch(query, queryFields(searchType, lang))},
+                "filter": []interface{}{term("language", lang)},
             },
-        }
+        })
+    }
+    clauses = append(clauses, map[string]interface{}{
+        "bool": map[string]interface{}{
+            "must": []interface{}{multiMatch(query, queryFields(searchType, ""))},
+            "must_not": []interface{}{
+                map[string]interface{}{"exists": map[string]interface{}{"field": "language"}},
+            },
+        },
+    })
+    return map[string]interface{}{
+        "bool": map[string]interface{}{
+            "should":               clauses,
+            "minimum_should_match": 1,
+        },
+    }
+}
+
+// Search performs a search query with filters and pagination. Results are
+// always restricted to req.TenantID and to the documents readable by the
+// identity in ctx; see buildSearchQuery.
+func (c *Client) Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResult, error) {
+    esQuery, err := buildSearchQuery(req, auth.IdentityFromContext(ctx))
+    if err != nil {
+        return nil, err
+    }
+    esQuery = c.ranking.Load().apply(esQuery, req.Rank, req.BoostRecent)
+    if req.Explain {
+        esQuery["explain"] = true
+    }
+    if req.Highlight {
+        esQuery["highlight"] = highlightQuery()
     }
 
     queryJSON, err := json.Marshal(esQuery)
     if err != nil {
-        return nil, 0, err
+        return nil, err
     }
     start := time.Now()
     res, err := c.es.Search(
-        c.es.Search.WithContext(context.Background()),
-        c.es.Search.WithIndex(c.index),
+        c.es.Search.WithContext(ctx),
+        c.es.Search.WithIndex(c.IndexFor(req.TenantID)),
         c.es.Search.WithBody(bytes.NewReader(queryJSON)),
+        // A tenant that has never been written to has no index yet
+        c.es.Search.WithIgnoreUnavailable(true),
     )
+    if c.observeLatency != nil {
+        c.observeLatency(time.Since(start))
+    }
     if err != nil {
-        return nil, 0, err
+        return nil, err
     }
     defer res.Body.Close()
     if res.IsError() {
-        return nil, 0, fmt.Errorf("search failed: %s", res.Status())
+        return nil, responseError(res, "search failed")
     }
-    var result map[string]interface{}
+    var result searchResponse
     if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-        return nil, 0, err
-    }
-    hits, ok := result["hits"].(map[string]interface{})
-    if !ok {
-        return nil, 0, fmt.Errorf("invalid search response format")
-    }
-    hitsList, _ := hits["hits"].([]interface{})
-    docs := make([]models.SearchDocument, 0, len(hitsList))
-    for _, hit := range hitsList {
-        hitMap, _ := hit.(map[string]interface{})
-        source, _ := hitMap["_source"].(map[string]interface{})
-        var doc models.SearchDocument
-        // Map fields from ES source to SearchDocument
-        if id, ok := source["id"].(float64); ok {
-            doc.ID = uint32(id)
-        }
-        if title, ok := source["title"].(string); ok {
-            doc.Title = title
-        }
-        if content, ok := source["content"].(string); ok {
-            doc.Content = content
-        }
-        if author, ok := source["author"].(string); ok {
-            doc.Author = author
-        }
-        if createdAt, ok := source["created_at"].(string); ok {
-            if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
-                doc.CreatedAt = t
-            }
-        }
-        if updatedAt, ok := source["updated_at"].(string); ok {
-            if t, err := time.Parse(time.RFC3339, updatedAt); err == nil {
-                doc.UpdatedAt = t
-            }
-        }
-        docs = append(docs, doc)
+        return nil, fmt.Errorf("invalid search response: %w", err)
     }
-    total := int64(0)
-    if v, ok := hits["total"].(map[string]interface{}); ok {
-        if val, ok := v["value"].(float64); ok {
-            total = int64(val)
-        }
+    docs := make([]models.SearchDocument, 0, len(result.Hits.Hits))
+    for i := range result.Hits.Hits {
+        docs = append(docs, result.Hits.Hits[i].document())
+    }
+    return &models.SearchResult{
+        Documents: docs,
+        Total:     int64(result.Hits.Total),
+        Took:      time.Duration(result.Took) * time.Millisecond,
+    }, nil
+}
+
+// highlightQuery highlights title and content in whichever of their
+// sub-fields the query matched
+func highlightQuery() map[string]interface{} {
+    fields := map[string]interface{}{}
+    for _, field := range []string{"title", "title.*", "content", "content.*"} {
+        fields[field] = map[string]interface{}{}
+    }
+    return map[string]interface{}{
+        "pre_tags":  []string{models.HighlightPreTag},
+        "post_tags": []string{models.HighlightPostTag},
+        "fields":    fields,
     }
-    _ = time.Since(start) // You can use this for took_ms if needed
-    return docs, total, nil
 }
 
-func (c *Client) HealthCheck() error {
-    return c.ping()
+// mergeHighlights merges the fragments of the sub-fields of title and
+// content ("title.en") into their parent field
+func mergeHighlights(highlight map[string][]string) map[string][]string {
+    highlights := map[string][]string{}
+    for field, fragments := range highlight {
+        field, _, _ = strings.Cut(field, ".")
+        highlights[field] = append(highlights[field], fragments...)
+    }
+    return highlights
+}
+
+func (c *Client) HealthCheck(ctx context.Context) error {
+    return c.ping(ctx)
+}
+
+// IndexExists returns an error unless the default tenant's index exists
+func (c *Client) IndexExists(ctx context.Context) error {
+    index := c.IndexFor(tenant.Default)
+    res, err := c.es.Indices.Exists([]string{index}, c.es.Indices.Exists.WithContext(ctx))
+    if err != nil {
+        return err
+    }
+    defer res.Body.Close()
+    if res.StatusCode == 404 {
+        return fmt.Errorf("index %s does not exist", index)
+    }
+    if res.IsError() {
+        return responseError(res, "index check failed")
+    }
+    return nil
 }
\ No newline at end of file
@@ -0,0 +1,233 @@
+// internal/elastic/connection.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"go.opentelemetry.io/otel"
+)
+
+// Connection configures how the client reaches the cluster
+type Connection struct {
+	// Addresses are the URLs of the cluster nodes; requests are spread over
+	// them. CloudID, if set, replaces them.
+	Addresses []string
+	CloudID   string
+	// Flavor is FlavorElasticsearch or FlavorOpenSearch
+	Flavor string
+
+	// APIKey (base64 encoded) takes precedence over Username and Password
+	Username string
+	Password string
+	APIKey   string
+
+	// CACert is a PEM file with the CA certificates to trust, in addition
+	// to the system ones. CertificateFingerprint instead trusts the server
+	// certificate with that SHA-256 fingerprint, or the CA with it, as
+	// printed by Elasticsearch on first start. They can't both be set.
+	CACert                 string
+	CertificateFingerprint string
+	// ClientCert and ClientKey are PEM files for mutual TLS
+	ClientCert string
+	ClientKey  string
+
+	// Requests answered with a RetryOnStatus status, or failing to connect,
+	// are retried up to MaxRetries times on the next node, waiting
+	// RetryBackoff, then twice as long each time, up to maxRetryBackoff.
+	// MaxRetries 0 disables retries.
+	RetryOnStatus []int
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	// Compress gzips request bodies
+	Compress bool
+
+	// ConnectTimeout is how long NewClient waits for the cluster to be
+	// reachable before giving up; 0 tries once
+	ConnectTimeout time.Duration
+}
+
+const maxRetryBackoff = 10 * time.Second
+
+// connectBackoff and maxConnectBackoff space the connection attempts of
+// NewClient
+var (
+	connectBackoff    = time.Second
+	maxConnectBackoff = 10 * time.Second
+)
+
+// errFlavorMismatch is returned by checkFlavor when the cluster is of the
+// other flavor; retrying won't help
+var errFlavorMismatch = errors.New("cluster flavor mismatch")
+
+// esConfig builds the configuration of the underlying client
+func (conn Connection) esConfig() (elasticsearch.Config, error) {
+	cfg := elasticsearch.Config{
+		Addresses:           conn.Addresses,
+		CloudID:             conn.CloudID,
+		Username:            conn.Username,
+		Password:            conn.Password,
+		APIKey:              conn.APIKey,
+		RetryOnStatus:       conn.RetryOnStatus,
+		MaxRetries:          conn.MaxRetries,
+		DisableRetry:        conn.MaxRetries == 0,
+		CompressRequestBody: conn.Compress,
+		Instrumentation:     elasticsearch.NewOpenTelemetryInstrumentation(otel.GetTracerProvider(), false),
+	}
+	if conn.CloudID != "" {
+		cfg.Addresses = nil
+	}
+	if conn.RetryBackoff > 0 {
+		cfg.RetryBackoff = func(attempt int) time.Duration {
+			return backoff(conn.RetryBackoff, attempt, maxRetryBackoff)
+		}
+	}
+
+	transport, err := conn.transport()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Transport = transport
+	return cfg, nil
+}
+
+// transport is the HTTP transport with the TLS settings of conn. The client
+// can set a CA certificate and fingerprint itself, but only on a plain
+// *http.Transport, which the OpenSearch transport isn't.
+func (conn Connection) transport() (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conn.CACert != "" {
+		pem, err := os.ReadFile(conn.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", conn.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conn.CertificateFingerprint != "" {
+		if conn.CACert != "" {
+			return nil, errors.New("a CA certificate and a certificate fingerprint can't both be set")
+		}
+		want, err := hex.DecodeString(strings.ReplaceAll(conn.CertificateFingerprint, ":", ""))
+		if err != nil || len(want) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate fingerprint %q", conn.CertificateFingerprint)
+		}
+		// The chain is checked against the fingerprint instead of the CAs,
+		// which needs the host dialed: the connection state has no server
+		// name for an IP address
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			config := transport.TLSClientConfig.Clone()
+			config.ServerName = host
+			config.InsecureSkipVerify = true
+			config.VerifyConnection = func(state tls.ConnectionState) error {
+				return verifyFingerprint(state, host, want)
+			}
+			tlsDialer := &tls.Dialer{NetDialer: dialer, Config: config}
+			return tlsDialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	if conn.ClientCert != "" || conn.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(conn.ClientCert, conn.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	if conn.Flavor == FlavorOpenSearch {
+		return &openSearchTransport{next: transport}, nil
+	}
+	return transport, nil
+}
+
+// verifyFingerprint accepts a server whose certificate has the SHA-256
+// fingerprint want, or whose certificate was issued for host by the CA in
+// its chain with that fingerprint. Finding the CA in the chain is not
+// enough: anyone can send it along with a certificate of their own.
+func verifyFingerprint(state tls.ConnectionState, host string, want []byte) error {
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("the server sent no certificate")
+	}
+	if sum := sha256.Sum256(certs[0].Raw); bytes.Equal(sum[:], want) {
+		return nil
+	}
+	for _, cert := range certs[1:] {
+		if sum := sha256.Sum256(cert.Raw); !bytes.Equal(sum[:], want) {
+			continue
+		}
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		intermediates := x509.NewCertPool()
+		for _, intermediate := range certs[1:] {
+			intermediates.AddCert(intermediate)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			DNSName:       host,
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			return fmt.Errorf("server certificate was not issued by the CA matching the configured fingerprint: %w", err)
+		}
+		return nil
+	}
+	return errors.New("no server certificate matches the configured fingerprint")
+}
+
+// backoff is base doubled for every attempt after the first, up to limit
+func backoff(base time.Duration, attempt int, limit time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
+
+// connect waits until the cluster answers and is of the configured flavor,
+// retrying with backoff until ctx is done. Without a deadline on ctx it
+// tries once.
+func (c *Client) connect(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		err := c.checkFlavor(ctx)
+		if _, ok := ctx.Deadline(); err == nil || !ok || errors.Is(err, errFlavorMismatch) {
+			return err
+		}
+		wait := backoff(connectBackoff, attempt, maxConnectBackoff)
+		slog.Warn("Cluster not reachable yet, retrying", "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		case <-time.After(wait):
+		}
+	}
+}
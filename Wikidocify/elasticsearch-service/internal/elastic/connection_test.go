@@ -0,0 +1,310 @@
+package elastic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// infoHandler answers the root endpoint like Elasticsearch after failing
+// the first failures requests with 503
+func infoHandler(t *testing.T, failures int32, check func(r *http.Request)) http.Handler {
+	t.Helper()
+	info, err := os.ReadFile(filepath.Join("testdata", FlavorElasticsearch, "info.json"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var requests atomic.Int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(info)
+	})
+}
+
+// connectClient creates a client for conn and connects it like NewClient,
+// without creating any index
+func connectClient(t *testing.T, conn Connection) error {
+	t.Helper()
+	if conn.Flavor == "" {
+		conn.Flavor = FlavorElasticsearch
+	}
+	cfg, err := conn.esConfig()
+	if err != nil {
+		return err
+	}
+	es, err := elasticsearch.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("elasticsearch.NewClient: %v", err)
+	}
+	client := &Client{es: es, flavor: conn.Flavor}
+
+	ctx := context.Background()
+	if conn.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.ConnectTimeout)
+		defer cancel()
+	}
+	return client.connect(ctx)
+}
+
+func fastConnectBackoff(t *testing.T) {
+	base, limit := connectBackoff, maxConnectBackoff
+	connectBackoff, maxConnectBackoff = 10*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { connectBackoff, maxConnectBackoff = base, limit })
+}
+
+func TestConnectRetriesUntilTheDeadline(t *testing.T) {
+	fastConnectBackoff(t)
+
+	server := httptest.NewServer(infoHandler(t, 3, nil))
+	defer server.Close()
+	if err := connectClient(t, Connection{Addresses: []string{server.URL}, ConnectTimeout: 5 * time.Second}); err != nil {
+		t.Fatalf("expected the connection to succeed once the cluster is up, got %v", err)
+	}
+
+	down := httptest.NewServer(infoHandler(t, 1000, nil))
+	defer down.Close()
+	if err := connectClient(t, Connection{Addresses: []string{down.URL}, ConnectTimeout: 100 * time.Millisecond}); err == nil {
+		t.Fatal("expected the connection to fail at the deadline")
+	}
+	if err := connectClient(t, Connection{Addresses: []string{down.URL}}); err == nil {
+		t.Fatal("expected a single failed attempt without a connect timeout")
+	}
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	server := httptest.NewServer(infoHandler(t, 1, nil))
+	defer server.Close()
+	conn := Connection{
+		Addresses:     []string{server.URL},
+		RetryOnStatus: []int{http.StatusServiceUnavailable},
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+	}
+	if err := connectClient(t, conn); err != nil {
+		t.Fatalf("expected the 503 to be retried, got %v", err)
+	}
+}
+
+func TestBackoffDoublesUpToTheLimit(t *testing.T) {
+	for attempt, want := range []time.Duration{100, 100, 200, 400, 500, 500} {
+		if got := backoff(100, attempt, 500); got != want {
+			t.Errorf("backoff(attempt %d) = %d, want %d", attempt, got, want)
+		}
+	}
+}
+
+func TestConnectionAuthentication(t *testing.T) {
+	var authorization atomic.Value
+	server := httptest.NewServer(infoHandler(t, 0, func(r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		conn Connection
+		want string
+	}{
+		{Connection{Username: "elastic", Password: "secret"}, "Basic ZWxhc3RpYzpzZWNyZXQ="},
+		{Connection{Username: "elastic", Password: "secret", APIKey: "a2V5OnNlY3JldA=="}, "APIKey a2V5OnNlY3JldA=="},
+	}
+	for _, tt := range tests {
+		tt.conn.Addresses = []string{server.URL}
+		if err := connectClient(t, tt.conn); err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		if got := authorization.Load(); got != tt.want {
+			t.Errorf("Authorization = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestConnectionTLS(t *testing.T) {
+	server := httptest.NewTLSServer(infoHandler(t, 0, nil))
+	defer server.Close()
+	cert := server.Certificate()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	sum := sha256.Sum256(cert.Raw)
+
+	tests := []struct {
+		name string
+		conn Connection
+		ok   bool
+	}{
+		{"untrusted", Connection{}, false},
+		{"ca cert", Connection{CACert: caFile}, true},
+		{"fingerprint", Connection{CertificateFingerprint: hex.EncodeToString(sum[:])}, true},
+		{"wrong fingerprint", Connection{CertificateFingerprint: hex.EncodeToString(make([]byte, sha256.Size))}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.conn.Addresses = []string{server.URL}
+			err := connectClient(t, tt.conn)
+			if tt.ok && err != nil {
+				t.Fatalf("expected the connection to succeed, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected the connection to fail")
+			}
+		})
+	}
+}
+
+// newCertificate returns a certificate for the hosts, signed by parent or
+// self-signed if parent is nil
+func newCertificate(t *testing.T, hosts []string, ca bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestConnectionCAFingerprint(t *testing.T) {
+	ca, caKey := newCertificate(t, nil, true, nil, nil)
+	other, otherKey := newCertificate(t, nil, true, nil, nil)
+	sum := sha256.Sum256(ca.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name  string
+		chain func() ([]*x509.Certificate, *ecdsa.PrivateKey)
+		ok    bool
+	}{
+		{"issued by the CA", func() ([]*x509.Certificate, *ecdsa.PrivateKey) {
+			leaf, key := newCertificate(t, []string{"127.0.0.1"}, false, ca, caKey)
+			return []*x509.Certificate{leaf, ca}, key
+		}, true},
+		{"issued by the CA for another host", func() ([]*x509.Certificate, *ecdsa.PrivateKey) {
+			leaf, key := newCertificate(t, []string{"elsewhere.example"}, false, ca, caKey)
+			return []*x509.Certificate{leaf, ca}, key
+		}, false},
+		{"CA sent along with a certificate it didn't issue", func() ([]*x509.Certificate, *ecdsa.PrivateKey) {
+			leaf, key := newCertificate(t, []string{"127.0.0.1"}, false, other, otherKey)
+			return []*x509.Certificate{leaf, ca}, key
+		}, false},
+		{"CA missing from the chain", func() ([]*x509.Certificate, *ecdsa.PrivateKey) {
+			leaf, key := newCertificate(t, []string{"127.0.0.1"}, false, ca, caKey)
+			return []*x509.Certificate{leaf}, key
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, key := tt.chain()
+			server := httptest.NewUnstartedServer(infoHandler(t, 0, nil))
+			cert := tls.Certificate{PrivateKey: key}
+			for _, c := range chain {
+				cert.Certificate = append(cert.Certificate, c.Raw)
+			}
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			server.StartTLS()
+			defer server.Close()
+
+			err := connectClient(t, Connection{Addresses: []string{server.URL}, CertificateFingerprint: fingerprint})
+			if tt.ok && err != nil {
+				t.Fatalf("expected the connection to succeed, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected the connection to fail")
+			}
+		})
+	}
+
+	if _, err := (Connection{CACert: "ca.pem", CertificateFingerprint: fingerprint}).transport(); err == nil {
+		t.Error("expected a CA certificate and a fingerprint to be rejected together")
+	}
+}
+
+func TestConnectionCompressesBodies(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err == nil {
+				body, _ = io.ReadAll(reader)
+			}
+		}
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		_, _ = w.Write([]byte(`{"took": 1, "hits": {"total": 0, "hits": []}}`))
+	}))
+	defer server.Close()
+
+	cfg, err := Connection{Addresses: []string{server.URL}, Compress: true}.esConfig()
+	if err != nil {
+		t.Fatalf("esConfig: %v", err)
+	}
+	es, err := elasticsearch.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("elasticsearch.NewClient: %v", err)
+	}
+	res, err := es.Search(es.Search.WithContext(context.Background()), es.Search.WithBody(bytes.NewReader([]byte(`{"size":0}`))))
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	res.Body.Close()
+	if string(body) != `{"size":0}` {
+		t.Fatalf("expected a gzipped body, got %q", body)
+	}
+}
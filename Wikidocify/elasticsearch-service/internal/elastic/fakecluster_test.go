@@ -0,0 +1,641 @@
+package elastic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCluster serves the API calls the client makes from memory. Fixed
+// responses, such as the root endpoint, are recorded from a real cluster of
+// the flavor in testdata/<flavor>. Searches only evaluate the structural
+// queries the client builds (term, terms, exists, bool and match_all), in ID
+// order; full-text matching, analysis, scoring and highlighting are tested
+// against real clusters with `go test -tags integration`.
+//
+// The OpenSearch fake behaves like OpenSearch where it differs from
+// Elasticsearch: it omits the X-Elastic-Product header, rejects the
+// Elasticsearch vendor media types, has no synonyms API and returns
+// hits.total as a plain number.
+type fakeCluster struct {
+	t      *testing.T
+	flavor string
+
+	mu       sync.Mutex
+	indexes  map[string]map[string]map[string]interface{} // index → id → source
+	meta     map[string]interface{}                       // index → mapping _meta
+	aliases  map[string][]string                          // index → aliases
+	synonyms map[string]bool
+	seqNos   map[string]int // "index/id" → _seq_no of documents written with PUT
+	seqNo    int
+}
+
+func newFakeCluster(t *testing.T, flavor string) *httptest.Server {
+	_, server := newFakeClusterWithState(t, flavor)
+	return server
+}
+
+// newFakeClusterWithState returns the fake as well, for tests that set up
+// or inspect what the cluster holds
+func newFakeClusterWithState(t *testing.T, flavor string) (*fakeCluster, *httptest.Server) {
+	t.Helper()
+	f := &fakeCluster{
+		t:        t,
+		flavor:   flavor,
+		indexes:  map[string]map[string]map[string]interface{}{},
+		meta:     map[string]interface{}{},
+		aliases:  map[string][]string{},
+		synonyms: map[string]bool{},
+		seqNos:   map[string]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		f.recorded(w, http.StatusOK, "info.json")
+	})
+	mux.HandleFunc("GET /_synonyms/{set}", f.handleSynonyms)
+	mux.HandleFunc("PUT /_synonyms/{set}", f.handleSynonyms)
+	mux.HandleFunc("HEAD /{index}", f.handleExists)
+	mux.HandleFunc("PUT /{index}", f.handleCreate)
+	mux.HandleFunc("POST /{index}/_update/{id}", f.handleUpdate)
+	mux.HandleFunc("DELETE /{index}/_doc/{id}", f.handleDelete)
+	mux.HandleFunc("PUT /{index}/_create/{id}", f.handlePut)
+	mux.HandleFunc("PUT /{index}/_doc/{id}", f.handlePut)
+	mux.HandleFunc("POST /_bulk", f.handleBulk)
+	mux.HandleFunc("POST /{index}/_search", f.handleSearch)
+	mux.HandleFunc("GET /{index}/_doc/{id}", f.handleGet)
+	mux.HandleFunc("GET /{index}/_stats/{metric}", f.handleStats)
+	// GET /{index}/_mapping, /{index}/_alias and /_alias/{name} overlap
+	// with /_synonyms/{set} as patterns
+	mux.HandleFunc("GET /{index}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.PathValue("index") == "_alias":
+			f.handleGetAliases(w, r, "", r.PathValue("action"))
+		case r.PathValue("action") == "_alias":
+			f.handleGetAliases(w, r, r.PathValue("index"), "")
+		case r.PathValue("action") == "_mapping":
+			f.handleGetMapping(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("POST /{index}/_refresh", f.handleMaintenance)
+	mux.HandleFunc("POST /{index}/_forcemerge", f.handleMaintenance)
+	mux.HandleFunc("POST /{index}/_count", f.handleByQuery)
+	mux.HandleFunc("POST /{index}/_delete_by_query", f.handleByQuery)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.flavor == FlavorOpenSearch {
+			for _, header := range []string{"Content-Type", "Accept"} {
+				if strings.Contains(r.Header.Get(header), "vnd.elasticsearch") {
+					f.recorded(w, http.StatusNotAcceptable, "not_acceptable.json")
+					return
+				}
+			}
+		} else {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+// recorded writes the recorded response in testdata/<flavor>/name
+func (f *fakeCluster) recorded(w http.ResponseWriter, status int, name string) {
+	body, err := os.ReadFile(filepath.Join("testdata", f.flavor, name))
+	if err != nil {
+		f.t.Errorf("missing recorded response: %v", err)
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func (f *fakeCluster) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeCluster) handleSynonyms(w http.ResponseWriter, r *http.Request) {
+	if f.flavor == FlavorOpenSearch {
+		f.recorded(w, http.StatusBadRequest, "no_handler.json")
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	set := r.PathValue("set")
+	switch r.Method {
+	case http.MethodGet:
+		if !f.synonyms[set] {
+			f.recorded(w, http.StatusNotFound, "synonyms_not_found.json")
+			return
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{"count": 0, "synonyms_set": []interface{}{}})
+	case http.MethodPut:
+		f.synonyms[set] = true
+		f.reply(w, http.StatusOK, map[string]interface{}{"result": "created"})
+	}
+}
+
+func (f *fakeCluster) handleExists(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.indexes[r.PathValue("index")]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeCluster) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.reply(w, http.StatusBadRequest, errorBody("parse_exception", err.Error()))
+		return
+	}
+	// OpenSearch has no synonyms sets to refer to
+	if settings, _ := json.Marshal(body["settings"]); f.flavor == FlavorOpenSearch && strings.Contains(string(settings), "synonyms_set") {
+		f.reply(w, http.StatusBadRequest, errorBody("illegal_argument_exception", "unknown setting [synonyms_set]"))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := r.PathValue("index")
+	if _, ok := f.indexes[index]; ok {
+		f.reply(w, http.StatusBadRequest, errorBody("resource_already_exists_exception", "index ["+index+"] already exists"))
+		return
+	}
+	f.indexes[index] = map[string]map[string]interface{}{}
+	if mappings, ok := body["mappings"].(map[string]interface{}); ok && mappings["_meta"] != nil {
+		f.meta[index] = mappings["_meta"]
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{"acknowledged": true, "index": index})
+}
+
+func errorBody(errorType, reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"error":  map[string]interface{}{"type": errorType, "reason": reason},
+		"status": http.StatusBadRequest,
+	}
+}
+
+// upsert applies an update request body with doc_as_upsert, keeping the
+// fields the update doesn't set. f.mu must be held.
+func (f *fakeCluster) upsert(index, id string, body []byte) error {
+	var update struct {
+		Doc         map[string]interface{} `json:"doc"`
+		DocAsUpsert bool                   `json:"doc_as_upsert"`
+	}
+	if err := json.Unmarshal(body, &update); err != nil {
+		return err
+	}
+	docs, ok := f.indexes[index]
+	if !ok {
+		return fmt.Errorf("no such index [%s]", index)
+	}
+	source, ok := docs[id]
+	if !ok {
+		if !update.DocAsUpsert {
+			return fmt.Errorf("document [%s] missing", id)
+		}
+		source = map[string]interface{}{}
+		docs[id] = source
+	}
+	for field, value := range update.Doc {
+		source[field] = value
+	}
+	return nil
+}
+
+func (f *fakeCluster) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.reply(w, http.StatusBadRequest, errorBody("parse_exception", err.Error()))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.upsert(r.PathValue("index"), r.PathValue("id"), body); err != nil {
+		f.reply(w, http.StatusNotFound, errorBody("document_missing_exception", err.Error()))
+		return
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{"_id": r.PathValue("id"), "result": "updated"})
+}
+
+func (f *fakeCluster) handleDelete(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	docs := f.indexes[r.PathValue("index")]
+	if _, ok := docs[r.PathValue("id")]; !ok {
+		f.reply(w, http.StatusNotFound, map[string]interface{}{"_id": r.PathValue("id"), "result": "not_found"})
+		return
+	}
+	delete(docs, r.PathValue("id"))
+	f.reply(w, http.StatusOK, map[string]interface{}{"_id": r.PathValue("id"), "result": "deleted"})
+}
+
+func (f *fakeCluster) handleBulk(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, 1<<20)
+	var items []interface{}
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || action["update"].ID == "" || !scanner.Scan() {
+			f.reply(w, http.StatusBadRequest, errorBody("illegal_argument_exception", "malformed bulk request"))
+			return
+		}
+		update := action["update"]
+		item := map[string]interface{}{"_index": update.Index, "_id": update.ID, "status": http.StatusOK}
+		if err := f.upsert(update.Index, update.ID, scanner.Bytes()); err != nil {
+			item["status"] = http.StatusNotFound
+			item["error"] = map[string]interface{}{"type": "document_missing_exception", "reason": err.Error()}
+		}
+		items = append(items, map[string]interface{}{"update": item})
+	}
+	errors := false
+	for _, item := range items {
+		if _, failed := item.(map[string]interface{})["update"].(map[string]interface{})["error"]; failed {
+			errors = true
+		}
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{"took": 1, "errors": errors, "items": items})
+}
+
+func (f *fakeCluster) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Query       map[string]interface{} `json:"query"`
+		From        int                    `json:"from"`
+		Size        int                    `json:"size"`
+		SearchAfter []int                  `json:"search_after"` // only sorting by ID is supported
+		Sort        []interface{}          `json:"sort"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.reply(w, http.StatusBadRequest, errorBody("parse_exception", err.Error()))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	docs, ok := f.indexes[r.PathValue("index")]
+	if !ok && r.URL.Query().Get("ignore_unavailable") != "true" {
+		f.reply(w, http.StatusNotFound, errorBody("index_not_found_exception", "no such index"))
+		return
+	}
+
+	type hit struct {
+		id     string
+		source map[string]interface{}
+	}
+	var hits []hit
+	for id, source := range docs {
+		if n, _ := strconv.Atoi(id); len(body.SearchAfter) > 0 && n <= body.SearchAfter[0] {
+			continue
+		}
+		if evaluate(body.Query, source) {
+			hits = append(hits, hit{id, source})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, _ := strconv.Atoi(hits[i].id)
+		b, _ := strconv.Atoi(hits[j].id)
+		return a < b
+	})
+	sortHits(body.Sort, func(i int) map[string]interface{} { return hits[i].source }, func(less func(i, j int) bool) {
+		sort.SliceStable(hits, less)
+	})
+
+	page := []interface{}{}
+	for i := body.From; i < len(hits) && i < body.From+body.Size; i++ {
+		page = append(page, map[string]interface{}{
+			"_index":  r.PathValue("index"),
+			"_id":     hits[i].id,
+			"_score":  nil,
+			"_source": hits[i].source,
+		})
+	}
+
+	var total interface{} = map[string]interface{}{"value": len(hits), "relation": "eq"}
+	if f.flavor == FlavorOpenSearch {
+		total = len(hits)
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{
+		"took":      1,
+		"timed_out": false,
+		"hits":      map[string]interface{}{"total": total, "hits": page},
+	})
+}
+
+// sortHits applies the first sort clause, {"field": "asc|desc"}, unless it
+// sorts by ID, which is the order hits are in already
+func sortHits(clauses []interface{}, source func(i int) map[string]interface{}, sortBy func(less func(i, j int) bool)) {
+	if len(clauses) == 0 {
+		return
+	}
+	clause, _ := clauses[0].(map[string]interface{})
+	for field, order := range clause {
+		if field == "id" || field == "_score" {
+			return
+		}
+		desc := order == "desc"
+		sortBy(func(i, j int) bool {
+			a, b := source(i)[field], source(j)[field]
+			if desc {
+				a, b = b, a
+			}
+			x, xNumber := a.(float64)
+			y, yNumber := b.(float64)
+			if xNumber && yNumber {
+				return x < y
+			}
+			// Dates are RFC 3339 strings in UTC, which sort as text
+			return fmt.Sprint(a) < fmt.Sprint(b)
+		})
+	}
+}
+
+func (f *fakeCluster) indexNotFound(w http.ResponseWriter, index string) {
+	body := errorBody("index_not_found_exception", "no such index ["+index+"]")
+	body["status"] = http.StatusNotFound
+	f.reply(w, http.StatusNotFound, body)
+}
+
+func (f *fakeCluster) handleGet(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index, id := r.PathValue("index"), r.PathValue("id")
+	source, ok := f.indexes[index][id]
+	if !ok {
+		f.reply(w, http.StatusNotFound, map[string]interface{}{"_index": index, "_id": id, "found": false})
+		return
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{
+		"_index":        index,
+		"_id":           id,
+		"found":         true,
+		"_seq_no":       f.seqNos[index+"/"+id],
+		"_primary_term": 1,
+		"_source":       source,
+	})
+}
+
+// handlePut stores a whole document, creating the index if needed. Like
+// Elasticsearch, _create refuses to overwrite a document and if_seq_no and
+// if_primary_term make the write conditional on the last one.
+func (f *fakeCluster) handlePut(w http.ResponseWriter, r *http.Request) {
+	var source map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&source); err != nil {
+		f.reply(w, http.StatusBadRequest, errorBody("parse_exception", err.Error()))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index, id := r.PathValue("index"), r.PathValue("id")
+	if f.indexes[index] == nil {
+		f.indexes[index] = map[string]map[string]interface{}{}
+	}
+	_, exists := f.indexes[index][id]
+	conflict := strings.HasSuffix(r.URL.Path, "/_create/"+id) && exists
+	if seqNo := r.URL.Query().Get("if_seq_no"); seqNo != "" {
+		conflict = !exists || seqNo != strconv.Itoa(f.seqNos[index+"/"+id]) || r.URL.Query().Get("if_primary_term") != "1"
+	}
+	if conflict {
+		body := errorBody("version_conflict_engine_exception", "["+id+"]: version conflict")
+		body["status"] = http.StatusConflict
+		f.reply(w, http.StatusConflict, body)
+		return
+	}
+	f.seqNo++
+	f.seqNos[index+"/"+id] = f.seqNo
+	f.indexes[index][id] = source
+	f.reply(w, http.StatusOK, map[string]interface{}{"_id": id, "_seq_no": f.seqNo, "_primary_term": 1, "result": "updated"})
+}
+
+// handleStats reports one segment per index holding documents and the size
+// of their sources as the store size, with no replicas
+func (f *fakeCluster) handleStats(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := r.PathValue("index")
+	docs, ok := f.indexes[index]
+	if !ok {
+		f.indexNotFound(w, index)
+		return
+	}
+	size := 0
+	for _, source := range docs {
+		data, _ := json.Marshal(source)
+		size += len(data)
+	}
+	totals := map[string]interface{}{
+		"docs":     map[string]interface{}{"count": len(docs), "deleted": 0},
+		"store":    map[string]interface{}{"size_in_bytes": size},
+		"segments": map[string]interface{}{"count": min(len(docs), 1)},
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{
+		"_all":    map[string]interface{}{"primaries": totals, "total": totals},
+		"indices": map[string]interface{}{index: map[string]interface{}{"primaries": totals, "total": totals}},
+	})
+}
+
+func (f *fakeCluster) handleGetMapping(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := r.PathValue("index")
+	if _, ok := f.indexes[index]; !ok {
+		f.indexNotFound(w, index)
+		return
+	}
+	mappings := map[string]interface{}{}
+	if meta, ok := f.meta[index]; ok {
+		mappings["_meta"] = meta
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{index: map[string]interface{}{"mappings": mappings}})
+}
+
+// handleGetAliases serves the aliases of index, or the indexes having one
+// of the comma-separated aliases in name
+func (f *fakeCluster) handleGetAliases(w http.ResponseWriter, r *http.Request, index, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	if name != "" {
+		names = strings.Split(name, ",")
+	}
+	if index != "" {
+		if _, ok := f.indexes[index]; !ok {
+			f.indexNotFound(w, index)
+			return
+		}
+	}
+
+	body := map[string]interface{}{}
+	for name := range f.indexes {
+		if index != "" && name != index {
+			continue
+		}
+		aliases := map[string]interface{}{}
+		for _, alias := range f.aliases[name] {
+			if names == nil || slices.Contains(names, alias) {
+				aliases[alias] = map[string]interface{}{}
+			}
+		}
+		if names == nil || len(aliases) > 0 {
+			body[name] = map[string]interface{}{"aliases": aliases}
+		}
+	}
+	if len(body) == 0 {
+		f.reply(w, http.StatusNotFound, map[string]interface{}{"error": "alias [" + name + "] missing", "status": 404})
+		return
+	}
+	f.reply(w, http.StatusOK, body)
+}
+
+func (f *fakeCluster) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := r.PathValue("index")
+	if _, ok := f.indexes[index]; !ok {
+		f.indexNotFound(w, index)
+		return
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{"_shards": map[string]interface{}{"total": 1, "successful": 1, "failed": 0}})
+}
+
+// handleByQuery counts, or deletes for _delete_by_query, the documents
+// matching the query
+func (f *fakeCluster) handleByQuery(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Query map[string]interface{} `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		f.reply(w, http.StatusBadRequest, errorBody("parse_exception", err.Error()))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	index := r.PathValue("index")
+	docs, ok := f.indexes[index]
+	if !ok {
+		f.indexNotFound(w, index)
+		return
+	}
+	matched := 0
+	for id, source := range docs {
+		if evaluate(body.Query, source) {
+			matched++
+			if strings.HasSuffix(r.URL.Path, "/_delete_by_query") {
+				delete(docs, id)
+			}
+		}
+	}
+	if strings.HasSuffix(r.URL.Path, "/_count") {
+		f.reply(w, http.StatusOK, map[string]interface{}{"count": matched})
+		return
+	}
+	f.reply(w, http.StatusOK, map[string]interface{}{"deleted": matched, "total": matched, "failures": []interface{}{}})
+}
+
+// evaluate reports whether source matches query. It covers the structural
+// queries the admin, generation, lease and sync run calls make; anything
+// else, full-text queries included, belongs in the integration suite.
+func evaluate(query map[string]interface{}, source map[string]interface{}) bool {
+	for kind, body := range query {
+		clause, _ := body.(map[string]interface{})
+		switch kind {
+		case "match_all":
+			return true
+		case "term":
+			for field, value := range clause {
+				return hasValue(source[field], value)
+			}
+		case "terms":
+			for field, values := range clause {
+				for _, value := range values.([]interface{}) {
+					if hasValue(source[field], value) {
+						return true
+					}
+				}
+			}
+			return false
+		case "exists":
+			_, ok := source[clause["field"].(string)]
+			return ok
+		case "bool":
+			return evaluateBool(clause, source)
+		default:
+			panic("fake cluster: unsupported query " + kind + "; test it against a real cluster in integration_test.go")
+		}
+	}
+	return false
+}
+
+func evaluateBool(clause map[string]interface{}, source map[string]interface{}) bool {
+	clauses := func(occur string) []map[string]interface{} {
+		items, _ := clause[occur].([]interface{})
+		var out []map[string]interface{}
+		for _, item := range items {
+			out = append(out, item.(map[string]interface{}))
+		}
+		return out
+	}
+
+	for _, occur := range []string{"must", "filter"} {
+		for _, q := range clauses(occur) {
+			if !evaluate(q, source) {
+				return false
+			}
+		}
+	}
+	for _, q := range clauses("must_not") {
+		if evaluate(q, source) {
+			return false
+		}
+	}
+
+	should := clauses("should")
+	minimum := 0
+	if len(clauses("must")) == 0 && len(clauses("filter")) == 0 && len(should) > 0 {
+		minimum = 1
+	}
+	if v, ok := clause["minimum_should_match"].(float64); ok {
+		minimum = int(v)
+	}
+	matched := 0
+	for _, q := range should {
+		if evaluate(q, source) {
+			matched++
+		}
+	}
+	return matched >= minimum
+}
+
+// hasValue reports whether a source field, which may be an array, holds value
+func hasValue(field, value interface{}) bool {
+	if values, ok := field.([]interface{}); ok {
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+	return field == value
+}
@@ -0,0 +1,112 @@
+// internal/elastic/flavor.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Cluster flavors the client can talk to
+const (
+	FlavorElasticsearch = "elasticsearch"
+	// FlavorOpenSearch is an OpenSearch 1.x or 2.x cluster. OpenSearch has
+	// no synonyms API, so synonyms management is unavailable.
+	FlavorOpenSearch = "opensearch"
+)
+
+// ErrSynonymsUnsupported is returned by PutSynonyms on OpenSearch
+var ErrSynonymsUnsupported = errors.New("synonyms management requires Elasticsearch")
+
+// Flavor returns the flavor of the cluster the client talks to
+func (c *Client) Flavor() string {
+	if c.flavor == "" {
+		return FlavorElasticsearch
+	}
+	return c.flavor
+}
+
+// SupportsSynonyms reports whether the cluster has the synonyms API used by
+// PutSynonyms
+func (c *Client) SupportsSynonyms() bool {
+	return c.Flavor() == FlavorElasticsearch
+}
+
+// openSearchTransport lets the Elasticsearch client talk to OpenSearch. The
+// client refuses any server whose responses lack the X-Elastic-Product
+// header, and OpenSearch rejects the Elasticsearch vendor media types the
+// client sends in compatibility mode.
+type openSearchTransport struct {
+	next http.RoundTripper
+}
+
+func (t *openSearchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for _, header := range []string{"Content-Type", "Accept"} {
+		if strings.HasPrefix(req.Header.Get(header), "application/vnd.elasticsearch+json") {
+			req.Header.Set(header, "application/json")
+		}
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Header.Set("X-Elastic-Product", "Elasticsearch")
+	return res, nil
+}
+
+// checkFlavor verifies that the cluster is of the configured flavor, since
+// the transport hides what OpenSearch is from the client
+func (c *Client) checkFlavor(ctx context.Context) error {
+	res, err := c.es.Info(c.es.Info.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, "elasticsearch ping failed")
+	}
+	var info struct {
+		Version struct {
+			Distribution string `json:"distribution"`
+			Number       string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return fmt.Errorf("invalid cluster info: %w", err)
+	}
+	isOpenSearch := info.Version.Distribution == FlavorOpenSearch
+	if isOpenSearch != (c.Flavor() == FlavorOpenSearch) {
+		return fmt.Errorf("%w: cluster version %s %s doesn't match flavor %s", errFlavorMismatch,
+			info.Version.Distribution, info.Version.Number, c.Flavor())
+	}
+	return nil
+}
+
+// totalHits decodes hits.total: an object ({"value": n}), or a plain number
+// with rest_total_hits_as_int and on older releases
+type totalHits int64
+
+func (t *totalHits) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var total struct {
+			Value int64 `json:"value"`
+		}
+		if err := json.Unmarshal(data, &total); err != nil {
+			return err
+		}
+		*t = totalHits(total.Value)
+		return nil
+	}
+	var value int64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid hits.total: %s", data)
+	}
+	*t = totalHits(value)
+	return nil
+}
@@ -0,0 +1,76 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNewClientRejectsOtherFlavor(t *testing.T) {
+	tests := []struct {
+		cluster, flavor string
+	}{
+		{FlavorElasticsearch, FlavorOpenSearch},
+		{FlavorOpenSearch, FlavorElasticsearch},
+	}
+	for _, tt := range tests {
+		server := newFakeCluster(t, tt.cluster)
+		if _, err := NewClient(Connection{Addresses: []string{server.URL}, Flavor: tt.flavor}, "test-index", RoutingShared); err == nil {
+			t.Errorf("expected a %s client to refuse %s", tt.flavor, tt.cluster)
+		}
+	}
+	if _, err := NewClient(Connection{Addresses: []string{"http://localhost:9200"}, Flavor: "solr"}, "test-index", RoutingShared); err == nil {
+		t.Error("expected an unknown flavor to be rejected")
+	}
+}
+
+func TestOpenSearchHasNoSynonyms(t *testing.T) {
+	server := newFakeCluster(t, FlavorOpenSearch)
+	client, err := NewClient(Connection{Addresses: []string{server.URL}, Flavor: FlavorOpenSearch}, "test-index", RoutingShared)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if client.SupportsSynonyms() {
+		t.Fatal("OpenSearch client claims synonyms support")
+	}
+	if err := client.PutSynonyms(context.Background(), []string{"k8s, kubernetes"}); !errors.Is(err, ErrSynonymsUnsupported) {
+		t.Fatalf("expected ErrSynonymsUnsupported, got %v", err)
+	}
+}
+
+func TestTotalHitsFormats(t *testing.T) {
+	tests := []struct {
+		body string
+		want totalHits
+	}{
+		{`{"value": 42, "relation": "eq"}`, 42},
+		{`42`, 42},
+	}
+	for _, tt := range tests {
+		var total totalHits
+		if err := json.Unmarshal([]byte(tt.body), &total); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", tt.body, err)
+		}
+		if total != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.body, total, tt.want)
+		}
+	}
+	var total totalHits
+	if err := json.Unmarshal([]byte(`"many"`), &total); err == nil {
+		t.Error("expected an invalid total to be rejected")
+	}
+}
+
+func TestOpenSearchWithCompatibilityHeaders(t *testing.T) {
+	// Makes the client send the Elasticsearch vendor media types
+	t.Setenv("ELASTIC_CLIENT_APIVERSIONING", "true")
+	server := newFakeCluster(t, FlavorOpenSearch)
+	client, err := NewClient(Connection{Addresses: []string{server.URL}, Flavor: FlavorOpenSearch}, "test-index", RoutingShared)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.IndexExists(context.Background()); err != nil {
+		t.Fatalf("IndexExists: %v", err)
+	}
+}
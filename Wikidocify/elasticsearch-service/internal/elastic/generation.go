@@ -0,0 +1,92 @@
+// internal/elastic/generation.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// generationDocID is the document holding the generation in the
+// generation index
+const generationDocID = "generation"
+
+// generationMapping matches the generation document
+var generationMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"generation": map[string]interface{}{"type": "long", "index": false},
+		},
+	},
+}
+
+// generationIndex holds the generation shared by every replica writing to
+// the index
+func (c *Client) generationIndex() string {
+	return c.index + "_generation"
+}
+
+// Generation returns a value that changes after every document write by
+// any replica. Anything derived from search results, such as cached
+// results, is stale once the generation has moved on. It is read from the
+// cluster, so a write consumed by one replica invalidates the results
+// cached by all of them.
+func (c *Client) Generation(ctx context.Context) (uint64, error) {
+	res, err := c.es.Get(c.generationIndex(), generationDocID, c.es.Get.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return 0, nil
+	}
+	if res.IsError() {
+		return 0, responseError(res, "failed to get generation")
+	}
+	var doc struct {
+		Source struct {
+			Generation uint64 `json:"generation"`
+		} `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return 0, fmt.Errorf("failed to decode generation: %w", err)
+	}
+	return doc.Source.Generation, nil
+}
+
+// bumpGeneration moves the generation on after a write. It replaces the
+// generation with a random value rather than incrementing it, so
+// concurrent writers never conflict; a value is never reused, which is all
+// readers compare. A failure is logged, and results cached since the last
+// generation may be served until they expire.
+func (c *Client) bumpGeneration(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	// Positive, to fit a long
+	body, err := json.Marshal(map[string]interface{}{"generation": rand.Uint64() >> 1})
+	if err != nil {
+		return
+	}
+	req := esapi.IndexRequest{
+		Index:      c.generationIndex(),
+		DocumentID: generationDocID,
+		Body:       bytes.NewReader(body),
+	}
+	res, err := req.Do(ctx, c.es)
+	if err == nil {
+		defer res.Body.Close()
+		if res.IsError() {
+			err = responseError(res, "failed to update generation")
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to move the index generation on; cached results may be stale until they expire", "error", err)
+	}
+}
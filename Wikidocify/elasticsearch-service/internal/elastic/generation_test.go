@@ -0,0 +1,30 @@
+package elastic
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGenerationIsSharedByReplicas(t *testing.T) {
+	replicaA, _ := newAdminTestClient(t, RoutingShared)
+	// Another replica on the same cluster, sharing nothing with the first
+	// in process
+	replicaB := &Client{es: replicaA.es, flavor: replicaA.flavor, index: replicaA.index, routing: replicaA.routing}
+	ctx := context.Background()
+
+	before, err := replicaB.Generation(ctx)
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+	indexTestDocument(t, replicaA, 1, "acme", "alice")
+	after, err := replicaB.Generation(ctx)
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+	if after == before {
+		t.Fatal("expected a write by one replica to move the generation of the other")
+	}
+	if a, _ := replicaA.Generation(ctx); a != after {
+		t.Errorf("expected both replicas to see generation %d, replica A saw %d", after, a)
+	}
+}
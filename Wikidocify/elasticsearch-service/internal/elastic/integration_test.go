@@ -0,0 +1,266 @@
+//go:build integration
+
+package elastic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/backend/backendtest"
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcelasticsearch "github.com/testcontainers/testcontainers-go/modules/elasticsearch"
+	tcopensearch "github.com/testcontainers/testcontainers-go/modules/opensearch"
+)
+
+// The integration suite runs the client against a real cluster of each
+// flavor, started with Docker:
+//
+//	go test -tags integration ./internal/elastic/
+//
+// It covers what the fake cluster doesn't evaluate: analysis, full-text
+// matching, scoring, highlighting, percolation and synonyms.
+
+// Images of the clusters the suite runs against; Elasticsearch matches
+// docker-compose.yml
+const (
+	elasticsearchImage = "docker.elastic.co/elasticsearch/elasticsearch:8.11.0"
+	opensearchImage    = "opensearchproject/opensearch:2.11.1"
+)
+
+// clusters holds the connection to each flavor's container
+var clusters = map[string]Connection{}
+
+// indexes numbers the index of each test, so every test starts empty
+var indexes atomic.Int32
+
+func TestMain(m *testing.M) {
+	os.Exit(runIntegration(m))
+}
+
+func runIntegration(m *testing.M) int {
+	ctx := context.Background()
+	dir, err := os.MkdirTemp("", "wikidocify-integration")
+	if err != nil {
+		log.Printf("failed to create temp dir: %v", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	es, err := tcelasticsearch.Run(ctx, elasticsearchImage)
+	defer testcontainers.TerminateContainer(es)
+	if err != nil {
+		log.Printf("failed to start Elasticsearch: %v", err)
+		return 1
+	}
+	caCert := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caCert, es.Settings.CACert, 0o600); err != nil {
+		log.Printf("failed to write the CA certificate: %v", err)
+		return 1
+	}
+	clusters[FlavorElasticsearch] = Connection{
+		Addresses: []string{es.Settings.Address},
+		Flavor:    FlavorElasticsearch,
+		Username:  es.Settings.Username,
+		Password:  es.Settings.Password,
+		CACert:    caCert,
+	}
+
+	opensearch, err := tcopensearch.Run(ctx, opensearchImage)
+	defer testcontainers.TerminateContainer(opensearch)
+	if err != nil {
+		log.Printf("failed to start OpenSearch: %v", err)
+		return 1
+	}
+	address, err := opensearch.Address(ctx)
+	if err != nil {
+		log.Printf("failed to get the OpenSearch address: %v", err)
+		return 1
+	}
+	clusters[FlavorOpenSearch] = Connection{
+		Addresses: []string{address},
+		Flavor:    FlavorOpenSearch,
+	}
+
+	return m.Run()
+}
+
+// newIntegrationClient returns a client of flavor's cluster using an index
+// no other test uses
+func newIntegrationClient(t *testing.T, flavor string) *Client {
+	t.Helper()
+	index := fmt.Sprintf("integration-%d", indexes.Add(1))
+	client, err := NewClient(clusters[flavor], index, RoutingShared)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.SetRanking(DefaultRanking)
+	return client
+}
+
+// forEachFlavor runs fn against each flavor's cluster
+func forEachFlavor(t *testing.T, fn func(t *testing.T, flavor string)) {
+	for _, flavor := range []string{FlavorElasticsearch, FlavorOpenSearch} {
+		t.Run(flavor, func(t *testing.T) {
+			fn(t, flavor)
+		})
+	}
+}
+
+func integrationSearch(t *testing.T, client *Client, req *models.SearchRequest) *models.SearchResult {
+	t.Helper()
+	if req.TenantID == "" {
+		req.TenantID = "default"
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+	result, err := client.Search(ctx, req)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	return result
+}
+
+func TestIntegrationBackend(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, flavor string) {
+		backendtest.Run(t, func(t *testing.T) backend.SearchBackend {
+			return newIntegrationClient(t, flavor)
+		})
+	})
+}
+
+func TestIntegrationLanguageAnalysis(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, flavor string) {
+		client := newIntegrationClient(t, flavor)
+		docs := []*models.SearchDocument{
+			{ID: 1, Title: "Die Häuser am See", Content: "Die Häuser werden im Sommer vermietet.", Language: "de", Visibility: models.VisibilityPublic},
+			{ID: 2, Title: "Running the release", Content: "How the release is run.", Language: "en", Visibility: models.VisibilityPublic},
+		}
+		if err := client.BulkIndex(context.Background(), docs); err != nil {
+			t.Fatalf("BulkIndex: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			req  *models.SearchRequest
+			want []uint32
+		}{
+			// "Häuser" is stemmed to "haus" by the German sub-field only
+			{"german stem", &models.SearchRequest{Query: "Haus", Lang: "de"}, []uint32{1}},
+			{"german stem in any language", &models.SearchRequest{Query: "Haus"}, []uint32{1}},
+			{"english stem", &models.SearchRequest{Query: "runs"}, []uint32{2}},
+			{"other language", &models.SearchRequest{Query: "Haus", Lang: "en"}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result := integrationSearch(t, client, tt.req)
+				var got []uint32
+				for _, doc := range result.Documents {
+					got = append(got, doc.ID)
+				}
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("expected %v, got %v", tt.want, got)
+				}
+			})
+		}
+	})
+}
+
+func TestIntegrationHighlightAndExplain(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, flavor string) {
+		client := newIntegrationClient(t, flavor)
+		doc := &models.SearchDocument{ID: 1, Title: "Roadmap 2025", Content: "The roadmap for next year.", Language: "en", Visibility: models.VisibilityPublic}
+		if err := client.IndexDocument(context.Background(), doc); err != nil {
+			t.Fatalf("IndexDocument: %v", err)
+		}
+
+		result := integrationSearch(t, client, &models.SearchRequest{Query: "roadmap", Highlight: true, Explain: true})
+		if len(result.Documents) != 1 {
+			t.Fatalf("expected one hit, got %+v", result.Documents)
+		}
+		hit := result.Documents[0]
+		want := models.HighlightPreTag + "Roadmap" + models.HighlightPostTag
+		if len(hit.Highlights["title"]) == 0 || !strings.Contains(hit.Highlights["title"][0], want) {
+			t.Errorf("expected %q in the title highlight, got %v", want, hit.Highlights)
+		}
+		if hit.Explanation == nil || hit.Explanation.Value <= 0 {
+			t.Errorf("expected a score explanation, got %+v", hit.Explanation)
+		}
+	})
+}
+
+func TestIntegrationPercolate(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, flavor string) {
+		client := newIntegrationClient(t, flavor)
+		ctx := context.Background()
+		index, alertsIndex := client.index+"-saved", client.index+"-alerts"
+		if err := client.EnsureSavedSearchIndexes(ctx, index, alertsIndex); err != nil {
+			t.Fatalf("EnsureSavedSearchIndexes: %v", err)
+		}
+		saved := &models.SavedSearch{ID: "s1", TenantID: "acme", Owner: "alice", OwnerMethod: "jwt", Name: "Roadmaps", Query: "roadmap"}
+		if err := client.PutSavedSearch(ctx, index, saved); err != nil {
+			t.Fatalf("PutSavedSearch: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			doc  *models.SearchDocument
+			want int
+		}{
+			{"match", &models.SearchDocument{ID: 1, TenantID: "acme", Title: "Roadmaps for 2025", Visibility: models.VisibilityPublic}, 1},
+			{"other tenant", &models.SearchDocument{ID: 2, TenantID: "globex", Title: "Roadmap", Visibility: models.VisibilityPublic}, 0},
+			{"not visible", &models.SearchDocument{ID: 3, TenantID: "acme", Title: "Roadmap", Visibility: models.VisibilityPrivate, Owner: "bob"}, 0},
+			{"no match", &models.SearchDocument{ID: 4, TenantID: "acme", Title: "Release notes", Visibility: models.VisibilityPublic}, 0},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				prepareDocument(tt.doc)
+				matches, err := client.Percolate(ctx, index, tt.doc)
+				if err != nil {
+					t.Fatalf("Percolate: %v", err)
+				}
+				if len(matches) != tt.want {
+					t.Errorf("expected %d matches, got %+v", tt.want, matches)
+				}
+			})
+		}
+	})
+}
+
+func TestIntegrationSynonyms(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, flavor string) {
+		client := newIntegrationClient(t, flavor)
+		ctx := context.Background()
+		err := client.PutSynonyms(ctx, []string{"k8s, kubernetes"})
+		if flavor == FlavorOpenSearch {
+			if !errors.Is(err, ErrSynonymsUnsupported) {
+				t.Fatalf("expected ErrSynonymsUnsupported, got %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("PutSynonyms: %v", err)
+		}
+		t.Cleanup(func() { _ = client.PutSynonyms(ctx, nil) })
+
+		doc := &models.SearchDocument{ID: 1, Title: "Upgrading Kubernetes", Language: "en", Visibility: models.VisibilityPublic}
+		if err := client.IndexDocument(ctx, doc); err != nil {
+			t.Fatalf("IndexDocument: %v", err)
+		}
+		if result := integrationSearch(t, client, &models.SearchRequest{Query: "k8s"}); len(result.Documents) != 1 {
+			t.Errorf("expected the synonym to match, got %+v", result.Documents)
+		}
+	})
+}
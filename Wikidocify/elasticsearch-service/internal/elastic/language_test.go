@@ -0,0 +1,98 @@
+package elastic
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/language"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestQueryFields(t *testing.T) {
+	tests := []struct {
+		searchType, lang string
+		want             []string
+	}{
+		{"", "", []string{"title^2", "content"}},
+		{"title", "", []string{"title"}},
+		{"content", "", []string{"content"}},
+		{"", "de", []string{"title.de^2", "content.de"}},
+		{"title", "ar", []string{"title.ar"}},
+		{"content", "en", []string{"content.en"}},
+	}
+	for _, tt := range tests {
+		if got := queryFields(tt.searchType, tt.lang); !slices.Equal(got, tt.want) {
+			t.Errorf("queryFields(%q, %q): expected %v, got %v", tt.searchType, tt.lang, tt.want, got)
+		}
+	}
+}
+
+func TestAnyLanguageMatch(t *testing.T) {
+	tests := []struct {
+		searchType string
+		want       []string // one clause per supported language, then the fallback
+	}{
+		{"", []string{
+			`{"bool":{"filter":[{"term":{"language":"en"}}],"must":[{"multi_match":{"fields":["title.en^2","content.en"],"query":"roadmap"}}]}}`,
+			`{"bool":{"filter":[{"term":{"language":"de"}}],"must":[{"multi_match":{"fields":["title.de^2","content.de"],"query":"roadmap"}}]}}`,
+			`{"bool":{"filter":[{"term":{"language":"ar"}}],"must":[{"multi_match":{"fields":["title.ar^2","content.ar"],"query":"roadmap"}}]}}`,
+			`{"bool":{"must":[{"multi_match":{"fields":["title^2","content"],"query":"roadmap"}}],"must_not":[{"exists":{"field":"language"}}]}}`,
+		}},
+		{"title", []string{
+			`{"bool":{"filter":[{"term":{"language":"en"}}],"must":[{"multi_match":{"fields":["title.en"],"query":"roadmap"}}]}}`,
+			`{"bool":{"filter":[{"term":{"language":"de"}}],"must":[{"multi_match":{"fields":["title.de"],"query":"roadmap"}}]}}`,
+			`{"bool":{"filter":[{"term":{"language":"ar"}}],"must":[{"multi_match":{"fields":["title.ar"],"query":"roadmap"}}]}}`,
+			`{"bool":{"must":[{"multi_match":{"fields":["title"],"query":"roadmap"}}],"must_not":[{"exists":{"field":"language"}}]}}`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run("type "+tt.searchType, func(t *testing.T) {
+			match := anyLanguageMatch("roadmap", tt.searchType)["bool"].(map[string]interface{})
+			if match["minimum_should_match"] != 1 {
+				t.Errorf("expected one clause to be required, got %v", match["minimum_should_match"])
+			}
+			clauses := match["should"].([]interface{})
+			if len(clauses) != len(language.Supported)+1 {
+				t.Fatalf("expected a clause per language and a fallback, got %s", marshal(t, clauses))
+			}
+			for i, want := range tt.want {
+				if got := marshal(t, clauses[i]); got != want {
+					t.Errorf("clause %d: expected\n%s\ngot\n%s", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSearchQueryLanguage(t *testing.T) {
+	identity := &auth.Identity{Subject: "alice", Method: "jwt"}
+	languageFilter := `{"term":{"language":"de"}}`
+	tests := []struct {
+		name           string
+		req            *models.SearchRequest
+		wantMust       string
+		languageFilter bool
+	}{
+		{"every language", &models.SearchRequest{Query: "roadmap", TenantID: "acme"}, marshal(t, anyLanguageMatch("roadmap", "")), false},
+		{"one language", &models.SearchRequest{Query: "Fahrplan", Lang: "de", TenantID: "acme"},
+			`{"multi_match":{"fields":["title.de^2","content.de"],"query":"Fahrplan"}}`, true},
+		{"no query", &models.SearchRequest{Author: "alice", Lang: "de", TenantID: "acme"}, `{"match_all":{}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			esQuery, err := buildSearchQuery(tt.req, identity)
+			if err != nil {
+				t.Fatalf("buildSearchQuery: %v", err)
+			}
+			query := esQuery["query"].(map[string]interface{})["bool"].(map[string]interface{})
+			if got := marshal(t, query["must"]); got != "["+tt.wantMust+"]" {
+				t.Errorf("expected must [%s], got %s", tt.wantMust, got)
+			}
+			if filters := marshal(t, query["filter"]); strings.Contains(filters, languageFilter) != tt.languageFilter {
+				t.Errorf("expected language filter %v, got %s", tt.languageFilter, filters)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// internal/elastic/lease.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ErrLeaseConflict is returned when another replica wrote the lease since
+// it was read
+var ErrLeaseConflict = errors.New("lease was changed by another replica")
+
+// leaseMapping matches models.Lease
+var leaseMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"name":        map[string]interface{}{"type": "keyword"},
+			"holder":      map[string]interface{}{"type": "keyword"},
+			"acquired_at": map[string]interface{}{"type": "date"},
+			"renewed_at":  map[string]interface{}{"type": "date"},
+			"expires_at":  map[string]interface{}{"type": "date"},
+		},
+	},
+}
+
+// leaseVersion identifies the revision of a lease document that was read,
+// for optimistic concurrency control
+type leaseVersion struct {
+	SeqNo       int
+	PrimaryTerm int
+}
+
+// EnsureLeaseIndex creates the lease index if it doesn't exist
+func (c *Client) EnsureLeaseIndex(ctx context.Context, index string) error {
+	return c.ensureAppendOnlyIndex(ctx, index, leaseMapping)
+}
+
+// AcquireLease takes the lease name for holder until ttl from now, or renews
+// it if holder has it already. A lease held by another replica is left
+// alone until it expires. The lease is returned as it stands afterwards, so
+// holder has it only if the returned holder is holder; it is nil if no
+// replica holds it.
+//
+// Every write is conditional on the sequence number and primary term that
+// were read, so of two replicas racing for an expired lease only one wins.
+// Expiry is judged by each replica's own clock, so the TTL must be well
+// above the clock skew between replicas.
+func (c *Client) AcquireLease(ctx context.Context, index, name, holder string, ttl time.Duration) (*models.Lease, error) {
+	lease, version, err := c.getLease(ctx, index, name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if lease != nil && lease.Holder != holder && now.Before(lease.ExpiresAt) {
+		return lease, nil
+	}
+
+	next := &models.Lease{
+		Name:       name,
+		Holder:     holder,
+		AcquiredAt: now,
+		RenewedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if lease != nil && lease.Holder == holder && now.Before(lease.ExpiresAt) {
+		next.AcquiredAt = lease.AcquiredAt
+	}
+	err = c.putLease(ctx, index, next, version)
+	if errors.Is(err, ErrLeaseConflict) {
+		// Another replica got there first; report what it wrote
+		lease, _, err = c.getLease(ctx, index, name)
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil && !now.Before(lease.ExpiresAt) {
+			return nil, nil
+		}
+		return lease, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// ReleaseLease expires the lease name now if holder has it, so another
+// replica can take it without waiting for the TTL
+func (c *Client) ReleaseLease(ctx context.Context, index, name, holder string) error {
+	lease, version, err := c.getLease(ctx, index, name)
+	if err != nil || lease == nil || lease.Holder != holder {
+		return err
+	}
+	lease.ExpiresAt = time.Now().UTC()
+	if err := c.putLease(ctx, index, lease, version); err != nil && !errors.Is(err, ErrLeaseConflict) {
+		return err
+	}
+	return nil
+}
+
+// getLease returns the lease name and its version, or nil if it was never
+// taken
+func (c *Client) getLease(ctx context.Context, index, name string) (*models.Lease, *leaseVersion, error) {
+	res, err := c.es.Get(index, name, c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil, nil
+	}
+	if res.IsError() {
+		return nil, nil, responseError(res, "failed to get lease")
+	}
+	var result struct {
+		SeqNo       int          `json:"_seq_no"`
+		PrimaryTerm int          `json:"_primary_term"`
+		Source      models.Lease `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, nil, err
+	}
+	return &result.Source, &leaseVersion{SeqNo: result.SeqNo, PrimaryTerm: result.PrimaryTerm}, nil
+}
+
+// putLease writes lease if it is still at version, or creates it if version
+// is nil. It returns ErrLeaseConflict if another replica wrote it first.
+func (c *Client) putLease(ctx context.Context, index string, lease *models.Lease, version *leaseVersion) error {
+	body, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	var res *esapi.Response
+	if version == nil {
+		req := esapi.CreateRequest{
+			Index:      index,
+			DocumentID: lease.Name,
+			Body:       bytes.NewReader(body),
+		}
+		res, err = req.Do(ctx, c.es)
+	} else {
+		req := esapi.IndexRequest{
+			Index:         index,
+			DocumentID:    lease.Name,
+			Body:          bytes.NewReader(body),
+			IfSeqNo:       &version.SeqNo,
+			IfPrimaryTerm: &version.PrimaryTerm,
+		}
+		res, err = req.Do(ctx, c.es)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 409 {
+		return ErrLeaseConflict
+	}
+	if res.IsError() {
+		return responseError(res, "failed to write lease")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestAcquireLease(t *testing.T) {
+	client, fake := newAdminTestClient(t, RoutingShared)
+	ctx := context.Background()
+	if err := client.EnsureLeaseIndex(ctx, "leases"); err != nil {
+		t.Fatalf("EnsureLeaseIndex: %v", err)
+	}
+
+	lease, err := client.AcquireLease(ctx, "leases", "sync", "replica-a", time.Minute)
+	if err != nil || lease == nil || lease.Holder != "replica-a" {
+		t.Fatalf("expected replica-a to take the free lease, got %+v, %v", lease, err)
+	}
+	acquired := lease.AcquiredAt
+
+	lease, err = client.AcquireLease(ctx, "leases", "sync", "replica-b", time.Minute)
+	if err != nil || lease == nil || lease.Holder != "replica-a" {
+		t.Fatalf("expected replica-a to keep its lease, got %+v, %v", lease, err)
+	}
+
+	lease, err = client.AcquireLease(ctx, "leases", "sync", "replica-a", time.Minute)
+	if err != nil || lease.Holder != "replica-a" || !lease.AcquiredAt.Equal(acquired) || !lease.RenewedAt.After(acquired) {
+		t.Fatalf("expected replica-a to renew its lease, got %+v, %v", lease, err)
+	}
+
+	// replica-a dies and its lease runs out
+	fake.mu.Lock()
+	fake.indexes["leases"]["sync"]["expires_at"] = time.Now().Add(-time.Second).Format(time.RFC3339Nano)
+	fake.mu.Unlock()
+	lease, err = client.AcquireLease(ctx, "leases", "sync", "replica-b", time.Minute)
+	if err != nil || lease.Holder != "replica-b" {
+		t.Fatalf("expected replica-b to take the expired lease, got %+v, %v", lease, err)
+	}
+
+	if err := client.ReleaseLease(ctx, "leases", "sync", "replica-a"); err != nil {
+		t.Fatalf("ReleaseLease: %v", err)
+	}
+	if lease, _ := client.AcquireLease(ctx, "leases", "sync", "replica-a", time.Minute); lease.Holder != "replica-b" {
+		t.Fatalf("a replica released a lease it didn't hold, now held by %s", lease.Holder)
+	}
+	if err := client.ReleaseLease(ctx, "leases", "sync", "replica-b"); err != nil {
+		t.Fatalf("ReleaseLease: %v", err)
+	}
+	if lease, _ := client.AcquireLease(ctx, "leases", "sync", "replica-a", time.Minute); lease.Holder != "replica-a" {
+		t.Fatalf("expected replica-a to take the released lease, got %s", lease.Holder)
+	}
+}
+
+func TestPutLeaseIsConditional(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingShared)
+	ctx := context.Background()
+	lease := &models.Lease{Name: "sync", Holder: "replica-a", ExpiresAt: time.Now().Add(time.Minute)}
+
+	if err := client.putLease(ctx, "leases", lease, nil); err != nil {
+		t.Fatalf("putLease: %v", err)
+	}
+	if err := client.putLease(ctx, "leases", lease, nil); !errors.Is(err, ErrLeaseConflict) {
+		t.Fatalf("expected creating an existing lease to conflict, got %v", err)
+	}
+
+	_, version, err := client.getLease(ctx, "leases", "sync")
+	if err != nil || version == nil {
+		t.Fatalf("getLease: %v, %v", version, err)
+	}
+	// Both replicas read the same version; only the first write succeeds
+	if err := client.putLease(ctx, "leases", &models.Lease{Name: "sync", Holder: "replica-b"}, version); err != nil {
+		t.Fatalf("putLease: %v", err)
+	}
+	if err := client.putLease(ctx, "leases", &models.Lease{Name: "sync", Holder: "replica-c"}, version); !errors.Is(err, ErrLeaseConflict) {
+		t.Fatalf("expected a write of a stale version to conflict, got %v", err)
+	}
+	if lease, _, _ := client.getLease(ctx, "leases", "sync"); lease.Holder != "replica-b" {
+		t.Fatalf("expected replica-b to hold the lease, got %s", lease.Holder)
+	}
+}
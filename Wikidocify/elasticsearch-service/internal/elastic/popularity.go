@@ -0,0 +1,299 @@
+// internal/elastic/popularity.go
+package elastic
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// maxPopularDocuments bounds the number of documents per tenant that get a
+// popularity score in one run; the most clicked ones are kept
+const maxPopularDocuments = 10000
+
+// clickPageSize is the number of distinct clicks fetched, and of searches
+// looked up, per request
+const clickPageSize = 1000
+
+// clicksMapping matches models.ClickEvent
+var clicksMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"timestamp":   map[string]interface{}{"type": "date"},
+			"tenant_id":   map[string]interface{}{"type": "keyword"},
+			"query_id":    map[string]interface{}{"type": "keyword"},
+			"document_id": map[string]interface{}{"type": "keyword"},
+			"position":    map[string]interface{}{"type": "integer"},
+			"caller":      map[string]interface{}{"type": "keyword"},
+		},
+	},
+}
+
+// click is a distinct click: a caller clicking the same result of the same
+// search again is one click
+type click struct {
+	TenantID   string `json:"tenant_id"`
+	QueryID    string `json:"query_id"`
+	DocumentID string `json:"document_id"`
+	Caller     string `json:"caller"`
+}
+
+// recordedSearch is the part of a models.SearchEvent clicks are checked
+// against
+type recordedSearch struct {
+	QueryID   string   `json:"query_id"`
+	TenantID  string   `json:"tenant_id"`
+	Caller    string   `json:"caller"`
+	ResultIDs []uint32 `json:"result_ids"`
+}
+
+// ClickCounts returns the number of clicks per tenant and document since
+// the given time. Only clicks on a result of a search recorded in
+// searchesIndex for the same caller and tenant count, and each caller counts
+// once per search and document, so callers can't raise the popularity of
+// documents they never saw by posting clicks.
+func (c *Client) ClickCounts(ctx context.Context, clicksIndex, searchesIndex string, since time.Time) (map[string]map[uint32]int64, error) {
+	clicks, err := c.distinctClicks(ctx, clicksIndex, since)
+	if err != nil {
+		return nil, err
+	}
+
+	queryIDs := make([]string, 0, len(clicks))
+	seen := make(map[string]bool, len(clicks))
+	for _, cl := range clicks {
+		if !seen[cl.QueryID] {
+			seen[cl.QueryID] = true
+			queryIDs = append(queryIDs, cl.QueryID)
+		}
+	}
+	searches := make(map[string]recordedSearch, len(queryIDs))
+	for batch := range slices.Chunk(queryIDs, clickPageSize) {
+		if err := c.recordedSearches(ctx, searchesIndex, batch, searches); err != nil {
+			return nil, err
+		}
+	}
+	return countClicks(clicks, searches), nil
+}
+
+// countClicks counts the clicks on a result of their search per tenant and
+// document, keeping the maxPopularDocuments most clicked documents of each
+// tenant
+func countClicks(clicks []click, searches map[string]recordedSearch) map[string]map[uint32]int64 {
+	counts := make(map[string]map[uint32]int64)
+	for _, cl := range clicks {
+		id, err := strconv.ParseUint(cl.DocumentID, 10, 32)
+		if err != nil {
+			continue
+		}
+		search, ok := searches[cl.QueryID]
+		if !ok || search.TenantID != cl.TenantID || search.Caller != cl.Caller || !slices.Contains(search.ResultIDs, uint32(id)) {
+			continue
+		}
+		if counts[cl.TenantID] == nil {
+			counts[cl.TenantID] = make(map[uint32]int64)
+		}
+		counts[cl.TenantID][uint32(id)]++
+	}
+
+	for tenantID, docs := range counts {
+		if len(docs) <= maxPopularDocuments {
+			continue
+		}
+		ids := slices.Collect(maps.Keys(docs))
+		slices.SortFunc(ids, func(a, b uint32) int { return cmp.Compare(docs[b], docs[a]) })
+		kept := make(map[uint32]int64, maxPopularDocuments)
+		for _, id := range ids[:maxPopularDocuments] {
+			kept[id] = docs[id]
+		}
+		counts[tenantID] = kept
+	}
+	return counts
+}
+
+// distinctClicks pages through the distinct clicks since the given time
+// with a composite aggregation, which unlike nested terms aggregations
+// stays within search.max_buckets however many clicks there are
+func (c *Client) distinctClicks(ctx context.Context, clicksIndex string, since time.Time) ([]click, error) {
+	sources := []interface{}{}
+	for _, field := range []string{"tenant_id", "query_id", "document_id", "caller"} {
+		sources = append(sources, map[string]interface{}{
+			field: map[string]interface{}{"terms": map[string]interface{}{"field": field}},
+		})
+	}
+
+	var clicks []click
+	var after json.RawMessage
+	for {
+		composite := map[string]interface{}{"size": clickPageSize, "sources": sources}
+		if after != nil {
+			composite["after"] = after
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"size": 0,
+			"query": map[string]interface{}{
+				"range": map[string]interface{}{
+					"timestamp": map[string]interface{}{"gte": since.Format(time.RFC3339)},
+				},
+			},
+			"aggs": map[string]interface{}{
+				"clicks": map[string]interface{}{"composite": composite},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		res, err := c.es.Search(
+			c.es.Search.WithContext(ctx),
+			c.es.Search.WithIndex(clicksIndex),
+			c.es.Search.WithBody(bytes.NewReader(body)),
+			c.es.Search.WithIgnoreUnavailable(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Aggregations struct {
+				Clicks struct {
+					AfterKey json.RawMessage `json:"after_key"`
+					Buckets  []struct {
+						Key click `json:"key"`
+					} `json:"buckets"`
+				} `json:"clicks"`
+			} `json:"aggregations"`
+		}
+		if res.IsError() {
+			err = responseError(res, "click aggregation failed")
+		} else {
+			err = json.NewDecoder(res.Body).Decode(&result)
+		}
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range result.Aggregations.Clicks.Buckets {
+			clicks = append(clicks, bucket.Key)
+		}
+		if len(result.Aggregations.Clicks.Buckets) < clickPageSize || result.Aggregations.Clicks.AfterKey == nil {
+			return clicks, nil
+		}
+		after = result.Aggregations.Clicks.AfterKey
+	}
+}
+
+// recordedSearches adds the searches with the given IDs to searches
+func (c *Client) recordedSearches(ctx context.Context, searchesIndex string, queryIDs []string, searches map[string]recordedSearch) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"size":    len(queryIDs),
+		"query":   map[string]interface{}{"terms": map[string]interface{}{"query_id": queryIDs}},
+		"_source": []string{"query_id", "tenant_id", "caller", "result_ids"},
+	})
+	if err != nil {
+		return err
+	}
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(searchesIndex),
+		c.es.Search.WithBody(bytes.NewReader(body)),
+		c.es.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, "failed to look up searches")
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source recordedSearch `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	for _, hit := range result.Hits.Hits {
+		searches[hit.Source.QueryID] = hit.Source
+	}
+	return nil
+}
+
+// UpdatePopularity writes scores onto tenantID's indexed documents and
+// resets the score of every other document that still has one. Documents
+// that no longer exist are skipped.
+func (c *Client) UpdatePopularity(ctx context.Context, tenantID string, scores map[uint32]float64) error {
+	index := c.IndexFor(tenantID)
+	defer c.bumpGeneration(ctx)
+
+	if len(scores) > 0 {
+		var body bytes.Buffer
+		for id, score := range scores {
+			fmt.Fprintf(&body, `{"update":{"_id":"%d"}}`+"\n", id)
+			if err := json.NewEncoder(&body).Encode(map[string]interface{}{
+				"doc": map[string]interface{}{"popularity": score},
+			}); err != nil {
+				return err
+			}
+		}
+		res, err := c.es.Bulk(&body, c.es.Bulk.WithContext(ctx), c.es.Bulk.WithIndex(index))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.IsError() {
+			return responseError(res, "failed to update popularity")
+		}
+		// Per-item errors are expected for deleted documents (404) and ignored
+	}
+
+	// Reset documents that dropped out of the click window
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	filters := []interface{}{
+		map[string]interface{}{"range": map[string]interface{}{"popularity": map[string]interface{}{"gt": 0}}},
+	}
+	if c.routing != RoutingIndex {
+		filters = append(filters, term("tenant_id", tenantID))
+	}
+	reset := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter":   filters,
+				"must_not": []interface{}{map[string]interface{}{"ids": map[string]interface{}{"values": ids}}},
+			},
+		},
+		"script": map[string]interface{}{
+			"source": "ctx._source.popularity = 0",
+			"lang":   "painless",
+		},
+	}
+	body, err := json.Marshal(reset)
+	if err != nil {
+		return err
+	}
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{index},
+		Body:      bytes.NewReader(body),
+		Conflicts: "proceed",
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return responseError(res, "failed to reset popularity")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCountClicks(t *testing.T) {
+	searches := map[string]recordedSearch{
+		"q1": {QueryID: "q1", TenantID: "acme", Caller: "alice", ResultIDs: []uint32{1, 2}},
+		"q2": {QueryID: "q2", TenantID: "acme", Caller: "bob", ResultIDs: []uint32{1}},
+	}
+	tests := []struct {
+		name   string
+		clicks []click
+		want   map[string]map[uint32]int64
+	}{
+		{
+			name:   "clicks on results",
+			clicks: []click{{"acme", "q1", "1", "alice"}, {"acme", "q1", "2", "alice"}, {"acme", "q2", "1", "bob"}},
+			want:   map[string]map[uint32]int64{"acme": {1: 2, 2: 1}},
+		},
+		{
+			name:   "unknown search",
+			clicks: []click{{"acme", "forged", "1", "alice"}},
+			want:   map[string]map[uint32]int64{},
+		},
+		{
+			name:   "another caller's search",
+			clicks: []click{{"acme", "q1", "1", "mallory"}},
+			want:   map[string]map[uint32]int64{},
+		},
+		{
+			name:   "document not in the results",
+			clicks: []click{{"acme", "q2", "2", "bob"}},
+			want:   map[string]map[uint32]int64{},
+		},
+		{
+			name:   "another tenant",
+			clicks: []click{{"globex", "q1", "1", "alice"}},
+			want:   map[string]map[uint32]int64{},
+		},
+		{
+			name:   "malformed document ID",
+			clicks: []click{{"acme", "q1", "x", "alice"}},
+			want:   map[string]map[uint32]int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countClicks(tt.clicks, searches)
+			if !maps.EqualFunc(got, tt.want, func(a, b map[uint32]int64) bool { return maps.Equal(a, b) }) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClickCountsPagesThroughDistinctClicks(t *testing.T) {
+	// Canned responses: two pages of distinct clicks, then the searches
+	var mu sync.Mutex
+	var requests []map[string]interface{}
+	client := newCannedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, body)
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/clicks/_search" && !strings.Contains(marshal(t, body), `"after"`):
+			buckets := make([]string, clickPageSize)
+			for i := range buckets {
+				buckets[i] = `{"key": {"tenant_id": "acme", "query_id": "q1", "document_id": "1", "caller": "alice"}, "doc_count": 3}`
+			}
+			_, _ = io.WriteString(w, `{"aggregations": {"clicks": {"after_key": {"tenant_id": "acme", "query_id": "q1", "document_id": "1", "caller": "alice"}, "buckets": [`+strings.Join(buckets, ",")+`]}}}`)
+		case r.URL.Path == "/clicks/_search":
+			_, _ = io.WriteString(w, `{"aggregations": {"clicks": {"buckets": [
+				{"key": {"tenant_id": "acme", "query_id": "q2", "document_id": "2", "caller": "bob"}, "doc_count": 1},
+				{"key": {"tenant_id": "acme", "query_id": "q3", "document_id": "2", "caller": "bob"}, "doc_count": 1}
+			]}}}`)
+		case r.URL.Path == "/searches/_search":
+			_, _ = io.WriteString(w, `{"hits": {"hits": [
+				{"_source": {"query_id": "q1", "tenant_id": "acme", "caller": "alice", "result_ids": [1]}},
+				{"_source": {"query_id": "q2", "tenant_id": "acme", "caller": "bob", "result_ids": [1, 2]}}
+			]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	counts, err := client.ClickCounts(context.Background(), "clicks", "searches", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ClickCounts: %v", err)
+	}
+	// q1 repeats one click, counted once per bucket; q3 was never recorded
+	want := map[uint32]int64{1: clickPageSize, 2: 1}
+	if !maps.Equal(counts["acme"], want) {
+		t.Errorf("got %v, want %v", counts["acme"], want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 3 {
+		t.Fatalf("expected two click pages and one search lookup, got %d requests", len(requests))
+	}
+	if !strings.Contains(marshal(t, requests[1]), `"after":{"caller":"alice"`) {
+		t.Errorf("expected the second page to continue after the first, got %s", marshal(t, requests[1]))
+	}
+	if lookup := marshal(t, requests[2]); !strings.Contains(lookup, `"q1","q2","q3"`) {
+		t.Errorf("expected every query ID to be looked up once, got %s", lookup)
+	}
+}
+
+func marshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(b)
+}
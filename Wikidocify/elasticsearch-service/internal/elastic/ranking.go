@@ -0,0 +1,104 @@
+// internal/elastic/ranking.go
+package elastic
+
+// Ranking modes
+const (
+	// RankRelevance ranks by BM25 text relevance only
+	RankRelevance = "relevance"
+	// RankBlended adds popularity and recency boosts to BM25 with function_score
+	RankBlended = "blended"
+)
+
+// Ranking configures the function_score boosts added to BM25. In blended
+// mode the score is
+//
+//	bm25 + PopularityWeight * log1p(PopularityFactor * popularity)
+//	     + RecencyWeight * gauss(updated_at)
+//
+// where the gauss decay is 1 at RecencyOrigin and drops to RecencyDecay at
+// RecencyScale from it. BoostMode controls how the boosts are combined with
+// BM25 ("sum" as above, or "multiply", ...).
+//
+// The recency decay can also be used on its own: it applies in blended mode
+// or when BoostRecent is set, and a request's boost_recent overrides both.
+type Ranking struct {
+	DefaultMode      string
+	PopularityWeight float64
+	PopularityFactor float64
+	BoostRecent      bool
+	RecencyWeight    float64
+	RecencyOrigin    string // Elasticsearch date or date math, e.g. "now"
+	RecencyScale     string // Elasticsearch duration, e.g. "30d"
+	RecencyDecay     float64
+	BoostMode        string
+}
+
+// DefaultRanking ranks by relevance unless a request asks for blended
+var DefaultRanking = Ranking{
+	DefaultMode:      RankRelevance,
+	PopularityWeight: 1,
+	PopularityFactor: 1,
+	RecencyWeight:    1,
+	RecencyOrigin:    "now",
+	RecencyScale:     "30d",
+	RecencyDecay:     0.5,
+	BoostMode:        "sum",
+}
+
+// SetRanking replaces the ranking configuration. It is safe to call while
+// searches run, which then use either the old or the new configuration.
+func (c *Client) SetRanking(r Ranking) {
+	c.ranking.Store(&r)
+}
+
+// apply wraps the query of esQuery in a function_score query with the boosts
+// selected by mode (or the default mode, if mode is empty) and boostRecent.
+// Filters stay inside the wrapped query, so ranking never changes which
+// documents match.
+func (r Ranking) apply(esQuery map[string]interface{}, mode string, boostRecent *bool) map[string]interface{} {
+	if mode == "" {
+		mode = r.DefaultMode
+	}
+	recent := mode == RankBlended || r.BoostRecent
+	if boostRecent != nil {
+		recent = *boostRecent
+	}
+
+	var functions []interface{}
+	if mode == RankBlended {
+		functions = append(functions, map[string]interface{}{
+			"field_value_factor": map[string]interface{}{
+				"field":    "popularity",
+				"factor":   r.PopularityFactor,
+				"modifier": "log1p",
+				"missing":  0,
+			},
+			"weight": r.PopularityWeight,
+		})
+	}
+	if recent {
+		functions = append(functions, map[string]interface{}{
+			"gauss": map[string]interface{}{
+				"updated_at": map[string]interface{}{
+					"origin": r.RecencyOrigin,
+					"scale":  r.RecencyScale,
+					"decay":  r.RecencyDecay,
+				},
+			},
+			"weight": r.RecencyWeight,
+		})
+	}
+	if len(functions) == 0 {
+		return esQuery
+	}
+
+	esQuery["query"] = map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query":      esQuery["query"],
+			"functions":  functions,
+			"score_mode": "sum",
+			"boost_mode": r.BoostMode,
+		},
+	}
+	return esQuery
+}
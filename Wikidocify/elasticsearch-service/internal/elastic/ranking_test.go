@@ -0,0 +1,144 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestRankingApply(t *testing.T) {
+	yes, no := true, false
+	boostRecent := DefaultRanking
+	boostRecent.BoostRecent = true
+	blendedByDefault := DefaultRanking
+	blendedByDefault.DefaultMode = RankBlended
+	blendedByDefault.BoostMode = "multiply"
+
+	tests := []struct {
+		name          string
+		ranking       Ranking
+		mode          string
+		boostRecent   *bool
+		wantFunctions []string // function names, in order; none leaves the query alone
+		wantBoostMode string
+	}{
+		{name: "relevance by default", ranking: DefaultRanking},
+		{name: "relevance", ranking: blendedByDefault, mode: RankRelevance},
+		{name: "blended", ranking: DefaultRanking, mode: RankBlended, wantFunctions: []string{"field_value_factor", "gauss"}, wantBoostMode: "sum"},
+		{name: "blended by default", ranking: blendedByDefault, wantFunctions: []string{"field_value_factor", "gauss"}, wantBoostMode: "multiply"},
+		{name: "recency configured", ranking: boostRecent, wantFunctions: []string{"gauss"}, wantBoostMode: "sum"},
+		{name: "recency requested", ranking: DefaultRanking, boostRecent: &yes, wantFunctions: []string{"gauss"}, wantBoostMode: "sum"},
+		{name: "recency turned off", ranking: DefaultRanking, mode: RankBlended, boostRecent: &no, wantFunctions: []string{"field_value_factor"}, wantBoostMode: "sum"},
+		{name: "recency turned off by request", ranking: boostRecent, boostRecent: &no},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{term("tenant_id", "acme")}}}
+			esQuery := tt.ranking.apply(map[string]interface{}{"query": original, "size": 10}, tt.mode, tt.boostRecent)
+
+			if esQuery["size"] != 10 {
+				t.Errorf("expected the rest of the request to be kept, got %v", esQuery)
+			}
+			if len(tt.wantFunctions) == 0 {
+				if !reflect.DeepEqual(esQuery["query"], original) {
+					t.Errorf("expected the query to be left alone, got %s", marshal(t, esQuery["query"]))
+				}
+				return
+			}
+			functionScore := esQuery["query"].(map[string]interface{})["function_score"].(map[string]interface{})
+			if !reflect.DeepEqual(functionScore["query"], original) {
+				t.Errorf("expected the filters inside the wrapped query, got %s", marshal(t, functionScore["query"]))
+			}
+			if functionScore["boost_mode"] != tt.wantBoostMode || functionScore["score_mode"] != "sum" {
+				t.Errorf("unexpected modes: %s", marshal(t, functionScore))
+			}
+			functions := functionScore["functions"].([]interface{})
+			if len(functions) != len(tt.wantFunctions) {
+				t.Fatalf("expected functions %v, got %s", tt.wantFunctions, marshal(t, functions))
+			}
+			for i, name := range tt.wantFunctions {
+				if _, ok := functions[i].(map[string]interface{})[name]; !ok {
+					t.Errorf("expected %s at %d, got %s", name, i, marshal(t, functions[i]))
+				}
+			}
+		})
+	}
+}
+
+func TestRankingFunctions(t *testing.T) {
+	r := Ranking{
+		PopularityWeight: 2,
+		PopularityFactor: 0.5,
+		RecencyWeight:    3,
+		RecencyOrigin:    "2025-01-01",
+		RecencyScale:     "7d",
+		RecencyDecay:     0.25,
+		BoostMode:        "sum",
+	}
+	esQuery := r.apply(map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}, RankBlended, nil)
+	got := marshal(t, esQuery["query"].(map[string]interface{})["function_score"].(map[string]interface{})["functions"])
+	want := `[{"field_value_factor":{"factor":0.5,"field":"popularity","missing":0,"modifier":"log1p"},"weight":2},` +
+		`{"gauss":{"updated_at":{"decay":0.25,"origin":"2025-01-01","scale":"7d"}},"weight":3}]`
+	if got != want {
+		t.Errorf("expected functions\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestSearchExplain(t *testing.T) {
+	explanation := `{"value": 1.5, "description": "sum of:", "details": [` +
+		`{"value": 1.2, "description": "weight(title:roadmap)"}, {"value": 0.3, "description": "function score"}]}`
+	var requests []map[string]interface{}
+	client := newCannedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		hit := `{"_index": "test-index", "_id": "1", "_score": 1.5, "_source": {"id": 1, "title": "Roadmap", "tenant_id": "default"}`
+		if body["explain"] == true {
+			hit += `, "_explanation": ` + explanation
+		}
+		_, _ = io.WriteString(w, `{"took": 1, "hits": {"total": {"value": 1}, "hits": [`+hit+`}]}}`)
+	})
+	client.SetRanking(DefaultRanking)
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+
+	tests := []struct {
+		name    string
+		explain bool
+		want    string
+	}{
+		{"explained", true, `"explanation":{"value":1.5,"description":"sum of:","details":[` +
+			`{"value":1.2,"description":"weight(title:roadmap)"},{"value":0.3,"description":"function score"}]}`},
+		{"not explained", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := client.Search(ctx, &models.SearchRequest{Query: "roadmap", TenantID: "default", Limit: 10, Explain: tt.explain})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if _, asked := requests[len(requests)-1]["explain"]; asked != tt.explain {
+				t.Errorf("expected explain in the request %v, got %v", tt.explain, requests[len(requests)-1])
+			}
+			if len(result.Documents) != 1 {
+				t.Fatalf("expected one hit, got %+v", result.Documents)
+			}
+			got := marshal(t, result.Documents[0])
+			if tt.want == "" {
+				if strings.Contains(got, "explanation") {
+					t.Errorf("expected no explanation, got %s", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("expected %s in %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// internal/elastic/response.go
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ResponseError is an error response from the cluster. Type, Reason and
+// RootCause come from the response body when it has them.
+type ResponseError struct {
+	Action     string // what the client was doing, e.g. "search failed"
+	StatusCode int
+	Type       string
+	Reason     string
+	RootCause  []ErrorCause
+}
+
+// ErrorCause is one of the root causes of an error response
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e *ResponseError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d %s", e.Action, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Type != "" || e.Reason != "" {
+		fmt.Fprintf(&b, ": %s", joinCause(e.Type, e.Reason))
+	}
+	for _, cause := range e.RootCause {
+		// The top-level error often repeats its only root cause
+		if cause.Type == e.Type && cause.Reason == e.Reason {
+			continue
+		}
+		fmt.Fprintf(&b, " (caused by %s)", joinCause(cause.Type, cause.Reason))
+	}
+	return b.String()
+}
+
+func joinCause(errorType, reason string) string {
+	if errorType == "" {
+		return reason
+	}
+	if reason == "" {
+		return errorType
+	}
+	return errorType + ": " + reason
+}
+
+// responseError reads the error body of res. Elasticsearch and OpenSearch
+// return {"error": {"type", "reason", "root_cause"}, "status"}; some errors,
+// such as an unknown endpoint, have a plain string as error. Bodies that
+// already were read or aren't JSON leave only the status.
+func responseError(res *esapi.Response, action string) error {
+	resErr := &ResponseError{Action: action, StatusCode: res.StatusCode}
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Error) == 0 {
+		return resErr
+	}
+	var detail struct {
+		Type      string       `json:"type"`
+		Reason    string       `json:"reason"`
+		RootCause []ErrorCause `json:"root_cause"`
+	}
+	if err := json.Unmarshal(body.Error, &detail); err == nil {
+		resErr.Type = detail.Type
+		resErr.Reason = detail.Reason
+		resErr.RootCause = detail.RootCause
+		return resErr
+	}
+	_ = json.Unmarshal(body.Error, &resErr.Reason)
+	return resErr
+}
+
+// searchResponse is the part of a search response the client reads
+type searchResponse struct {
+	Took int64 `json:"took"` // milliseconds
+	Hits struct {
+		Total totalHits   `json:"total"`
+		Hits  []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+type searchHit struct {
+	Index       string                   `json:"_index"`
+	ID          string                   `json:"_id"`
+	Score       float64                  `json:"_score"` // null when sorting by a field
+	Source      hitSource                `json:"_source"`
+	Explanation *models.ScoreExplanation `json:"_explanation"`
+	Highlight   map[string][]string      `json:"highlight"`
+}
+
+// hitSource is an indexed SearchDocument. The timestamps are decoded with
+// the date formats the mapping accepts rather than RFC 3339 only.
+type hitSource struct {
+	models.SearchDocument
+	CreatedAt dateTime `json:"created_at"`
+	UpdatedAt dateTime `json:"updated_at"`
+}
+
+// document converts the hit to a SearchDocument
+func (h *searchHit) document() models.SearchDocument {
+	doc := h.Source.SearchDocument
+	doc.CreatedAt = time.Time(h.Source.CreatedAt)
+	doc.UpdatedAt = time.Time(h.Source.UpdatedAt)
+	doc.Index = h.Index
+	doc.Score = h.Score
+	doc.Explanation = h.Explanation
+	if h.Highlight != nil {
+		doc.Highlights = mergeHighlights(h.Highlight)
+	}
+	return doc
+}
+
+// dateTime decodes a date field in the default format of a date mapping,
+// strict_date_optional_time||epoch_millis. The cluster validates dates at
+// index time, so anything else in a source is an error rather than a zero
+// time.
+type dateTime time.Time
+
+// dateLayouts are the shapes of strict_date_optional_time. Layouts without
+// an offset are UTC, as in Elasticsearch.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02T15Z07:00",
+	"2006-01-02T15",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func (d *dateTime) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*d = dateTime{}
+		return nil
+	}
+	value := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil && len(value) > 4 {
+		*d = dateTime(time.UnixMilli(millis).UTC())
+		return nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			*d = dateTime(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid date %s", data)
+}
@@ -0,0 +1,125 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+func TestDateTimeFormats(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{`"2024-03-05T10:04:05Z"`, time.Date(2024, 3, 5, 10, 4, 5, 0, time.UTC)},
+		{`"2024-03-05T10:04:05.123+01:00"`, time.Date(2024, 3, 5, 9, 4, 5, 123e6, time.UTC)},
+		{`"2024-03-05T10:04:05.123456"`, time.Date(2024, 3, 5, 10, 4, 5, 123456e3, time.UTC)},
+		{`"2024-03-05T10:04"`, time.Date(2024, 3, 5, 10, 4, 0, 0, time.UTC)},
+		{`"2024-03-05"`, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{`1709633045000`, time.Date(2024, 3, 5, 10, 4, 5, 0, time.UTC)},
+		{`"1709633045000"`, time.Date(2024, 3, 5, 10, 4, 5, 0, time.UTC)},
+		{`null`, time.Time{}},
+	}
+	for _, tt := range tests {
+		var d dateTime
+		if err := json.Unmarshal([]byte(tt.value), &d); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.value, err)
+			continue
+		}
+		if got := time.Time(d); !got.Equal(tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	var d dateTime
+	if err := json.Unmarshal([]byte(`"05/03/2024"`), &d); err == nil {
+		t.Error("expected a date outside the mapping formats to be rejected")
+	}
+}
+
+func TestResponseErrorReadsBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			"object",
+			`{"error":{"root_cause":[{"type":"query_shard_exception","reason":"failed to create query"}],"type":"search_phase_execution_exception","reason":"all shards failed"},"status":400}`,
+			"search failed: 400 Bad Request: search_phase_execution_exception: all shards failed (caused by query_shard_exception: failed to create query)",
+		},
+		{
+			"string",
+			`{"error":"no handler found for uri [/_synonyms/x] and method [GET]"}`,
+			"search failed: 400 Bad Request: no handler found for uri [/_synonyms/x] and method [GET]",
+		},
+		{
+			"not json",
+			`Bad Request`,
+			"search failed: 400 Bad Request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &esapi.Response{StatusCode: 400, Body: io.NopCloser(strings.NewReader(tt.body))}
+			err := responseError(res, "search failed")
+			if err.Error() != tt.want {
+				t.Fatalf("got %q, want %q", err, tt.want)
+			}
+			var resErr *ResponseError
+			if !errors.As(err, &resErr) || resErr.StatusCode != 400 {
+				t.Fatalf("expected a ResponseError with the status, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestSearchDecodesHits(t *testing.T) {
+	// Search responses as each flavor returns them; OpenSearch reports
+	// hits.total as a plain number
+	tests := []struct {
+		flavor string
+		total  string
+	}{
+		{FlavorElasticsearch, `{"value": 1, "relation": "eq"}`},
+		{FlavorOpenSearch, `1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flavor, func(t *testing.T) {
+			client := newCannedClient(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, `{"took": 1, "timed_out": false, "hits": {"total": `+tt.total+`, "max_score": 0.2876821, "hits": [
+					{"_index": "test-index", "_id": "1", "_score": 0.2876821, "_source": {"id": 1, "tenant_id": "default", "title": "Roadmap", "visibility": "public", "language": "en", "created_at": "2024-03-05T10:04:05Z", "updated_at": "2024-03-05T10:04:05Z"},
+					 "highlight": {"title": ["<mark>Roadmap</mark>"]}}]}}`)
+			})
+			client.flavor = tt.flavor
+			client.SetRanking(DefaultRanking)
+
+			ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+			result, err := client.Search(ctx, &models.SearchRequest{Query: "roadmap", TenantID: "default", Limit: 10})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if result.Took != time.Millisecond || result.Total != 1 {
+				t.Errorf("expected the took and total reported by the cluster, got %v and %d", result.Took, result.Total)
+			}
+			if len(result.Documents) != 1 {
+				t.Fatalf("expected one hit, got %+v", result.Documents)
+			}
+			hit := result.Documents[0]
+			created := time.Date(2024, 3, 5, 10, 4, 5, 0, time.UTC)
+			if hit.Index != "test-index" || hit.Score <= 0 || !hit.CreatedAt.Equal(created) || hit.TenantID != "default" {
+				t.Fatalf("unexpected hit %+v", hit)
+			}
+		})
+	}
+}
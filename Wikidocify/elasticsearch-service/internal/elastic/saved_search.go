@@ -0,0 +1,373 @@
+// internal/elastic/saved_search.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ErrSavedSearchNotFound is returned when a saved search doesn't exist
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// percolateBatchSize is the number of matching saved searches fetched per
+// request when percolating a document
+const percolateBatchSize = 500
+
+// savedSearchProperties are the searchable fields of a stored
+// models.SavedSearch; the rest is only kept in _source
+var savedSearchProperties = map[string]interface{}{
+	"dynamic": false,
+	"properties": map[string]interface{}{
+		"id":         map[string]interface{}{"type": "keyword"},
+		"tenant_id":  map[string]interface{}{"type": "keyword"},
+		"owner":      map[string]interface{}{"type": "keyword"},
+		"created_at": map[string]interface{}{"type": "date"},
+	},
+}
+
+// alertsMapping matches models.SearchMatchEvent
+var alertsMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"event":             map[string]interface{}{"type": "keyword"},
+			"id":                map[string]interface{}{"type": "keyword"},
+			"tenant_id":         map[string]interface{}{"type": "keyword"},
+			"saved_search_id":   map[string]interface{}{"type": "keyword"},
+			"saved_search_name": map[string]interface{}{"type": "keyword"},
+			"owner":             map[string]interface{}{"type": "keyword"},
+			"document_id":       map[string]interface{}{"type": "integer"},
+			"title":             map[string]interface{}{"type": "text"},
+			"author":            map[string]interface{}{"type": "keyword"},
+			"matched_at":        map[string]interface{}{"type": "date"},
+		},
+	},
+}
+
+// savedSearchMappingProperties maps the percolator field, the saved search
+// metadata and every document field, so stored queries can be parsed and
+// percolated documents are analyzed like indexed ones.
+func savedSearchMappingProperties() map[string]interface{} {
+	properties := documentMappingProperties()
+	properties["query"] = map[string]interface{}{"type": "percolator"}
+	properties["saved_search"] = savedSearchProperties
+	return properties
+}
+
+// putSavedSearchMapping adds the fields introduced since the percolator
+// index was created; see putMapping
+func (c *Client) putSavedSearchMapping(ctx context.Context, index string, analyzed bool) error {
+	properties := savedSearchMappingProperties()
+	if !analyzed {
+		properties = withoutAnalyzedText(properties)
+	}
+	body, err := json.Marshal(map[string]interface{}{"properties": properties})
+	if err != nil {
+		return err
+	}
+	res, err := c.es.Indices.PutMapping([]string{index}, bytes.NewReader(body), c.es.Indices.PutMapping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, fmt.Sprintf("failed to update mapping of index %s", index))
+	}
+	return nil
+}
+
+// EnsureSavedSearchIndexes creates the percolator index holding saved
+// searches and the index recording sent alerts, or updates the percolator
+// index mapping with document fields added since it was created.
+func (c *Client) EnsureSavedSearchIndexes(ctx context.Context, index, alertsIndex string) error {
+	res, err := c.es.Indices.Exists([]string{index}, c.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == 200 {
+		analyzed, err := c.hasAnalysis(ctx, index)
+		if err != nil {
+			return err
+		}
+		if !analyzed {
+			slog.WarnContext(ctx, "Saved searches index lacks the synonym and language analyzers; run the analysis migration",
+				"index", index, "endpoint", "POST /api/v1/admin/index/migrate-analysis")
+		}
+		if err := c.putSavedSearchMapping(ctx, index, analyzed); err != nil {
+			return err
+		}
+	} else {
+		mapping := map[string]interface{}{
+			"settings": c.analysisSettings(),
+			"mappings": map[string]interface{}{"properties": savedSearchMappingProperties()},
+		}
+		if err := c.ensureAppendOnlyIndex(ctx, index, mapping); err != nil {
+			return err
+		}
+	}
+	return c.ensureAppendOnlyIndex(ctx, alertsIndex, alertsMapping)
+}
+
+// savedSearchQuery returns the percolator query of s. It is the query a
+// search with the same parameters by the owner would run without any
+// group, so alerts obey the same tenant and access rules as search results
+// but never rely on a group membership the owner may since have lost.
+func savedSearchQuery(s *models.SavedSearch) (interface{}, error) {
+	req := &models.SearchRequest{
+		Query:    s.Query,
+		Type:     s.Type,
+		Author:   s.Author,
+		TenantID: s.TenantID,
+	}
+	owner := &auth.Identity{Subject: s.Owner, Method: s.OwnerMethod}
+	esQuery, err := buildSearchQuery(req, owner)
+	if err != nil {
+		return nil, err
+	}
+	return esQuery["query"], nil
+}
+
+// PutSavedSearch stores s, replacing the saved search with the same ID
+func (c *Client) PutSavedSearch(ctx context.Context, index string, s *models.SavedSearch) error {
+	query, err := savedSearchQuery(s)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":        query,
+		"saved_search": s,
+	})
+	if err != nil {
+		return err
+	}
+	req := esapi.IndexRequest{
+		Index:      index,
+		DocumentID: s.ID,
+		Body:       bytes.NewReader(body),
+		Refresh:    "true",
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, "failed to store saved search")
+	}
+	return nil
+}
+
+// GetSavedSearch returns the saved search with the given ID
+func (c *Client) GetSavedSearch(ctx context.Context, index, id string) (*models.SavedSearch, error) {
+	res, err := c.es.Get(index, id, c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, ErrSavedSearchNotFound
+	}
+	if res.IsError() {
+		return nil, responseError(res, "failed to get saved search")
+	}
+	var result struct {
+		Source struct {
+			SavedSearch models.SavedSearch `json:"saved_search"`
+		} `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result.Source.SavedSearch, nil
+}
+
+// ListSavedSearches returns up to limit saved searches of owner in a tenant,
+// newest first, ties broken by ID, and how many there are in total
+func (c *Client) ListSavedSearches(ctx context.Context, index, tenantID, owner string, limit int) ([]models.SavedSearch, int64, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					term("saved_search.tenant_id", tenantID),
+					term("saved_search.owner", owner),
+				},
+			},
+		},
+		"sort": []interface{}{
+			map[string]interface{}{"saved_search.created_at": "desc"},
+			map[string]interface{}{"saved_search.id": "desc"},
+		},
+		"size":             limit,
+		"_source":          []string{"saved_search"},
+		"track_total_hits": true,
+	}
+	return c.searchSavedSearches(ctx, index, query)
+}
+
+// DeleteSavedSearch removes a saved search and the record of its alerts
+func (c *Client) DeleteSavedSearch(ctx context.Context, index, alertsIndex, id string) error {
+	req := esapi.DeleteRequest{
+		Index:      index,
+		DocumentID: id,
+		Refresh:    "true",
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return ErrSavedSearchNotFound
+	}
+	if res.IsError() {
+		return responseError(res, "failed to delete saved search")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"query": term("saved_search_id", id)})
+	if err != nil {
+		return err
+	}
+	res, err = c.es.DeleteByQuery([]string{alertsIndex}, bytes.NewReader(body),
+		c.es.DeleteByQuery.WithContext(ctx),
+		c.es.DeleteByQuery.WithConflicts("proceed"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, "failed to delete alerts of saved search")
+	}
+	return nil
+}
+
+// Percolate returns the saved searches of the document's tenant that match
+// doc
+func (c *Client) Percolate(ctx context.Context, index string, doc *models.SearchDocument) ([]models.SavedSearch, error) {
+	var matches []models.SavedSearch
+	var after []interface{}
+	for {
+		query := map[string]interface{}{
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"filter": []interface{}{
+						map[string]interface{}{
+							"percolate": map[string]interface{}{
+								"field":    "query",
+								"document": doc,
+							},
+						},
+						term("saved_search.tenant_id", doc.TenantID),
+					},
+				},
+			},
+			"sort":    []interface{}{map[string]interface{}{"saved_search.id": "asc"}},
+			"size":    percolateBatchSize,
+			"_source": []string{"saved_search"},
+		}
+		if after != nil {
+			query["search_after"] = after
+		}
+		batch, _, err := c.searchSavedSearches(ctx, index, query)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, batch...)
+		if len(batch) < percolateBatchSize {
+			return matches, nil
+		}
+		after = []interface{}{batch[len(batch)-1].ID}
+	}
+}
+
+func (c *Client) searchSavedSearches(ctx context.Context, index string, query map[string]interface{}) ([]models.SavedSearch, int64, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(index),
+		c.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, 0, responseError(res, "saved search query failed")
+	}
+	var result struct {
+		Hits struct {
+			Total totalHits `json:"total"`
+			Hits  []struct {
+				Source struct {
+					SavedSearch models.SavedSearch `json:"saved_search"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, 0, err
+	}
+	searches := make([]models.SavedSearch, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		searches = append(searches, hit.Source.SavedSearch)
+	}
+	return searches, int64(result.Hits.Total), nil
+}
+
+// ClaimAlert records that the alert for event is being sent. It returns
+// false if it was already recorded, so each saved search alerts at most once
+// per document, however often the document is re-indexed or the event
+// redelivered.
+func (c *Client) ClaimAlert(ctx context.Context, alertsIndex string, event *models.SearchMatchEvent) (bool, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+	req := esapi.CreateRequest{
+		Index:      alertsIndex,
+		DocumentID: event.ID,
+		Body:       bytes.NewReader(body),
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 409 {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, responseError(res, "failed to record alert")
+	}
+	return true, nil
+}
+
+// ReleaseAlert forgets a claimed alert that could not be sent, so it is
+// sent the next time the document is indexed
+func (c *Client) ReleaseAlert(ctx context.Context, alertsIndex, id string) error {
+	req := esapi.DeleteRequest{
+		Index:      alertsIndex,
+		DocumentID: id,
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return responseError(res, "failed to release alert")
+	}
+	return nil
+}
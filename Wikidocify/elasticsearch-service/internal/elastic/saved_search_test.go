@@ -0,0 +1,137 @@
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// newCannedClient returns a client of a cluster answering with handler.
+// Unlike the fake cluster, it only replays the responses the test gives.
+func newCannedClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatalf("elasticsearch.NewClient: %v", err)
+	}
+	return &Client{es: es, index: "test-index", routing: RoutingShared}
+}
+
+func TestSavedSearchQueryLeavesOutGroups(t *testing.T) {
+	query, err := savedSearchQuery(&models.SavedSearch{
+		TenantID:    "acme",
+		Owner:       "alice",
+		OwnerMethod: "jwt",
+		Query:       "roadmap",
+	})
+	if err != nil {
+		t.Fatalf("savedSearchQuery: %v", err)
+	}
+	got := marshal(t, query)
+	for _, want := range []string{
+		`{"term":{"tenant_id":"acme"}}`,
+		`{"term":{"visibility":"public"}}`,
+		`{"term":{"owner":"alice"}}`,
+		`{"term":{"allowed_users":"alice"}}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %s in the percolator query, got %s", want, got)
+		}
+	}
+	if strings.Contains(got, "allowed_groups") {
+		t.Errorf("expected no group clause, got %s", got)
+	}
+}
+
+func TestPercolatePagesThroughMatches(t *testing.T) {
+	var requests []string
+	client := newCannedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		hits := make([]string, percolateBatchSize)
+		if len(requests) > 1 {
+			hits = hits[:1]
+		}
+		for i := range hits {
+			hits[i] = fmt.Sprintf(`{"_source": {"saved_search": {"id": "s%d-%d", "tenant_id": "acme", "owner": "alice"}}}`, len(requests), i)
+		}
+		_, _ = io.WriteString(w, `{"hits": {"total": {"value": 0}, "hits": [`+strings.Join(hits, ",")+`]}}`)
+	})
+
+	doc := &models.SearchDocument{ID: 42, TenantID: "acme", Title: "Roadmap"}
+	matches, err := client.Percolate(context.Background(), "saved", doc)
+	if err != nil {
+		t.Fatalf("Percolate: %v", err)
+	}
+	if len(matches) != percolateBatchSize+1 {
+		t.Fatalf("expected every match, got %d", len(matches))
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected two pages, got %d requests", len(requests))
+	}
+	for _, want := range []string{`"percolate":{"document":`, `"title":"Roadmap"`, `{"term":{"saved_search.tenant_id":"acme"}}`} {
+		if !strings.Contains(requests[0], want) {
+			t.Errorf("expected %s in the request, got %s", want, requests[0])
+		}
+	}
+	if want := fmt.Sprintf(`"search_after":["s1-%d"]`, percolateBatchSize-1); !strings.Contains(requests[1], want) {
+		t.Errorf("expected the second page to continue after the first, got %s", requests[1])
+	}
+}
+
+func TestClaimAlertOnce(t *testing.T) {
+	var mu sync.Mutex
+	claimed := map[string]bool{}
+	client := newCannedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch {
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/alerts/_create/"):
+			if claimed[id] {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = io.WriteString(w, `{"error": {"type": "version_conflict_engine_exception"}, "status": 409}`)
+				return
+			}
+			claimed[id] = true
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, `{"result": "created"}`)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/alerts/_doc/"):
+			delete(claimed, id)
+			_, _ = io.WriteString(w, `{"result": "deleted"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	ctx := context.Background()
+	event := &models.SearchMatchEvent{ID: "s1:42", SavedSearchID: "s1", DocumentID: 42}
+
+	if ok, err := client.ClaimAlert(ctx, "alerts", event); err != nil || !ok {
+		t.Fatalf("expected the first claim to succeed, got %v, %v", ok, err)
+	}
+	if ok, err := client.ClaimAlert(ctx, "alerts", event); err != nil || ok {
+		t.Fatalf("expected a redelivered event not to be claimed again, got %v, %v", ok, err)
+	}
+	if err := client.ReleaseAlert(ctx, "alerts", event.ID); err != nil {
+		t.Fatalf("ReleaseAlert: %v", err)
+	}
+	if ok, err := client.ClaimAlert(ctx, "alerts", event); err != nil || !ok {
+		t.Fatalf("expected a released alert to be claimed again, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,169 @@
+// internal/elastic/sync_runs.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ErrSyncRunConflict is returned when another replica wrote the sync run
+// since it was read
+var ErrSyncRunConflict = errors.New("sync run was changed by another replica")
+
+// syncRunsMapping matches models.SyncRun
+var syncRunsMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id":               map[string]interface{}{"type": "keyword"},
+			"tenant_id":        map[string]interface{}{"type": "keyword"},
+			"status":           map[string]interface{}{"type": "keyword"},
+			"started_at":       map[string]interface{}{"type": "date"},
+			"finished_at":      map[string]interface{}{"type": "date"},
+			"checkpointed_at":  map[string]interface{}{"type": "date"},
+			"last_id":          map[string]interface{}{"type": "long"},
+			"batches":          map[string]interface{}{"type": "integer"},
+			"documents_synced": map[string]interface{}{"type": "long"},
+			"attempts":         map[string]interface{}{"type": "integer"},
+			"errors":           map[string]interface{}{"type": "keyword", "index": false},
+		},
+	},
+}
+
+// EnsureSyncRunsIndex creates the sync runs index if it doesn't exist
+func (c *Client) EnsureSyncRunsIndex(ctx context.Context, index string) error {
+	return c.ensureAppendOnlyIndex(ctx, index, syncRunsMapping)
+}
+
+// PutSyncRun stores run, replacing the previous checkpoint of the run. The
+// write is conditional on the version of run, which is updated afterwards;
+// a run that was never stored is created. It returns ErrSyncRunConflict if
+// another replica wrote the run first.
+func (c *Client) PutSyncRun(ctx context.Context, index string, run *models.SyncRun) error {
+	body, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	// The next run looks for the last one, so make it visible to searches
+	// once it has finished; checkpoints become visible within a second
+	refresh := ""
+	if run.Status != models.SyncRunRunning {
+		refresh = "true"
+	}
+	var res *esapi.Response
+	if run.PrimaryTerm == 0 {
+		req := esapi.CreateRequest{
+			Index:      index,
+			DocumentID: run.ID,
+			Body:       bytes.NewReader(body),
+			Refresh:    refresh,
+		}
+		res, err = req.Do(ctx, c.es)
+	} else {
+		req := esapi.IndexRequest{
+			Index:         index,
+			DocumentID:    run.ID,
+			Body:          bytes.NewReader(body),
+			IfSeqNo:       &run.SeqNo,
+			IfPrimaryTerm: &run.PrimaryTerm,
+			Refresh:       refresh,
+		}
+		res, err = req.Do(ctx, c.es)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 409 {
+		return ErrSyncRunConflict
+	}
+	if res.IsError() {
+		return responseError(res, "failed to store sync run")
+	}
+	var result struct {
+		SeqNo       int `json:"_seq_no"`
+		PrimaryTerm int `json:"_primary_term"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	run.SeqNo, run.PrimaryTerm = result.SeqNo, result.PrimaryTerm
+	return nil
+}
+
+// GetSyncRun returns the run id with its version, or nil if there is none.
+// Unlike a search, it sees the last checkpoint as soon as it is written.
+func (c *Client) GetSyncRun(ctx context.Context, index, id string) (*models.SyncRun, error) {
+	res, err := c.es.Get(index, id, c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, responseError(res, "failed to get sync run")
+	}
+	var result struct {
+		SeqNo       int            `json:"_seq_no"`
+		PrimaryTerm int            `json:"_primary_term"`
+		Source      models.SyncRun `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	run := result.Source
+	run.SeqNo, run.PrimaryTerm = result.SeqNo, result.PrimaryTerm
+	return &run, nil
+}
+
+// ListSyncRuns returns up to limit sync runs, most recently started first.
+// With a tenantID only the runs syncing that tenant are listed, and an
+// empty one, for the runs of every tenant, is a scope like any other.
+func (c *Client) ListSyncRuns(ctx context.Context, index string, tenantID *string, limit int) ([]models.SyncRun, error) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if tenantID != nil {
+		query = map[string]interface{}{"term": map[string]interface{}{"tenant_id": *tenantID}}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query": query,
+		"sort":  []interface{}{map[string]interface{}{"started_at": "desc"}},
+		"size":  limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(index),
+		c.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res, "failed to list sync runs")
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source models.SyncRun `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	runs := make([]models.SyncRun, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		runs = append(runs, hit.Source)
+	}
+	return runs, nil
+}
@@ -0,0 +1,95 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestSyncRuns(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingShared)
+	ctx := context.Background()
+	if err := client.EnsureSyncRunsIndex(ctx, "runs"); err != nil {
+		t.Fatalf("EnsureSyncRunsIndex: %v", err)
+	}
+
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	runs := []models.SyncRun{
+		{ID: "a", TenantID: "", Status: models.SyncRunCompleted},
+		{ID: "b", TenantID: "acme", Status: models.SyncRunCompleted},
+		{ID: "c", TenantID: "", Status: models.SyncRunRunning, LastID: 40},
+	}
+	for i := range runs {
+		runs[i].StartedAt = start.Add(time.Duration(i) * time.Hour)
+		if err := client.PutSyncRun(ctx, "runs", &runs[i]); err != nil {
+			t.Fatalf("PutSyncRun: %v", err)
+		}
+	}
+	// A checkpoint replaces the run
+	checkpoint := runs[2]
+	checkpoint.LastID = 80
+	if err := client.PutSyncRun(ctx, "runs", &checkpoint); err != nil {
+		t.Fatalf("PutSyncRun: %v", err)
+	}
+
+	runs, err := client.ListSyncRuns(ctx, "runs", nil, 10)
+	if err != nil {
+		t.Fatalf("ListSyncRuns: %v", err)
+	}
+	if len(runs) != 3 || runs[0].ID != "c" || runs[1].ID != "b" || runs[2].ID != "a" {
+		t.Fatalf("expected every run, most recent first, got %+v", runs)
+	}
+	if runs[0].LastID != 80 {
+		t.Errorf("expected the last checkpoint, got %d", runs[0].LastID)
+	}
+
+	all := ""
+	runs, err = client.ListSyncRuns(ctx, "runs", &all, 1)
+	if err != nil || len(runs) != 1 || runs[0].ID != "c" {
+		t.Fatalf("expected the latest run of every tenant, got %+v, %v", runs, err)
+	}
+
+	got, err := client.GetSyncRun(ctx, "runs", "c")
+	if err != nil || got == nil || got.LastID != 80 || got.SeqNo != checkpoint.SeqNo {
+		t.Fatalf("expected the last checkpoint with its version, got %+v, %v", got, err)
+	}
+	if got, err := client.GetSyncRun(ctx, "runs", "missing"); err != nil || got != nil {
+		t.Errorf("expected no run, got %+v, %v", got, err)
+	}
+}
+
+func TestPutSyncRunConflicts(t *testing.T) {
+	client, _ := newAdminTestClient(t, RoutingShared)
+	ctx := context.Background()
+	if err := client.EnsureSyncRunsIndex(ctx, "runs"); err != nil {
+		t.Fatalf("EnsureSyncRunsIndex: %v", err)
+	}
+
+	run := models.SyncRun{ID: "a", Status: models.SyncRunFailed}
+	if err := client.PutSyncRun(ctx, "runs", &run); err != nil {
+		t.Fatalf("PutSyncRun: %v", err)
+	}
+	// Two replicas read the failed run and both try to resume it
+	first, _ := client.GetSyncRun(ctx, "runs", "a")
+	second, _ := client.GetSyncRun(ctx, "runs", "a")
+	first.Status = models.SyncRunRunning
+	if err := client.PutSyncRun(ctx, "runs", first); err != nil {
+		t.Fatalf("PutSyncRun: %v", err)
+	}
+	second.Status = models.SyncRunRunning
+	if err := client.PutSyncRun(ctx, "runs", second); !errors.Is(err, ErrSyncRunConflict) {
+		t.Errorf("expected the second resume to conflict, got %v", err)
+	}
+	// The winner keeps checkpointing
+	first.LastID = 10
+	if err := client.PutSyncRun(ctx, "runs", first); err != nil {
+		t.Errorf("expected the next checkpoint to be stored, got %v", err)
+	}
+	// A run is created only once
+	if err := client.PutSyncRun(ctx, "runs", &models.SyncRun{ID: "a"}); !errors.Is(err, ErrSyncRunConflict) {
+		t.Errorf("expected creating an existing run to conflict, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// internal/elastic/synonyms.go
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ErrInvalidSynonyms is returned when Elasticsearch rejects a synonyms set
+var ErrInvalidSynonyms = errors.New("invalid synonym rules")
+
+// ErrSynonymVersionExists is returned when another change claimed the same
+// synonyms version first
+var ErrSynonymVersionExists = errors.New("synonyms version already exists")
+
+// synonymVersionsMapping matches models.SynonymVersion
+var synonymVersionsMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"version":          map[string]interface{}{"type": "integer"},
+			"rules":            map[string]interface{}{"type": "keyword", "index": false},
+			"created_at":       map[string]interface{}{"type": "date"},
+			"created_by":       map[string]interface{}{"type": "keyword"},
+			"rolled_back_from": map[string]interface{}{"type": "integer"},
+		},
+	},
+}
+
+// synonymsSet is the name of the Elasticsearch synonyms set used by every
+// document index
+func (c *Client) synonymsSet() string {
+	return c.index + "_synonyms"
+}
+
+// ensureSynonymsSet creates the synonyms set, empty, if it doesn't exist.
+// Indexes referring to a missing set can't be created.
+func (c *Client) ensureSynonymsSet(ctx context.Context) error {
+	res, err := c.es.SynonymsGetSynonym(c.synonymsSet(), c.es.SynonymsGetSynonym.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		if res.IsError() {
+			return responseError(res, "failed to get synonyms set")
+		}
+		return nil
+	}
+	return c.putSynonymsSet(ctx, nil)
+}
+
+// PutSynonyms replaces the rules of the synonyms set and reloads the search
+// analyzers of every document index. Rules Elasticsearch rejects return an
+// error wrapping ErrInvalidSynonyms.
+func (c *Client) PutSynonyms(ctx context.Context, rules []string) error {
+	if !c.SupportsSynonyms() {
+		return ErrSynonymsUnsupported
+	}
+	if err := c.putSynonymsSet(ctx, rules); err != nil {
+		return err
+	}
+
+	// Updating the set already reloads analyzers using it; reloading
+	// explicitly covers indexes that were closed at the time
+	indexes := []string{c.index}
+	if c.routing == RoutingIndex {
+		indexes = []string{c.index + "-*"}
+	}
+	res, err := c.es.Indices.ReloadSearchAnalyzers(indexes,
+		c.es.Indices.ReloadSearchAnalyzers.WithContext(ctx),
+		c.es.Indices.ReloadSearchAnalyzers.WithAllowNoIndices(true),
+		c.es.Indices.ReloadSearchAnalyzers.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res, "failed to reload search analyzers")
+	}
+	// Cached results were computed with the old synonyms
+	c.bumpGeneration(ctx)
+	return nil
+}
+
+func (c *Client) putSynonymsSet(ctx context.Context, rules []string) error {
+	set := make([]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		set = append(set, map[string]interface{}{"synonyms": rule})
+	}
+	body, err := json.Marshal(map[string]interface{}{"synonyms_set": set})
+	if err != nil {
+		return err
+	}
+	res, err := c.es.SynonymsPutSynonym(c.synonymsSet(), bytes.NewReader(body), c.es.SynonymsPutSynonym.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 400 {
+		var body struct {
+			Error struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&body)
+		return fmt.Errorf("%w: %s", ErrInvalidSynonyms, body.Error.Reason)
+	}
+	if res.IsError() {
+		return responseError(res, "failed to update synonyms set")
+	}
+	return nil
+}
+
+// EnsureSynonymVersionsIndex creates the index keeping every synonyms version
+func (c *Client) EnsureSynonymVersionsIndex(ctx context.Context, index string) error {
+	return c.ensureAppendOnlyIndex(ctx, index, synonymVersionsMapping)
+}
+
+// CreateSynonymVersion stores a synonyms version. It returns
+// ErrSynonymVersionExists if the version number is taken.
+func (c *Client) CreateSynonymVersion(ctx context.Context, index string, v *models.SynonymVersion) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req := esapi.CreateRequest{
+		Index:      index,
+		DocumentID: fmt.Sprint(v.Version),
+		Body:       bytes.NewReader(body),
+		Refresh:    "true",
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 409 {
+		return ErrSynonymVersionExists
+	}
+	if res.IsError() {
+		return responseError(res, "failed to store synonyms version")
+	}
+	return nil
+}
+
+// DeleteSynonymVersion removes a version that could not be applied
+func (c *Client) DeleteSynonymVersion(ctx context.Context, index string, version int) error {
+	req := esapi.DeleteRequest{
+		Index:      index,
+		DocumentID: fmt.Sprint(version),
+		Refresh:    "true",
+	}
+	res, err := req.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return responseError(res, "failed to delete synonyms version")
+	}
+	return nil
+}
+
+// GetSynonymVersion returns one synonyms version, or nil if it doesn't exist
+func (c *Client) GetSynonymVersion(ctx context.Context, index string, version int) (*models.SynonymVersion, error) {
+	res, err := c.es.Get(index, fmt.Sprint(version), c.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, responseError(res, "failed to get synonyms version")
+	}
+	var result struct {
+		Source models.SynonymVersion `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result.Source, nil
+}
+
+// ListSynonymVersions returns up to limit synonyms versions, newest first
+func (c *Client) ListSynonymVersions(ctx context.Context, index string, limit int) ([]models.SynonymVersion, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"sort": []interface{}{map[string]interface{}{"version": "desc"}},
+		"size": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.es.Search(
+		c.es.Search.WithContext(ctx),
+		c.es.Search.WithIndex(index),
+		c.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, responseError(res, "failed to list synonyms versions")
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source models.SynonymVersion `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	versions := make([]models.SynonymVersion, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		versions = append(versions, hit.Source)
+	}
+	return versions, nil
+}
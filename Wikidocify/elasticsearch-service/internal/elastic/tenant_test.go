@@ -0,0 +1,71 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestBuildSearchQueryRequiresTenant(t *testing.T) {
+	identity := &auth.Identity{Subject: "alice", Method: "jwt"}
+	_, err := buildSearchQuery(&models.SearchRequest{Query: "roadmap"}, identity)
+	if !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+}
+
+func TestBuildSearchQueryFiltersByTenant(t *testing.T) {
+	identity := &auth.Identity{Subject: "alice", Method: "jwt"}
+	query, err := buildSearchQuery(&models.SearchRequest{Query: "roadmap", TenantID: "acme"}, identity)
+	if err != nil {
+		t.Fatalf("buildSearchQuery: %v", err)
+	}
+	want := roundTrip(t, term("tenant_id", "acme"))
+	for _, filter := range queryFilters(t, query) {
+		if reflect.DeepEqual(filter, want) {
+			return
+		}
+	}
+	t.Fatalf("query has no tenant filter: %v", roundTrip(t, query))
+}
+
+func TestIndexFor(t *testing.T) {
+	shared := &Client{index: "docs", routing: RoutingShared}
+	perTenant := &Client{index: "docs", routing: RoutingIndex}
+
+	if got := shared.IndexFor("acme"); got != "docs" {
+		t.Fatalf("shared routing: expected docs, got %s", got)
+	}
+	if got := perTenant.IndexFor("acme"); got != "docs-acme" {
+		t.Fatalf("index routing: expected docs-acme, got %s", got)
+	}
+}
+
+func TestSearchUsesTenantIndex(t *testing.T) {
+	client, searches, lastBody := newTestClientWithRouting(t, RoutingIndex, "/test-index-acme/_search")
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+
+	if _, err := client.Search(ctx, &models.SearchRequest{Query: "roadmap", Limit: 10, TenantID: "acme"}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if n := searches.Load(); n != 1 {
+		t.Fatalf("expected one search against the tenant index, got %d", n)
+	}
+
+	var sent map[string]interface{}
+	if err := json.Unmarshal(lastBody.Load().([]byte), &sent); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+	want := roundTrip(t, term("tenant_id", "acme"))
+	for _, filter := range queryFilters(t, sent) {
+		if reflect.DeepEqual(filter, want) {
+			return
+		}
+	}
+	t.Fatalf("request has no tenant filter: %v", sent)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Analytics report defaults
+const (
+	defaultAnalyticsWindow = 7 * 24 * time.Hour
+	defaultAnalyticsLimit  = 20
+	maxAnalyticsLimit      = 100
+	defaultSlowQueryMs     = 500
+)
+
+type AnalyticsHandler struct {
+	analyticsService *services.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService *services.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsService: analyticsService,
+	}
+}
+
+// Click records that a user opened a search result
+func (h *AnalyticsHandler) Click(c *gin.Context) {
+	var req models.ClickRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid click",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.analyticsService.RecordClick(c.Request.Context(), tenant.FromContext(c.Request.Context()), &req)
+	c.Status(http.StatusAccepted)
+}
+
+// TopQueries returns the most frequent queries in the window
+func (h *AnalyticsHandler) TopQueries(c *gin.Context) {
+	h.report(c, h.analyticsService.TopQueries)
+}
+
+// ZeroResults returns the most frequent queries that found nothing
+func (h *AnalyticsHandler) ZeroResults(c *gin.Context) {
+	h.report(c, h.analyticsService.ZeroResultQueries)
+}
+
+// SlowQueries returns the queries slower than min_latency_ms (default 500)
+func (h *AnalyticsHandler) SlowQueries(c *gin.Context) {
+	h.report(c, h.analyticsService.SlowQueries)
+}
+
+func (h *AnalyticsHandler) report(c *gin.Context, run func(context.Context, *models.AnalyticsQuery) ([]models.QueryStats, error)) {
+	q, ok := parseAnalyticsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats, err := run(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Analytics query failed",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tenant_id": q.TenantID,
+		"from":      q.From,
+		"to":        q.To,
+		"queries":   stats,
+	})
+}
+
+// parseAnalyticsQuery reads the report window from either from/to (RFC 3339)
+// or window (a duration ending now, default 7 days), plus limit and
+// min_latency_ms.
+func parseAnalyticsQuery(c *gin.Context) (*models.AnalyticsQuery, bool) {
+	q := &models.AnalyticsQuery{
+		TenantID:     tenant.FromContext(c.Request.Context()),
+		To:           time.Now().UTC(),
+		Limit:        defaultAnalyticsLimit,
+		MinLatencyMs: defaultSlowQueryMs,
+	}
+	badRequest := func(message string) (*models.AnalyticsQuery, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return nil, false
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return badRequest("Invalid to: expected an RFC 3339 timestamp")
+		}
+		q.To = t
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return badRequest("Invalid from: expected an RFC 3339 timestamp")
+		}
+		q.From = t
+	} else {
+		window := defaultAnalyticsWindow
+		if w := c.Query("window"); w != "" {
+			d, err := time.ParseDuration(w)
+			if err != nil || d <= 0 {
+				return badRequest("Invalid window: expected a duration such as 24h")
+			}
+			window = d
+		}
+		q.From = q.To.Add(-window)
+	}
+	if !q.From.Before(q.To) {
+		return badRequest("from must be before to")
+	}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return badRequest("Invalid limit")
+		}
+		q.Limit = min(limit, maxAnalyticsLimit)
+	}
+	if m := c.Query("min_latency_ms"); m != "" {
+		ms, err := strconv.ParseInt(m, 10, 64)
+		if err != nil || ms < 0 {
+			return badRequest("Invalid min_latency_ms")
+		}
+		q.MinLatencyMs = ms
+	}
+	return q, true
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseAnalyticsQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantError  string
+		wantWindow time.Duration
+		wantFrom   time.Time // checked with wantTo when set
+		wantTo     time.Time
+		wantLimit  int
+		wantMinMs  int64
+	}{
+		{name: "defaults", wantWindow: defaultAnalyticsWindow, wantLimit: defaultAnalyticsLimit, wantMinMs: defaultSlowQueryMs},
+		{name: "window", query: "window=24h", wantWindow: 24 * time.Hour, wantLimit: defaultAnalyticsLimit, wantMinMs: defaultSlowQueryMs},
+		{name: "from and to", query: "from=2025-03-01T00:00:00Z&to=2025-03-08T00:00:00Z", wantFrom: from, wantTo: to,
+			wantLimit: defaultAnalyticsLimit, wantMinMs: defaultSlowQueryMs},
+		{name: "window ending at to", query: "window=24h&to=2025-03-08T00:00:00Z", wantFrom: to.Add(-24 * time.Hour), wantTo: to,
+			wantLimit: defaultAnalyticsLimit, wantMinMs: defaultSlowQueryMs},
+		{name: "from over window", query: "from=2025-03-01T00:00:00Z&to=2025-03-08T00:00:00Z&window=1h", wantFrom: from, wantTo: to,
+			wantLimit: defaultAnalyticsLimit, wantMinMs: defaultSlowQueryMs},
+		{name: "limit and latency", query: "limit=5&min_latency_ms=0", wantWindow: defaultAnalyticsWindow, wantLimit: 5},
+		{name: "limit capped", query: "limit=1000", wantWindow: defaultAnalyticsWindow, wantLimit: maxAnalyticsLimit, wantMinMs: defaultSlowQueryMs},
+		{name: "invalid to", query: "to=yesterday", wantError: "Invalid to: expected an RFC 3339 timestamp"},
+		{name: "invalid from", query: "from=2025-03-01", wantError: "Invalid from: expected an RFC 3339 timestamp"},
+		{name: "invalid window", query: "window=7d", wantError: "Invalid window: expected a duration such as 24h"},
+		{name: "negative window", query: "window=-1h", wantError: "Invalid window: expected a duration such as 24h"},
+		{name: "from after to", query: "from=2025-03-08T00:00:00Z&to=2025-03-01T00:00:00Z", wantError: "from must be before to"},
+		{name: "empty range", query: "from=2025-03-08T00:00:00Z&to=2025-03-08T00:00:00Z", wantError: "from must be before to"},
+		{name: "zero limit", query: "limit=0", wantError: "Invalid limit"},
+		{name: "invalid limit", query: "limit=ten", wantError: "Invalid limit"},
+		{name: "negative latency", query: "min_latency_ms=-1", wantError: "Invalid min_latency_ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/analytics/top-queries?"+tt.query, nil)
+			c.Request = c.Request.WithContext(tenant.WithTenant(c.Request.Context(), "acme"))
+
+			q, ok := parseAnalyticsQuery(c)
+			if tt.wantError != "" {
+				if ok || w.Code != http.StatusBadRequest {
+					t.Fatalf("expected 400, got %d", w.Code)
+				}
+				if want := `{"error":"` + tt.wantError + `"}`; w.Body.String() != want {
+					t.Errorf("expected %s, got %s", want, w.Body)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("unexpected rejection: %s", w.Body)
+			}
+			if q.TenantID != "acme" || q.Limit != tt.wantLimit || q.MinLatencyMs != tt.wantMinMs {
+				t.Errorf("unexpected query: %+v", q)
+			}
+			if !tt.wantTo.IsZero() {
+				if !q.From.Equal(tt.wantFrom) || !q.To.Equal(tt.wantTo) {
+					t.Errorf("expected %v to %v, got %v to %v", tt.wantFrom, tt.wantTo, q.From, q.To)
+				}
+				return
+			}
+			if q.To.Sub(q.From) != tt.wantWindow || time.Since(q.To) > time.Minute {
+				t.Errorf("expected the %v ending now, got %v to %v", tt.wantWindow, q.From, q.To)
+			}
+		})
+	}
+}
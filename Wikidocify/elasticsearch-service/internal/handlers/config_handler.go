@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wikidocify/elasticsearch-service/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigHandler struct {
+	current func() *config.Config
+}
+
+// NewConfigHandler returns a handler showing the configuration returned by
+// current, which changes when the configuration is reloaded
+func NewConfigHandler(current func() *config.Config) *ConfigHandler {
+	return &ConfigHandler{
+		current: current,
+	}
+}
+
+// Get returns the configuration in effect with its secrets redacted, as
+// JSON or, with format=yaml, in the configuration file format
+func (h *ConfigHandler) Get(c *gin.Context) {
+	cfg := h.current().Redacted()
+	if c.Query("format") == "yaml" {
+		c.YAML(http.StatusOK, cfg)
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConsistencyHandler struct {
+	consistencyService *services.ConsistencyService
+}
+
+func NewConsistencyHandler(consistencyService *services.ConsistencyService) *ConsistencyHandler {
+	return &ConsistencyHandler{
+		consistencyService: consistencyService,
+	}
+}
+
+// Check starts a consistency check of the tenant's index in the background
+// and returns immediately; GET /report shows the result. With repair=true
+// the differences are fixed as well.
+func (h *ConsistencyHandler) Check(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	repair := c.Query("repair") == "true"
+	if h.consistencyService.Running() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": services.ErrCheckInProgress.Error(),
+		})
+		return
+	}
+
+	// The check outlives the request, so keep only the request ID
+	ctx := logging.WithRequestID(context.Background(), logging.RequestIDFromContext(c.Request.Context()))
+	go func() {
+		if _, err := h.consistencyService.Check(ctx, tenantID, repair); err != nil && !errors.Is(err, services.ErrCheckInProgress) {
+			slog.ErrorContext(ctx, "Consistency check failed", "tenant_id", tenantID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   "Consistency check started",
+		"tenant_id": tenantID,
+		"repair":    repair,
+	})
+}
+
+// Report returns the report of the last check of the tenant's index
+func (h *ConsistencyHandler) Report(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	report := h.consistencyService.LastReport(tenantID)
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":       "No consistency check has run for tenant " + tenantID,
+			"in_progress": h.consistencyService.Running(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"in_progress": h.consistencyService.Running(),
+		"report":      report,
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Livez reports that the process is up. It never checks dependencies.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"timestamp": time.Now(),
+	})
+}
+
+// Readyz reports whether the service can serve search requests
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	response := h.healthService.Readiness()
+	if response.Status != services.StatusReady {
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Details returns the per-dependency health report
+func (h *HealthHandler) Details(c *gin.Context) {
+	response := h.healthService.Details()
+	if response.Status == services.StatusUnhealthy {
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
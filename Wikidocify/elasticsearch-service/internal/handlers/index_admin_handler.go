@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IndexAdminHandler inspects and maintains a tenant's search index. Every
+// route takes the tenant from the tenant_id query parameter or the
+// X-Tenant-ID header, defaulting to the default tenant; see syncTenant.
+type IndexAdminHandler struct {
+	indexAdminService *services.IndexAdminService
+}
+
+func NewIndexAdminHandler(indexAdminService *services.IndexAdminService) *IndexAdminHandler {
+	return &IndexAdminHandler{
+		indexAdminService: indexAdminService,
+	}
+}
+
+// Stats returns the document count, size, segments, mapping version and
+// aliases of the tenant's index
+func (h *IndexAdminHandler) Stats(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	stats, err := h.indexAdminService.Stats(c.Request.Context(), tenantID)
+	if err != nil {
+		indexAdminError(c, "Failed to get index stats", err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// Document returns the indexed copy of a document next to the doc service
+// copy, with the fields that differ
+func (h *IndexAdminHandler) Document(c *gin.Context) {
+	id, ok := parseDocumentID(c)
+	if !ok {
+		return
+	}
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	comparison, err := h.indexAdminService.CompareDocument(c.Request.Context(), tenantID, id)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to compare document",
+			"details": err.Error(),
+		})
+		return
+	}
+	if comparison.Indexed == nil && comparison.Source == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found in the index or the doc service"})
+		return
+	}
+	c.JSON(http.StatusOK, comparison)
+}
+
+// Refresh makes every write to the tenant's index visible to searches
+func (h *IndexAdminHandler) Refresh(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	if err := h.indexAdminService.Refresh(c.Request.Context(), tenantID); err != nil {
+		indexAdminError(c, "Failed to refresh index", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Index refreshed",
+		"tenant_id": tenantID,
+	})
+}
+
+// ForceMerge starts a force merge of the tenant's index in the background
+// and returns immediately; merges of large indexes outlast any request
+// timeout. The optional max_num_segments and only_expunge_deletes query
+// parameters are passed on to the cluster.
+func (h *IndexAdminHandler) ForceMerge(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	maxSegments := 0
+	if m := c.Query("max_num_segments"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_num_segments"})
+			return
+		}
+		maxSegments = n
+	}
+	onlyExpungeDeletes := c.Query("only_expunge_deletes") == "true"
+	if onlyExpungeDeletes && maxSegments > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_num_segments and only_expunge_deletes can't be combined"})
+		return
+	}
+
+	// The merge outlives the request, so keep only the request ID
+	ctx := logging.WithRequestID(context.Background(), logging.RequestIDFromContext(c.Request.Context()))
+	go func() {
+		if err := h.indexAdminService.ForceMerge(ctx, tenantID, maxSegments, onlyExpungeDeletes); err != nil {
+			slog.ErrorContext(ctx, "Force merge failed", "tenant_id", tenantID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   "Force merge started",
+		"tenant_id": tenantID,
+	})
+}
+
+// DeleteByQuery counts the tenant's documents matching a query and, when
+// asked and the count still matches the dry run, deletes them; see
+// models.DeleteByQueryRequest
+func (h *IndexAdminHandler) DeleteByQuery(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	var req models.DeleteByQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid delete by query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.indexAdminService.DeleteByQuery(c.Request.Context(), tenantID, caller(c), &req)
+	switch {
+	case errors.Is(err, services.ErrExpectedCountRequired), errors.Is(err, elastic.ErrEmptyQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCountChanged):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"matched": result.Matched,
+		})
+	case err != nil:
+		indexAdminError(c, "Delete by query failed", err)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// MigrateAnalysis adds the synonym and language analyzers to the tenant's
+// index and the saved searches index if they lack them. Each index is
+// closed for a moment, so searches and writes to it fail meanwhile.
+func (h *IndexAdminHandler) MigrateAnalysis(c *gin.Context) {
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+	migrated, err := h.indexAdminService.MigrateAnalysis(c.Request.Context(), tenantID)
+	if err != nil {
+		indexAdminError(c, "Analysis migration failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tenant_id": tenantID,
+		"migrated":  migrated,
+	})
+}
+
+// indexAdminError replies to a failed index operation. A cluster rejecting
+// the request, such as a malformed query, is the caller's error.
+func indexAdminError(c *gin.Context, message string, err error) {
+	var resErr *elastic.ResponseError
+	switch {
+	case errors.Is(err, elastic.ErrIndexNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	case errors.As(err, &resErr) && resErr.StatusCode == http.StatusBadRequest:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedSearchHandler manages the caller's saved searches in the request's
+// tenant. Callers only ever see their own saved searches.
+type SavedSearchHandler struct {
+	savedSearchService *services.SavedSearchService
+}
+
+func NewSavedSearchHandler(savedSearchService *services.SavedSearchService) *SavedSearchHandler {
+	return &SavedSearchHandler{
+		savedSearchService: savedSearchService,
+	}
+}
+
+// Create saves a new search
+func (h *SavedSearchHandler) Create(c *gin.Context) {
+	req, ok := bindSavedSearch(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	saved, err := h.savedSearchService.Create(ctx, auth.IdentityFromContext(ctx), tenant.FromContext(ctx), req)
+	if err != nil {
+		savedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, saved)
+}
+
+// List returns the caller's saved searches
+func (h *SavedSearchHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+	searches, err := h.savedSearchService.List(ctx, auth.IdentityFromContext(ctx), tenant.FromContext(ctx))
+	if err != nil {
+		savedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"saved_searches": searches,
+		"total":          len(searches),
+	})
+}
+
+// Get returns one saved search
+func (h *SavedSearchHandler) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	saved, err := h.savedSearchService.Get(ctx, auth.IdentityFromContext(ctx), tenant.FromContext(ctx), c.Param("id"))
+	if err != nil {
+		savedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// Update replaces the parameters of a saved search
+func (h *SavedSearchHandler) Update(c *gin.Context) {
+	req, ok := bindSavedSearch(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	saved, err := h.savedSearchService.Update(ctx, auth.IdentityFromContext(ctx), tenant.FromContext(ctx), c.Param("id"), req)
+	if err != nil {
+		savedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// Delete removes a saved search
+func (h *SavedSearchHandler) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.savedSearchService.Delete(ctx, auth.IdentityFromContext(ctx), tenant.FromContext(ctx), c.Param("id")); err != nil {
+		savedSearchError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func bindSavedSearch(c *gin.Context) (*models.SavedSearchRequest, bool) {
+	var req models.SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid saved search",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+func savedSearchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, elastic.ErrSavedSearchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+	case errors.Is(err, services.ErrTooManySavedSearches):
+		c.JSON(http.StatusConflict, gin.H{"error": "Saved search limit reached"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Saved search operation failed",
+			"details": err.Error(),
+		})
+	}
+}
@@ -1,22 +1,28 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/logging"
 	"wikidocify/elasticsearch-service/internal/models"
 	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
 
 	"github.com/gin-gonic/gin"
 )
 
 type SearchHandler struct {
-    searchService *services.SearchService
+    searchService    *services.SearchService
+    analyticsService *services.AnalyticsService // nil when analytics are disabled
 }
 
-func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+func NewSearchHandler(searchService *services.SearchService, analyticsService *services.AnalyticsService) *SearchHandler {
     return &SearchHandler{
-        searchService: searchService,
+        searchService:    searchService,
+        analyticsService: analyticsService,
     }
 }
 
@@ -49,8 +55,26 @@ func (h *SearchHandler) Search(c *gin.Context) {
         req.Offset = 0
     }
 
+    // Tenant is resolved by tenant.Middleware, never taken from the query binding
+    req.TenantID = tenant.FromContext(c.Request.Context())
+
     // Perform search
-    docs, total, err := h.searchService.Search(&req)
+    start := time.Now()
+    result, err := h.searchService.Search(c.Request.Context(), &req)
+    took := time.Since(start)
+    queryID := logging.NewRequestID()
+    if errors.Is(err, elastic.ErrNoTenant) {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": "Tenant is required",
+        })
+        return
+    }
+    if errors.Is(err, elastic.ErrNoIdentity) {
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "error": "Authentication required",
+        })
+        return
+    }
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
             "error":   "Search failed",
@@ -59,38 +83,16 @@ func (h *SearchHandler) Search(c *gin.Context) {
         return
     }
 
+    if h.analyticsService != nil {
+        h.analyticsService.RecordSearch(c.Request.Context(), queryID, &req, result, took)
+    }
+
     response := models.SearchResponse{
-        Documents: docs,
-        Total:     total,
+        QueryID:   queryID,
+        Documents: result.Documents,
+        Total:     result.Total,
         Query:     req.Query,
-        Took:      0, // Optionally set if you measure time
+        Took:      result.Took.Milliseconds(),
     }
     c.JSON(http.StatusOK, response)
 }
-
-// Health handles health check requests
-func (h *SearchHandler) Health(c *gin.Context) {
-    healthStatus := h.searchService.HealthCheck()
-
-    response := models.HealthResponse{
-        Timestamp:       time.Now(),
-        ElasticsearchOK: healthStatus["elasticsearch"],
-        DocServiceOK:    healthStatus["doc_service"],
-        Details:         make(map[string]string),
-    }
-
-    // Determine overall status
-    if response.ElasticsearchOK && response.DocServiceOK {
-        response.Status = "healthy"
-        c.JSON(http.StatusOK, response)
-    } else {
-        response.Status = "unhealthy"
-        if !response.ElasticsearchOK {
-            response.Details["elasticsearch"] = "Connection failed"
-        }
-        if !response.DocServiceOK {
-            response.Details["doc_service"] = "Connection failed"
-        }
-        c.JSON(http.StatusServiceUnavailable, response)
-    }
-}
\ No newline at end of file
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SyncHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSyncHandler(searchService *services.SearchService) *SyncHandler {
+	return &SyncHandler{
+		searchService: searchService,
+	}
+}
+
+// FullSync starts a full sync in the background and returns immediately.
+// The optional tenant_id query parameter limits the sync to one tenant.
+func (h *SyncHandler) FullSync(c *gin.Context) {
+	tenantID, ok := syncTenant(c, "")
+	if !ok {
+		return
+	}
+	if h.searchService.SyncInProgress() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": services.ErrSyncInProgress.Error(),
+		})
+		return
+	}
+
+	// The sync outlives the request, so keep only the request ID
+	ctx := logging.WithRequestID(context.Background(), logging.RequestIDFromContext(c.Request.Context()))
+	go func() {
+		if err := h.searchService.FullSync(ctx, tenantID); err != nil && !errors.Is(err, services.ErrSyncInProgress) {
+			slog.ErrorContext(ctx, "Manual full sync failed", "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   "Full sync started",
+		"tenant_id": tenantID,
+	})
+}
+
+// SyncDocument re-indexes a single document from the doc service. Admin
+// credentials bound to a tenant get 404 for another tenant's document.
+func (h *SyncHandler) SyncDocument(c *gin.Context) {
+	id, ok := parseDocumentID(c)
+	if !ok {
+		return
+	}
+	tenantID, ok := syncTenant(c, "")
+	if !ok {
+		return
+	}
+
+	_, err := h.searchService.SyncDocument(c.Request.Context(), tenantID, id)
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Document not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to sync document",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Document synced",
+		"id":      id,
+	})
+}
+
+// DeleteDocument removes a single document from the search index. The
+// tenant_id query parameter names the tenant's index; it defaults to the
+// default tenant.
+func (h *SyncHandler) DeleteDocument(c *gin.Context) {
+	id, ok := parseDocumentID(c)
+	if !ok {
+		return
+	}
+	tenantID, ok := syncTenant(c, tenant.Default)
+	if !ok {
+		return
+	}
+
+	if err := h.searchService.DeleteDocument(c.Request.Context(), tenantID, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete document",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Document deleted from index",
+		"id":      id,
+	})
+}
+
+// Status returns the sync configuration and last sync result. It covers
+// every tenant's runs, so the route is closed to tenant-bound credentials.
+func (h *SyncHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, h.searchService.GetSyncStatus(c.Request.Context()))
+}
+
+// syncTenant returns the tenant named by the tenant_id query parameter or the
+// X-Tenant-ID header, or fallback. Admin credentials bound to a tenant can
+// only act on that tenant.
+func syncTenant(c *gin.Context, fallback string) (string, bool) {
+	tenantID := c.Query("tenant_id")
+	if tenantID == "" {
+		tenantID = c.GetHeader(tenant.Header)
+	}
+	identity := auth.IdentityFromContext(c.Request.Context())
+	if tenantID == "" && identity != nil && identity.Tenant != "" {
+		tenantID = identity.Tenant
+	}
+	if tenantID == "" {
+		return fallback, true
+	}
+
+	if !tenant.Valid(tenantID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid tenant ID",
+		})
+		return "", false
+	}
+	if identity != nil && identity.Tenant != "" && identity.Tenant != tenantID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Credentials are not valid for tenant " + tenantID,
+		})
+		return "", false
+	}
+	return tenantID, true
+}
+
+func parseDocumentID(c *gin.Context) (uint32, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid document ID",
+		})
+		return 0, false
+	}
+	return uint32(id), true
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend/memory"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSyncDocumentChecksTheTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	docs := map[string]*models.Document{
+		"1": {ID: 1, TenantID: "acme", Title: "Roadmap", Visibility: models.VisibilityPublic},
+		"2": {ID: 2, TenantID: "globex", Title: "Salaries", Visibility: models.VisibilityPublic},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /documents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(doc)
+	})
+	docService := httptest.NewServer(mux)
+	defer docService.Close()
+
+	searchService := services.NewSearchService(memory.New(), services.NewDocServiceClient(docService.URL, "", time.Second), time.Hour, 10, false)
+	handler := NewSyncHandler(searchService)
+
+	tests := []struct {
+		name   string
+		tenant string // of the admin credentials
+		id     string
+		want   int
+	}{
+		{"own tenant", "acme", "1", http.StatusOK},
+		{"other tenant", "acme", "2", http.StatusNotFound},
+		{"every tenant", "", "2", http.StatusOK},
+		{"missing", "", "3", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/sync/document/:id", func(c *gin.Context) {
+				identity := &auth.Identity{Subject: "ci", Method: "api_key", Scopes: []string{auth.ScopeAdmin}, Tenant: tt.tenant}
+				c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+			}, handler.SyncDocument)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sync/document/"+tt.id, nil))
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSynonymsBody caps the size of an uploaded synonyms file
+const maxSynonymsBody = 1 << 20
+
+type SynonymsHandler struct {
+	synonymService *services.SynonymService
+}
+
+func NewSynonymsHandler(synonymService *services.SynonymService) *SynonymsHandler {
+	return &SynonymsHandler{
+		synonymService: synonymService,
+	}
+}
+
+// Get returns the synonym rules in effect and their version
+func (h *SynonymsHandler) Get(c *gin.Context) {
+	current, err := h.synonymService.Current(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get synonyms",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, current)
+}
+
+// Put replaces the synonym rules. The body is either JSON ({"rules": [...]})
+// or, with Content-Type text/plain, a synonyms file with one rule per line.
+// Malformed rules are rejected with their line numbers and nothing changes.
+func (h *SynonymsHandler) Put(c *gin.Context) {
+	var rules []string
+	var lines []int
+	if strings.HasPrefix(c.ContentType(), "text/plain") {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSynonymsBody))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read synonyms"})
+			return
+		}
+		rules, lines = services.ParseSynonymText(string(body))
+	} else {
+		var req models.SynonymRulesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid synonyms",
+				"details": err.Error(),
+			})
+			return
+		}
+		rules = req.Rules
+	}
+
+	if errs := services.ValidateSynonymRules(rules, lines); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid synonym rules",
+			"errors": errs,
+		})
+		return
+	}
+	for i := range rules {
+		rules[i] = strings.TrimSpace(rules[i])
+	}
+
+	version, err := h.synonymService.Update(c.Request.Context(), caller(c), rules)
+	if err != nil {
+		synonymsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// Versions lists the synonym versions, newest first
+func (h *SynonymsHandler) Versions(c *gin.Context) {
+	versions, err := h.synonymService.Versions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list synonym versions",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// Rollback re-applies the rules of an earlier version as a new version
+func (h *SynonymsHandler) Rollback(c *gin.Context) {
+	var req models.SynonymRollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid rollback",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	version, err := h.synonymService.Rollback(c.Request.Context(), caller(c), req.Version)
+	if err != nil {
+		synonymsError(c, err)
+		return
+	}
+	if version == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Synonym version not found"})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+func synonymsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, elastic.ErrInvalidSynonyms):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Elasticsearch rejected the synonym rules",
+			"details": err.Error(),
+		})
+	case errors.Is(err, elastic.ErrSynonymVersionExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Synonyms were changed concurrently, retry"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update synonyms",
+			"details": err.Error(),
+		})
+	}
+}
+
+// caller names the authenticated caller for audit fields
+func caller(c *gin.Context) string {
+	identity := auth.IdentityFromContext(c.Request.Context())
+	if identity == nil {
+		return ""
+	}
+	return identity.Method + ":" + identity.Subject
+}
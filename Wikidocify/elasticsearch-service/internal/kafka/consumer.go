@@ -4,36 +4,58 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"log/slog"
 	"strconv"
+	"sync"
 	"time"
 
+	"wikidocify/elasticsearch-service/internal/models"
 	"wikidocify/elasticsearch-service/internal/services"
+	"wikidocify/elasticsearch-service/internal/tenant"
+	"wikidocify/elasticsearch-service/internal/tracing"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type DocEvent struct {
-	Event   string `json:"event"`   // "created", "updated", "deleted"
-	ID      string `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Event    string `json:"event"` // "created", "updated", "deleted"
+	TenantID string `json:"tenant_id"`
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
 }
 
-// StartConsumer starts a Kafka consumer for document events.
-// It listens for "created", "updated", and "deleted" events and syncs/deletes documents in Elasticsearch.
-func StartConsumer(searchService *services.SearchService) {
-	broker := os.Getenv("KAFKA_BROKER")
-	topic := os.Getenv("KAFKA_TOPIC")
-	groupID := os.Getenv("KAFKA_GROUP_ID")
-	if broker == "" || topic == "" {
-		log.Fatal("KAFKA_BROKER and KAFKA_TOPIC must be set")
-	}
-	if groupID == "" {
-		groupID = "search-service-group"
-	}
+// Consumer states reported by Consumer.Status.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateError    = "error"
+)
+
+// Consumer reads document events from Kafka and applies them to the search
+// index. It keeps enough state to report its health.
+type Consumer struct {
+	reader        *kafka.Reader
+	searchService *services.SearchService
+	savedSearches *services.SavedSearchService
+	topic         string
+	groupID       string
 
+	mu            sync.RWMutex
+	state         string
+	lastError     string
+	lastMessageAt time.Time
+	processed     int64
+	failed        int64
+}
+
+// NewConsumer creates a consumer of topic on broker in consumer group
+// groupID.
+func NewConsumer(searchService *services.SearchService, broker, topic, groupID string) *Consumer {
 	r := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:     []string{broker},
 		Topic:       topic,
@@ -43,43 +65,156 @@ func StartConsumer(searchService *services.SearchService) {
 		StartOffset: kafka.LastOffset,
 	})
 
-	log.Println("🔄 Kafka consumer started for topic:", topic, "with group:", groupID)
+	return &Consumer{
+		reader:        r,
+		searchService: searchService,
+		topic:         topic,
+		groupID:       groupID,
+		state:         StateStarting,
+	}
+}
+
+// SetSavedSearches enables saved search alerts: every document indexed from
+// an event is percolated against the saved searches. It must be called
+// before Run.
+func (c *Consumer) SetSavedSearches(savedSearches *services.SavedSearchService) {
+	c.savedSearches = savedSearches
+}
+
+// Run consumes messages until the process exits.
+// It listens for "created", "updated", and "deleted" events and syncs/deletes documents in Elasticsearch.
+func (c *Consumer) Run() {
+	slog.Info("Kafka consumer started", "topic", c.topic, "group_id", c.groupID)
 	ctx := context.Background()
 	for {
-		msg, err := r.ReadMessage(ctx)
+		msg, err := c.reader.ReadMessage(ctx)
 		if err != nil {
-			log.Printf("Kafka read error: %v", err)
+			slog.Error("Kafka read error", "error", err)
+			c.setState(StateError, err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
-		var ev DocEvent
-		if err := json.Unmarshal(msg.Value, &ev); err != nil {
-			log.Printf("Failed to unmarshal Kafka event: %v", err)
-			continue
+		c.setState(StateRunning, nil)
+		ok := handleMessage(c.searchService, c.savedSearches, msg)
+
+		c.mu.Lock()
+		c.lastMessageAt = time.Now()
+		if ok {
+			c.processed++
+		} else {
+			c.failed++
 		}
-		log.Printf("Received Kafka event: %+v", ev)
+		c.mu.Unlock()
+	}
+}
+
+// Status reports the consumer state and its lag behind the partition head.
+func (c *Consumer) Status() models.ConsumerStatus {
+	stats := c.reader.Stats()
 
-		id, err := strconv.ParseUint(ev.ID, 10, 32)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return models.ConsumerStatus{
+		State:         c.state,
+		Topic:         c.topic,
+		GroupID:       c.groupID,
+		Lag:           stats.Lag,
+		Processed:     c.processed,
+		Failed:        c.failed,
+		LastMessageAt: c.lastMessageAt,
+		LastError:     c.lastError,
+	}
+}
+
+func (c *Consumer) setState(state string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+	if err != nil {
+		c.lastError = err.Error()
+	}
+}
+
+// handleMessage applies a single document event to the search index and
+// reports whether it succeeded. Indexed documents are percolated when
+// savedSearches is non-nil. The span continues the trace started by the
+// producer, using the context carried in the message headers.
+func handleMessage(searchService *services.SearchService, savedSearches *services.SavedSearchService, msg kafka.Message) bool {
+	ctx, span := tracing.Tracer().Start(messageContext(msg), msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	var ev DocEvent
+	if err := json.Unmarshal(msg.Value, &ev); err != nil {
+		slog.ErrorContext(ctx, "Failed to unmarshal Kafka event", "offset", msg.Offset, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid event payload")
+		return false
+	}
+	// Events from producers that predate tenants belong to the default tenant
+	if ev.TenantID == "" {
+		ev.TenantID = tenant.Default
+	}
+	// Never log ev.Title/ev.Content: the payload carries the document body.
+	logger := slog.With("doc_id", ev.ID, "tenant_id", ev.TenantID, "event_type", ev.Event)
+	logger.DebugContext(ctx, "Received Kafka event", "offset", msg.Offset)
+	span.SetAttributes(
+		attribute.String("document.id", ev.ID),
+		attribute.String("tenant.id", ev.TenantID),
+		attribute.String("event.type", ev.Event),
+	)
+	if !tenant.Valid(ev.TenantID) {
+		logger.ErrorContext(ctx, "Invalid tenant ID in event")
+		span.SetStatus(codes.Error, "invalid tenant id")
+		return false
+	}
+	ctx = tenant.WithTenant(ctx, ev.TenantID)
+
+	id, err := strconv.ParseUint(ev.ID, 10, 32)
+	if err != nil {
+		logger.ErrorContext(ctx, "Invalid document ID in event", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid document id")
+		return false
+	}
+
+	switch ev.Event {
+	case "created", "updated":
+		doc, err := searchService.SyncDocument(ctx, "", uint32(id))
 		if err != nil {
-			log.Printf("Invalid document ID in event: %v", err)
-			continue
+			logger.ErrorContext(ctx, "Failed to sync document", "error", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "sync failed")
+			return false
 		}
-
-		switch ev.Event {
-		case "created", "updated":
-			if err := searchService.SyncDocument(uint32(id)); err != nil {
-				log.Printf("Failed to sync document (ID %d): %v", id, err)
-			} else {
-				log.Printf("Successfully synced document (ID %d)", id)
-			}
-		case "deleted":
-			if err := searchService.DeleteDocument(uint32(id)); err != nil {
-				log.Printf("Failed to delete document (ID %d): %v", id, err)
-			} else {
-				log.Printf("Successfully deleted document (ID %d)", id)
+		// The document is indexed either way; a failed percolation only
+		// costs its alerts
+		if savedSearches != nil {
+			if err := savedSearches.Percolate(ctx, doc); err != nil {
+				logger.ErrorContext(ctx, "Failed to check saved searches", "error", err)
+				span.RecordError(err)
 			}
-		default:
-			log.Printf("Unknown event type: %s", ev.Event)
 		}
+	case "deleted":
+		if err := searchService.DeleteDocument(ctx, ev.TenantID, uint32(id)); err != nil {
+			logger.ErrorContext(ctx, "Failed to delete document", "error", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "delete failed")
+			return false
+		}
+	default:
+		logger.WarnContext(ctx, "Unknown event type")
+		return false
 	}
+
+	logger.InfoContext(ctx, "Processed Kafka event", "duration_ms", time.Since(start).Milliseconds())
+	return true
 }
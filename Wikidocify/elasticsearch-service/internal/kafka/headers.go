@@ -0,0 +1,52 @@
+// internal/kafka/headers.go
+package kafka
+
+import (
+	"context"
+
+	"wikidocify/elasticsearch-service/internal/logging"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// messageContext returns a context carrying the trace context and request
+// ID the producer put in the message headers.
+func messageContext(msg kafka.Message) context.Context {
+	carrier := headerCarrier{msg: &msg}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+	return logging.WithRequestID(ctx, carrier.Get(logging.RequestIDHeader))
+}
+
+// headerCarrier adapts Kafka message headers to propagation.TextMapCarrier
+// so trace context can be extracted from consumed messages.
+type headerCarrier struct {
+	msg *kafka.Message
+}
+
+func (h headerCarrier) Get(key string) string {
+	for _, hdr := range h.msg.Headers {
+		if hdr.Key == key {
+			return string(hdr.Value)
+		}
+	}
+	return ""
+}
+
+func (h headerCarrier) Set(key, value string) {
+	for i, hdr := range h.msg.Headers {
+		if hdr.Key == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, hdr := range h.msg.Headers {
+		keys[i] = hdr.Key
+	}
+	return keys
+}
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// producedTraceparent is the W3C trace context header the file upload
+// service's producer writes for trace 4bf92f35… and span 00f067aa…; its
+// producer test checks it writes exactly these headers
+const producedTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestMessageContext(t *testing.T) {
+	if _, err := tracing.Init(context.Background(), "test", tracing.ExporterNone, 1); err != nil {
+		t.Fatalf("tracing.Init: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		headers       []kafka.Header
+		wantRequestID string
+		wantTraceID   string
+	}{
+		{
+			name: "produced headers",
+			headers: []kafka.Header{
+				{Key: "traceparent", Value: []byte(producedTraceparent)},
+				{Key: logging.RequestIDHeader, Value: []byte("req-1")},
+			},
+			wantRequestID: "req-1",
+			wantTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:          "request ID only",
+			headers:       []kafka.Header{{Key: logging.RequestIDHeader, Value: []byte("req-2")}},
+			wantRequestID: "req-2",
+		},
+		{
+			name:        "trace context only",
+			headers:     []kafka.Header{{Key: "traceparent", Value: []byte(producedTraceparent)}},
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{name: "no headers"},
+		{
+			name:    "invalid trace context",
+			headers: []kafka.Header{{Key: "traceparent", Value: []byte("garbage")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := messageContext(kafka.Message{Headers: tt.headers})
+			if got := logging.RequestIDFromContext(ctx); got != tt.wantRequestID {
+				t.Errorf("expected request ID %q, got %q", tt.wantRequestID, got)
+			}
+			sc := trace.SpanContextFromContext(ctx)
+			if tt.wantTraceID == "" {
+				if sc.IsValid() {
+					t.Errorf("expected no span context, got %v", sc)
+				}
+				return
+			}
+			if got := sc.TraceID().String(); got != tt.wantTraceID {
+				t.Errorf("expected trace ID %s, got %s", tt.wantTraceID, got)
+			}
+			if !sc.IsRemote() || !sc.IsSampled() {
+				t.Errorf("expected a remote sampled parent, got %v", sc)
+			}
+		})
+	}
+}
+
+func TestHeaderCarrier(t *testing.T) {
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "a", Value: []byte("1")}}}
+	carrier := headerCarrier{msg: &msg}
+	carrier.Set("a", "2")
+	carrier.Set("b", "3")
+
+	if got := carrier.Get("a"); got != "2" {
+		t.Errorf("expected the existing header to be replaced, got %q", got)
+	}
+	if got := carrier.Get("b"); got != "3" {
+		t.Errorf("expected the new header to be added, got %q", got)
+	}
+	if got := carrier.Get("c"); got != "" {
+		t.Errorf("expected no value for a missing header, got %q", got)
+	}
+	if keys := carrier.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("expected keys [a b], got %v", keys)
+	}
+}
@@ -0,0 +1,60 @@
+// internal/language/language.go
+package language
+
+import (
+	"slices"
+	"unicode/utf8"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// Supported languages, as ISO 639-1 codes
+const (
+	English = "en"
+	German  = "de"
+	Arabic  = "ar"
+)
+
+// Supported lists the languages documents are analyzed in
+var Supported = []string{English, German, Arabic}
+
+// Default is used for documents whose language can't be detected
+const Default = English
+
+// maxDetectBytes bounds the text examined; the start of a document is
+// enough to tell its language
+const maxDetectBytes = 8 << 10
+
+var detectOptions = whatlanggo.Options{
+	Whitelist: map[whatlanggo.Lang]bool{
+		whatlanggo.Eng: true,
+		whatlanggo.Deu: true,
+		whatlanggo.Arb: true,
+	},
+}
+
+// Valid reports whether lang is a supported language code
+func Valid(lang string) bool {
+	return slices.Contains(Supported, lang)
+}
+
+// Detect returns the supported language text is most likely written in, or
+// Default if it can't tell.
+func Detect(text string) string {
+	if len(text) > maxDetectBytes {
+		// Cut at a rune boundary
+		n := maxDetectBytes
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n]
+	}
+	info := whatlanggo.DetectWithOptions(text, detectOptions)
+	if info.Confidence == 0 {
+		return Default
+	}
+	if lang := info.Lang.Iso6391(); Valid(lang) {
+		return lang
+	}
+	return Default
+}
@@ -0,0 +1,37 @@
+package language
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "The quarterly roadmap describes the features we plan to ship before the end of the year.", English},
+		{"german", "Die Straßenbahn fährt jeden Morgen pünktlich um acht Uhr vom Hauptbahnhof in die Innenstadt.", German},
+		{"arabic", "تصف خارطة الطريق الفصلية الميزات التي نخطط لإطلاقها قبل نهاية العام.", Arabic},
+		{"empty", "", Default},
+		{"no letters", "1234 5678 -- 42", Default},
+		// The limit falls in the middle of a two-byte letter
+		{"long text cut in a rune", "a " + strings.Repeat("ب", maxDetectBytes), Arabic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	for lang, want := range map[string]bool{"en": true, "de": true, "ar": true, "fr": false, "": false, "EN": false} {
+		if got := Valid(lang); got != want {
+			t.Errorf("Valid(%q): expected %v, got %v", lang, want, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// internal/leader/elasticsearch.go
+package leader
+
+import (
+	"context"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+)
+
+// Elasticsearch is a Lock kept as a lease document in an Elasticsearch
+// index, written with optimistic concurrency control; see
+// elastic.Client.AcquireLease.
+type Elasticsearch struct {
+	client *elastic.Client
+	index  string
+	name   string
+}
+
+// NewElasticsearch returns a Lock on the lease document name in index,
+// which must exist; see elastic.Client.EnsureLeaseIndex.
+func NewElasticsearch(client *elastic.Client, index, name string) *Elasticsearch {
+	return &Elasticsearch{client: client, index: index, name: name}
+}
+
+func (l *Elasticsearch) Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error) {
+	lease, err := l.client.AcquireLease(ctx, l.index, l.name, holder, ttl)
+	if err != nil || lease == nil {
+		return "", err
+	}
+	return lease.Holder, nil
+}
+
+func (l *Elasticsearch) Release(ctx context.Context, holder string) error {
+	return l.client.ReleaseLease(ctx, l.index, l.name, holder)
+}
@@ -0,0 +1,157 @@
+// internal/leader/leader.go
+package leader
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// Lock is a lease on leadership that one holder has at a time. A holder
+// keeps it by calling Acquire again before the TTL runs out; if it stops,
+// because it died or lost its connection, the lease expires and another
+// holder can take it.
+type Lock interface {
+	// Acquire takes or renews the lease for holder for ttl and returns the
+	// holder of the lease afterwards, which is holder if it leads and empty
+	// if the leader isn't known
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error)
+	// Release gives up the lease if holder has it
+	Release(ctx context.Context, holder string) error
+}
+
+// Elector keeps trying to lead through a Lock and renews the lease while it
+// leads. It only reports leading while the lease it last took is unexpired
+// and the last renewal succeeded, so a replica cut off from the lock backend
+// steps down before another can take over.
+type Elector struct {
+	lock          Lock
+	backend       string
+	id            string
+	ttl           time.Duration
+	renewInterval time.Duration
+
+	mu        sync.RWMutex
+	leader    string
+	leaseEnds time.Time // end of this replica's lease while it leads
+	checkedAt time.Time
+	lastErr   error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewElector returns an Elector for replica id. backend names the kind of
+// lock, for Status.
+func NewElector(lock Lock, backend, id string, ttl, renewInterval time.Duration) *Elector {
+	return &Elector{
+		lock:          lock,
+		backend:       backend,
+		id:            id,
+		ttl:           ttl,
+		renewInterval: renewInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start makes a first attempt to lead, so IsLeader is settled when it
+// returns, then renews or retries every renew interval until Stop.
+func (e *Elector) Start() {
+	e.attempt()
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.attempt()
+			}
+		}
+	}()
+}
+
+// Stop stops renewing and releases the lease, so another replica can take
+// over without waiting for it to expire.
+func (e *Elector) Stop(ctx context.Context) error {
+	close(e.stop)
+	<-e.done
+
+	e.mu.Lock()
+	e.leader = ""
+	e.leaseEnds = time.Time{}
+	e.mu.Unlock()
+	return e.lock.Release(ctx, e.id)
+}
+
+// attempt takes or renews the lease. The lease is counted from before the
+// call, as the backend may have granted it at any point during it.
+func (e *Elector) attempt() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+	defer cancel()
+	leader, err := e.lock.Acquire(ctx, e.id, e.ttl)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	wasLeader := e.leading(start)
+	e.checkedAt = time.Now()
+	e.lastErr = err
+	if err != nil {
+		// Whether the lease is still ours can't be told, so step down
+		slog.Warn("Failed to renew sync leadership", "replica", e.id, "backend", e.backend, "error", err)
+		leader = ""
+	}
+	e.leader = leader
+	if leader == e.id {
+		e.leaseEnds = start.Add(e.ttl)
+	} else {
+		e.leaseEnds = time.Time{}
+	}
+
+	switch isLeader := e.leading(start); {
+	case isLeader && !wasLeader:
+		slog.Info("Acquired sync leadership", "replica", e.id, "backend", e.backend)
+	case !isLeader && wasLeader:
+		slog.Warn("Lost sync leadership", "replica", e.id, "leader", leader)
+	}
+}
+
+// leading reports whether this replica's lease runs past now. e.mu must be
+// held.
+func (e *Elector) leading(now time.Time) bool {
+	return e.leader == e.id && now.Before(e.leaseEnds)
+}
+
+// IsLeader reports whether this replica leads
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leading(time.Now())
+}
+
+// Status reports the leader as last seen
+func (e *Elector) Status() models.LeaderStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	status := models.LeaderStatus{
+		Backend:   e.backend,
+		Replica:   e.id,
+		Leader:    e.leader,
+		IsLeader:  e.leading(time.Now()),
+		CheckedAt: e.checkedAt,
+	}
+	if e.leader == e.id && !status.IsLeader {
+		status.Leader = ""
+	}
+	if e.lastErr != nil {
+		status.Error = e.lastErr.Error()
+	}
+	return status
+}
@@ -0,0 +1,91 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryLock is a Lock shared by the electors of a test
+type memoryLock struct {
+	mu        sync.Mutex
+	holder    string
+	expiresAt time.Time
+	err       error
+}
+
+func (l *memoryLock) Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return "", l.err
+	}
+	if l.holder == "" || l.holder == holder || time.Now().After(l.expiresAt) {
+		l.holder = holder
+		l.expiresAt = time.Now().Add(ttl)
+	}
+	return l.holder, nil
+}
+
+func (l *memoryLock) Release(ctx context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == holder {
+		l.holder = ""
+	}
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	lock := &memoryLock{}
+	a := NewElector(lock, "memory", "replica-a", time.Minute, time.Hour)
+	b := NewElector(lock, "memory", "replica-b", time.Minute, time.Hour)
+	a.Start()
+	b.Start()
+	defer b.Stop(context.Background())
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected replica-a alone to lead, got %v and %v", a.IsLeader(), b.IsLeader())
+	}
+	if status := b.Status(); status.Leader != "replica-a" || status.Replica != "replica-b" || status.IsLeader {
+		t.Errorf("unexpected status of replica-b: %+v", status)
+	}
+
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if a.IsLeader() {
+		t.Error("expected replica-a to step down when stopped")
+	}
+	b.attempt()
+	if !b.IsLeader() {
+		t.Error("expected replica-b to take over the released lease")
+	}
+}
+
+func TestElectorStepsDownOnError(t *testing.T) {
+	lock := &memoryLock{}
+	e := NewElector(lock, "memory", "replica-a", time.Minute, time.Hour)
+	e.attempt()
+	if !e.IsLeader() {
+		t.Fatal("expected replica-a to lead")
+	}
+
+	lock.err = errors.New("connection refused")
+	e.attempt()
+	status := e.Status()
+	if e.IsLeader() || status.Leader != "" || status.Error != "connection refused" {
+		t.Fatalf("expected replica-a to step down and report the error, got %+v", status)
+	}
+}
+
+func TestElectorLeaseExpires(t *testing.T) {
+	e := NewElector(&memoryLock{}, "memory", "replica-a", time.Millisecond, time.Hour)
+	e.attempt()
+	time.Sleep(5 * time.Millisecond)
+	if e.IsLeader() {
+		t.Fatal("expected the lease to have run out without renewal")
+	}
+}
@@ -0,0 +1,124 @@
+// internal/leader/postgres.go
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Postgres is a Lock kept as a session-level advisory lock on a dedicated
+// connection. The lock lasts as long as the session: it goes when the
+// leader's process dies and its connection closes, and, as the session's
+// idle_session_timeout is the TTL, when the leader stops renewing, which
+// only pings the connection. Holders are told apart by the connection's
+// application_name, which is how other replicas learn the leader.
+type Postgres struct {
+	url string
+	key int64
+
+	mu   sync.Mutex
+	conn *pgx.Conn
+	held bool
+}
+
+// NewPostgres returns a Lock on the advisory lock named name in the
+// database at url. The name is hashed to a 32-bit key, so that pg_locks
+// shows it in objid alone.
+func NewPostgres(url, name string) *Postgres {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return &Postgres{url: url, key: int64(h.Sum32())}
+}
+
+func (l *Postgres) Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		if err := l.connect(ctx, holder, ttl); err != nil {
+			return "", err
+		}
+	}
+	if l.held {
+		if err := l.conn.Ping(ctx); err != nil {
+			l.close()
+			return "", fmt.Errorf("lost the connection holding the advisory lock: %w", err)
+		}
+		return holder, nil
+	}
+
+	var acquired bool
+	if err := l.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		l.close()
+		return "", fmt.Errorf("failed to try the advisory lock: %w", err)
+	}
+	if acquired {
+		l.held = true
+		return holder, nil
+	}
+
+	var leader string
+	err := l.conn.QueryRow(ctx, `
+		SELECT a.application_name
+		FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted
+		  AND l.classid::bigint = 0 AND l.objid::bigint = $1 AND l.objsubid = 1`,
+		l.key,
+	).Scan(&leader)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Released since we tried
+		return "", nil
+	}
+	if err != nil {
+		l.close()
+		return "", fmt.Errorf("failed to look up the advisory lock holder: %w", err)
+	}
+	return leader, nil
+}
+
+func (l *Postgres) Release(ctx context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	var err error
+	if l.held {
+		_, err = l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	}
+	l.close()
+	return err
+}
+
+// connect opens the session the lock is taken in. l.mu must be held.
+func (l *Postgres) connect(ctx context.Context, holder string, ttl time.Duration) error {
+	config, err := pgx.ParseConfig(l.url)
+	if err != nil {
+		return fmt.Errorf("invalid Postgres URL: %w", err)
+	}
+	config.RuntimeParams["application_name"] = holder
+	config.RuntimeParams["idle_session_timeout"] = strconv.FormatInt(ttl.Milliseconds(), 10)
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+	l.conn = conn
+	return nil
+}
+
+// close ends the session, which releases the lock if it is held. l.mu must
+// be held.
+func (l *Postgres) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = l.conn.Close(ctx)
+	l.conn = nil
+	l.held = false
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var requestIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		incoming string
+		path     string
+		status   int
+		level    string
+	}{
+		{"echoes the caller's ID", "caller-id", "/documents/1", http.StatusOK, "INFO"},
+		{"generates an ID", "", "/documents/1", http.StatusOK, "INFO"},
+		{"client error", "caller-id", "/documents/1?fail=400", http.StatusBadRequest, "WARN"},
+		{"server error", "", "/documents/1?fail=500", http.StatusInternalServerError, "ERROR"},
+		{"unmatched route", "", "/missing", http.StatusNotFound, "WARN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLogs(t)
+			var seen string
+			router := gin.New()
+			router.Use(RequestID(), AccessLog())
+			router.GET("/documents/:id", func(c *gin.Context) {
+				seen = RequestIDFromContext(c.Request.Context())
+				switch c.Query("fail") {
+				case "400":
+					c.Status(http.StatusBadRequest)
+				case "500":
+					c.Status(http.StatusInternalServerError)
+				default:
+					c.Status(http.StatusOK)
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.incoming != "" && id != tt.incoming {
+				t.Errorf("expected the response ID %q, got %q", tt.incoming, id)
+			}
+			if tt.incoming == "" && !requestIDPattern.MatchString(id) {
+				t.Errorf("expected a generated 128-bit hex ID, got %q", id)
+			}
+			if w.Code != http.StatusNotFound && seen != id {
+				t.Errorf("expected the handler context to carry %q, got %q", id, seen)
+			}
+
+			record := lastRecord(t, buf)
+			want := map[string]any{
+				"msg":        "http request",
+				"level":      tt.level,
+				"request_id": id,
+				"status":     float64(tt.status),
+				"method":     http.MethodGet,
+			}
+			for key, value := range want {
+				if record[key] != value {
+					t.Errorf("expected %s %v, got %v", key, value, record[key])
+				}
+			}
+			if w.Code == http.StatusNotFound && record["route"] != "unmatched" {
+				t.Errorf("expected the unmatched route, got %v", record["route"])
+			}
+			if w.Code != http.StatusNotFound && record["route"] != "/documents/:id" {
+				t.Errorf("expected the route template, got %v", record["route"])
+			}
+		})
+	}
+}
+
+func TestNewRequestIDIsUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		id := NewRequestID()
+		if !requestIDPattern.MatchString(id) {
+			t.Fatalf("expected a 128-bit hex ID, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("expected unique IDs, got %q twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleCount returns the number of observations of a histogram series
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/api/v1/sync/document/:id", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	requests := HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/api/v1/sync/document/:id", "404")
+	unmatched := HTTPRequestsTotal.WithLabelValues(http.MethodGet, "unmatched", "404")
+	duration := HTTPRequestDuration.WithLabelValues(http.MethodGet, "/api/v1/sync/document/:id")
+	beforeRequests, beforeUnmatched, beforeDuration := testutil.ToFloat64(requests), testutil.ToFloat64(unmatched), sampleCount(t, duration)
+
+	for _, path := range []string{"/api/v1/sync/document/1", "/api/v1/sync/document/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(requests) - beforeRequests; got != 2 {
+		t.Errorf("expected both requests counted under the route template, got %v", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - beforeUnmatched; got != 1 {
+		t.Errorf("expected the unknown path counted as unmatched, got %v", got)
+	}
+	if got := sampleCount(t, duration) - beforeDuration; got != 2 {
+		t.Errorf("expected two latency observations, got %d", got)
+	}
+	if got := testutil.ToFloat64(HTTPRequestsInFlight); got != 0 {
+		t.Errorf("expected no request in flight, got %v", got)
+	}
+}
@@ -2,63 +2,477 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// Document visibility levels, as defined by the doc service
+const (
+	VisibilityPrivate = "private"
+	VisibilityTeam    = "team"
+	VisibilityPublic  = "public"
+)
+
 // Document represents the original document structure from the doc service
 type Document struct {
-	ID        uint32    `json:"id"`
-	Title     string    `json:"title"`
-	Content   []byte    `json:"content"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint32    `json:"id"`
+	TenantID      string    `json:"tenant_id"`
+	Title         string    `json:"title"`
+	Content       []byte    `json:"content"`
+	Author        string    `json:"author"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Owner         string    `json:"owner"`
+	Visibility    string    `json:"visibility"`
+	AllowedUsers  []string  `json:"allowed_users"`
+	AllowedGroups []string  `json:"allowed_groups"`
 }
 
 // SearchDocument represents the document structure in Elasticsearch
 type SearchDocument struct {
-	ID        uint32    `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uint32    `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Owner      string    `json:"owner"`
+	Visibility string    `json:"visibility"`
+	// Language is detected at index time, see the language package
+	Language string `json:"language,omitempty"`
+	// Always sent, even when empty: documents are upserted, so an omitted
+	// list would keep the previously indexed one
+	AllowedUsers  []string `json:"allowed_users"`
+	AllowedGroups []string `json:"allowed_groups"`
+	// Popularity is maintained by the popularity job and never sent on upsert
+	Popularity float64 `json:"popularity,omitempty"`
+
+	// Index, Score, Explanation and Highlights are only set on search hits,
+	// the last two only when the search asked for them. Index is the index
+	// the hit came from, on backends that have several.
+	Index       string            `json:"index,omitempty"`
+	Score       float64           `json:"score,omitempty"`
+	Explanation *ScoreExplanation `json:"explanation,omitempty"`
+	// Highlights maps "title" and "content" to fragments with the matched
+	// terms wrapped in HighlightPreTag and HighlightPostTag
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// Tags around matched terms in SearchDocument.Highlights
+const (
+	HighlightPreTag  = "<mark>"
+	HighlightPostTag = "</mark>"
+)
+
+// ScoreExplanation is the Elasticsearch breakdown of how a hit's score was
+// computed
+type ScoreExplanation struct {
+	Value       float64            `json:"value"`
+	Description string             `json:"description"`
+	Details     []ScoreExplanation `json:"details,omitempty"`
 }
 
-// ToSearchDocument converts Document to SearchDocument
+// ToSearchDocument converts Document to SearchDocument. Documents without a
+// visibility are indexed as private so they are never exposed by accident.
 func (d *Document) ToSearchDocument() *SearchDocument {
+	visibility := d.Visibility
+	if visibility == "" {
+		visibility = VisibilityPrivate
+	}
 	return &SearchDocument{
-		ID:        d.ID,
-		Title:     d.Title,
-		Content:   string(d.Content),
-		Author:    d.Author,
-		CreatedAt: d.CreatedAt,
-		UpdatedAt: d.UpdatedAt,
+		ID:            d.ID,
+		TenantID:      d.TenantID,
+		Title:         d.Title,
+		Content:       string(d.Content),
+		Author:        d.Author,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+		Owner:         d.Owner,
+		Visibility:    visibility,
+		AllowedUsers:  d.AllowedUsers,
+		AllowedGroups: d.AllowedGroups,
 	}
 }
 
 // SearchRequest represents search request parameters
 type SearchRequest struct {
 	Query  string `json:"query" form:"query" binding:"required"`
-	Type   string `json:"type" form:"type"`           // "title", "content", or "all"
-	Limit  int    `json:"limit" form:"limit"`         // default 10
-	Offset int    `json:"offset" form:"offset"`       // default 0
-	Author string `json:"author" form:"author"`       // optional filter
+	Type   string `json:"type" form:"type"`     // "title", "content", or "all"
+	Limit  int    `json:"limit" form:"limit"`   // default 10
+	Offset int    `json:"offset" form:"offset"` // default 0
+	Author string `json:"author" form:"author"` // optional filter
+
+	// Rank selects the ranking mode: "relevance" (BM25 only) or "blended"
+	// (BM25 with popularity and recency); empty uses the configured default
+	Rank string `json:"rank" form:"rank" binding:"omitempty,oneof=relevance blended"`
+	// BoostRecent turns the updated_at decay on or off; nil uses the
+	// configured default
+	BoostRecent *bool `json:"boost_recent" form:"boost_recent"`
+	// Lang searches only documents in that language, with its analyzer;
+	// empty searches every language
+	Lang string `json:"lang" form:"lang" binding:"omitempty,oneof=en de ar"`
+	// Explain returns each hit's score breakdown
+	Explain bool `json:"explain" form:"explain"`
+	// Highlight returns the matching fragments of title and content
+	Highlight bool `json:"highlight" form:"highlight"`
+
+	// TenantID is resolved by the tenant middleware, never bound from input
+	TenantID string `json:"tenant_id" form:"-"`
+}
+
+// SearchResult is a page of search hits
+type SearchResult struct {
+	Documents []SearchDocument `json:"documents"`
+	Total     int64            `json:"total"`
+	// Took is the time the backend spent searching, as it reports it
+	Took time.Duration `json:"took"`
 }
 
 // SearchResponse represents search response
 type SearchResponse struct {
+	QueryID   string           `json:"query_id"` // reported back with clicks
 	Documents []SearchDocument `json:"documents"`
 	Total     int64            `json:"total"`
-	Took      int64            `json:"took_ms"`
+	Took      int64            `json:"took_ms"` // backend search time; 0 for cached results
 	Query     string           `json:"query"`
 }
 
-// HealthResponse represents health check response
+// HealthResponse represents the readiness and detailed health responses
 type HealthResponse struct {
-	Status          string            `json:"status"`
-	Timestamp       time.Time         `json:"timestamp"`
-	ElasticsearchOK bool              `json:"elasticsearch_ok"`
-	DocServiceOK    bool              `json:"doc_service_ok"`
-	Details         map[string]string `json:"details,omitempty"`
-}
\ No newline at end of file
+	Status       string                      `json:"status"`
+	Timestamp    time.Time                   `json:"timestamp"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+	Kafka        *ConsumerStatus             `json:"kafka,omitempty"`
+	Sync         *SyncResult                 `json:"sync,omitempty"`
+}
+
+// DependencyStatus is the result of checking a single dependency
+type DependencyStatus struct {
+	Healthy   bool      `json:"healthy"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ConsumerStatus reports the state of the Kafka consumer
+type ConsumerStatus struct {
+	State         string    `json:"state"` // "starting", "running" or "error"
+	Topic         string    `json:"topic"`
+	GroupID       string    `json:"group_id"`
+	Lag           int64     `json:"lag"`
+	Processed     int64     `json:"processed"`
+	Failed        int64     `json:"failed"`
+	LastMessageAt time.Time `json:"last_message_at,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// SyncResult describes the outcome of the most recent full sync
+type SyncResult struct {
+	RunID           string    `json:"run_id,omitempty"`
+	TenantID        string    `json:"tenant_id,omitempty"` // empty for a sync of all tenants
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	Success         bool      `json:"success"`
+	DocumentsSynced int       `json:"documents_synced"`
+	DurationMs      int64     `json:"duration_ms"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Sync run statuses. A run that was interrupted, by a crash or a restart,
+// stays running until it is resumed, which happens once it stopped
+// checkpointing.
+const (
+	SyncRunRunning   = "running"
+	SyncRunCompleted = "completed"
+	SyncRunFailed    = "failed"
+)
+
+// SyncRun is a full sync, checkpointed after every batch so that a run that
+// fails or is interrupted resumes where it stopped
+type SyncRun struct {
+	ID             string     `json:"id"`
+	TenantID       string     `json:"tenant_id"` // empty for a sync of all tenants
+	Status         string     `json:"status"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	CheckpointedAt time.Time  `json:"checkpointed_at"`
+	// LastID is the checkpoint: documents are synced in ID order, and every
+	// document up to LastID has been indexed
+	LastID          uint32   `json:"last_id"`
+	Batches         int      `json:"batches"`
+	DocumentsSynced int      `json:"documents_synced"`
+	Attempts        int      `json:"attempts"`         // 1, plus one per resume
+	Errors          []string `json:"errors,omitempty"` // one per failed attempt
+	// SeqNo and PrimaryTerm are the version of the run as it was last read
+	// or stored, so that a replica only writes the run if no other replica
+	// wrote it since. Both are zero for a run that was never stored.
+	SeqNo       int `json:"-"`
+	PrimaryTerm int `json:"-"`
+}
+
+// Result summarizes a finished run
+func (r *SyncRun) Result() *SyncResult {
+	result := &SyncResult{
+		RunID:           r.ID,
+		TenantID:        r.TenantID,
+		StartedAt:       r.StartedAt,
+		Success:         r.Status == SyncRunCompleted,
+		DocumentsSynced: r.DocumentsSynced,
+	}
+	if r.FinishedAt != nil {
+		result.FinishedAt = *r.FinishedAt
+		result.DurationMs = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	}
+	if r.Status == SyncRunFailed && len(r.Errors) > 0 {
+		result.Error = r.Errors[len(r.Errors)-1]
+	}
+	return result
+}
+
+// Lease is a lock held by one replica until it expires, unless renewed
+type Lease struct {
+	Name       string    `json:"name"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LeaderStatus reports which replica runs the scheduled syncs
+type LeaderStatus struct {
+	Backend   string    `json:"backend"`           // "none", "elasticsearch" or "postgres"
+	Replica   string    `json:"replica,omitempty"` // this replica
+	Leader    string    `json:"leader,omitempty"`  // empty while no replica leads
+	IsLeader  bool      `json:"is_leader"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// SearchEvent is one recorded search, stored in the analytics index
+type SearchEvent struct {
+	QueryID      string    `json:"query_id"`
+	Timestamp    time.Time `json:"timestamp"`
+	TenantID     string    `json:"tenant_id"`
+	Query        string    `json:"query"` // normalized: lowercased, whitespace collapsed
+	Type         string    `json:"type"`
+	Author       string    `json:"author,omitempty"`
+	Limit        int       `json:"limit"`
+	Offset       int       `json:"offset"`
+	Hits         int64     `json:"hits"`
+	LatencyMs    int64     `json:"latency_ms"`
+	Caller       string    `json:"caller"`
+	CallerMethod string    `json:"caller_method"`
+	// ResultIDs are the documents returned; clicks only count towards
+	// popularity on one of them
+	ResultIDs []uint32 `json:"result_ids,omitempty"`
+}
+
+// QueryStats aggregates the recorded searches for one query
+type QueryStats struct {
+	Query        string  `json:"query"`
+	Count        int64   `json:"count"`
+	AvgHits      float64 `json:"avg_hits"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+// AnalyticsQuery selects the recorded searches an analytics report covers
+type AnalyticsQuery struct {
+	TenantID     string
+	From         time.Time
+	To           time.Time
+	Limit        int
+	MinLatencyMs int64 // slow queries only
+}
+
+// ClickRequest reports that a user opened a search result
+type ClickRequest struct {
+	QueryID    string `json:"query_id" binding:"required"`
+	DocumentID uint32 `json:"document_id" binding:"required"`
+	Position   int    `json:"position" binding:"min=0"` // zero-based rank in the result list
+}
+
+// ClickEvent is one recorded click, stored in the clicks index
+type ClickEvent struct {
+	Timestamp  time.Time `json:"timestamp"`
+	TenantID   string    `json:"tenant_id"`
+	QueryID    string    `json:"query_id"`
+	DocumentID uint32    `json:"document_id"`
+	Position   int       `json:"position"`
+	Caller     string    `json:"caller"`
+}
+
+// SavedSearchRequest creates or replaces a saved search. It needs a query,
+// an author or both.
+type SavedSearchRequest struct {
+	Name   string `json:"name" binding:"required,max=200"`
+	Query  string `json:"query" binding:"required_without=Author"`
+	Type   string `json:"type" binding:"omitempty,oneof=title content all"`
+	Author string `json:"author"`
+}
+
+// SavedSearch is a search a user is alerted about whenever a newly indexed
+// document matches it. Group membership can change after the search is
+// saved, so alerts only cover the documents the owner may read by name:
+// public ones, their own and those listing them in allowed_users.
+type SavedSearch struct {
+	ID          string    `json:"id"`
+	TenantID    string    `json:"tenant_id"`
+	Owner       string    `json:"owner"`
+	OwnerMethod string    `json:"owner_method"`
+	Name        string    `json:"name"`
+	Query       string    `json:"query,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SearchMatchEvent is published when an indexed document matches a saved
+// search. It names the document but doesn't carry its content.
+type SearchMatchEvent struct {
+	Event           string    `json:"event"` // always "search.match"
+	ID              string    `json:"id"`    // "<saved search id>:<document id>", stable across retries
+	TenantID        string    `json:"tenant_id"`
+	SavedSearchID   string    `json:"saved_search_id"`
+	SavedSearchName string    `json:"saved_search_name"`
+	Owner           string    `json:"owner"`
+	DocumentID      uint32    `json:"document_id"`
+	Title           string    `json:"title"`
+	Author          string    `json:"author"`
+	MatchedAt       time.Time `json:"matched_at"`
+}
+
+// SynonymRulesRequest replaces the synonym rules. Rules use the Solr
+// format: "k8s, kubernetes" or "es => elasticsearch".
+type SynonymRulesRequest struct {
+	Rules []string `json:"rules"`
+}
+
+// SynonymRollbackRequest re-applies the rules of an earlier version
+type SynonymRollbackRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// SynonymVersion is one applied set of synonym rules. Every change,
+// including a rollback, creates a new version.
+type SynonymVersion struct {
+	Version        int       `json:"version"`
+	Rules          []string  `json:"rules"`
+	CreatedAt      time.Time `json:"created_at"`
+	CreatedBy      string    `json:"created_by"`
+	RolledBackFrom int       `json:"rolled_back_from,omitempty"`
+}
+
+// SynonymRuleError reports why one rule is malformed
+type SynonymRuleError struct {
+	Line  int    `json:"line"` // 1-based
+	Rule  string `json:"rule"`
+	Error string `json:"error"`
+}
+
+// IndexStats describes a tenant's search index
+type IndexStats struct {
+	Index string `json:"index"`
+	// Indices are the concrete indexes behind Index, several if it is an alias
+	Indices          []string `json:"indices"`
+	Documents        int64    `json:"documents"`
+	DeletedDocuments int64    `json:"deleted_documents"` // not yet merged away
+	SizeBytes        int64    `json:"size_bytes"`        // all copies, replicas included
+	PrimarySizeBytes int64    `json:"primary_size_bytes"`
+	Segments         int64    `json:"segments"`
+	// MappingVersion is 0 for indexes created before versions were recorded;
+	// it is brought up to ExpectedMappingVersion the next time the service
+	// writes to the index after a restart
+	MappingVersion         int `json:"mapping_version"`
+	ExpectedMappingVersion int `json:"expected_mapping_version"`
+	// Aliases maps each alias of the index to every index it points at
+	Aliases map[string][]string `json:"aliases"`
+}
+
+// DocumentComparison compares the indexed copy of a document with the doc
+// service copy. Either copy is nil when it doesn't exist.
+type DocumentComparison struct {
+	ID          uint32            `json:"id"`
+	TenantID    string            `json:"tenant_id"`
+	Indexed     *SearchDocument   `json:"indexed"`
+	Source      *SearchDocument   `json:"source"`
+	InSync      bool              `json:"in_sync"`
+	Differences []FieldDifference `json:"differences"`
+}
+
+// FieldDifference is a field whose indexed value differs from the doc
+// service value
+type FieldDifference struct {
+	Field   string      `json:"field"`
+	Indexed interface{} `json:"indexed"`
+	Source  interface{} `json:"source"`
+}
+
+// DeleteByQueryRequest deletes the documents matching a query. Without
+// dry_run=false it only counts them; the deletion itself must pass the
+// count of the dry run as expected_count, and is refused if the number of
+// matching documents changed since.
+type DeleteByQueryRequest struct {
+	Query         json.RawMessage `json:"query" binding:"required"` // Elasticsearch query DSL
+	DryRun        *bool           `json:"dry_run"`                  // default true
+	ExpectedCount *int64          `json:"expected_count"`
+}
+
+// DeleteByQueryResult reports the documents a delete by query matched and,
+// unless it was a dry run, deleted
+type DeleteByQueryResult struct {
+	DryRun  bool  `json:"dry_run"`
+	Matched int64 `json:"matched"`
+	Deleted int64 `json:"deleted"`
+}
+
+// DocumentVersion identifies the current version of a document in the doc
+// service or the search index
+type DocumentVersion struct {
+	ID        uint32    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConsistencyReport is the outcome of comparing a tenant's documents in the
+// doc service with its search index. The lists hold at most a fixed number
+// of IDs each; the counts are complete.
+type ConsistencyReport struct {
+	TenantID   string    `json:"tenant_id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"` // the check stopped early
+
+	SourceDocuments  int `json:"source_documents"`
+	IndexedDocuments int `json:"indexed_documents"`
+
+	// Missing are in the doc service but not indexed
+	Missing      []uint32 `json:"missing"`
+	MissingCount int      `json:"missing_count"`
+	// Stale are indexed with another updated_at than the doc service's
+	Stale      []StaleDocument `json:"stale"`
+	StaleCount int             `json:"stale_count"`
+	// IndexOnly are indexed but not in the doc service
+	IndexOnly      []uint32 `json:"index_only"`
+	IndexOnlyCount int      `json:"index_only_count"`
+
+	// Repair is set when the check was asked to fix the differences
+	Repair *ConsistencyRepair `json:"repair,omitempty"`
+}
+
+// StaleDocument is an indexed document that differs from the doc service
+type StaleDocument struct {
+	ID               uint32    `json:"id"`
+	SourceUpdatedAt  time.Time `json:"source_updated_at"`
+	IndexedUpdatedAt time.Time `json:"indexed_updated_at"`
+}
+
+// ConsistencyRepair counts the fixes made by a consistency check
+type ConsistencyRepair struct {
+	Resynced int      `json:"resynced"` // missing or stale, indexed again
+	Deleted  int      `json:"deleted"`  // index only, removed from the index
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"` // the first few failures
+}
@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestToSearchDocumentDefaultsToPrivate(t *testing.T) {
+	doc := Document{ID: 1, Title: "Roadmap", Owner: "alice"}
+	if got := doc.ToSearchDocument().Visibility; got != VisibilityPrivate {
+		t.Fatalf("expected visibility %q for a document without one, got %q", VisibilityPrivate, got)
+	}
+
+	doc.Visibility = VisibilityTeam
+	doc.AllowedGroups = []string{"platform"}
+	searchDoc := doc.ToSearchDocument()
+	if searchDoc.Visibility != VisibilityTeam || searchDoc.Owner != "alice" || len(searchDoc.AllowedGroups) != 1 {
+		t.Fatalf("ACL fields were not carried over: %+v", searchDoc)
+	}
+}
@@ -0,0 +1,57 @@
+// internal/notify/kafka.go
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publishes alerts to a Kafka topic, keyed by saved search ID so the
+// alerts of one saved search stay in order.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+func NewKafka(broker, topic string) *Kafka {
+	return &Kafka{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(broker),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+func (k *Kafka) Notify(ctx context.Context, event *models.SearchMatchEvent) error {
+	msg, err := message(ctx, event)
+	if err != nil {
+		return err
+	}
+	return k.writer.WriteMessages(ctx, msg)
+}
+
+// message encodes event as a Kafka message, carrying the request ID of ctx
+func message(ctx context.Context, event *models.SearchMatchEvent) (kafka.Message, error) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	msg := kafka.Message{
+		Key:   []byte(event.SavedSearchID),
+		Value: value,
+	}
+	if id := logging.RequestIDFromContext(ctx); id != "" {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: logging.RequestIDHeader, Value: []byte(id)})
+	}
+	return msg, nil
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
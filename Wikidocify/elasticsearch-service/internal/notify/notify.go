@@ -0,0 +1,32 @@
+// internal/notify/notify.go
+package notify
+
+import (
+	"context"
+	"log/slog"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// Notifier delivers saved search alerts.
+type Notifier interface {
+	Notify(ctx context.Context, event *models.SearchMatchEvent) error
+	Close() error
+}
+
+// Log is a Notifier that only logs alerts, for development.
+type Log struct{}
+
+func (Log) Notify(ctx context.Context, event *models.SearchMatchEvent) error {
+	slog.InfoContext(ctx, "Saved search matched",
+		"saved_search_id", event.SavedSearchID,
+		"owner", event.Owner,
+		"tenant_id", event.TenantID,
+		"doc_id", event.DocumentID,
+	)
+	return nil
+}
+
+func (Log) Close() error {
+	return nil
+}
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func testEvent() *models.SearchMatchEvent {
+	return &models.SearchMatchEvent{
+		Event:         "search.match",
+		ID:            "s1:42",
+		TenantID:      "acme",
+		SavedSearchID: "s1",
+		Owner:         "alice",
+		DocumentID:    42,
+		MatchedAt:     time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		status    int
+		wantError bool
+	}{
+		{name: "signed", secret: "s3cret", status: http.StatusNoContent},
+		{name: "unsigned", status: http.StatusOK},
+		{name: "rejected", status: http.StatusInternalServerError, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			ctx := logging.WithRequestID(context.Background(), "req-1")
+			err := NewWebhook(server.URL, tt.secret, time.Second).Notify(ctx, testEvent())
+			if (err != nil) != tt.wantError {
+				t.Fatalf("expected error %v, got %v", tt.wantError, err)
+			}
+
+			var event models.SearchMatchEvent
+			if err := json.Unmarshal(body, &event); err != nil || event != *testEvent() {
+				t.Errorf("expected the event as the body, got %s", body)
+			}
+			if got.Header.Get(logging.RequestIDHeader) != "req-1" {
+				t.Errorf("expected the request ID to be forwarded, got %q", got.Header.Get(logging.RequestIDHeader))
+			}
+			signature := got.Header.Get(SignatureHeader)
+			if tt.secret == "" {
+				if signature != "" {
+					t.Errorf("expected no signature without a secret, got %q", signature)
+				}
+				return
+			}
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			mac.Write(body)
+			if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+				t.Errorf("expected signature %q, got %q", want, signature)
+			}
+		})
+	}
+}
+
+func TestKafkaMessage(t *testing.T) {
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	msg, err := message(ctx, testEvent())
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+	if string(msg.Key) != "s1" {
+		t.Errorf("expected the saved search ID as the key, got %q", msg.Key)
+	}
+	var event models.SearchMatchEvent
+	if err := json.Unmarshal(msg.Value, &event); err != nil || event != *testEvent() {
+		t.Errorf("expected the event as the value, got %s", msg.Value)
+	}
+	if len(msg.Headers) != 1 || msg.Headers[0].Key != logging.RequestIDHeader || string(msg.Headers[0].Value) != "req-1" {
+		t.Errorf("expected the request ID header, got %+v", msg.Headers)
+	}
+
+	msg, _ = message(context.Background(), testEvent())
+	if len(msg.Headers) != 0 {
+		t.Errorf("expected no header without a request ID, got %+v", msg.Headers)
+	}
+}
+
+func TestLog(t *testing.T) {
+	if err := (Log{}).Notify(context.Background(), testEvent()); err != nil {
+		t.Errorf("Notify: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// internal/ratelimit/concurrency.go
+package ratelimit
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// latencySmoothing is the weight of a new sample in the latency moving average.
+const latencySmoothing = 0.2
+
+// ConcurrencyLimiter caps the number of requests hitting Elasticsearch at
+// once. While the average Elasticsearch latency is above the threshold the
+// cap drops to shedLimit, so an overloaded cluster gets room to recover
+// instead of a growing queue.
+type ConcurrencyLimiter struct {
+	maxInFlight int
+	shedLimit   int
+	threshold   time.Duration
+
+	mu         sync.Mutex
+	inFlight   int
+	avgLatency time.Duration
+	shedding   bool
+}
+
+// NewConcurrencyLimiter returns a limiter allowing maxInFlight concurrent
+// requests, or shedLimit while the latency average exceeds threshold.
+func NewConcurrencyLimiter(maxInFlight, shedLimit int, threshold time.Duration) *ConcurrencyLimiter {
+	if shedLimit > maxInFlight {
+		shedLimit = maxInFlight
+	}
+	return &ConcurrencyLimiter{
+		maxInFlight: maxInFlight,
+		shedLimit:   shedLimit,
+		threshold:   threshold,
+	}
+}
+
+// ObserveLatency records the duration of an Elasticsearch request.
+func (l *ConcurrencyLimiter) ObserveLatency(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.avgLatency == 0 {
+		l.avgLatency = d
+	} else {
+		l.avgLatency = time.Duration(latencySmoothing*float64(d) + (1-latencySmoothing)*float64(l.avgLatency))
+	}
+
+	shedding := l.avgLatency > l.threshold
+	if shedding != l.shedding {
+		l.shedding = shedding
+		slog.Warn("Search load shedding changed",
+			"shedding", shedding,
+			"avg_latency_ms", l.avgLatency.Milliseconds(),
+			"threshold_ms", l.threshold.Milliseconds(),
+		)
+	}
+}
+
+func (l *ConcurrencyLimiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.maxInFlight
+	if l.shedding {
+		limit = l.shedLimit
+	}
+	if l.inFlight >= limit {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+func (l *ConcurrencyLimiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+}
+
+// Middleware rejects requests with 503 and Retry-After when the concurrency
+// cap is reached.
+func (l *ConcurrencyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.acquire() {
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Search is overloaded, retry later",
+			})
+			return
+		}
+		defer l.release()
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admitted returns how many requests the limiter lets in at once
+func admitted(l *ConcurrencyLimiter) int {
+	n := 0
+	for l.acquire() {
+		n++
+	}
+	for range n {
+		l.release()
+	}
+	return n
+}
+
+func TestSheddingTransitions(t *testing.T) {
+	l := NewConcurrencyLimiter(4, 1, 300*time.Millisecond)
+
+	tests := []struct {
+		name     string
+		latency  time.Duration
+		samples  int
+		shedding bool
+		want     int
+	}{
+		{"fast", 10 * time.Millisecond, 1, false, 4},
+		{"one slow request", time.Second, 1, false, 4},
+		{"slow on average", time.Second, 2, true, 1},
+		{"recovering", 10 * time.Millisecond, 1, true, 1},
+		{"recovered", 10 * time.Millisecond, 10, false, 4},
+	}
+	for _, tt := range tests {
+		for range tt.samples {
+			l.ObserveLatency(tt.latency)
+		}
+		if l.shedding != tt.shedding || admitted(l) != tt.want {
+			t.Errorf("%s: expected shedding %v admitting %d, got %v admitting %d (average %v)",
+				tt.name, tt.shedding, tt.want, l.shedding, admitted(l), l.avgLatency)
+		}
+	}
+}
+
+func TestShedLimitCappedAtMax(t *testing.T) {
+	l := NewConcurrencyLimiter(2, 10, time.Millisecond)
+	l.ObserveLatency(time.Second)
+	if n := admitted(l); n != 2 {
+		t.Errorf("expected the shed limit to be capped at 2, got %d", n)
+	}
+}
+
+func TestConcurrencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewConcurrencyLimiter(1, 1, time.Second)
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.GET("/search", l.Middleware(), func(c *gin.Context) {
+		if c.Query("block") != "" {
+			close(entered)
+			<-unblock
+		}
+		c.Status(http.StatusOK)
+	})
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve("/search?block=1")
+	}()
+	<-entered
+	if w := serve("/search"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected 503 with Retry-After while at the cap, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(unblock)
+	wg.Wait()
+	if w := serve("/search"); w.Code != http.StatusOK {
+		t.Errorf("expected the slot to be released, got %d", w.Code)
+	}
+}
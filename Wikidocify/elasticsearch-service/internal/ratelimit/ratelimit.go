@@ -0,0 +1,169 @@
+// internal/ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit of the form "rate:burst", e.g. "20:40".
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected rate:burst", s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate in %q", s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 1 {
+		return Limit{}, fmt.Errorf("invalid burst in %q", s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+// ParseRoutes parses per-route limits of the form "route=rate:burst", where
+// route is a gin route path such as "/api/v1/search".
+func ParseRoutes(specs []string) (map[string]Limit, error) {
+	routes := make(map[string]Limit, len(specs))
+	for _, spec := range specs {
+		route, limit, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(route) == "" {
+			return nil, fmt.Errorf("invalid route rate limit %q: expected route=rate:burst", spec)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.TrimSpace(route)] = l
+	}
+	return routes, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// idleBuckets are dropped after this long without requests; by then they
+// have refilled, so dropping them doesn't change any client's allowance.
+const idleBuckets = 10 * time.Minute
+
+// Limiter rate limits clients per route with token buckets.
+type Limiter struct {
+	mu           sync.Mutex
+	defaultLimit Limit
+	routes       map[string]Limit
+	buckets      map[string]*bucket
+	lastSweep    time.Time
+}
+
+// NewLimiter returns a Limiter applying routes[route] to each route, or
+// defaultLimit to routes that are not listed.
+func NewLimiter(defaultLimit Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{
+		defaultLimit: defaultLimit,
+		routes:       routes,
+		buckets:      make(map[string]*bucket),
+		lastSweep:    time.Now(),
+	}
+}
+
+// SetLimits replaces the limits, e.g. on a configuration reload. Clients
+// keep their tokens, up to the new burst.
+func (l *Limiter) SetLimits(defaultLimit Limit, routes map[string]Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.defaultLimit = defaultLimit
+	l.routes = routes
+}
+
+// allow takes a token from the bucket of key on route. It returns the limit
+// applied, the tokens left and, when the request is rejected, how long until
+// a token is available.
+func (l *Limiter) allow(route, key string, now time.Time) (Limit, int, time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.routes[route]
+	if !ok {
+		limit = l.defaultLimit
+	}
+
+	if now.Sub(l.lastSweep) > idleBuckets {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > idleBuckets {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	id := route + "|" + key
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[id] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return limit, 0, wait, false
+	}
+	b.tokens--
+	return limit, int(b.tokens), 0, true
+}
+
+// Middleware rate limits requests per route and client. It must run after
+// the auth middleware so authenticated clients are keyed by identity rather
+// than IP. Responses carry RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers; rejected requests get 429 with Retry-After.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		limit, remaining, wait, ok := l.allow(c.FullPath(), clientKey(c), now)
+
+		// Seconds until the bucket is full again
+		reset := math.Ceil(float64(limit.Burst-remaining) / limit.Rate)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(reset)))
+
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller: the API key name or token subject for
+// authenticated requests, otherwise the client IP.
+func clientKey(c *gin.Context) string {
+	identity := auth.IdentityFromContext(c.Request.Context())
+	if identity != nil && identity.Method != "anonymous" {
+		return identity.Method + ":" + identity.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Limit
+		wantErr bool
+	}{
+		{spec: "20:40", want: Limit{Rate: 20, Burst: 40}},
+		{spec: " 0.1:2 ", want: Limit{Rate: 0.1, Burst: 2}},
+		{spec: "20", wantErr: true},
+		{spec: "0:10", wantErr: true},
+		{spec: "-1:10", wantErr: true},
+		{spec: "fast:10", wantErr: true},
+		{spec: "5:0", wantErr: true},
+		{spec: "5:1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseLimit(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]Limit
+		wantErr bool
+	}{
+		{name: "none", want: map[string]Limit{}},
+		{name: "several", specs: []string{"/api/v1/search=20:40", " /api/v1/sync/full =0.1:2"},
+			want: map[string]Limit{"/api/v1/search": {20, 40}, "/api/v1/sync/full": {0.1, 2}}},
+		{name: "no limit", specs: []string{"/api/v1/search"}, wantErr: true},
+		{name: "no route", specs: []string{"=20:40"}, wantErr: true},
+		{name: "invalid limit", specs: []string{"/api/v1/search=20"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoutes(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for route, limit := range tt.want {
+				if got[route] != limit {
+					t.Errorf("expected %+v for %s, got %+v", limit, route, got[route])
+				}
+			}
+		})
+	}
+}
+
+func TestAllowRefillsTokens(t *testing.T) {
+	l := NewLimiter(Limit{Rate: 1, Burst: 2}, map[string]Limit{"/search": {Rate: 10, Burst: 1}})
+	start := time.Now()
+
+	tests := []struct {
+		name          string
+		route, key    string
+		at            time.Duration
+		wantOK        bool
+		wantRemaining int
+		wantWait      time.Duration
+	}{
+		{"full bucket", "/other", "a", 0, true, 1, 0},
+		{"last token", "/other", "a", 0, true, 0, 0},
+		{"empty bucket", "/other", "a", 0, false, 0, time.Second},
+		{"half refilled", "/other", "a", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"refilled", "/other", "a", time.Second, true, 0, 0},
+		{"another client", "/other", "b", time.Second, true, 1, 0},
+		{"route limit", "/search", "a", time.Second, true, 0, 0},
+		{"route limit reached", "/search", "a", time.Second, false, 0, 100 * time.Millisecond},
+		{"refilled up to the burst", "/other", "a", time.Hour, true, 1, 0},
+	}
+	for _, tt := range tests {
+		limit, remaining, wait, ok := l.allow(tt.route, tt.key, start.Add(tt.at))
+		if ok != tt.wantOK || remaining != tt.wantRemaining || wait != tt.wantWait {
+			t.Errorf("%s: expected %v, %d left, wait %v; got %v, %d left, wait %v (limit %+v)",
+				tt.name, tt.wantOK, tt.wantRemaining, tt.wantWait, ok, remaining, wait, limit)
+		}
+	}
+}
+
+func TestSetLimitsKeepsTokens(t *testing.T) {
+	l := NewLimiter(Limit{Rate: 1, Burst: 5}, nil)
+	now := time.Now()
+	l.allow("/search", "a", now)
+	l.SetLimits(Limit{Rate: 1, Burst: 2}, nil)
+	if _, remaining, _, _ := l.allow("/search", "a", now); remaining != 1 {
+		t.Errorf("expected the tokens to be capped at the new burst, got %d left", remaining)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := NewLimiter(Limit{Rate: 1, Burst: 2}, nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if subject := c.GetHeader("X-Subject"); subject != "" {
+			c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), &auth.Identity{Subject: subject, Method: "api_key"}))
+		}
+	})
+	router.GET("/search", l.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		subject       string
+		wantStatus    int
+		wantRemaining string
+		wantReset     string
+		wantRetry     string
+	}{
+		{"first", "", http.StatusOK, "1", "1", ""},
+		{"second", "", http.StatusOK, "0", "2", ""},
+		{"limited", "", http.StatusTooManyRequests, "0", "2", "1"},
+		{"authenticated client", "frontend", http.StatusOK, "1", "1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/search", nil)
+			if tt.subject != "" {
+				r.Header.Set("X-Subject", tt.subject)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			for header, want := range map[string]string{
+				"RateLimit-Limit":     "2",
+				"RateLimit-Remaining": tt.wantRemaining,
+				"RateLimit-Reset":     tt.wantReset,
+				"Retry-After":         tt.wantRetry,
+			} {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("expected %s %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
@@ -2,19 +2,77 @@
 package routes
 
 import (
+	"wikidocify/elasticsearch-service/internal/auth"
 	"wikidocify/elasticsearch-service/internal/handlers"
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/metrics"
+	"wikidocify/elasticsearch-service/internal/tenant"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// Middleware holds the middleware applied to the /api/v1 routes.
+type Middleware struct {
+	// Auth authenticates every request and stores the caller identity in the
+	// request context; see auth.Middleware and auth.Anonymous. Required.
+	Auth gin.HandlerFunc
+	// RateLimit limits requests per client and route; nil disables it.
+	RateLimit gin.HandlerFunc
+	// SearchConcurrency caps concurrent searches; nil disables it.
+	SearchConcurrency gin.HandlerFunc
+}
+
+// api returns the non-nil middleware in the order they must run
+func (m Middleware) api() []gin.HandlerFunc {
+	chain := []gin.HandlerFunc{m.Auth}
+	if m.RateLimit != nil {
+		chain = append(chain, m.RateLimit)
+	}
+	return chain
+}
+
+func (m Middleware) search() []gin.HandlerFunc {
+	chain := []gin.HandlerFunc{auth.RequireScope(auth.ScopeSearch), tenant.Middleware()}
+	if m.SearchConcurrency != nil {
+		chain = append(chain, m.SearchConcurrency)
+	}
+	return chain
+}
+
+// Handlers holds the handlers of the HTTP routes. IndexAdmin, Consistency,
+// Synonyms, Analytics and SavedSearches are nil when the feature is disabled or the
+// backend doesn't support it.
+type Handlers struct {
+	Search        *handlers.SearchHandler
+	Health        *handlers.HealthHandler
+	Sync          *handlers.SyncHandler
+	Config        *handlers.ConfigHandler
+	IndexAdmin    *handlers.IndexAdminHandler
+	Consistency   *handlers.ConsistencyHandler
+	Synonyms      *handlers.SynonymsHandler
+	Analytics     *handlers.AnalyticsHandler
+	SavedSearches *handlers.SavedSearchHandler
+}
+
 // SetupRoutes configures all HTTP routes for the search service.
-func SetupRoutes(router *gin.Engine, searchHandler *handlers.SearchHandler) {
+func SetupRoutes(router *gin.Engine, h Handlers, middleware Middleware) {
 	// Middleware
-	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(metrics.GinMiddleware())
+	router.Use(otelgin.Middleware("wikidocify-search-service"))
+	router.Use(logging.RequestID())
+	router.Use(logging.AccessLog())
 
-	// Health check (no API prefix)
-	router.GET("/health", searchHandler.Health)
+	// Health checks (no API prefix). /health is kept as an alias of /readyz.
+	router.GET("/livez", h.Health.Livez)
+	router.GET("/readyz", h.Health.Readyz)
+	router.GET("/health", h.Health.Readyz)
+	router.GET("/health/details", h.Health.Details)
+
+	// Prometheus scrape endpoint
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// Root endpoint for service info
 	router.GET("/", func(c *gin.Context) {
@@ -26,8 +84,66 @@ func SetupRoutes(router *gin.Engine, searchHandler *handlers.SearchHandler) {
 	})
 
 	// API v1 routes
-	api := router.Group("/api/v1")
+	api := router.Group("/api/v1", middleware.api()...)
 	{
-		api.GET("/search", searchHandler.Search)
+		api.GET("/search", append(middleware.search(), h.Search.Search)...)
+		if h.Analytics != nil {
+			api.POST("/search/click", auth.RequireScope(auth.ScopeSearch), tenant.Middleware(), h.Analytics.Click)
+		}
+
+		if h.SavedSearches != nil {
+			saved := api.Group("/saved-searches", auth.RequireScope(auth.ScopeSearch), tenant.Middleware())
+			{
+				saved.POST("", h.SavedSearches.Create)
+				saved.GET("", h.SavedSearches.List)
+				saved.GET("/:id", h.SavedSearches.Get)
+				saved.PUT("/:id", h.SavedSearches.Update)
+				saved.DELETE("/:id", h.SavedSearches.Delete)
+			}
+		}
+
+		sync := api.Group("/sync", auth.RequireScope(auth.ScopeAdmin))
+		{
+			sync.POST("/full", h.Sync.FullSync)
+			sync.POST("/document/:id", h.Sync.SyncDocument)
+			sync.DELETE("/document/:id", h.Sync.DeleteDocument)
+			// Lists the sync runs of every tenant
+			sync.GET("/status", auth.RequireAllTenants(), h.Sync.Status)
+		}
+
+		admin := api.Group("/admin", auth.RequireScope(auth.ScopeAdmin))
+		{
+			admin.GET("/config", h.Config.Get)
+			if h.IndexAdmin != nil {
+				admin.GET("/index/stats", h.IndexAdmin.Stats)
+				admin.GET("/index/documents/:id", h.IndexAdmin.Document)
+				admin.POST("/index/refresh", h.IndexAdmin.Refresh)
+				admin.POST("/index/forcemerge", h.IndexAdmin.ForceMerge)
+				admin.POST("/index/delete-by-query", h.IndexAdmin.DeleteByQuery)
+				// Closes indexes other tenants may share
+				admin.POST("/index/migrate-analysis", auth.RequireAllTenants(), h.IndexAdmin.MigrateAnalysis)
+			}
+			if h.Consistency != nil {
+				admin.POST("/consistency/check", h.Consistency.Check)
+				admin.GET("/consistency/report", h.Consistency.Report)
+			}
+			// The synonyms set is shared by every tenant
+			if h.Synonyms != nil {
+				synonyms := admin.Group("/synonyms", auth.RequireAllTenants())
+				synonyms.GET("", h.Synonyms.Get)
+				synonyms.PUT("", h.Synonyms.Put)
+				synonyms.GET("/versions", h.Synonyms.Versions)
+				synonyms.POST("/rollback", h.Synonyms.Rollback)
+			}
+		}
+
+		if h.Analytics != nil {
+			analytics := api.Group("/analytics", auth.RequireScope(auth.ScopeAdmin), tenant.Middleware())
+			{
+				analytics.GET("/top-queries", h.Analytics.TopQueries)
+				analytics.GET("/zero-results", h.Analytics.ZeroResults)
+				analytics.GET("/slow-queries", h.Analytics.SlowQueries)
+			}
+		}
 	}
-}
\ No newline at end of file
+}
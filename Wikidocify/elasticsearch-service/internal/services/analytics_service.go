@@ -0,0 +1,190 @@
+// internal/services/analytics_service.go
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// AnalyticsService records searches and result clicks off the request path
+// and reports on them. Events are buffered and written in bulk by a
+// background worker; when the buffer is full new events are dropped rather
+// than slowing searches down.
+type AnalyticsService struct {
+	esClient      *elastic.Client
+	index         string
+	clicksIndex   string
+	batchSize     int
+	flushInterval time.Duration
+
+	events  chan analyticsEvent
+	dropped atomic.Int64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// analyticsEvent is a search or click event bound for index
+type analyticsEvent struct {
+	index string
+	event interface{}
+}
+
+func NewAnalyticsService(esClient *elastic.Client, index, clicksIndex string, bufferSize, batchSize int, flushInterval time.Duration) *AnalyticsService {
+	return &AnalyticsService{
+		esClient:      esClient,
+		index:         index,
+		clicksIndex:   clicksIndex,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		events:        make(chan analyticsEvent, bufferSize),
+		done:          make(chan struct{}),
+	}
+}
+
+// ClicksIndex returns the name of the index holding click events
+func (s *AnalyticsService) ClicksIndex() string {
+	return s.clicksIndex
+}
+
+// Start creates the analytics indexes and starts the background writer
+func (s *AnalyticsService) Start(ctx context.Context) error {
+	if err := s.esClient.EnsureAnalyticsIndex(ctx, s.index); err != nil {
+		return err
+	}
+	if err := s.esClient.EnsureClicksIndex(ctx, s.clicksIndex); err != nil {
+		return err
+	}
+	go s.run()
+	return nil
+}
+
+// Close stops accepting events and waits until the buffered ones are written
+func (s *AnalyticsService) Close() {
+	s.once.Do(func() {
+		close(s.events)
+		<-s.done
+	})
+}
+
+// RecordSearch queues a search and the documents it returned for the
+// analytics index. It never blocks.
+func (s *AnalyticsService) RecordSearch(ctx context.Context, queryID string, req *models.SearchRequest, result *models.SearchResult, latency time.Duration) {
+	resultIDs := make([]uint32, len(result.Documents))
+	for i, doc := range result.Documents {
+		resultIDs[i] = doc.ID
+	}
+	event := models.SearchEvent{
+		QueryID:   queryID,
+		Timestamp: time.Now().UTC(),
+		TenantID:  req.TenantID,
+		Query:     normalizeQuery(req.Query),
+		Type:      req.Type,
+		Author:    req.Author,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+		Hits:      result.Total,
+		LatencyMs: latency.Milliseconds(),
+		ResultIDs: resultIDs,
+	}
+	if identity := auth.IdentityFromContext(ctx); identity != nil {
+		event.Caller = identity.Subject
+		event.CallerMethod = identity.Method
+	}
+	s.enqueue(ctx, analyticsEvent{index: s.index, event: event})
+}
+
+// RecordClick queues a result click for the clicks index. It never blocks.
+func (s *AnalyticsService) RecordClick(ctx context.Context, tenantID string, click *models.ClickRequest) {
+	event := models.ClickEvent{
+		Timestamp:  time.Now().UTC(),
+		TenantID:   tenantID,
+		QueryID:    click.QueryID,
+		DocumentID: click.DocumentID,
+		Position:   click.Position,
+	}
+	if identity := auth.IdentityFromContext(ctx); identity != nil {
+		event.Caller = identity.Subject
+	}
+	s.enqueue(ctx, analyticsEvent{index: s.clicksIndex, event: event})
+}
+
+func (s *AnalyticsService) enqueue(ctx context.Context, event analyticsEvent) {
+	select {
+	case s.events <- event:
+	default:
+		if s.dropped.Add(1)%100 == 1 {
+			slog.WarnContext(ctx, "Analytics buffer full, dropping events", "dropped", s.dropped.Load())
+		}
+	}
+}
+
+// run writes buffered events in batches of batchSize, or every flushInterval
+func (s *AnalyticsService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make(map[string][]interface{})
+	size := 0
+	flush := func() {
+		if size == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for index, events := range batch {
+			if err := s.esClient.AppendEvents(ctx, index, events); err != nil {
+				slog.Error("Failed to write analytics events", "index", index, "count", len(events), "error", err)
+			}
+		}
+		clear(batch)
+		size = 0
+	}
+
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch[event.index] = append(batch[event.index], event.event)
+			size++
+			if size >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// TopQueries returns the most frequent queries
+func (s *AnalyticsService) TopQueries(ctx context.Context, q *models.AnalyticsQuery) ([]models.QueryStats, error) {
+	return s.esClient.TopQueries(ctx, s.index, q)
+}
+
+// ZeroResultQueries returns the most frequent queries that found nothing
+func (s *AnalyticsService) ZeroResultQueries(ctx context.Context, q *models.AnalyticsQuery) ([]models.QueryStats, error) {
+	return s.esClient.ZeroResultQueries(ctx, s.index, q)
+}
+
+// SlowQueries returns the slowest queries
+func (s *AnalyticsService) SlowQueries(ctx context.Context, q *models.AnalyticsQuery) ([]models.QueryStats, error) {
+	return s.esClient.SlowQueries(ctx, s.index, q)
+}
+
+// normalizeQuery lowercases a query and collapses whitespace so equivalent
+// searches are counted (and cached) together
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
@@ -0,0 +1,270 @@
+// internal/services/consistency_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
+)
+
+// ErrCheckInProgress is returned by Check when another check is running
+var ErrCheckInProgress = errors.New("a consistency check is already in progress")
+
+// Report limits: the lists of a report hold at most maxReportedDifferences
+// IDs each, and a repair keeps the first maxRepairErrors errors
+const (
+	maxReportedDifferences = 1000
+	maxRepairErrors        = 20
+)
+
+// versionLister lists up to limit document versions with an ID greater than
+// afterID, in ID order
+type versionLister func(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error)
+
+// ConsistencyService compares a tenant's documents in the doc service, the
+// source of truth, with the search index, and optionally repairs the
+// differences. Both sides are streamed in ID order a page at a time, so a
+// check never holds more than a page of either in memory, plus the first
+// IDs that differ for the report.
+type ConsistencyService struct {
+	sourceVersions versionLister
+	indexVersions  versionLister
+	docService     *DocServiceClient
+	searchService  *SearchService
+	batchSize      int
+
+	running atomic.Bool
+	mu      sync.RWMutex
+	reports map[string]*models.ConsistencyReport // last report per tenant
+}
+
+func NewConsistencyService(esClient *elastic.Client, docService *DocServiceClient, searchService *SearchService, batchSize int) *ConsistencyService {
+	return &ConsistencyService{
+		sourceVersions: docService.GetDocumentVersions,
+		indexVersions:  esClient.DocumentVersions,
+		docService:     docService,
+		searchService:  searchService,
+		batchSize:      batchSize,
+		reports:        map[string]*models.ConsistencyReport{},
+	}
+}
+
+// Running reports whether a check is in progress
+func (s *ConsistencyService) Running() bool {
+	return s.running.Load()
+}
+
+// LastReport returns the report of the last check of tenantID, or nil if
+// none ran since the service started
+func (s *ConsistencyService) LastReport(tenantID string) *models.ConsistencyReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.reports[tenantID]
+}
+
+// Check compares tenantID's documents in the doc service and the index.
+// With repair, missing and stale documents are synced again and documents
+// only in the index are deleted from it, as the comparison finds them, so
+// a check holds no more IDs however many documents differ. The report is
+// kept for LastReport even when the check fails part way, and then counts
+// the repairs made until it failed.
+func (s *ConsistencyService) Check(ctx context.Context, tenantID string, repair bool) (*models.ConsistencyReport, error) {
+	if !s.running.CompareAndSwap(false, true) {
+		return nil, ErrCheckInProgress
+	}
+	defer s.running.Store(false)
+
+	slog.InfoContext(ctx, "Starting consistency check", "tenant_id", tenantID, "repair", repair)
+	report := &models.ConsistencyReport{
+		TenantID:  tenantID,
+		StartedAt: time.Now(),
+		Missing:   []uint32{},
+		Stale:     []models.StaleDocument{},
+		IndexOnly: []uint32{},
+	}
+	var fix *repairer
+	if repair {
+		fix = &repairer{service: s, tenantID: tenantID}
+		report.Repair = &fix.result
+	}
+	err := s.compare(ctx, tenantID, report, fix)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.reports[tenantID] = report
+	s.mu.Unlock()
+
+	if err != nil {
+		return report, err
+	}
+	slog.InfoContext(ctx, "Consistency check completed",
+		"tenant_id", tenantID,
+		"missing", report.MissingCount,
+		"stale", report.StaleCount,
+		"index_only", report.IndexOnlyCount,
+		"duration_ms", report.FinishedAt.Sub(report.StartedAt).Milliseconds(),
+	)
+	return report, nil
+}
+
+// compare merges the two ID-ordered streams of versions, filling in report
+// and, unless fix is nil, repairing each difference as it is found. A
+// repair never disturbs the streams: a document indexed again has an ID
+// below the index cursor, and a deleted one was already read.
+func (s *ConsistencyService) compare(ctx context.Context, tenantID string, report *models.ConsistencyReport, fix *repairer) error {
+	source := &versionCursor{list: s.sourceVersions, tenantID: tenantID, limit: s.batchSize}
+	index := &versionCursor{list: s.indexVersions, tenantID: tenantID, limit: s.batchSize}
+	defer func() {
+		report.SourceDocuments = source.count
+		report.IndexedDocuments = index.count
+	}()
+
+	a, err := source.next(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get document versions from doc service: %w", err)
+	}
+	b, err := index.next(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get document versions from index: %w", err)
+	}
+	for a != nil || b != nil {
+		advanceSource, advanceIndex := false, false
+		switch {
+		case b == nil || (a != nil && a.ID < b.ID):
+			report.MissingCount++
+			if len(report.Missing) < maxReportedDifferences {
+				report.Missing = append(report.Missing, a.ID)
+			}
+			if fix != nil {
+				fix.resync(ctx, a.ID)
+			}
+			advanceSource = true
+		case a == nil || b.ID < a.ID:
+			report.IndexOnlyCount++
+			if len(report.IndexOnly) < maxReportedDifferences {
+				report.IndexOnly = append(report.IndexOnly, b.ID)
+			}
+			if fix != nil {
+				fix.indexOnly(ctx, b.ID)
+			}
+			advanceIndex = true
+		default:
+			// Dates are indexed with millisecond precision
+			if !a.UpdatedAt.Truncate(time.Millisecond).Equal(b.UpdatedAt.Truncate(time.Millisecond)) {
+				report.StaleCount++
+				if len(report.Stale) < maxReportedDifferences {
+					report.Stale = append(report.Stale, models.StaleDocument{
+						ID:               a.ID,
+						SourceUpdatedAt:  a.UpdatedAt,
+						IndexedUpdatedAt: b.UpdatedAt,
+					})
+				}
+				if fix != nil {
+					fix.resync(ctx, a.ID)
+				}
+			}
+			advanceSource, advanceIndex = true, true
+		}
+
+		if advanceSource {
+			if a, err = source.next(ctx); err != nil {
+				return fmt.Errorf("failed to get document versions from doc service: %w", err)
+			}
+		}
+		if advanceIndex {
+			if b, err = index.next(ctx); err != nil {
+				return fmt.Errorf("failed to get document versions from index: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// repairer fixes the differences of a tenant and counts the fixes
+type repairer struct {
+	service  *ConsistencyService
+	tenantID string
+	result   models.ConsistencyRepair
+}
+
+func (r *repairer) fail(id uint32, err error) {
+	r.result.Failed++
+	if len(r.result.Errors) < maxRepairErrors {
+		r.result.Errors = append(r.result.Errors, fmt.Sprintf("document %d: %v", id, err))
+	}
+}
+
+// resync syncs a missing or stale document again
+func (r *repairer) resync(ctx context.Context, id uint32) {
+	if _, err := r.service.searchService.SyncDocument(ctx, r.tenantID, id); err != nil {
+		r.fail(id, err)
+		return
+	}
+	r.result.Resynced++
+}
+
+// indexOnly deletes a document only in the index. It may have been created
+// since it was listed, so it is only deleted once the doc service confirms
+// it doesn't exist in the tenant.
+func (r *repairer) indexOnly(ctx context.Context, id uint32) {
+	doc, err := r.service.docService.GetDocument(ctx, id)
+	switch {
+	case err == nil && (doc.TenantID == r.tenantID || doc.TenantID == "" && r.tenantID == tenant.Default):
+		r.resync(ctx, id)
+	case err == nil, errors.Is(err, ErrDocumentNotFound):
+		if err := r.service.searchService.DeleteDocument(ctx, r.tenantID, id); err != nil {
+			r.fail(id, err)
+			return
+		}
+		r.result.Deleted++
+	default:
+		r.fail(id, err)
+	}
+}
+
+// versionCursor reads the versions from a versionLister one at a time. The
+// limit must not exceed what either side returns in a page, since a short
+// page ends the stream; SYNC_BATCH_SIZE is validated accordingly.
+type versionCursor struct {
+	list     versionLister
+	tenantID string
+	limit    int
+
+	page  []models.DocumentVersion
+	after uint32
+	done  bool
+	count int // versions returned so far
+}
+
+// next returns the next version, or nil at the end
+func (c *versionCursor) next(ctx context.Context) (*models.DocumentVersion, error) {
+	if len(c.page) == 0 && !c.done {
+		page, err := c.list(ctx, c.tenantID, c.after, c.limit)
+		if err != nil {
+			return nil, err
+		}
+		c.page = page
+		c.done = len(page) < c.limit
+		if len(page) > 0 {
+			c.after = page[len(page)-1].ID
+		}
+	}
+	if len(c.page) == 0 {
+		return nil, nil
+	}
+	v := c.page[0]
+	c.page = c.page[1:]
+	c.count++
+	return &v, nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/backend/memory"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// sliceVersions lists versions, which must be in ID order, like the index
+func sliceVersions(versions []models.DocumentVersion) versionLister {
+	return func(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error) {
+		var page []models.DocumentVersion
+		for _, v := range versions {
+			if v.ID > afterID && v.TenantID == tenantID && len(page) < limit {
+				page = append(page, v)
+			}
+		}
+		return page, nil
+	}
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	updated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var docs []*models.Document
+	for i := uint32(1); i <= 5; i++ {
+		doc := publicDocument(i, "Handbook "+strconv.Itoa(int(i)))
+		doc.TenantID = "default"
+		doc.UpdatedAt = updated
+		docs = append(docs, doc)
+	}
+	docService := fakeDocService(t, docs)
+	service := &ConsistencyService{
+		sourceVersions: docService.GetDocumentVersions,
+		indexVersions: sliceVersions([]models.DocumentVersion{
+			{ID: 1, TenantID: "default", UpdatedAt: updated.Add(300 * time.Microsecond)},
+			{ID: 2, TenantID: "default", UpdatedAt: updated.Add(-time.Hour)},
+			{ID: 4, TenantID: "default", UpdatedAt: updated},
+			{ID: 6, TenantID: "default", UpdatedAt: updated},
+			{ID: 7, TenantID: "acme", UpdatedAt: updated},
+		}),
+		docService:    docService,
+		searchService: NewSearchService(memory.New(), docService, time.Hour, 2, false),
+		batchSize:     2,
+		reports:       map[string]*models.ConsistencyReport{},
+	}
+
+	report, err := service.Check(context.Background(), "default", false)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if report.SourceDocuments != 5 || report.IndexedDocuments != 4 {
+		t.Errorf("expected 5 source and 4 indexed documents, got %d and %d", report.SourceDocuments, report.IndexedDocuments)
+	}
+	if !slices.Equal(report.Missing, []uint32{3, 5}) || report.MissingCount != 2 {
+		t.Errorf("expected 3 and 5 missing, got %v", report.Missing)
+	}
+	if len(report.Stale) != 1 || report.Stale[0].ID != 2 || report.StaleCount != 1 {
+		t.Errorf("expected 2 to be stale and sub-millisecond differences ignored, got %+v", report.Stale)
+	}
+	if !slices.Equal(report.IndexOnly, []uint32{6}) || report.IndexOnlyCount != 1 {
+		t.Errorf("expected 6 to be index only, got %v", report.IndexOnly)
+	}
+	if report.Repair != nil {
+		t.Errorf("expected no repair, got %+v", report.Repair)
+	}
+	if service.LastReport("default") != report {
+		t.Error("expected the report to be kept")
+	}
+
+	report, err = service.Check(context.Background(), "default", true)
+	if err != nil {
+		t.Fatalf("Check with repair: %v", err)
+	}
+	if r := report.Repair; r == nil || r.Resynced != 3 || r.Deleted != 1 || r.Failed != 0 {
+		t.Fatalf("expected 3 documents re-synced and 1 deleted, got %+v", r)
+	}
+}
+
+func TestConsistencyRepairWhileComparing(t *testing.T) {
+	var docs []*models.Document
+	var versions []models.DocumentVersion
+	for i := uint32(1); i <= maxReportedDifferences+5; i++ {
+		doc := publicDocument(i, "Handbook")
+		doc.TenantID = "default"
+		docs = append(docs, doc)
+		versions = append(versions, models.DocumentVersion{ID: i, TenantID: "default", UpdatedAt: doc.UpdatedAt})
+	}
+	docService := fakeDocService(t, docs)
+	newService := func(index versionLister) *ConsistencyService {
+		return &ConsistencyService{
+			sourceVersions: docService.GetDocumentVersions,
+			indexVersions:  index,
+			docService:     docService,
+			searchService:  NewSearchService(memory.New(), docService, time.Hour, 100, false),
+			batchSize:      100,
+			reports:        map[string]*models.ConsistencyReport{},
+		}
+	}
+
+	// More differences than a report lists are all repaired
+	report, err := newService(sliceVersions(nil)).Check(context.Background(), "default", true)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if report.MissingCount != len(docs) || len(report.Missing) != maxReportedDifferences {
+		t.Errorf("expected %d missing, %d listed, got %d and %d", len(docs), maxReportedDifferences, report.MissingCount, len(report.Missing))
+	}
+	if r := report.Repair; r.Resynced != len(docs) || r.Failed != 0 {
+		t.Errorf("expected every missing document to be re-synced, got %+v", r)
+	}
+
+	// A check that fails part way reports the repairs made until then
+	failing := func(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error) {
+		if afterID > 0 {
+			return nil, context.DeadlineExceeded
+		}
+		return sliceVersions(versions[100:])(ctx, tenantID, afterID, limit)
+	}
+	report, err = newService(failing).Check(context.Background(), "default", true)
+	if err == nil {
+		t.Fatal("expected the check to fail")
+	}
+	if r := report.Repair; r == nil || r.Resynced != 100 || report.Error == "" {
+		t.Errorf("expected the first 100 documents to be re-synced before the failure, got %+v", report)
+	}
+}
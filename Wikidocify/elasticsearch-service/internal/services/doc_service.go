@@ -2,15 +2,26 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"wikidocify/elasticsearch-service/internal/logging"
 	"wikidocify/elasticsearch-service/internal/models"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// ErrDocumentNotFound is returned by GetDocument when the doc service has no
+// document with the ID
+var ErrDocumentNotFound = errors.New("document not found")
+
 // DocServiceClient is an HTTP client for the document service.
 type DocServiceClient struct {
 	baseURL    string
@@ -24,15 +35,24 @@ func NewDocServiceClient(baseURL, apiKey string, timeout time.Duration) *DocServ
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
 
-// GetAllDocuments fetches all documents from the document service.
-func (c *DocServiceClient) GetAllDocuments() ([]*models.Document, error) {
-	url := fmt.Sprintf("%s/documents", c.baseURL)
-	req, err := http.NewRequest("GET", url, nil)
+// GetDocumentsAfter fetches up to limit documents with an ID greater than
+// afterID, in ID order, for batch sync. An empty tenantID fetches documents
+// of every tenant.
+func (c *DocServiceClient) GetDocumentsAfter(ctx context.Context, tenantID string, afterID uint32, limit int) ([]*models.Document, error) {
+	query := url.Values{
+		"after_id": {strconv.FormatUint(uint64(afterID), 10)},
+		"limit":    {strconv.Itoa(limit)},
+	}
+	if tenantID != "" {
+		query.Set("tenant_id", tenantID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/documents?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +60,7 @@ func (c *DocServiceClient) GetAllDocuments() ([]*models.Document, error) {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.setRequestID(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -61,10 +82,10 @@ func (c *DocServiceClient) GetAllDocuments() ([]*models.Document, error) {
 	return response.Documents, nil
 }
 
-// GetDocumentsPaginated fetches a page of documents for batch sync.
-func (c *DocServiceClient) GetDocumentsPaginated(page, limit int) ([]*models.Document, error) {
-	url := fmt.Sprintf("%s/documents?page=%d&limit=%d", c.baseURL, page, limit)
-	req, err := http.NewRequest("GET", url, nil)
+// GetDocument fetches a single document by ID.
+func (c *DocServiceClient) GetDocument(ctx context.Context, id uint32) (*models.Document, error) {
+	url := fmt.Sprintf("%s/documents/%d", c.baseURL, id)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -72,34 +93,41 @@ func (c *DocServiceClient) GetDocumentsPaginated(page, limit int) ([]*models.Doc
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.setRequestID(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get documents: %w", err)
+		return nil, fmt.Errorf("failed to get document: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrDocumentNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("doc service returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var response struct {
-		Documents []*models.Document `json:"documents"`
-		Total     int               `json:"total"`
-		Page      int               `json:"page"`
-		Limit     int               `json:"limit"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	var document models.Document
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	return response.Documents, nil
+	return &document, nil
 }
 
-// GetDocument fetches a single document by ID.
-func (c *DocServiceClient) GetDocument(id uint32) (*models.Document, error) {
-	url := fmt.Sprintf("%s/documents/%d", c.baseURL, id)
-	req, err := http.NewRequest("GET", url, nil)
+// GetDocumentVersions fetches the versions of up to limit documents with an
+// ID greater than afterID, in ID order. An empty tenantID covers every
+// tenant.
+func (c *DocServiceClient) GetDocumentVersions(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error) {
+	query := url.Values{
+		"after_id": {strconv.FormatUint(uint64(afterID), 10)},
+		"limit":    {strconv.Itoa(limit)},
+	}
+	if tenantID != "" {
+		query.Set("tenant_id", tenantID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/documents/versions?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -107,35 +135,36 @@ func (c *DocServiceClient) GetDocument(id uint32) (*models.Document, error) {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.setRequestID(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get document: %w", err)
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("document not found")
-	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("doc service returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var document models.Document
-	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+	var response struct {
+		Versions []models.DocumentVersion `json:"versions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	return &document, nil
+	return response.Versions, nil
 }
 
 // HealthCheck checks the health of the document service.
-func (c *DocServiceClient) HealthCheck() error {
+func (c *DocServiceClient) HealthCheck(ctx context.Context) error {
 	url := fmt.Sprintf("%s/health", c.baseURL)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
+	c.setRequestID(req)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("doc service health check failed: %w", err)
@@ -145,4 +174,12 @@ func (c *DocServiceClient) HealthCheck() error {
 		return fmt.Errorf("doc service health check failed with status: %d", resp.StatusCode)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// setRequestID forwards the request ID from the request context so calls can
+// be correlated across services.
+func (c *DocServiceClient) setRequestID(req *http.Request) {
+	if id := logging.RequestIDFromContext(req.Context()); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+}
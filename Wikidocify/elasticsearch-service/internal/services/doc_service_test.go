@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGetDocumentsAfterEscapesTheQuery(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{"documents": []}`))
+	}))
+	defer server.Close()
+	client := NewDocServiceClient(server.URL, "", time.Second)
+
+	tests := []struct {
+		name     string
+		tenantID string
+		want     url.Values
+	}{
+		{"every tenant", "", url.Values{"after_id": {"7"}, "limit": {"50"}}},
+		{"one tenant", "acme", url.Values{"after_id": {"7"}, "limit": {"50"}, "tenant_id": {"acme"}}},
+		{"reserved characters", "acme&limit=1#x", url.Values{"after_id": {"7"}, "limit": {"50"}, "tenant_id": {"acme&limit=1#x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.GetDocumentsAfter(context.Background(), tt.tenantID, 7, 50); err != nil {
+				t.Fatalf("GetDocumentsAfter: %v", err)
+			}
+			if query.Encode() != tt.want.Encode() {
+				t.Errorf("expected query %q, got %q", tt.want.Encode(), query.Encode())
+			}
+		})
+	}
+}
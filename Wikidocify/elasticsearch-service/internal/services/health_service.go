@@ -0,0 +1,165 @@
+// internal/services/health_service.go
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// Overall health states.
+const (
+	StatusReady     = "ready"
+	StatusNotReady  = "not_ready"
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// ConsumerStatusProvider reports the state of the Kafka consumer.
+type ConsumerStatusProvider interface {
+	Status() models.ConsumerStatus
+}
+
+// HealthService runs readiness and dependency checks. Results are cached for
+// cacheTTL so frequent probes don't hit the search backend or the doc
+// service on every request.
+type HealthService struct {
+	backend       backend.SearchBackend
+	docService    *DocServiceClient
+	searchService *SearchService
+	consumer      ConsumerStatusProvider
+	cacheTTL      time.Duration
+	checkTimeout  time.Duration
+
+	readinessMu sync.Mutex
+	readiness   cachedHealth
+
+	detailsMu sync.Mutex
+	details   cachedHealth
+}
+
+type cachedHealth struct {
+	response  models.HealthResponse
+	expiresAt time.Time
+}
+
+func NewHealthService(searchBackend backend.SearchBackend, docService *DocServiceClient, searchService *SearchService, consumer ConsumerStatusProvider, cacheTTL, checkTimeout time.Duration) *HealthService {
+	return &HealthService{
+		backend:       searchBackend,
+		docService:    docService,
+		searchService: searchService,
+		consumer:      consumer,
+		cacheTTL:      cacheTTL,
+		checkTimeout:  checkTimeout,
+	}
+}
+
+// Readiness reports whether search can be served: the search backend is
+// reachable and the index exists. The doc service and Kafka are not required.
+func (h *HealthService) Readiness() models.HealthResponse {
+	h.readinessMu.Lock()
+	defer h.readinessMu.Unlock()
+
+	if time.Now().Before(h.readiness.expiresAt) {
+		return h.readiness.response
+	}
+
+	es := h.check(h.checkBackend)
+
+	response := models.HealthResponse{
+		Status:       StatusReady,
+		Timestamp:    time.Now(),
+		Dependencies: map[string]models.DependencyStatus{h.backend.Name(): es},
+	}
+	if !es.Healthy {
+		response.Status = StatusNotReady
+		slog.Warn("Readiness check failed", "dependency", h.backend.Name(), "error", es.Error)
+	}
+
+	h.readiness = cachedHealth{response: response, expiresAt: time.Now().Add(h.cacheTTL)}
+	return response
+}
+
+// Details reports every dependency with its latency, the Kafka consumer state
+// and lag, and the last sync result. The status is "unhealthy" only when
+// search itself is unavailable; other failures make it "degraded".
+func (h *HealthService) Details() models.HealthResponse {
+	h.detailsMu.Lock()
+	defer h.detailsMu.Unlock()
+
+	if time.Now().Before(h.details.expiresAt) {
+		return h.details.response
+	}
+
+	var es, doc models.DependencyStatus
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		es = h.check(h.checkBackend)
+	}()
+	go func() {
+		defer wg.Done()
+		doc = h.check(h.docService.HealthCheck)
+	}()
+	wg.Wait()
+
+	response := models.HealthResponse{
+		Status:    StatusHealthy,
+		Timestamp: time.Now(),
+		Dependencies: map[string]models.DependencyStatus{
+			h.backend.Name(): es,
+			"doc_service":    doc,
+		},
+		Sync: h.searchService.LastSyncResult(),
+	}
+	if h.consumer != nil {
+		status := h.consumer.Status()
+		response.Kafka = &status
+	}
+
+	switch {
+	case !es.Healthy:
+		response.Status = StatusUnhealthy
+	case !doc.Healthy,
+		response.Kafka != nil && response.Kafka.State == "error",
+		response.Sync != nil && !response.Sync.Success:
+		response.Status = StatusDegraded
+	}
+
+	h.details = cachedHealth{response: response, expiresAt: time.Now().Add(h.cacheTTL)}
+	return response
+}
+
+// checkBackend checks that the search backend is reachable and its index
+// exists
+func (h *HealthService) checkBackend(ctx context.Context) error {
+	if err := h.backend.HealthCheck(ctx); err != nil {
+		return err
+	}
+	return h.backend.IndexExists(ctx)
+}
+
+// check runs fn with the configured timeout and measures its latency. The
+// check is detached from any request context because its result is shared.
+func (h *HealthService) check(fn func(context.Context) error) models.DependencyStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), h.checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	status := models.DependencyStatus{
+		Healthy:   err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,185 @@
+// internal/services/index_admin_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
+)
+
+// ErrExpectedCountRequired is returned by DeleteByQuery when a deletion
+// doesn't pass the count of a dry run
+var ErrExpectedCountRequired = errors.New("run a dry run first and pass its count as expected_count")
+
+// ErrCountChanged is returned by DeleteByQuery when the number of matching
+// documents is no longer the one the dry run counted
+var ErrCountChanged = errors.New("the number of matching documents changed since the dry run")
+
+// IndexAdminService inspects and maintains the tenants' search indexes
+type IndexAdminService struct {
+	esClient         *elastic.Client
+	docService       *DocServiceClient
+	savedSearchIndex string
+}
+
+func NewIndexAdminService(esClient *elastic.Client, docService *DocServiceClient) *IndexAdminService {
+	return &IndexAdminService{
+		esClient:   esClient,
+		docService: docService,
+	}
+}
+
+// SetSavedSearchIndex has MigrateAnalysis migrate the saved searches
+// percolator index too
+func (s *IndexAdminService) SetSavedSearchIndex(index string) {
+	s.savedSearchIndex = index
+}
+
+// MigrateAnalysis adds the synonym and language analyzers to tenantID's
+// index and the saved searches index if they were created before them, and
+// returns the indexes it migrated. Each is closed for a moment, failing its
+// searches and writes, so this is only done on request.
+func (s *IndexAdminService) MigrateAnalysis(ctx context.Context, tenantID string) ([]string, error) {
+	migrated := []string{}
+	ok, err := s.esClient.MigrateIndexAnalysis(ctx, tenantID)
+	if err != nil {
+		return migrated, err
+	}
+	if ok {
+		migrated = append(migrated, s.esClient.IndexFor(tenantID))
+	}
+	if s.savedSearchIndex != "" {
+		ok, err := s.esClient.MigrateSavedSearchAnalysis(ctx, s.savedSearchIndex)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated = append(migrated, s.savedSearchIndex)
+		}
+	}
+	slog.InfoContext(ctx, "Analysis migration completed", "tenant_id", tenantID, "migrated", migrated)
+	return migrated, nil
+}
+
+// Stats returns the stats of tenantID's index
+func (s *IndexAdminService) Stats(ctx context.Context, tenantID string) (*models.IndexStats, error) {
+	return s.esClient.IndexStats(ctx, tenantID)
+}
+
+// CompareDocument fetches a document from tenantID's index and from the doc
+// service and reports the fields that differ. A document of another tenant
+// is treated as missing on both sides, so tenant-bound credentials can't
+// read it.
+func (s *IndexAdminService) CompareDocument(ctx context.Context, tenantID string, id uint32) (*models.DocumentComparison, error) {
+	indexed, err := s.esClient.GetIndexedDocument(ctx, tenantID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indexed document: %w", err)
+	}
+
+	var source *models.SearchDocument
+	doc, err := s.docService.GetDocument(ctx, id)
+	switch {
+	case errors.Is(err, ErrDocumentNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to get document from doc service: %w", err)
+	default:
+		source = doc.ToSearchDocument()
+		if source.TenantID == "" {
+			source.TenantID = tenant.Default
+		}
+		if source.TenantID != tenantID {
+			source = nil
+		}
+	}
+
+	comparison := &models.DocumentComparison{
+		ID:          id,
+		TenantID:    tenantID,
+		Indexed:     indexed,
+		Source:      source,
+		Differences: []models.FieldDifference{},
+	}
+	if indexed != nil && source != nil {
+		comparison.Differences = diffDocuments(indexed, source)
+	}
+	comparison.InSync = (indexed == nil) == (source == nil) && len(comparison.Differences) == 0
+	return comparison, nil
+}
+
+// diffDocuments returns the doc service fields whose indexed value differs.
+// Fields the index derives, such as the language and popularity, aren't
+// compared, and an empty list equals a missing one.
+func diffDocuments(indexed, source *models.SearchDocument) []models.FieldDifference {
+	diffs := []models.FieldDifference{}
+	add := func(field string, equal bool, indexedValue, sourceValue interface{}) {
+		if !equal {
+			diffs = append(diffs, models.FieldDifference{Field: field, Indexed: indexedValue, Source: sourceValue})
+		}
+	}
+	add("tenant_id", indexed.TenantID == source.TenantID, indexed.TenantID, source.TenantID)
+	add("title", indexed.Title == source.Title, indexed.Title, source.Title)
+	add("content", indexed.Content == source.Content, indexed.Content, source.Content)
+	add("author", indexed.Author == source.Author, indexed.Author, source.Author)
+	add("created_at", indexed.CreatedAt.Equal(source.CreatedAt), indexed.CreatedAt, source.CreatedAt)
+	add("updated_at", indexed.UpdatedAt.Equal(source.UpdatedAt), indexed.UpdatedAt, source.UpdatedAt)
+	add("owner", indexed.Owner == source.Owner, indexed.Owner, source.Owner)
+	add("visibility", indexed.Visibility == source.Visibility, indexed.Visibility, source.Visibility)
+	add("allowed_users", slices.Equal(indexed.AllowedUsers, source.AllowedUsers), indexed.AllowedUsers, source.AllowedUsers)
+	add("allowed_groups", slices.Equal(indexed.AllowedGroups, source.AllowedGroups), indexed.AllowedGroups, source.AllowedGroups)
+	return diffs
+}
+
+// Refresh makes every write to tenantID's index visible to searches
+func (s *IndexAdminService) Refresh(ctx context.Context, tenantID string) error {
+	return s.esClient.Refresh(ctx, tenantID)
+}
+
+// ForceMerge merges the segments of tenantID's index; see
+// elastic.Client.ForceMerge. It blocks until the merge is done.
+func (s *IndexAdminService) ForceMerge(ctx context.Context, tenantID string, maxSegments int, onlyExpungeDeletes bool) error {
+	slog.InfoContext(ctx, "Starting force merge", "tenant_id", tenantID, "max_num_segments", maxSegments, "only_expunge_deletes", onlyExpungeDeletes)
+	if err := s.esClient.ForceMerge(ctx, tenantID, maxSegments, onlyExpungeDeletes); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Force merge completed", "tenant_id", tenantID)
+	return nil
+}
+
+// DeleteByQuery counts tenantID's documents matching req.Query and, unless
+// it is a dry run, deletes them. A deletion must carry the count of a dry
+// run, and is refused with ErrCountChanged if the count moved since, so a
+// mistyped query can't delete more than the operator saw.
+func (s *IndexAdminService) DeleteByQuery(ctx context.Context, tenantID, deletedBy string, req *models.DeleteByQueryRequest) (*models.DeleteByQueryResult, error) {
+	dryRun := req.DryRun == nil || *req.DryRun
+	if !dryRun && req.ExpectedCount == nil {
+		return nil, ErrExpectedCountRequired
+	}
+
+	matched, err := s.esClient.CountByQuery(ctx, tenantID, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.DeleteByQueryResult{DryRun: dryRun, Matched: matched}
+	if dryRun {
+		return result, nil
+	}
+	if matched != *req.ExpectedCount {
+		return result, fmt.Errorf("%w: expected %d, now %d", ErrCountChanged, *req.ExpectedCount, matched)
+	}
+
+	result.Deleted, err = s.esClient.DeleteByQuery(ctx, tenantID, req.Query)
+	slog.WarnContext(ctx, "Deleted documents by query",
+		"tenant_id", tenantID,
+		"query", string(req.Query),
+		"deleted", result.Deleted,
+		"deleted_by", deletedBy,
+		"error", err,
+	)
+	return result, err
+}
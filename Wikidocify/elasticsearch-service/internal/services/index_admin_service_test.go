@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	updated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	source := &models.SearchDocument{
+		ID:         1,
+		TenantID:   "acme",
+		Title:      "Roadmap",
+		Content:    "Q3 plans",
+		UpdatedAt:  updated,
+		Visibility: models.VisibilityTeam,
+	}
+	indexed := *source
+	indexed.UpdatedAt = updated.In(time.FixedZone("CET", 3600))
+	indexed.AllowedGroups = []string{}
+	indexed.Language = "en"
+	indexed.Popularity = 3
+
+	if diffs := diffDocuments(&indexed, source); len(diffs) != 0 {
+		t.Fatalf("expected equal times, empty lists and derived fields to match, got %+v", diffs)
+	}
+
+	indexed.Title = "Old roadmap"
+	indexed.AllowedGroups = []string{"eng"}
+	diffs := diffDocuments(&indexed, source)
+	if len(diffs) != 2 || diffs[0].Field != "title" || diffs[1].Field != "allowed_groups" {
+		t.Fatalf("expected title and allowed_groups to differ, got %+v", diffs)
+	}
+	if diffs[0].Indexed != "Old roadmap" || diffs[0].Source != "Roadmap" {
+		t.Errorf("unexpected values: %+v", diffs[0])
+	}
+}
@@ -0,0 +1,108 @@
+// internal/services/popularity_service.go
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+)
+
+// PopularityService periodically turns result clicks into a popularity
+// score on the indexed documents, used by the blended ranking mode. The
+// score of a document is the number of distinct callers and searches that
+// clicked it over the last window; see elastic.Client.ClickCounts.
+type PopularityService struct {
+	esClient      *elastic.Client
+	clicksIndex   string
+	searchesIndex string
+	window        time.Duration
+	interval      time.Duration
+	elector       LeaderElector
+
+	// tenants scored by the previous run, so their scores are reset once
+	// they have no clicks left in the window
+	scoredTenants map[string]bool
+}
+
+func NewPopularityService(esClient *elastic.Client, clicksIndex, searchesIndex string, window, interval time.Duration) *PopularityService {
+	return &PopularityService{
+		esClient:      esClient,
+		clicksIndex:   clicksIndex,
+		searchesIndex: searchesIndex,
+		window:        window,
+		interval:      interval,
+	}
+}
+
+// SetLeaderElector makes only the leader among the replicas update the
+// scores; without one, every replica does. It must be called before Start.
+func (s *PopularityService) SetLeaderElector(elector LeaderElector) {
+	s.elector = elector
+}
+
+// Start runs ScheduledUpdate every interval
+func (s *PopularityService) Start() {
+	slog.Info("Starting popularity updates", "interval", s.interval.String(), "window", s.window.String())
+
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		for range ticker.C {
+			if _, err := s.ScheduledUpdate(context.Background()); err != nil {
+				slog.Error("Popularity update failed", "error", err)
+			}
+		}
+	}()
+}
+
+// ScheduledUpdate runs Update if this replica leads, and reports whether
+// it did
+func (s *PopularityService) ScheduledUpdate(ctx context.Context) (bool, error) {
+	if s.elector != nil && !s.elector.IsLeader() {
+		slog.DebugContext(ctx, "Skipping popularity update; another replica leads", "leader", s.elector.Status().Leader)
+		return false, nil
+	}
+	return true, s.Update(ctx)
+}
+
+// Update recomputes the popularity scores of every tenant from the clicks
+// in the window
+func (s *PopularityService) Update(ctx context.Context) error {
+	start := time.Now()
+	counts, err := s.esClient.ClickCounts(ctx, s.clicksIndex, s.searchesIndex, start.Add(-s.window))
+	if err != nil {
+		return err
+	}
+
+	// Tenants without clicks any more get an empty score set, which resets them
+	for tenantID := range s.scoredTenants {
+		if _, ok := counts[tenantID]; !ok {
+			counts[tenantID] = nil
+		}
+	}
+
+	documents := 0
+	scored := make(map[string]bool, len(counts))
+	for tenantID, clicks := range counts {
+		scores := make(map[uint32]float64, len(clicks))
+		for id, count := range clicks {
+			scores[id] = float64(count)
+		}
+		if err := s.esClient.UpdatePopularity(ctx, tenantID, scores); err != nil {
+			return err
+		}
+		documents += len(scores)
+		if len(scores) > 0 {
+			scored[tenantID] = true
+		}
+	}
+	s.scoredTenants = scored
+
+	slog.InfoContext(ctx, "Updated popularity scores",
+		"tenants", len(counts),
+		"documents", documents,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return nil
+}
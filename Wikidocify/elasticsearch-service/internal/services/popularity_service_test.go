@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// staticElector is a LeaderElector that always gives the same answer
+type staticElector bool
+
+func (e staticElector) IsLeader() bool {
+	return bool(e)
+}
+
+func (e staticElector) Status() models.LeaderStatus {
+	return models.LeaderStatus{Backend: "static", IsLeader: bool(e)}
+}
+
+func TestPopularityUpdatesOnlyOnTheLeader(t *testing.T) {
+	// Without a client, an update would panic
+	service := NewPopularityService(nil, "clicks", "searches", 0, 0)
+	service.SetLeaderElector(staticElector(false))
+
+	ran, err := service.ScheduledUpdate(context.Background())
+	if ran || err != nil {
+		t.Fatalf("expected a follower to skip the update, got %v, %v", ran, err)
+	}
+}
@@ -0,0 +1,240 @@
+// internal/services/saved_search_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/logging"
+	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/notify"
+)
+
+// SearchMatchEvent is the event type of saved search alerts
+const SearchMatchEvent = "search.match"
+
+// ErrTooManySavedSearches is returned when an owner already has the maximum
+// number of saved searches in a tenant
+var ErrTooManySavedSearches = errors.New("too many saved searches")
+
+// savedSearchStore keeps the saved searches and their alerts; it is
+// implemented by *elastic.Client
+type savedSearchStore interface {
+	EnsureSavedSearchIndexes(ctx context.Context, index, alertsIndex string) error
+	PutSavedSearch(ctx context.Context, index string, s *models.SavedSearch) error
+	GetSavedSearch(ctx context.Context, index, id string) (*models.SavedSearch, error)
+	ListSavedSearches(ctx context.Context, index, tenantID, owner string, limit int) ([]models.SavedSearch, int64, error)
+	DeleteSavedSearch(ctx context.Context, index, alertsIndex, id string) error
+	Percolate(ctx context.Context, index string, doc *models.SearchDocument) ([]models.SavedSearch, error)
+	ClaimAlert(ctx context.Context, alertsIndex string, event *models.SearchMatchEvent) (bool, error)
+	ReleaseAlert(ctx context.Context, alertsIndex, id string) error
+}
+
+// SavedSearchService manages saved searches and alerts their owners when a
+// newly indexed document matches one. Saved searches are stored as
+// percolator queries, so matching a document is a single percolate request
+// however many searches are saved.
+type SavedSearchService struct {
+	esClient    savedSearchStore
+	index       string
+	alertsIndex string
+	maxPerOwner int
+	notifier    notify.Notifier
+}
+
+func NewSavedSearchService(esClient *elastic.Client, index, alertsIndex string, maxPerOwner int, notifier notify.Notifier) *SavedSearchService {
+	return &SavedSearchService{
+		esClient:    esClient,
+		index:       index,
+		alertsIndex: alertsIndex,
+		maxPerOwner: maxPerOwner,
+		notifier:    notifier,
+	}
+}
+
+// Start creates the saved search and alert indexes
+func (s *SavedSearchService) Start(ctx context.Context) error {
+	return s.esClient.EnsureSavedSearchIndexes(ctx, s.index, s.alertsIndex)
+}
+
+// Close releases the notifier
+func (s *SavedSearchService) Close() error {
+	return s.notifier.Close()
+}
+
+// Create saves a search for identity in a tenant. Concurrent creates can
+// all pass the count of the owner's searches, so once stored every create
+// trims them back to the owner's maxPerOwner oldest: whichever runs last
+// removes the extra ones, and a create whose search is removed fails.
+func (s *SavedSearchService) Create(ctx context.Context, identity *auth.Identity, tenantID string, req *models.SavedSearchRequest) (*models.SavedSearch, error) {
+	_, total, err := s.esClient.ListSavedSearches(ctx, s.index, tenantID, identity.Subject, 0)
+	if err != nil {
+		return nil, err
+	}
+	if total >= int64(s.maxPerOwner) {
+		return nil, ErrTooManySavedSearches
+	}
+
+	now := time.Now().UTC()
+	saved := &models.SavedSearch{
+		ID:        logging.NewRequestID(),
+		TenantID:  tenantID,
+		CreatedAt: now,
+	}
+	s.apply(saved, identity, req, now)
+	if err := s.esClient.PutSavedSearch(ctx, s.index, saved); err != nil {
+		return nil, err
+	}
+	if err := s.trim(ctx, saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// trim deletes the saved searches of the owner of saved beyond its
+// maxPerOwner oldest, newest first. It returns ErrTooManySavedSearches if
+// saved was one of them.
+func (s *SavedSearchService) trim(ctx context.Context, saved *models.SavedSearch) error {
+	_, total, err := s.esClient.ListSavedSearches(ctx, s.index, saved.TenantID, saved.Owner, 0)
+	if err != nil || total <= int64(s.maxPerOwner) {
+		return err
+	}
+	extra, _, err := s.esClient.ListSavedSearches(ctx, s.index, saved.TenantID, saved.Owner, int(total)-s.maxPerOwner)
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, search := range extra {
+		// Another create may be trimming the same searches
+		err := s.esClient.DeleteSavedSearch(ctx, s.index, s.alertsIndex, search.ID)
+		if err != nil && !errors.Is(err, elastic.ErrSavedSearchNotFound) {
+			return err
+		}
+		removed = removed || search.ID == saved.ID
+	}
+	if removed {
+		return ErrTooManySavedSearches
+	}
+	return nil
+}
+
+// List returns identity's saved searches in a tenant
+func (s *SavedSearchService) List(ctx context.Context, identity *auth.Identity, tenantID string) ([]models.SavedSearch, error) {
+	searches, _, err := s.esClient.ListSavedSearches(ctx, s.index, tenantID, identity.Subject, s.maxPerOwner)
+	return searches, err
+}
+
+// Get returns one of identity's saved searches. Searches of other owners or
+// tenants are reported as not found.
+func (s *SavedSearchService) Get(ctx context.Context, identity *auth.Identity, tenantID, id string) (*models.SavedSearch, error) {
+	saved, err := s.esClient.GetSavedSearch(ctx, s.index, id)
+	if err != nil {
+		return nil, err
+	}
+	if saved.TenantID != tenantID || saved.Owner != identity.Subject {
+		return nil, elastic.ErrSavedSearchNotFound
+	}
+	return saved, nil
+}
+
+// Update replaces the parameters of one of identity's saved searches
+func (s *SavedSearchService) Update(ctx context.Context, identity *auth.Identity, tenantID, id string, req *models.SavedSearchRequest) (*models.SavedSearch, error) {
+	saved, err := s.Get(ctx, identity, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	s.apply(saved, identity, req, time.Now().UTC())
+	if err := s.esClient.PutSavedSearch(ctx, s.index, saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// Delete removes one of identity's saved searches
+func (s *SavedSearchService) Delete(ctx context.Context, identity *auth.Identity, tenantID, id string) error {
+	if _, err := s.Get(ctx, identity, tenantID, id); err != nil {
+		return err
+	}
+	return s.esClient.DeleteSavedSearch(ctx, s.index, s.alertsIndex, id)
+}
+
+// apply copies the request onto saved
+func (s *SavedSearchService) apply(saved *models.SavedSearch, identity *auth.Identity, req *models.SavedSearchRequest, now time.Time) {
+	saved.Owner = identity.Subject
+	saved.OwnerMethod = identity.Method
+	saved.Name = req.Name
+	saved.Query = req.Query
+	saved.Type = req.Type
+	saved.Author = req.Author
+	saved.UpdatedAt = now
+}
+
+// Percolate alerts the owners of the saved searches matching a newly indexed
+// document. Each saved search alerts at most once per document. A failed
+// alert is logged and released so it is retried when the document is
+// indexed again; it never fails indexing.
+//
+// Percolator queries stored by earlier versions may still grant access
+// through the owner's groups, so every match is checked against the access
+// rules without groups before alerting.
+func (s *SavedSearchService) Percolate(ctx context.Context, doc *models.SearchDocument) error {
+	matches, err := s.esClient.Percolate(ctx, s.index, doc)
+	if err != nil {
+		return fmt.Errorf("failed to percolate document: %w", err)
+	}
+
+	sent := 0
+	for _, saved := range matches {
+		owner := &auth.Identity{Subject: saved.Owner, Method: saved.OwnerMethod}
+		if !backend.Readable(owner, doc) {
+			continue
+		}
+		event := &models.SearchMatchEvent{
+			Event:           SearchMatchEvent,
+			ID:              fmt.Sprintf("%s:%d", saved.ID, doc.ID),
+			TenantID:        doc.TenantID,
+			SavedSearchID:   saved.ID,
+			SavedSearchName: saved.Name,
+			Owner:           saved.Owner,
+			DocumentID:      doc.ID,
+			Title:           doc.Title,
+			Author:          doc.Author,
+			MatchedAt:       time.Now().UTC(),
+		}
+		logger := slog.With("saved_search_id", saved.ID, "doc_id", doc.ID, "tenant_id", doc.TenantID)
+
+		claimed, err := s.esClient.ClaimAlert(ctx, s.alertsIndex, event)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to record saved search alert", "error", err)
+			continue
+		}
+		if !claimed {
+			logger.DebugContext(ctx, "Saved search alert already sent")
+			continue
+		}
+		if err := s.notifier.Notify(ctx, event); err != nil {
+			logger.ErrorContext(ctx, "Failed to send saved search alert", "error", err)
+			if err := s.esClient.ReleaseAlert(ctx, s.alertsIndex, event.ID); err != nil {
+				logger.ErrorContext(ctx, "Failed to release saved search alert", "error", err)
+			}
+			continue
+		}
+		sent++
+	}
+
+	if len(matches) > 0 {
+		slog.InfoContext(ctx, "Percolated document",
+			"doc_id", doc.ID,
+			"tenant_id", doc.TenantID,
+			"matches", len(matches),
+			"alerts_sent", sent,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// memorySavedSearches is a savedSearchStore in memory. Percolate matches a
+// saved search whose query is in the document title, whatever the access
+// rules, like a percolator query stored with the owner's groups would.
+type memorySavedSearches struct {
+	mu       sync.Mutex
+	searches []models.SavedSearch
+	alerts   map[string]bool
+
+	// listed, if set, is called by ListSavedSearches before it reads
+	listed func()
+}
+
+func newMemorySavedSearches() *memorySavedSearches {
+	return &memorySavedSearches{alerts: map[string]bool{}}
+}
+
+func (m *memorySavedSearches) EnsureSavedSearchIndexes(ctx context.Context, index, alertsIndex string) error {
+	return nil
+}
+
+func (m *memorySavedSearches) PutSavedSearch(ctx context.Context, index string, s *models.SavedSearch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.searches = slices.DeleteFunc(m.searches, func(saved models.SavedSearch) bool { return saved.ID == s.ID })
+	m.searches = append(m.searches, *s)
+	return nil
+}
+
+func (m *memorySavedSearches) GetSavedSearch(ctx context.Context, index, id string) (*models.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, saved := range m.searches {
+		if saved.ID == id {
+			return &saved, nil
+		}
+	}
+	return nil, elastic.ErrSavedSearchNotFound
+}
+
+func (m *memorySavedSearches) ListSavedSearches(ctx context.Context, index, tenantID, owner string, limit int) ([]models.SavedSearch, int64, error) {
+	if m.listed != nil {
+		m.listed()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var owned []models.SavedSearch
+	for _, saved := range m.searches {
+		if saved.TenantID == tenantID && saved.Owner == owner {
+			owned = append(owned, saved)
+		}
+	}
+	slices.SortFunc(owned, func(a, b models.SavedSearch) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return owned[:min(limit, len(owned))], int64(len(owned)), nil
+}
+
+func (m *memorySavedSearches) DeleteSavedSearch(ctx context.Context, index, alertsIndex, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.searches)
+	m.searches = slices.DeleteFunc(m.searches, func(saved models.SavedSearch) bool { return saved.ID == id })
+	if len(m.searches) == n {
+		return elastic.ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+func (m *memorySavedSearches) Percolate(ctx context.Context, index string, doc *models.SearchDocument) ([]models.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matches []models.SavedSearch
+	for _, saved := range m.searches {
+		if saved.TenantID == doc.TenantID && strings.Contains(doc.Title, saved.Query) {
+			matches = append(matches, saved)
+		}
+	}
+	return matches, nil
+}
+
+func (m *memorySavedSearches) ClaimAlert(ctx context.Context, alertsIndex string, event *models.SearchMatchEvent) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.alerts[event.ID] {
+		return false, nil
+	}
+	m.alerts[event.ID] = true
+	return true, nil
+}
+
+func (m *memorySavedSearches) ReleaseAlert(ctx context.Context, alertsIndex, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.alerts, id)
+	return nil
+}
+
+// recordingNotifier keeps the events it is given, failing while fail is set
+type recordingNotifier struct {
+	fail   bool
+	events []*models.SearchMatchEvent
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event *models.SearchMatchEvent) error {
+	if n.fail {
+		return errors.New("broker unavailable")
+	}
+	n.events = append(n.events, event)
+	return nil
+}
+
+func (n *recordingNotifier) Close() error {
+	return nil
+}
+
+func newTestSavedSearchService(maxPerOwner int) (*SavedSearchService, *memorySavedSearches, *recordingNotifier) {
+	store := newMemorySavedSearches()
+	notifier := &recordingNotifier{}
+	service := &SavedSearchService{
+		esClient:    store,
+		index:       "saved",
+		alertsIndex: "alerts",
+		maxPerOwner: maxPerOwner,
+		notifier:    notifier,
+	}
+	return service, store, notifier
+}
+
+func TestPercolateAlertsOncePerDocument(t *testing.T) {
+	service, _, notifier := newTestSavedSearchService(10)
+	ctx := context.Background()
+	alice := &auth.Identity{Subject: "alice", Method: "jwt"}
+	saved, err := service.Create(ctx, alice, "acme", &models.SavedSearchRequest{Name: "Roadmaps", Query: "Roadmap"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	doc := &models.SearchDocument{ID: 42, TenantID: "acme", Title: "Roadmap 2025", Visibility: models.VisibilityPublic}
+	for range 2 {
+		if err := service.Percolate(ctx, doc); err != nil {
+			t.Fatalf("Percolate: %v", err)
+		}
+	}
+	if len(notifier.events) != 1 {
+		t.Fatalf("expected one alert for a document indexed twice, got %d", len(notifier.events))
+	}
+	event := notifier.events[0]
+	if event.ID != saved.ID+":42" || event.Owner != "alice" || event.SavedSearchName != "Roadmaps" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+
+	// Another tenant's document doesn't match
+	if err := service.Percolate(ctx, &models.SearchDocument{ID: 43, TenantID: "globex", Title: "Roadmap", Visibility: models.VisibilityPublic}); err != nil {
+		t.Fatalf("Percolate: %v", err)
+	}
+	if len(notifier.events) != 1 {
+		t.Errorf("expected no alert for another tenant, got %d", len(notifier.events))
+	}
+}
+
+func TestPercolateRetriesFailedAlerts(t *testing.T) {
+	service, _, notifier := newTestSavedSearchService(10)
+	ctx := context.Background()
+	if _, err := service.Create(ctx, &auth.Identity{Subject: "alice", Method: "jwt"}, "acme", &models.SavedSearchRequest{Name: "Roadmaps", Query: "Roadmap"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	doc := &models.SearchDocument{ID: 42, TenantID: "acme", Title: "Roadmap", Visibility: models.VisibilityPublic}
+
+	notifier.fail = true
+	if err := service.Percolate(ctx, doc); err != nil {
+		t.Fatalf("expected a failed alert not to fail indexing, got %v", err)
+	}
+	notifier.fail = false
+	if err := service.Percolate(ctx, doc); err != nil {
+		t.Fatalf("Percolate: %v", err)
+	}
+	if len(notifier.events) != 1 {
+		t.Fatalf("expected the released alert to be sent when the document is indexed again, got %d", len(notifier.events))
+	}
+}
+
+func TestPercolateIgnoresGroupAccess(t *testing.T) {
+	service, _, notifier := newTestSavedSearchService(10)
+	ctx := context.Background()
+	alice := &auth.Identity{Subject: "alice", Method: "jwt", Groups: []string{"eng"}}
+	if _, err := service.Create(ctx, alice, "acme", &models.SavedSearchRequest{Name: "Roadmaps", Query: "Roadmap"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		doc   *models.SearchDocument
+		alert bool
+	}{
+		{"public", &models.SearchDocument{ID: 1, Visibility: models.VisibilityPublic}, true},
+		{"owned", &models.SearchDocument{ID: 2, Visibility: models.VisibilityPrivate, Owner: "alice"}, true},
+		{"allowed user", &models.SearchDocument{ID: 3, Visibility: models.VisibilityTeam, AllowedUsers: []string{"alice"}}, true},
+		{"allowed group", &models.SearchDocument{ID: 4, Visibility: models.VisibilityTeam, AllowedGroups: []string{"eng"}}, false},
+		{"private", &models.SearchDocument{ID: 5, Visibility: models.VisibilityPrivate, Owner: "bob"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.doc.TenantID, tt.doc.Title = "acme", "Roadmap"
+			before := len(notifier.events)
+			if err := service.Percolate(ctx, tt.doc); err != nil {
+				t.Fatalf("Percolate: %v", err)
+			}
+			if alerted := len(notifier.events) > before; alerted != tt.alert {
+				t.Errorf("expected alert %v, got %v", tt.alert, alerted)
+			}
+		})
+	}
+}
+
+func TestConcurrentCreatesKeepTheLimit(t *testing.T) {
+	const creates, limit = 5, 2
+	service, store, _ := newTestSavedSearchService(limit)
+	ctx := context.Background()
+	alice := &auth.Identity{Subject: "alice", Method: "jwt"}
+
+	// Every create counts the owner's searches before any is stored
+	var counted sync.WaitGroup
+	counted.Add(creates)
+	var calls atomic.Int32
+	store.listed = func() {
+		if calls.Add(1) <= creates {
+			counted.Done()
+			counted.Wait()
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, creates)
+	for range creates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Create(ctx, alice, "acme", &models.SavedSearchRequest{Name: "Roadmaps", Query: "Roadmap"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil && !errors.Is(err, ErrTooManySavedSearches) {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	searches, total, _ := store.ListSavedSearches(ctx, "saved", "acme", "alice", creates)
+	if total != limit {
+		t.Fatalf("expected %d saved searches to be kept, got %d", limit, total)
+	}
+	if _, err := service.Create(ctx, alice, "acme", &models.SavedSearchRequest{Name: "More", Query: "More"}); !errors.Is(err, ErrTooManySavedSearches) {
+		t.Errorf("expected the next create to be refused, got %v (kept %+v)", err, searches)
+	}
+}
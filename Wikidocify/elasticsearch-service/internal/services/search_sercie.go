@@ -2,85 +2,433 @@
 package services
 
 import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend"
+	"wikidocify/elasticsearch-service/internal/cache"
 	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/logging"
 	"wikidocify/elasticsearch-service/internal/models"
+	"wikidocify/elasticsearch-service/internal/tenant"
 )
 
+// ErrSyncInProgress is returned by FullSync when another full sync is running
+var ErrSyncInProgress = errors.New("a full sync is already in progress")
+
+// Sync run limits: a run keeps the errors of its last maxSyncRunErrors
+// attempts, and the sync status lists the last syncHistoryLength runs
+const (
+	maxSyncRunErrors  = 20
+	syncHistoryLength = 10
+)
+
+// A running full sync checkpoints its run at least every syncRunHeartbeat,
+// between batches too. A running run not checkpointed for staleSyncRunAfter
+// was interrupted, and the next full sync resumes it.
+const (
+	syncRunHeartbeat  = 30 * time.Second
+	staleSyncRunAfter = 3 * syncRunHeartbeat
+)
+
+// LeaderElector tells whether this replica leads the scheduled syncs
+type LeaderElector interface {
+	IsLeader() bool
+	Status() models.LeaderStatus
+}
+
 type SearchService struct {
-	esClient     *elastic.Client
+	backend      backend.SearchBackend
 	docService   *DocServiceClient
 	syncInterval time.Duration
 	batchSize    int
 	enableSync   bool
-	lastSyncTime time.Time
+
+	syncing      atomic.Bool
+	mu           sync.RWMutex
+	lastSync     *models.SyncResult
+	lastSyncTime time.Time // when the last successful full sync finished
+
+	runs         SyncRunStore
+	resumeMaxAge time.Duration
+	runMu        sync.Mutex // guards the running sync's run and its writes
+
+	resultCache cache.Cache
+	cacheTTL    time.Duration
+
+	elector LeaderElector
 }
 
-func NewSearchService(esClient *elastic.Client, docService *DocServiceClient, syncInterval time.Duration, batchSize int, enableSync bool) *SearchService {
+func NewSearchService(searchBackend backend.SearchBackend, docService *DocServiceClient, syncInterval time.Duration, batchSize int, enableSync bool) *SearchService {
 	return &SearchService{
-		esClient:     esClient,
+		backend:      searchBackend,
 		docService:   docService,
 		syncInterval: syncInterval,
 		batchSize:    batchSize,
 		enableSync:   enableSync,
+		runs:         NewMemorySyncRunStore(),
 	}
 }
 
-// Search performs a search using the ES client and returns docs and total count
-func (s *SearchService) Search(req *models.SearchRequest) ([]models.SearchDocument, int64, error) {
-	return s.esClient.Search(req)
+// SetSyncRunStore keeps the full sync runs in store instead of memory. A
+// run that failed or was interrupted is resumed from its checkpoint by the
+// next full sync of the same tenants, unless its last checkpoint is older
+// than resumeMaxAge; 0 never resumes. It must be called before the service
+// is used.
+func (s *SearchService) SetSyncRunStore(store SyncRunStore, resumeMaxAge time.Duration) {
+	s.runs = store
+	s.resumeMaxAge = resumeMaxAge
+}
+
+// LoadLastSync restores the outcome of the last finished full sync, and when
+// the last successful one finished, from the sync run store, so they survive
+// restarts
+func (s *SearchService) LoadLastSync(ctx context.Context) error {
+	runs, err := s.runs.ListSyncRuns(ctx, syncHistoryLength)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var last *models.SyncResult
+	for _, run := range runs {
+		if run.FinishedAt == nil {
+			continue
+		}
+		if last == nil {
+			last = run.Result()
+			s.lastSync = last
+		}
+		if run.Status == models.SyncRunCompleted {
+			s.lastSyncTime = *run.FinishedAt
+			break
+		}
+	}
+	return nil
+}
+
+// SetResultCache enables caching of search results in c for at most ttl.
+// It must be called before the service is used.
+func (s *SearchService) SetResultCache(c cache.Cache, ttl time.Duration) {
+	s.resultCache = c
+	s.cacheTTL = ttl
+}
+
+// SetLeaderElector makes only the leader among the replicas run scheduled
+// syncs; without one, every replica runs them. It must be called before the
+// service is used.
+func (s *SearchService) SetLeaderElector(elector LeaderElector) {
+	s.elector = elector
 }
 
-// SyncDocument fetches a document from doc service and indexes it in ES
-func (s *SearchService) SyncDocument(docID uint32) error {
+// Search performs a search using the backend. Results are cached when a
+// result cache is set; see searchCacheKey. Cached results have no Took, as
+// the backend didn't search.
+func (s *SearchService) Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResult, error) {
+	identity := auth.IdentityFromContext(ctx)
+	if s.resultCache == nil || identity == nil {
+		return s.backend.Search(ctx, req)
+	}
+
+	generation, err := s.backend.Generation(ctx)
+	if err != nil {
+		// Without the generation a cached result may be stale
+		slog.WarnContext(ctx, "Failed to get the index generation; searching without the cache", "error", err)
+		return s.backend.Search(ctx, req)
+	}
+	key := searchCacheKey(generation, req, identity)
+	if value, ok := s.resultCache.Get(ctx, key); ok {
+		var result models.SearchResult
+		if err := json.Unmarshal(value, &result); err == nil {
+			slog.DebugContext(ctx, "Search cache hit", "tenant_id", req.TenantID)
+			result.Took = 0
+			return &result, nil
+		}
+	}
+
+	result, err := s.backend.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if value, err := json.Marshal(result); err == nil {
+		s.resultCache.Set(ctx, key, value, s.cacheTTL)
+	}
+	return result, nil
+}
+
+// searchCacheKey identifies a search result. It covers the normalized
+// request, everything the ACL filter depends on, and the index generation,
+// so a write to the index makes every earlier entry unreachable.
+func searchCacheKey(generation uint64, req *models.SearchRequest, identity *auth.Identity) string {
+	groups := slices.Clone(identity.Groups)
+	slices.Sort(groups)
+
+	searchType := req.Type
+	if searchType != "title" && searchType != "content" {
+		searchType = "all"
+	}
+
+	key, _ := json.Marshal([]interface{}{
+		generation,
+		req.TenantID,
+		searchType,
+		normalizeQuery(req.Query),
+		req.Author,
+		req.Limit,
+		req.Offset,
+		req.Rank,
+		req.Lang,
+		req.BoostRecent,
+		req.Explain,
+		req.Highlight,
+		identity.Method,
+		identity.Subject,
+		groups,
+	})
+	sum := sha256.Sum256(key)
+	return "search:" + hex.EncodeToString(sum[:])
+}
+
+// SyncDocument fetches a document from doc service, indexes it and
+// returns the indexed document. Unless tenantID is empty, a document of
+// another tenant isn't indexed and ErrDocumentNotFound is returned, as for
+// a missing one.
+func (s *SearchService) SyncDocument(ctx context.Context, tenantID string, docID uint32) (*models.SearchDocument, error) {
 	// Get document from doc service
-	doc, err := s.docService.GetDocument(docID)
+	doc, err := s.docService.GetDocument(ctx, docID)
 	if err != nil {
-		return fmt.Errorf("failed to get document from doc service: %w", err)
+		return nil, fmt.Errorf("failed to get document from doc service: %w", err)
+	}
+	if docTenant := cmp.Or(doc.TenantID, tenant.Default); tenantID != "" && docTenant != tenantID {
+		return nil, fmt.Errorf("document %d of tenant %s: %w", docID, docTenant, ErrDocumentNotFound)
 	}
 
 	// Convert to search document
 	searchDoc := doc.ToSearchDocument()
 
-	// Index in Elasticsearch
-	if err := s.esClient.IndexDocument(searchDoc); err != nil {
-		return fmt.Errorf("failed to index document: %w", err)
+	if err := s.backend.IndexDocument(ctx, searchDoc); err != nil {
+		return nil, fmt.Errorf("failed to index document: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Synced document", "doc_id", docID, "tenant_id", searchDoc.TenantID)
+	return searchDoc, nil
+}
+
+// DeleteDocument removes a tenant's document from the index by ID
+func (s *SearchService) DeleteDocument(ctx context.Context, tenantID string, docID uint32) error {
+	if err := s.backend.DeleteDocument(ctx, tenantID, docID); err != nil {
+		return fmt.Errorf("failed to delete document from index: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Deleted document from index", "doc_id", docID, "tenant_id", tenantID)
+	return nil
+}
+
+// FullSync fetches documents from doc service and bulk indexes them.
+// An empty tenantID syncs every tenant, otherwise only that tenant's
+// documents are synced. Documents are synced in ID order and the run is
+// checkpointed in the sync run store after every batch, so if the last run
+// of the same tenants failed or was interrupted, FullSync resumes it after
+// its last checkpoint. The outcome is kept for GetSyncStatus and the health
+// details report.
+//
+// A run still checkpointing, on this replica or another, is left alone and
+// FullSync returns ErrSyncInProgress. Every write of the run is conditional
+// on the version that was read, so if another replica resumes the run
+// anyway, after missing heartbeats, this sync stops at its next checkpoint.
+func (s *SearchService) FullSync(ctx context.Context, tenantID string) error {
+	if !s.syncing.CompareAndSwap(false, true) {
+		return ErrSyncInProgress
 	}
+	defer s.syncing.Store(false)
 
-	log.Printf("Successfully synced document ID: %d", docID)
+	run, err := s.resumableRun(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if run != nil {
+		slog.InfoContext(ctx, "Resuming full sync",
+			"run_id", run.ID,
+			"tenant_id", tenantID,
+			"after_id", run.LastID,
+			"documents_synced", run.DocumentsSynced,
+		)
+		run.Status = models.SyncRunRunning
+		run.Attempts++
+		run.FinishedAt = nil
+		run.CheckpointedAt = time.Now().UTC()
+	} else {
+		now := time.Now().UTC()
+		run = &models.SyncRun{
+			ID:             logging.NewRequestID(),
+			TenantID:       tenantID,
+			Status:         models.SyncRunRunning,
+			StartedAt:      now,
+			CheckpointedAt: now,
+			Attempts:       1,
+		}
+		slog.InfoContext(ctx, "Starting full sync", "run_id", run.ID, "tenant_id", tenantID)
+	}
+	if err := s.saveRun(ctx, run); err != nil {
+		// Another replica resumed the run between our read and write
+		return fmt.Errorf("%w: run %s was resumed by another replica", ErrSyncInProgress, run.ID)
+	}
+
+	syncCtx, cancel := context.WithCancelCause(ctx)
+	stopHeartbeat := s.heartbeat(syncCtx, cancel, run)
+	err = s.fullSync(syncCtx, run)
+	stopHeartbeat()
+	if cause := context.Cause(syncCtx); errors.Is(cause, elastic.ErrSyncRunConflict) {
+		err = cause
+	}
+	cancel(nil)
+
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.Status = models.SyncRunCompleted
+	if err != nil {
+		run.Status = models.SyncRunFailed
+		run.Errors = append(run.Errors, err.Error())
+		if len(run.Errors) > maxSyncRunErrors {
+			run.Errors = run.Errors[len(run.Errors)-maxSyncRunErrors:]
+		}
+	}
+	if errors.Is(err, elastic.ErrSyncRunConflict) {
+		// The run belongs to the replica that took it over
+		slog.WarnContext(ctx, "Full sync was taken over by another replica", "run_id", run.ID, "tenant_id", tenantID)
+	} else if saveErr := s.saveRun(ctx, run); saveErr != nil && err == nil {
+		err = saveErr
+		run.Status = models.SyncRunFailed
+		run.Errors = append(run.Errors, err.Error())
+	}
+
+	result := run.Result()
+	s.mu.Lock()
+	s.lastSync = result
+	if result.Success {
+		s.lastSyncTime = result.FinishedAt
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Full sync completed",
+		"run_id", run.ID,
+		"tenant_id", tenantID,
+		"total", run.DocumentsSynced,
+		"attempts", run.Attempts,
+		"duration_ms", result.DurationMs,
+	)
 	return nil
 }
 
-// DeleteDocument removes a document from ES by ID
-func (s *SearchService) DeleteDocument(docID uint32) error {
-	if err := s.esClient.DeleteDocument(docID); err != nil {
-		return fmt.Errorf("failed to delete document from Elasticsearch: %w", err)
+// resumableRun returns the last run of tenantID's full syncs if it should
+// be resumed: it failed, or it is running but stopped checkpointing and
+// was interrupted, and it was checkpointed within the resume max age. It
+// returns ErrSyncInProgress if the last run is still checkpointing.
+func (s *SearchService) resumableRun(ctx context.Context, tenantID string) (*models.SyncRun, error) {
+	run, err := s.runs.LatestSyncRun(ctx, tenantID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load the last sync run; starting a new one", "tenant_id", tenantID, "error", err)
+		return nil, nil
+	}
+	if run == nil || run.Status == models.SyncRunCompleted {
+		return nil, nil
 	}
+	idle := time.Since(run.CheckpointedAt)
+	if run.Status == models.SyncRunRunning && idle < staleSyncRunAfter {
+		slog.InfoContext(ctx, "Full sync is running elsewhere", "run_id", run.ID, "tenant_id", tenantID, "checkpointed_at", run.CheckpointedAt)
+		return nil, fmt.Errorf("%w: run %s is still checkpointing", ErrSyncInProgress, run.ID)
+	}
+	if s.resumeMaxAge <= 0 || idle > s.resumeMaxAge {
+		return nil, nil
+	}
+	return run, nil
+}
 
-	log.Printf("Successfully deleted document ID: %d", docID)
+// saveRun stores the run, even when ctx was canceled, under runMu. It
+// returns elastic.ErrSyncRunConflict if another replica wrote the run
+// since; any other failure is logged and the sync goes on, since without
+// the checkpoint a resume only redoes more work.
+func (s *SearchService) saveRun(ctx context.Context, run *models.SyncRun) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	err := s.runs.SaveSyncRun(ctx, run)
+	if errors.Is(err, elastic.ErrSyncRunConflict) {
+		return err
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to save sync run", "run_id", run.ID, "error", err)
+	}
 	return nil
 }
 
-// FullSync fetches all documents from doc service and bulk indexes them in ES
-func (s *SearchService) FullSync() error {
-	log.Println("Starting full sync...")
-	
-	page := 1
-	totalSynced := 0
+// heartbeat checkpoints run every syncRunHeartbeat until the returned stop
+// is called, so that other replicas don't take over a run whose batches
+// are slow. If another replica took it over anyway, ctx is canceled with
+// elastic.ErrSyncRunConflict.
+func (s *SearchService) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, run *models.SyncRun) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(syncRunHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runMu.Lock()
+				run.CheckpointedAt = time.Now().UTC()
+				s.runMu.Unlock()
+				if err := s.saveRun(ctx, run); err != nil {
+					cancel(err)
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
 
+// fullSync fetches the documents after the run's checkpoint from the doc
+// service a batch at a time, indexes them and moves the checkpoint on
+func (s *SearchService) fullSync(ctx context.Context, run *models.SyncRun) error {
 	for {
 		// Get documents in batches
-		docs, err := s.docService.GetDocumentsPaginated(page, s.batchSize)
+		docs, err := s.docService.GetDocumentsAfter(ctx, run.TenantID, run.LastID, s.batchSize)
 		if err != nil {
-			return fmt.Errorf("failed to get documents from doc service: %w", err)
+			return fmt.Errorf("failed to get documents after ID %d from doc service: %w", run.LastID, err)
 		}
 
 		if len(docs) == 0 {
 			break
 		}
+		// A doc service that ignores after_id returns the first batch again
+		// and again; without this the sync would never end
+		if lastID := docs[len(docs)-1].ID; lastID <= run.LastID {
+			return fmt.Errorf("doc service returned documents up to ID %d when asked for those after ID %d; does it support after_id?", lastID, run.LastID)
+		}
 
 		// Convert to search documents
 		searchDocs := make([]*models.SearchDocument, len(docs))
@@ -88,79 +436,106 @@ func (s *SearchService) FullSync() error {
 			searchDocs[i] = doc.ToSearchDocument()
 		}
 
-		// If you have BulkIndex implemented, use it. Otherwise, index one by one:
-		for _, sd := range searchDocs {
-			if err := s.esClient.IndexDocument(sd); err != nil {
-				return fmt.Errorf("failed to index document: %w", err)
-			}
+		if err := s.backend.BulkIndex(ctx, searchDocs); err != nil {
+			return fmt.Errorf("failed to index documents after ID %d: %w", run.LastID, err)
 		}
 
-		totalSynced += len(docs)
-		log.Printf("Synced batch %d: %d documents (total: %d)", page, len(docs), totalSynced)
+		s.runMu.Lock()
+		run.LastID = docs[len(docs)-1].ID
+		run.Batches++
+		run.DocumentsSynced += len(docs)
+		run.CheckpointedAt = time.Now().UTC()
+		s.runMu.Unlock()
+		if err := s.saveRun(ctx, run); err != nil {
+			return err
+		}
+		slog.DebugContext(ctx, "Synced batch", "run_id", run.ID, "last_id", run.LastID, "count", len(docs), "total", run.DocumentsSynced)
 
 		// If we got fewer documents than the batch size, we're done
 		if len(docs) < s.batchSize {
 			break
 		}
-
-		page++
 	}
 
-	s.lastSyncTime = time.Now()
-	log.Printf("Full sync completed. Total documents synced: %d", totalSynced)
 	return nil
 }
 
-// StartPeriodicSync runs FullSync on a schedule if enabled
+// ScheduledSync runs a full sync of every tenant if this replica leads the
+// scheduled syncs, and reports whether it ran
+func (s *SearchService) ScheduledSync(ctx context.Context) (bool, error) {
+	if s.elector != nil && !s.elector.IsLeader() {
+		slog.DebugContext(ctx, "Skipping scheduled sync; another replica leads", "leader", s.elector.Status().Leader)
+		return false, nil
+	}
+	return true, s.FullSync(ctx, "")
+}
+
+// StartPeriodicSync runs ScheduledSync on a schedule if enabled
 func (s *SearchService) StartPeriodicSync() {
 	if !s.enableSync {
-		log.Println("Periodic sync is disabled")
+		slog.Info("Periodic sync is disabled")
 		return
 	}
 
-	log.Printf("Starting periodic sync every %v", s.syncInterval)
-	
+	slog.Info("Starting periodic sync", "interval", s.syncInterval.String())
+
 	ticker := time.NewTicker(s.syncInterval)
 	go func() {
 		for range ticker.C {
-			log.Println("Starting periodic sync...")
-			if err := s.FullSync(); err != nil {
-				log.Printf("Periodic sync failed: %v", err)
-			} else {
-				log.Println("Periodic sync completed successfully")
+			if _, err := s.ScheduledSync(context.Background()); err != nil {
+				slog.Error("Periodic sync failed", "error", err)
 			}
 		}
 	}()
 }
 
-// GetSyncStatus returns sync status info
-func (s *SearchService) GetSyncStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"sync_enabled":    s.enableSync,
-		"sync_interval":   s.syncInterval.String(),
-		"batch_size":      s.batchSize,
-		"last_sync_time":  s.lastSyncTime,
+// LastSyncResult returns the outcome of the most recent full sync, or nil
+// if none has run yet
+func (s *SearchService) LastSyncResult() *models.SyncResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.lastSync == nil {
+		return nil
 	}
+	result := *s.lastSync
+	return &result
 }
 
-// HealthCheck checks the health of ES and doc service
-func (s *SearchService) HealthCheck() map[string]bool {
-	status := map[string]bool{
-		"elasticsearch": true,
-		"doc_service":   true,
-	}
+// SyncInProgress reports whether a full sync is currently running
+func (s *SearchService) SyncInProgress() bool {
+	return s.syncing.Load()
+}
 
-	// Check Elasticsearch
-	if err := s.esClient.HealthCheck(); err != nil {
-		status["elasticsearch"] = false
-		log.Printf("Elasticsearch health check failed: %v", err)
+// LeaderStatus reports which replica runs the scheduled syncs
+func (s *SearchService) LeaderStatus() models.LeaderStatus {
+	if s.elector == nil {
+		return models.LeaderStatus{Backend: "none", IsLeader: true}
 	}
+	return s.elector.Status()
+}
 
-	// Check Doc Service
-	if err := s.docService.HealthCheck(); err != nil {
-		status["doc_service"] = false
-		log.Printf("Doc service health check failed: %v", err)
+// GetSyncStatus returns sync status info, with the most recent runs.
+// last_sync_time, when the last successful full sync finished (zero before
+// the first one), is kept for the clients that predate last_sync.
+func (s *SearchService) GetSyncStatus(ctx context.Context) map[string]interface{} {
+	s.mu.RLock()
+	lastSyncTime := s.lastSyncTime
+	s.mu.RUnlock()
+	status := map[string]interface{}{
+		"sync_enabled":   s.enableSync,
+		"sync_interval":  s.syncInterval.String(),
+		"batch_size":     s.batchSize,
+		"in_progress":    s.SyncInProgress(),
+		"last_sync":      s.LastSyncResult(),
+		"last_sync_time": lastSyncTime,
+		"leader":         s.LeaderStatus(),
+	}
+	runs, err := s.runs.ListSyncRuns(ctx, syncHistoryLength)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list sync runs", "error", err)
+		status["runs_error"] = err.Error()
+	} else {
+		status["runs"] = runs
 	}
-
 	return status
-}
\ No newline at end of file
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+	"wikidocify/elasticsearch-service/internal/backend/memory"
+	"wikidocify/elasticsearch-service/internal/cache"
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// fakeDocService serves docs from the doc service's listing, versions and
+// single document endpoints. docs must be in ID order.
+func fakeDocService(t *testing.T, docs []*models.Document) *DocServiceClient {
+	t.Helper()
+	return flakyDocService(t, docs, func(int) bool { return false })
+}
+
+// flakyDocService is fakeDocService, failing the document listings after
+// the IDs for which fail returns true
+func flakyDocService(t *testing.T, docs []*models.Document, fail func(afterID int) bool) *DocServiceClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /documents", func(w http.ResponseWriter, r *http.Request) {
+		afterID, _ := strconv.Atoi(r.URL.Query().Get("after_id"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if fail(afterID) {
+			http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		tenantID := r.URL.Query().Get("tenant_id")
+		page := []*models.Document{}
+		for _, doc := range docs {
+			if int(doc.ID) > afterID && len(page) < limit && (tenantID == "" || doc.TenantID == tenantID) {
+				page = append(page, doc)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"documents": page,
+			"after_id":  afterID,
+			"limit":     limit,
+		})
+	})
+	mux.HandleFunc("GET /documents/versions", func(w http.ResponseWriter, r *http.Request) {
+		afterID, _ := strconv.Atoi(r.URL.Query().Get("after_id"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		versions := []models.DocumentVersion{}
+		for _, doc := range docs {
+			if int(doc.ID) > afterID && len(versions) < limit && doc.TenantID == r.URL.Query().Get("tenant_id") {
+				versions = append(versions, models.DocumentVersion{ID: doc.ID, TenantID: doc.TenantID, UpdatedAt: doc.UpdatedAt})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"versions": versions, "limit": limit})
+	})
+	mux.HandleFunc("GET /documents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		for _, doc := range docs {
+			if strconv.Itoa(int(doc.ID)) == r.PathValue("id") {
+				_ = json.NewEncoder(w).Encode(doc)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return NewDocServiceClient(server.URL, "", time.Second)
+}
+
+func publicDocument(id uint32, title string) *models.Document {
+	return &models.Document{ID: id, Title: title, Content: []byte("body of " + title), Visibility: models.VisibilityPublic}
+}
+
+func searchTotal(ctx context.Context, service *SearchService, req *models.SearchRequest) (int64, error) {
+	result, err := service.Search(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return result.Total, nil
+}
+
+func TestFullSyncIndexesEveryPage(t *testing.T) {
+	var docs []*models.Document
+	for i := uint32(1); i <= 5; i++ {
+		docs = append(docs, publicDocument(i, "Handbook "+strconv.Itoa(int(i))))
+	}
+	service := NewSearchService(memory.New(), fakeDocService(t, docs), time.Hour, 2, false)
+
+	if err := service.FullSync(context.Background(), ""); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	if result := service.LastSyncResult(); result == nil || !result.Success || result.DocumentsSynced != 5 {
+		t.Fatalf("unexpected sync result: %+v", result)
+	}
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+	result, err := service.Search(ctx, &models.SearchRequest{Query: "handbook", TenantID: "default", Limit: 10})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.Total != 5 {
+		t.Fatalf("expected 5 synced documents to match, got %d", result.Total)
+	}
+}
+
+func TestFullSyncResumesFromCheckpoint(t *testing.T) {
+	var docs []*models.Document
+	for i := uint32(1); i <= 5; i++ {
+		docs = append(docs, publicDocument(i, "Handbook "+strconv.Itoa(int(i))))
+	}
+	var down atomic.Bool
+	down.Store(true)
+	var mu sync.Mutex
+	var requested []int
+	client := flakyDocService(t, docs, func(afterID int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		requested = append(requested, afterID)
+		return afterID == 2 && down.Load()
+	})
+	service := NewSearchService(memory.New(), client, time.Hour, 2, false)
+	service.SetSyncRunStore(NewMemorySyncRunStore(), time.Hour)
+	ctx := context.Background()
+
+	if err := service.FullSync(ctx, ""); err == nil {
+		t.Fatal("expected the sync to fail")
+	}
+	failed := service.LastSyncResult()
+	if failed == nil || failed.Success || failed.DocumentsSynced != 2 {
+		t.Fatalf("expected a failure after the first batch, got %+v", failed)
+	}
+	if last, _ := service.GetSyncStatus(ctx)["last_sync_time"].(time.Time); !last.IsZero() {
+		t.Errorf("expected no last_sync_time before a successful sync, got %v", last)
+	}
+
+	down.Store(false)
+	mu.Lock()
+	requested = nil
+	mu.Unlock()
+	if err := service.FullSync(ctx, ""); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	mu.Lock()
+	if !slices.Equal(requested, []int{2, 4}) {
+		t.Errorf("expected the sync to resume after ID 2, requested %v", requested)
+	}
+	mu.Unlock()
+	status := service.GetSyncStatus(ctx)
+	runs, _ := status["runs"].([]models.SyncRun)
+	if len(runs) != 1 {
+		t.Fatalf("expected the run to be resumed, got %+v", status)
+	}
+	run := runs[0]
+	if run.ID != failed.RunID || run.Status != models.SyncRunCompleted || run.Attempts != 2 ||
+		run.DocumentsSynced != 5 || run.LastID != 5 || len(run.Errors) != 1 {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if last, _ := status["last_sync_time"].(time.Time); !last.Equal(*run.FinishedAt) {
+		t.Errorf("expected last_sync_time to be when the run finished, got %v", status["last_sync_time"])
+	}
+
+	// A completed run is not resumed
+	if err := service.FullSync(ctx, ""); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	runs, _ = service.GetSyncStatus(ctx)["runs"].([]models.SyncRun)
+	if len(runs) != 2 || runs[0].ID == run.ID || runs[0].DocumentsSynced != 5 {
+		t.Fatalf("expected a new run, got %+v", runs)
+	}
+}
+
+func TestFullSyncLeavesRunningRunsAlone(t *testing.T) {
+	docs := []*models.Document{publicDocument(1, "Roadmap"), publicDocument(2, "Roadmap draft")}
+	var requested atomic.Int32
+	client := flakyDocService(t, docs, func(int) bool {
+		requested.Add(1)
+		return false
+	})
+	store := NewMemorySyncRunStore()
+	service := NewSearchService(memory.New(), client, time.Hour, 10, false)
+	service.SetSyncRunStore(store, time.Hour)
+	ctx := context.Background()
+
+	// Another replica checkpointed its run a moment ago
+	running := &models.SyncRun{
+		ID:             "elsewhere",
+		Status:         models.SyncRunRunning,
+		StartedAt:      time.Now().UTC().Add(-time.Minute),
+		CheckpointedAt: time.Now().UTC(),
+		LastID:         1,
+		Attempts:       1,
+	}
+	if err := store.SaveSyncRun(ctx, running); err != nil {
+		t.Fatalf("SaveSyncRun: %v", err)
+	}
+	if err := service.FullSync(ctx, ""); !errors.Is(err, ErrSyncInProgress) {
+		t.Fatalf("expected the running run to be left alone, got %v", err)
+	}
+	if requested.Load() != 0 {
+		t.Fatalf("expected no documents to be fetched, got %d requests", requested.Load())
+	}
+
+	// Once it stops checkpointing, it was interrupted and is resumed
+	running.CheckpointedAt = time.Now().UTC().Add(-staleSyncRunAfter - time.Second)
+	if err := store.SaveSyncRun(ctx, running); err != nil {
+		t.Fatalf("SaveSyncRun: %v", err)
+	}
+	if err := service.FullSync(ctx, ""); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+	run, _ := store.LatestSyncRun(ctx, "")
+	if run.ID != "elsewhere" || run.Status != models.SyncRunCompleted || run.Attempts != 2 || run.DocumentsSynced != 1 {
+		t.Fatalf("expected the stale run to be resumed after ID 1, got %+v", run)
+	}
+}
+
+func TestFullSyncStopsWhenTakenOver(t *testing.T) {
+	var docs []*models.Document
+	for i := uint32(1); i <= 6; i++ {
+		docs = append(docs, publicDocument(i, "Handbook "+strconv.Itoa(int(i))))
+	}
+	store := NewMemorySyncRunStore()
+	client := flakyDocService(t, docs, func(afterID int) bool {
+		if afterID == 2 {
+			// Another replica resumes the run after the first checkpoint
+			run, _ := store.LatestSyncRun(context.Background(), "")
+			run.Attempts++
+			_ = store.SaveSyncRun(context.Background(), run)
+		}
+		return false
+	})
+	service := NewSearchService(memory.New(), client, time.Hour, 2, false)
+	service.SetSyncRunStore(store, time.Hour)
+	ctx := context.Background()
+
+	if err := service.FullSync(ctx, ""); !errors.Is(err, elastic.ErrSyncRunConflict) {
+		t.Fatalf("expected the sync to stop, got %v", err)
+	}
+	run, _ := store.LatestSyncRun(ctx, "")
+	if run.Status != models.SyncRunRunning || run.Attempts != 2 || run.LastID != 2 {
+		t.Fatalf("expected the run to be left to the other replica, got %+v", run)
+	}
+}
+
+func TestFullSyncFailsWhenAfterIDIsIgnored(t *testing.T) {
+	// An old doc service lists the first documents whatever after_id is
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"documents": []*models.Document{publicDocument(1, "Roadmap"), publicDocument(2, "Roadmap draft")},
+		})
+	}))
+	defer server.Close()
+	service := NewSearchService(memory.New(), NewDocServiceClient(server.URL, "", time.Second), time.Hour, 2, false)
+
+	err := service.FullSync(context.Background(), "")
+	if err == nil || !strings.Contains(err.Error(), "after_id") {
+		t.Fatalf("expected the sync to fail instead of looping, got %v", err)
+	}
+	if result := service.LastSyncResult(); result == nil || result.Success || result.DocumentsSynced != 2 {
+		t.Fatalf("expected a failure after the first batch, got %+v", result)
+	}
+}
+
+func TestCachedResultsAreDroppedAfterWrites(t *testing.T) {
+	docs := []*models.Document{publicDocument(1, "Roadmap"), publicDocument(2, "Roadmap draft")}
+	service := NewSearchService(memory.New(), fakeDocService(t, docs), time.Hour, 10, false)
+	service.SetResultCache(cache.NewLRU(10), time.Minute)
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+	req := &models.SearchRequest{Query: "roadmap", TenantID: "default", Limit: 10}
+	if _, err := service.SyncDocument(ctx, "", 1); err != nil {
+		t.Fatalf("SyncDocument: %v", err)
+	}
+	if total, err := searchTotal(ctx, service, req); err != nil || total != 1 {
+		t.Fatalf("expected 1 result, got %d (%v)", total, err)
+	}
+
+	if _, err := service.SyncDocument(ctx, "", 2); err != nil {
+		t.Fatalf("SyncDocument: %v", err)
+	}
+	if total, err := searchTotal(ctx, service, req); err != nil || total != 2 {
+		t.Fatalf("expected the cached result to be replaced after indexing, got %d (%v)", total, err)
+	}
+
+	if err := service.DeleteDocument(ctx, "default", 1); err != nil {
+		t.Fatalf("DeleteDocument: %v", err)
+	}
+	if total, err := searchTotal(ctx, service, req); err != nil || total != 1 {
+		t.Fatalf("expected the cached result to be replaced after deleting, got %d (%v)", total, err)
+	}
+}
+
+func TestCachedResultsDontCrossIdentities(t *testing.T) {
+	docs := []*models.Document{
+		{ID: 1, Title: "Salaries", Owner: "alice", Visibility: models.VisibilityPrivate},
+	}
+	service := NewSearchService(memory.New(), fakeDocService(t, docs), time.Hour, 10, false)
+	service.SetResultCache(cache.NewLRU(10), time.Minute)
+	if err := service.FullSync(context.Background(), ""); err != nil {
+		t.Fatalf("FullSync: %v", err)
+	}
+
+	req := &models.SearchRequest{Query: "salaries", TenantID: "default", Limit: 10}
+	alice := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "alice", Method: "jwt"})
+	if total, _ := searchTotal(alice, service, req); total != 1 {
+		t.Fatalf("owner should see the document, got %d results", total)
+	}
+	bob := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "bob", Method: "jwt"})
+	if total, _ := searchTotal(bob, service, req); total != 0 {
+		t.Fatalf("another caller was served the owner's cached result (%d results)", total)
+	}
+}
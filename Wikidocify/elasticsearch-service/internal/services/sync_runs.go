@@ -0,0 +1,131 @@
+// internal/services/sync_runs.go
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// SyncRunStore keeps the full sync runs, their checkpoints and history
+type SyncRunStore interface {
+	// SaveSyncRun stores run, replacing an earlier state of the same run,
+	// if the stored run is still at the version of run, and updates that
+	// version. It returns elastic.ErrSyncRunConflict if the run was written
+	// since it was read, or already exists when run was never stored.
+	SaveSyncRun(ctx context.Context, run *models.SyncRun) error
+	// LatestSyncRun returns the run that started last among those syncing
+	// tenantID, empty for every tenant, with its version as it stands, or
+	// nil if there is none
+	LatestSyncRun(ctx context.Context, tenantID string) (*models.SyncRun, error)
+	// ListSyncRuns returns up to limit runs, most recently started first
+	ListSyncRuns(ctx context.Context, limit int) ([]models.SyncRun, error)
+}
+
+// elasticSyncRuns keeps the sync runs in an Elasticsearch index, so they
+// outlive the process
+type elasticSyncRuns struct {
+	esClient *elastic.Client
+	index    string
+}
+
+// NewElasticsearchSyncRunStore returns a SyncRunStore kept in index, which
+// is created if it doesn't exist
+func NewElasticsearchSyncRunStore(ctx context.Context, esClient *elastic.Client, index string) (SyncRunStore, error) {
+	if err := esClient.EnsureSyncRunsIndex(ctx, index); err != nil {
+		return nil, err
+	}
+	return &elasticSyncRuns{esClient: esClient, index: index}, nil
+}
+
+func (s *elasticSyncRuns) SaveSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return s.esClient.PutSyncRun(ctx, s.index, run)
+}
+
+func (s *elasticSyncRuns) LatestSyncRun(ctx context.Context, tenantID string) (*models.SyncRun, error) {
+	runs, err := s.esClient.ListSyncRuns(ctx, s.index, &tenantID, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	// The search may lag behind the last checkpoint; get the run as it
+	// stands, with the version to write it back with
+	return s.esClient.GetSyncRun(ctx, s.index, runs[0].ID)
+}
+
+func (s *elasticSyncRuns) ListSyncRuns(ctx context.Context, limit int) ([]models.SyncRun, error) {
+	return s.esClient.ListSyncRuns(ctx, s.index, nil, limit)
+}
+
+// maxMemorySyncRuns is the number of runs a memory store keeps
+const maxMemorySyncRuns = 100
+
+// memorySyncRuns keeps the last sync runs in process, for the backends
+// other than Elasticsearch. Failed runs resume, but not after a restart.
+type memorySyncRuns struct {
+	mu    sync.Mutex
+	runs  []models.SyncRun // in start order
+	seqNo int              // of the last write, for the runs' versions
+}
+
+// NewMemorySyncRunStore returns a SyncRunStore that keeps the last runs in
+// memory
+func NewMemorySyncRunStore() SyncRunStore {
+	return &memorySyncRuns{}
+}
+
+func (s *memorySyncRuns) SaveSyncRun(ctx context.Context, run *models.SyncRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqNo++
+	for i := range s.runs {
+		if s.runs[i].ID == run.ID {
+			if s.runs[i].SeqNo != run.SeqNo || run.PrimaryTerm == 0 {
+				return elastic.ErrSyncRunConflict
+			}
+			run.SeqNo = s.seqNo
+			s.runs[i] = *run
+			s.runs[i].Errors = slices.Clone(run.Errors)
+			return nil
+		}
+	}
+	if run.PrimaryTerm != 0 {
+		// The run was dropped since it was read
+		return elastic.ErrSyncRunConflict
+	}
+	run.SeqNo, run.PrimaryTerm = s.seqNo, 1
+	saved := *run
+	saved.Errors = slices.Clone(run.Errors)
+	s.runs = append(s.runs, saved)
+	if len(s.runs) > maxMemorySyncRuns {
+		s.runs = s.runs[len(s.runs)-maxMemorySyncRuns:]
+	}
+	return nil
+}
+
+func (s *memorySyncRuns) LatestSyncRun(ctx context.Context, tenantID string) (*models.SyncRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if s.runs[i].TenantID == tenantID {
+			run := s.runs[i]
+			run.Errors = slices.Clone(run.Errors)
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memorySyncRuns) ListSyncRuns(ctx context.Context, limit int) ([]models.SyncRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]models.SyncRun, 0, min(limit, len(s.runs)))
+	for i := len(s.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		run := s.runs[i]
+		run.Errors = slices.Clone(run.Errors)
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
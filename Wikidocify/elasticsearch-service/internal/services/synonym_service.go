@@ -0,0 +1,183 @@
+// internal/services/synonym_service.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"wikidocify/elasticsearch-service/internal/elastic"
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+// maxSynonymVersions is the number of versions listed by Versions
+const maxSynonymVersions = 100
+
+// SynonymService manages the search synonyms. Every change is stored as a
+// numbered version before it is applied, so any earlier set of rules can be
+// restored.
+type SynonymService struct {
+	esClient      *elastic.Client
+	versionsIndex string
+
+	mu sync.Mutex // serializes changes made through this instance
+}
+
+func NewSynonymService(esClient *elastic.Client, versionsIndex string) *SynonymService {
+	return &SynonymService{
+		esClient:      esClient,
+		versionsIndex: versionsIndex,
+	}
+}
+
+// Start creates the versions index
+func (s *SynonymService) Start(ctx context.Context) error {
+	return s.esClient.EnsureSynonymVersionsIndex(ctx, s.versionsIndex)
+}
+
+// Current returns the version in effect, or an empty version 0 if the rules
+// were never changed
+func (s *SynonymService) Current(ctx context.Context) (*models.SynonymVersion, error) {
+	versions, err := s.esClient.ListSynonymVersions(ctx, s.versionsIndex, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return &models.SynonymVersion{Rules: []string{}}, nil
+	}
+	return &versions[0], nil
+}
+
+// Versions returns the most recent versions, newest first
+func (s *SynonymService) Versions(ctx context.Context) ([]models.SynonymVersion, error) {
+	return s.esClient.ListSynonymVersions(ctx, s.versionsIndex, maxSynonymVersions)
+}
+
+// Update replaces the rules, which must have been validated with
+// ValidateSynonymRules
+func (s *SynonymService) Update(ctx context.Context, createdBy string, rules []string) (*models.SynonymVersion, error) {
+	return s.apply(ctx, createdBy, rules, 0)
+}
+
+// Rollback re-applies the rules of an earlier version as a new version. It
+// returns nil if the version doesn't exist.
+func (s *SynonymService) Rollback(ctx context.Context, createdBy string, version int) (*models.SynonymVersion, error) {
+	previous, err := s.esClient.GetSynonymVersion(ctx, s.versionsIndex, version)
+	if err != nil || previous == nil {
+		return nil, err
+	}
+	return s.apply(ctx, createdBy, previous.Rules, version)
+}
+
+// apply records the next version, then applies it. A version that could not
+// be applied is removed again, so the latest version is always the one in
+// effect.
+func (s *SynonymService) apply(ctx context.Context, createdBy string, rules []string, rolledBackFrom int) (*models.SynonymVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []string{}
+	}
+	next := &models.SynonymVersion{
+		Version:        current.Version + 1,
+		Rules:          rules,
+		CreatedAt:      time.Now().UTC(),
+		CreatedBy:      createdBy,
+		RolledBackFrom: rolledBackFrom,
+	}
+	if err := s.esClient.CreateSynonymVersion(ctx, s.versionsIndex, next); err != nil {
+		return nil, err
+	}
+	if err := s.esClient.PutSynonyms(ctx, rules); err != nil {
+		if delErr := s.esClient.DeleteSynonymVersion(ctx, s.versionsIndex, next.Version); delErr != nil {
+			return nil, fmt.Errorf("%w (and removing version %d failed: %v)", err, next.Version, delErr)
+		}
+		return nil, err
+	}
+	return next, nil
+}
+
+// ParseSynonymText splits a synonyms file into rules. Blank lines and lines
+// starting with '#' are skipped; lines holds the 1-based line number of each
+// rule.
+func ParseSynonymText(text string) (rules []string, lines []int) {
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+		lines = append(lines, i+1)
+	}
+	return rules, lines
+}
+
+// ValidateSynonymRules checks that every rule is in the Solr format Elasticsearch
+// accepts: equivalent terms separated by commas ("k8s, kubernetes") or an
+// explicit mapping ("es, elastic => elasticsearch"). lines gives the line
+// number reported for each rule; if nil, rules are numbered from 1.
+func ValidateSynonymRules(rules []string, lines []int) []models.SynonymRuleError {
+	var errs []models.SynonymRuleError
+	for i, rule := range rules {
+		line := i + 1
+		if lines != nil {
+			line = lines[i]
+		}
+		if msg := validateSynonymRule(rule); msg != "" {
+			errs = append(errs, models.SynonymRuleError{Line: line, Rule: rule, Error: msg})
+		}
+	}
+	return errs
+}
+
+func validateSynonymRule(rule string) string {
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return "rule is empty"
+	}
+	if strings.ContainsAny(rule, "\n\r") {
+		return "rule spans several lines"
+	}
+
+	sides := strings.Split(rule, "=>")
+	switch len(sides) {
+	case 1:
+		terms, msg := synonymTerms(sides[0])
+		if msg != "" {
+			return msg
+		}
+		if len(terms) < 2 {
+			return "equivalent synonyms need at least two comma-separated terms"
+		}
+	case 2:
+		if _, msg := synonymTerms(sides[0]); msg != "" {
+			return "left of =>: " + msg
+		}
+		if _, msg := synonymTerms(sides[1]); msg != "" {
+			return "right of =>: " + msg
+		}
+	default:
+		return "rule contains more than one =>"
+	}
+	return ""
+}
+
+func synonymTerms(side string) ([]string, string) {
+	if strings.TrimSpace(side) == "" {
+		return nil, "no terms"
+	}
+	terms := strings.Split(side, ",")
+	for _, term := range terms {
+		if strings.TrimSpace(term) == "" {
+			return nil, "empty term between commas"
+		}
+	}
+	return terms, ""
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/models"
+)
+
+func TestParseSynonymText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		rules []string
+		lines []int
+	}{
+		{"empty", "", nil, nil},
+		{"one rule", "k8s, kubernetes", []string{"k8s, kubernetes"}, []int{1}},
+		{"comments and blank lines", "# infra\n\nk8s, kubernetes\n  # indented comment\nes => elasticsearch\n", []string{"k8s, kubernetes", "es => elasticsearch"}, []int{3, 5}},
+		{"trims lines", "  db, database  \r\n", []string{"db, database"}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, lines := ParseSynonymText(tt.text)
+			if !slices.Equal(rules, tt.rules) || !slices.Equal(lines, tt.lines) {
+				t.Errorf("got %q at %v, want %q at %v", rules, lines, tt.rules, tt.lines)
+			}
+		})
+	}
+}
+
+func TestValidateSynonymRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		lines []int
+		want  []models.SynonymRuleError
+	}{
+		{"equivalent terms", []string{"k8s, kubernetes", "db,database,datastore"}, nil, nil},
+		{"explicit mapping", []string{"es, elastic => elasticsearch", "k8s => kubernetes"}, nil, nil},
+		{"empty", []string{"  "}, nil, []models.SynonymRuleError{{Line: 1, Rule: "  ", Error: "rule is empty"}}},
+		{"single term", []string{"kubernetes"}, nil, []models.SynonymRuleError{{Line: 1, Rule: "kubernetes", Error: "equivalent synonyms need at least two comma-separated terms"}}},
+		{"empty term", []string{"k8s,,kubernetes"}, nil, []models.SynonymRuleError{{Line: 1, Rule: "k8s,,kubernetes", Error: "empty term between commas"}}},
+		{"empty left side", []string{" => elasticsearch"}, nil, []models.SynonymRuleError{{Line: 1, Rule: " => elasticsearch", Error: "left of =>: no terms"}}},
+		{"empty right side", []string{"es =>"}, nil, []models.SynonymRuleError{{Line: 1, Rule: "es =>", Error: "right of =>: no terms"}}},
+		{"two arrows", []string{"a => b => c"}, nil, []models.SynonymRuleError{{Line: 1, Rule: "a => b => c", Error: "rule contains more than one =>"}}},
+		{"several lines", []string{"a, b\nc, d"}, nil, []models.SynonymRuleError{{Line: 1, Rule: "a, b\nc, d", Error: "rule spans several lines"}}},
+		{"numbered from 1", []string{"a, b", "c"}, nil, []models.SynonymRuleError{{Line: 2, Rule: "c", Error: "equivalent synonyms need at least two comma-separated terms"}}},
+		{"file line numbers", []string{"a, b", "c"}, []int{3, 7}, []models.SynonymRuleError{{Line: 7, Rule: "c", Error: "equivalent synonyms need at least two comma-separated terms"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateSynonymRules(tt.rules, tt.lines); !slices.Equal(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
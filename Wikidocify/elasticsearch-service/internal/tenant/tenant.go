@@ -0,0 +1,77 @@
+// internal/tenant/tenant.go
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header optionally names the tenant of a request.
+const Header = "X-Tenant-ID"
+
+// Default is the tenant used when neither the request nor the credentials
+// name one. It matches the doc service default.
+const Default = "default"
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Valid reports whether id is a usable tenant ID. Tenant IDs end up in index
+// names, so they are limited to lowercase letters, digits, '-' and '_'.
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying tenantID.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext returns the tenant stored in ctx, or "".
+func FromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// Middleware resolves the tenant of a request from the tenant_id query
+// parameter, the X-Tenant-ID header or the caller's credentials, in that
+// order, and stores it in the request context. It must run after the auth
+// middleware: credentials bound to a tenant may only access that tenant.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := auth.IdentityFromContext(c.Request.Context())
+
+		tenantID := c.Query("tenant_id")
+		if tenantID == "" {
+			tenantID = c.GetHeader(Header)
+		}
+		if tenantID == "" && identity != nil {
+			tenantID = identity.Tenant
+		}
+		if tenantID == "" {
+			tenantID = Default
+		}
+
+		if !Valid(tenantID) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid tenant ID",
+			})
+			return
+		}
+		if identity != nil && identity.Tenant != "" && identity.Tenant != tenantID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Credentials are not valid for tenant " + tenantID,
+			})
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
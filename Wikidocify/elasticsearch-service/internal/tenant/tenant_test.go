@@ -0,0 +1,63 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wikidocify/elasticsearch-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		query      string
+		header     string
+		identity   *auth.Identity
+		wantStatus int
+		wantTenant string
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantTenant: Default},
+		{name: "query parameter", query: "acme", header: "globex", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "header", header: "globex", wantStatus: http.StatusOK, wantTenant: "globex"},
+		{name: "credentials", identity: &auth.Identity{Subject: "svc", Tenant: "initech"}, wantStatus: http.StatusOK, wantTenant: "initech"},
+		{name: "header over credentials", header: "initech", identity: &auth.Identity{Subject: "svc", Tenant: "initech"}, wantStatus: http.StatusOK, wantTenant: "initech"},
+		{name: "unbound credentials", query: "acme", identity: &auth.Identity{Subject: "ops"}, wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "uppercase", query: "Acme", wantStatus: http.StatusBadRequest},
+		{name: "path characters", header: "../acme", wantStatus: http.StatusBadRequest},
+		{name: "too long", query: "a123456789012345678901234567890123456789012345678901234567890123", wantStatus: http.StatusBadRequest},
+		{name: "other tenant than the credentials", query: "acme", identity: &auth.Identity{Subject: "svc", Tenant: "globex"}, wantStatus: http.StatusForbidden},
+		{name: "other tenant in the header", header: "acme", identity: &auth.Identity{Subject: "svc", Tenant: "globex"}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.identity != nil {
+					c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), tt.identity))
+				}
+			})
+			router.GET("/", Middleware(), func(c *gin.Context) {
+				got = FromContext(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/?tenant_id="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+			if got != tt.wantTenant {
+				t.Errorf("expected tenant %q, got %q", tt.wantTenant, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{"default", "", false},
+		{"none", ExporterNone, false},
+		{"stdout", ExporterStdout, false},
+		{"unknown", "jaeger", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Init(context.Background(), "test", tt.exporter, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+			// Trace context is propagated even when exporting is disabled
+			fields := otel.GetTextMapPropagator().Fields()
+			for _, want := range []string{"traceparent", "baggage"} {
+				if !slices.Contains(fields, want) {
+					t.Errorf("expected the propagator to handle %s, got %v", want, fields)
+				}
+			}
+		})
+	}
+}
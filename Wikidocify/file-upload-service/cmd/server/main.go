@@ -1,54 +1,122 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"wikidocify/file-upload-service/internal/config"
 	"wikidocify/file-upload-service/internal/kafka"
+	"wikidocify/file-upload-service/internal/logging"
 	"wikidocify/file-upload-service/internal/routes"
-
-	"github.com/joho/godotenv"
+	"wikidocify/file-upload-service/internal/tracing"
 )
 
 func main() {
-	log.Println("[STARTUP] Starting file-upload-service server...")
+	// Load configuration: defaults, config file, environment (.env included) and flags
+	args := os.Args[1:]
+	cfg, err := config.Load(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "file-upload-service: %v\n", err)
+		os.Exit(2)
+	}
+	var liveConfig atomic.Pointer[config.Config]
+	liveConfig.Store(cfg)
+
+	// Initialize structured logging
+	if err := logging.InitLogger(cfg.Log.Level); err != nil {
+		logging.Fatal("Failed to initialize logging", "component", "config", "error", err)
+	}
+	slog.Info("Starting file-upload-service server", "component", "startup")
 
-	// Load environment variables from .env file
-	log.Println("[CONFIG] Loading environment variables...")
-	if err := godotenv.Load(); err != nil {
-		log.Printf("[CONFIG] Warning: Error loading .env file: %v", err)
-		log.Println("[CONFIG] Continuing with system environment variables...")
-	} else {
-		log.Println("[CONFIG] Environment variables loaded successfully")
+	// Initialize tracing before the instrumented DB and Kafka clients
+	shutdownTracing, err := tracing.InitTracer(context.Background(),
+		cfg.Tracing.Exporter, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "component", "tracing", "error", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to shut down tracing", "component", "tracing", "error", err)
+		}
+	}()
 
 	// Initialize database
-	log.Println("[DATABASE] Initializing database connection...")
-	config.InitDB()
+	config.InitDB(cfg)
 
 	// Initialize Kafka producer
-	log.Println("[KAFKA] Initializing Kafka producer...")
-	kafka.InitKafkaWriter()
+	kafka.InitKafkaWriter(cfg.Kafka.Broker, cfg.Kafka.Topic)
 
 	// Setup routes
-	log.Println("[ROUTES] Setting up HTTP routes...")
-	router := routes.SetupRoutes()
+	router := routes.SetupRoutes(liveConfig.Load)
 
-	// Get server port from environment variable
-	port := os.Getenv("UPLOAD_SERVICE_PORT")
-	if port == "" {
-		port = "8081" // fallback to default port
-		log.Printf("[CONFIG] UPLOAD_SERVICE_PORT not set, using default port: %s", port)
-	} else {
-		log.Printf("[CONFIG] Using configured port: %s", port)
-	}
+	// Reload the log level on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloadConfig(&liveConfig, args)
+		}
+	}()
 
 	// Start the server
-	log.Printf("[SERVER] Starting HTTP server on port %s...", port)
-	log.Printf("[SERVER] Server ready! Visit http://localhost:%s/documents", port)
+	server := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: router,
+	}
+	go func() {
+		slog.Info("Starting HTTP server", "component", "server", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Failed to start server", "component", "server", "error", err)
+		}
+	}()
+
+	// Wait for SIGINT or SIGTERM, then let outstanding requests finish so
+	// their events are published and the deferred tracing shutdown flushes
+	// their spans
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	slog.Info("Shutting down server", "component", "server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shut down", "component", "server", "error", err)
+	}
+	if err := kafka.KafkaWriter.Close(); err != nil {
+		slog.Error("Failed to close Kafka producer", "component", "kafka", "error", err)
+	}
+}
+
+// reloadConfig loads the configuration again and applies the log level. An
+// invalid configuration is logged and the current one kept.
+func reloadConfig(live *atomic.Pointer[config.Config], args []string) {
+	cfg, restart, err := config.Reload(live.Load(), args)
+	if err == nil {
+		err = logging.SetLevel(cfg.Log.Level)
+	}
+	if err != nil {
+		slog.Error("Failed to reload config, keeping the current one", "component", "config", "error", err)
+		return
+	}
+	live.Store(cfg)
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("[SERVER] Failed to start server: %v", err)
+	slog.Info("Config reloaded", "component", "config", "log_level", cfg.Log.Level)
+	if len(restart) > 0 {
+		slog.Warn("Config changes that need a restart were ignored", "component", "config", "sections", restart)
 	}
 }
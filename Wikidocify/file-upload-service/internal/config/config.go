@@ -0,0 +1,285 @@
+// config/config.go
+// This file contains the configuration of the document service
+// It defines the Config struct and the Load, Validate, Redacted and Reload functions
+// Settings come from defaults, a YAML file, the environment and flags, in that order
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"wikidocify/file-upload-service/internal/tracing"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the document service. The json/yaml keys
+// are those of the configuration file and of the /admin/config view.
+type Config struct {
+	Server struct {
+		Port string `json:"port" yaml:"port"`
+	} `json:"server" yaml:"server"`
+
+	Database struct {
+		Host     string `json:"host" yaml:"host"`
+		Port     string `json:"port" yaml:"port"`
+		User     string `json:"user" yaml:"user"`
+		Password string `json:"password" yaml:"password"` // required, no default
+		Name     string `json:"name" yaml:"name"`
+		SSLMode  string `json:"sslmode" yaml:"sslmode"`
+	} `json:"database" yaml:"database"`
+
+	Kafka struct {
+		Broker string `json:"broker" yaml:"broker"`
+		Topic  string `json:"topic" yaml:"topic"`
+	} `json:"kafka" yaml:"kafka"`
+
+	Log struct {
+		Level string `json:"level" yaml:"level"` // "debug", "info", "warn" or "error"
+	} `json:"log" yaml:"log"`
+
+	// Admin.Token is the bearer token of the /admin endpoints, which are
+	// disabled without one
+	Admin struct {
+		Token string `json:"token" yaml:"token"`
+	} `json:"admin" yaml:"admin"`
+
+	// The OTLP endpoint is read from OTEL_EXPORTER_OTLP_ENDPOINT
+	Tracing struct {
+		ServiceName string  `json:"service_name" yaml:"service_name"`
+		Exporter    string  `json:"exporter" yaml:"exporter"` // "none", "stdout" or "otlp"
+		SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+	} `json:"tracing" yaml:"tracing"`
+
+	// file is the configuration as the file left it, before the
+	// environment and flags, so Reload can tell what the file changed
+	file *Config
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the YAML file named by the -config flag or CONFIG_FILE, the
+// environment (including a .env file) and the command line flags args. The
+// result is validated, and the error lists every invalid setting.
+func Load(args []string) (*Config, error) {
+	// Load environment variables from .env file (optional, for local dev)
+	_ = godotenv.Load()
+
+	flags := flag.NewFlagSet("file-upload-service", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML configuration file (default $CONFIG_FILE)")
+	port := flags.String("port", "", "HTTP port (overrides UPLOAD_SERVICE_PORT)")
+	logLevel := flags.String("log-level", "", "debug, info, warn or error (overrides LOG_LEVEL)")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults()
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		file := *cfg
+		cfg.file = &file
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment:\n%w", err)
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// defaults returns the configuration used when nothing else is set. The
+// database password has no default.
+func defaults() *Config {
+	cfg := &Config{}
+	cfg.Server.Port = "8081"
+	cfg.Database.Host = "localhost"
+	cfg.Database.Port = "5432"
+	cfg.Database.User = "doc_db_admin"
+	cfg.Database.Name = "documents_db"
+	cfg.Database.SSLMode = "disable"
+	cfg.Log.Level = "info"
+	cfg.Tracing.ServiceName = tracing.ServiceName
+	cfg.Tracing.Exporter = "none"
+	cfg.Tracing.SampleRatio = 1.0
+	return cfg
+}
+
+// loadFile overrides cfg with the settings of a YAML file. Unknown keys are
+// rejected so typos don't go unnoticed.
+func (cfg *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with the environment variables that are set
+func (cfg *Config) loadEnv() error {
+	setString := func(key string, value *string) {
+		if v := os.Getenv(key); v != "" {
+			*value = v
+		}
+	}
+	setString("UPLOAD_SERVICE_PORT", &cfg.Server.Port)
+	setString("DB_HOST", &cfg.Database.Host)
+	setString("DB_PORT", &cfg.Database.Port)
+	setString("DB_USER", &cfg.Database.User)
+	setString("DB_PASSWORD", &cfg.Database.Password)
+	setString("DB_NAME", &cfg.Database.Name)
+	setString("DB_SSLMODE", &cfg.Database.SSLMode)
+	setString("KAFKA_BROKER", &cfg.Kafka.Broker)
+	setString("KAFKA_TOPIC", &cfg.Kafka.Topic)
+	setString("LOG_LEVEL", &cfg.Log.Level)
+	setString("ADMIN_TOKEN", &cfg.Admin.Token)
+	setString("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	setString("OTEL_TRACES_EXPORTER", &cfg.Tracing.Exporter)
+
+	if v := os.Getenv("OTEL_TRACES_SAMPLE_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("OTEL_TRACES_SAMPLE_RATIO=%q: expected a number", v)
+		}
+		cfg.Tracing.SampleRatio = ratio
+	}
+	return nil
+}
+
+// Validate returns one error per invalid or missing setting, naming each by
+// its file key and environment variable
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, env, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s (%s) %s", key, env, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(isPort(cfg.Server.Port), "server.port", "UPLOAD_SERVICE_PORT", "must be a port number, got %q", cfg.Server.Port)
+	check(cfg.Database.Host != "", "database.host", "DB_HOST", "is required")
+	check(isPort(cfg.Database.Port), "database.port", "DB_PORT", "must be a port number, got %q", cfg.Database.Port)
+	check(cfg.Database.User != "", "database.user", "DB_USER", "is required")
+	check(cfg.Database.Password != "", "database.password", "DB_PASSWORD", "is required")
+	check(cfg.Database.Name != "", "database.name", "DB_NAME", "is required")
+	check(slices.Contains([]string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}, cfg.Database.SSLMode),
+		"database.sslmode", "DB_SSLMODE", "must be a PostgreSQL sslmode such as disable or verify-full, got %q", cfg.Database.SSLMode)
+	check(cfg.Kafka.Broker != "", "kafka.broker", "KAFKA_BROKER", "is required")
+	check(cfg.Kafka.Topic != "", "kafka.topic", "KAFKA_TOPIC", "is required")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(strings.ToUpper(cfg.Log.Level))) == nil,
+		"log.level", "LOG_LEVEL", "must be debug, info, warn or error, got %q", cfg.Log.Level)
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, cfg.Tracing.Exporter),
+		"tracing.exporter", "OTEL_TRACES_EXPORTER", "must be none, stdout or otlp, got %q", cfg.Tracing.Exporter)
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio", "OTEL_TRACES_SAMPLE_RATIO", "must be between 0 and 1")
+
+	return errors.Join(errs...)
+}
+
+func isPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port < 65536
+}
+
+// DSN returns the PostgreSQL connection URL of the database settings. Each
+// part is escaped, so a password may contain spaces, quotes, @ or /.
+func (cfg *Config) DSN() string {
+	db := cfg.Database
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.User, db.Password),
+		Host:     net.JoinHostPort(db.Host, db.Port),
+		Path:     "/" + db.Name,
+		RawQuery: url.Values{"sslmode": {db.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// Redacted returns a copy of cfg that is safe to show: the database password
+// and the admin token are replaced by "REDACTED" and credentials are removed
+// from the broker address
+func (cfg *Config) Redacted() *Config {
+	c := *cfg
+	if c.Database.Password != "" {
+		c.Database.Password = "REDACTED"
+	}
+	if c.Admin.Token != "" {
+		c.Admin.Token = "REDACTED"
+	}
+	if u, err := url.Parse(c.Kafka.Broker); err == nil && u.User != nil {
+		c.Kafka.Broker = u.Redacted()
+	}
+	return &c
+}
+
+// Reload loads the configuration again, with the same args as at startup,
+// and returns current with the log level, the only setting that can change
+// at runtime, taken from the file if the file changed it. The environment
+// and flags can't change in a running process, so a new level in the file
+// applies even when LOG_LEVEL or -log-level set it at startup. The sections
+// in which other settings changed are returned so the caller can warn that
+// they need a restart.
+func Reload(current *Config, args []string) (*Config, []string, error) {
+	next, err := Load(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reloaded := *current
+	reloaded.file = next.file
+	if current.file != nil && next.file != nil && current.file.Log != next.file.Log {
+		reloaded.Log = next.file.Log
+	}
+	if err := reloaded.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	pinned := *next
+	pinned.Log = current.Log
+	var changed []string
+	va, vb := reflect.ValueOf(current).Elem(), reflect.ValueOf(&pinned).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		if !va.Type().Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, va.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	return &reloaded, changed, nil
+}
@@ -6,13 +6,13 @@
 package config
 
 import (
-	"fmt"
-	"log"
-	"os"
+	"log/slog"
 
+	"wikidocify/file-upload-service/internal/logging"
+	"wikidocify/file-upload-service/internal/metrics"
 	"wikidocify/file-upload-service/internal/models"
+	"wikidocify/file-upload-service/internal/tracing"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -20,66 +20,41 @@ import (
 var DB *gorm.DB
 
 // InitDB initializes the database connection and performs auto-migration.
-func InitDB() {
-	log.Println("[DATABASE] Starting database initialization...")
+func InitDB(cfg *Config) {
+	logger := slog.With("component", "database")
+	logger.Info("Connecting to database",
+		"host", cfg.Database.Host, "user", cfg.Database.User, "dbname", cfg.Database.Name,
+		"port", cfg.Database.Port, "sslmode", cfg.Database.SSLMode)
 
-	// Load environment variables from .env file (optional, for local dev)
-	_ = godotenv.Load()
-
-	// Build DSN from environment variables with sensible defaults
-	host := os.Getenv("DB_HOST")
-	if host == "" {
-		host = "localhost"
-	}
-	user := os.Getenv("DB_USER")
-	if user == "" {
-		user = "doc_db_admin"
-	}
-	password := os.Getenv("DB_PASSWORD")
-	if password == "" {
-		password = "SecurePass889"
-	}
-	dbname := os.Getenv("DB_NAME")
-	if dbname == "" {
-		dbname = "documents_db"
-	}
-	port := os.Getenv("DB_PORT")
-	if port == "" {
-		port = "5432"
-	}
-	sslmode := os.Getenv("DB_SSLMODE")
-	if sslmode == "" {
-		sslmode = "disable"
+	var err error
+	DB, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
+	if err != nil {
+		logging.Fatal("Failed to connect to database", "component", "database", "error", err)
 	}
+	logger.Info("Database connection established")
 
-	log.Printf("[DATABASE] Connecting to database: host=%s, user=%s, dbname=%s, port=%s, sslmode=%s",
-		host, user, dbname, port, sslmode)
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		host, user, password, dbname, port, sslmode)
+	// Record query latency for every GORM statement
+	if err := DB.Use(metrics.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register metrics plugin", "component", "database", "error", err)
+	}
 
-	log.Println("[DATABASE] Attempting database connection...")
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("[DATABASE] Failed to connect to database: %v", err)
+	// Create a child span for every GORM statement
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register tracing plugin", "component", "database", "error", err)
 	}
-	log.Println("[DATABASE] Database connection established successfully!")
 
 	// Auto migrate the Document model
-	log.Println("[DATABASE] Starting database migration...")
 	err = DB.AutoMigrate(&models.Document{})
 	if err != nil {
-		log.Fatalf("[DATABASE] Failed to migrate database: %v", err)
+		logging.Fatal("Failed to migrate database", "component", "database", "error", err)
 	}
-	log.Println("[DATABASE] Database migration completed successfully!")
-	log.Println("[DATABASE] Database initialization complete")
+	logger.Info("Database migration completed")
 }
 
 // GetDB returns the database connection instance.
 func GetDB() *gorm.DB {
 	if DB == nil {
-		log.Println("[DATABASE] Warning: Database connection is nil")
+		slog.Warn("Database connection is nil", "component", "database")
 	}
 	return DB
 }
@@ -8,8 +8,9 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"wikidocify/file-upload-service/internal/models"
@@ -18,8 +19,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// TenantHeader optionally names the tenant of a request
+const TenantHeader = "X-Tenant-ID"
+
+// documentStore is the part of models.DocumentModel the controller uses
+type documentStore interface {
+	Create(ctx context.Context, doc *models.Document) error
+	FindAllPaginated(ctx context.Context, tenantID string, page, limit int) ([]models.Document, int64, error)
+	FindAfterID(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.Document, error)
+	FindVersions(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error)
+	FindByID(ctx context.Context, id string) (models.Document, error)
+	Update(ctx context.Context, doc *models.Document) error
+	Delete(ctx context.Context, id string) error
+}
+
 type DocumentController struct {
-	documentModel *models.DocumentModel
+	documentModel documentStore
 }
 
 // DocumentRequest represents the JSON structure for incoming requests
@@ -27,47 +42,92 @@ type DocumentRequest struct {
 	Title   string `json:"title" binding:"required"`
 	Content string `json:"content" binding:"required"`
 	Author  string `json:"author"`
+
+	// Workspace the document belongs to; falls back to the X-Tenant-ID header
+	// and then to the default tenant. It cannot be changed by an update.
+	TenantID string `json:"tenant_id"`
+
+	// Access control; owner defaults to the author and visibility to public.
+	// An update only changes the fields it sends.
+	Owner         *string   `json:"owner"`
+	Visibility    *string   `json:"visibility" binding:"omitempty,oneof=private team public"`
+	AllowedUsers  *[]string `json:"allowed_users"`
+	AllowedGroups *[]string `json:"allowed_groups"`
+}
+
+// applyACL copies the access control fields sent in the request onto doc,
+// keeping the others
+func (r *DocumentRequest) applyACL(doc *models.Document) {
+	if r.Owner != nil {
+		doc.Owner = *r.Owner
+	}
+	if doc.Owner == "" {
+		doc.Owner = doc.Author
+	}
+	if r.Visibility != nil && *r.Visibility != "" {
+		doc.Visibility = *r.Visibility
+	}
+	if doc.Visibility == "" {
+		doc.Visibility = models.VisibilityPublic
+	}
+	if r.AllowedUsers != nil {
+		doc.AllowedUsers = *r.AllowedUsers
+	}
+	if r.AllowedGroups != nil {
+		doc.AllowedGroups = *r.AllowedGroups
+	}
 }
 
 func NewDocumentController(db *gorm.DB) *DocumentController {
-	log.Println("[CONTROLLER] Creating new DocumentController instance")
 	return &DocumentController{
 		documentModel: models.NewDocumentModel(db),
 	}
 }
 
 func (dc *DocumentController) Create(c *gin.Context) {
-	log.Printf("[API] POST /documents - Creating new document from IP: %s", c.ClientIP())
+	ctx := c.Request.Context()
 
 	var docRequest DocumentRequest
 	if err := c.ShouldBindJSON(&docRequest); err != nil {
-		log.Printf("[API] POST /documents - Invalid JSON payload: %v", err)
+		slog.WarnContext(ctx, "Invalid JSON payload", "component", "api", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	document := models.Document{
-		Title:   docRequest.Title,
-		Content: []byte(docRequest.Content),
-		Author:  docRequest.Author,
+	tenantID := docRequest.TenantID
+	if tenantID == "" {
+		tenantID = c.GetHeader(TenantHeader)
+	}
+	if tenantID == "" {
+		tenantID = models.DefaultTenant
+	}
+	if !models.ValidTenantID(tenantID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
 	}
 
-	log.Printf("[API] POST /documents - Received document: title='%s', author='%s', content_size=%d bytes",
-		document.Title, document.Author, len(document.Content))
+	document := models.Document{
+		TenantID: tenantID,
+		Title:    docRequest.Title,
+		Content:  []byte(docRequest.Content),
+		Author:   docRequest.Author,
+	}
+	docRequest.applyACL(&document)
 
-	if err := dc.documentModel.Create(&document); err != nil {
-		log.Printf("[DATABASE] Failed to create document: %v", err)
+	if err := dc.documentModel.Create(ctx, &document); err != nil {
+		slog.ErrorContext(ctx, "Failed to create document", "component", "database", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("[DATABASE] Document created successfully with ID: %d", document.ID)
-	log.Printf("[API] POST /documents - Returning created document (ID: %d)", document.ID)
+	slog.InfoContext(ctx, "Document created", "component", "api",
+		"doc_id", document.ID, "tenant_id", document.TenantID,
+		"content_size", len(document.Content), "visibility", document.Visibility)
 	c.JSON(http.StatusCreated, document)
 }
 
 func (dc *DocumentController) GetAll(c *gin.Context) {
-	log.Printf("[API] GET /documents - Fetching all documents from IP: %s", c.ClientIP())
+	ctx := c.Request.Context()
 
 	// Parse pagination params
 	page := 1
@@ -84,11 +144,46 @@ func (dc *DocumentController) GetAll(c *gin.Context) {
 	if limit < 1 {
 		limit = 20
 	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	// Optional tenant filter
+	tenantID := c.Query("tenant_id")
+	if tenantID == "" {
+		tenantID = c.GetHeader(TenantHeader)
+	}
+	if tenantID != "" && !models.ValidTenantID(tenantID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+
+	// ?after_id= pages by ID instead, for callers that walk every document
+	if a := c.Query("after_id"); a != "" {
+		var afterID uint32
+		if _, err := fmt.Sscanf(a, "%d", &afterID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after_id"})
+			return
+		}
+		documents, err := dc.documentModel.FindAfterID(ctx, tenantID, afterID, limit)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to fetch documents", "component", "database",
+				"tenant_id", tenantID, "after_id", afterID, "limit", limit, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"documents": documents,
+			"after_id":  afterID,
+			"limit":     limit,
+		})
+		return
+	}
 
-	log.Printf("[DATABASE] Querying documents: page=%d, limit=%d", page, limit)
-	documents, total, err := dc.documentModel.FindAllPaginated(page, limit)
+	documents, total, err := dc.documentModel.FindAllPaginated(ctx, tenantID, page, limit)
 	if err != nil {
-		log.Printf("[DATABASE] Failed to fetch documents: %v", err)
+		slog.ErrorContext(ctx, "Failed to fetch documents", "component", "database",
+			"tenant_id", tenantID, "page", page, "limit", limit, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -101,42 +196,81 @@ func (dc *DocumentController) GetAll(c *gin.Context) {
 	})
 }
 
+// maxPageLimit caps the page size of GetAll and Versions
+const maxPageLimit = 10000
+
+// Versions lists the ID, tenant and last update time of documents in ID
+// order, a page at a time: ?after_id= is the last ID of the previous page.
+// It lets the search service check its index without fetching contents.
+func (dc *DocumentController) Versions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var afterID uint32
+	limit := 1000
+	if a := c.Query("after_id"); a != "" {
+		if _, err := fmt.Sscanf(a, "%d", &afterID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after_id"})
+			return
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 || limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	tenantID := c.Query("tenant_id")
+	if tenantID == "" {
+		tenantID = c.GetHeader(TenantHeader)
+	}
+	if tenantID != "" && !models.ValidTenantID(tenantID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+
+	versions, err := dc.documentModel.FindVersions(ctx, tenantID, afterID, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to fetch document versions", "component", "database",
+			"tenant_id", tenantID, "after_id", afterID, "limit", limit, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": versions,
+		"limit":    limit,
+	})
+}
+
 func (dc *DocumentController) GetByID(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.Param("id")
-	log.Printf("[API] GET /documents/%s - Fetching document by ID from IP: %s", id, c.ClientIP())
 
-	log.Printf("[DATABASE] Querying document with ID: %s", id)
-	document, err := dc.documentModel.FindByID(id)
+	document, err := dc.documentModel.FindByID(ctx, id)
 	if err != nil {
-		log.Printf("[DATABASE] Document with ID %s not found: %v", id, err)
+		slog.DebugContext(ctx, "Document not found", "component", "database", "doc_id", id, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
 		return
 	}
 
-	log.Printf("[DATABASE] Found document: ID=%d, title='%s', content_size=%d bytes",
-		document.ID, document.Title, len(document.Content))
-	log.Printf("[API] GET /documents/%s - Returning document", id)
 	c.JSON(http.StatusOK, document)
 }
 
 func (dc *DocumentController) Update(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.Param("id")
-	log.Printf("[API] PUT /documents/%s - Updating document from IP: %s", id, c.ClientIP())
 
-	log.Printf("[DATABASE] Finding document with ID: %s for update", id)
-	document, err := dc.documentModel.FindByID(id)
+	document, err := dc.documentModel.FindByID(ctx, id)
 	if err != nil {
-		log.Printf("[DATABASE] Document with ID %s not found for update: %v", id, err)
+		slog.DebugContext(ctx, "Document not found for update", "component", "database", "doc_id", id, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
 		return
 	}
 
-	log.Printf("[DATABASE] Found document to update: ID=%d, current title='%s', content_size=%d bytes",
-		document.ID, document.Title, len(document.Content))
-
 	var docRequest DocumentRequest
 	if err := c.ShouldBindJSON(&docRequest); err != nil {
-		log.Printf("[API] PUT /documents/%s - Invalid JSON payload: %v", id, err)
+		slog.WarnContext(ctx, "Invalid JSON payload", "component", "api", "doc_id", id, "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -144,41 +278,36 @@ func (dc *DocumentController) Update(c *gin.Context) {
 	document.Title = docRequest.Title
 	document.Content = []byte(docRequest.Content)
 	document.Author = docRequest.Author
+	docRequest.applyACL(&document)
 
-	log.Printf("[API] PUT /documents/%s - New data: title='%s', author='%s', content_size=%d bytes",
-		id, document.Title, document.Author, len(document.Content))
-
-	if err := dc.documentModel.Update(&document); err != nil {
-		log.Printf("[DATABASE] Failed to update document with ID %s: %v", id, err)
+	if err := dc.documentModel.Update(ctx, &document); err != nil {
+		slog.ErrorContext(ctx, "Failed to update document", "component", "database", "doc_id", id, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("[DATABASE] Document with ID %s updated successfully", id)
-	log.Printf("[API] PUT /documents/%s - Returning updated document", id)
+	slog.InfoContext(ctx, "Document updated", "component", "api",
+		"doc_id", document.ID, "content_size", len(document.Content), "visibility", document.Visibility)
 	c.JSON(http.StatusOK, document)
 }
 
 func (dc *DocumentController) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.Param("id")
-	log.Printf("[API] DELETE /documents/%s - Deleting document from IP: %s", id, c.ClientIP())
 
-	_, err := dc.documentModel.FindByID(id)
+	_, err := dc.documentModel.FindByID(ctx, id)
 	if err != nil {
-		log.Printf("[DATABASE] Document with ID %s not found for deletion: %v", id, err)
+		slog.DebugContext(ctx, "Document not found for deletion", "component", "database", "doc_id", id, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
 		return
 	}
 
-	log.Printf("[DATABASE] Document with ID %s found, proceeding with deletion...", id)
-
-	if err := dc.documentModel.Delete(id); err != nil {
-		log.Printf("[DATABASE] Failed to delete document with ID %s: %v", id, err)
+	if err := dc.documentModel.Delete(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete document", "component", "database", "doc_id", id, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("[DATABASE] Document with ID %s deleted successfully", id)
-	log.Printf("[API] DELETE /documents/%s - Document deleted successfully", id)
+	slog.InfoContext(ctx, "Document deleted", "component", "api", "doc_id", id)
 	c.JSON(http.StatusOK, gin.H{"message": "Document deleted"})
 }
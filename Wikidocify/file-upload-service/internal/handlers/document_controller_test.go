@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wikidocify/file-upload-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// memoryStore is a documentStore over a map. The listings return nothing
+// and only record the limit they were given.
+type memoryStore struct {
+	docs   map[string]models.Document
+	limits []int
+}
+
+func (s *memoryStore) Create(ctx context.Context, doc *models.Document) error {
+	doc.ID = uint32(len(s.docs) + 1)
+	s.docs[strconv.Itoa(int(doc.ID))] = *doc
+	return nil
+}
+
+func (s *memoryStore) FindAllPaginated(ctx context.Context, tenantID string, page, limit int) ([]models.Document, int64, error) {
+	s.limits = append(s.limits, limit)
+	return nil, 0, nil
+}
+
+func (s *memoryStore) FindAfterID(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.Document, error) {
+	s.limits = append(s.limits, limit)
+	return nil, nil
+}
+
+func (s *memoryStore) FindVersions(ctx context.Context, tenantID string, afterID uint32, limit int) ([]models.DocumentVersion, error) {
+	s.limits = append(s.limits, limit)
+	return nil, nil
+}
+
+func (s *memoryStore) FindByID(ctx context.Context, id string) (models.Document, error) {
+	doc, ok := s.docs[id]
+	if !ok {
+		return models.Document{}, gorm.ErrRecordNotFound
+	}
+	return doc, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, doc *models.Document) error {
+	s.docs[strconv.Itoa(int(doc.ID))] = *doc
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string) error {
+	delete(s.docs, id)
+	return nil
+}
+
+func newTestRouter(store *memoryStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	dc := &DocumentController{documentModel: store}
+	router := gin.New()
+	router.POST("/documents", dc.Create)
+	router.PUT("/documents/:id", dc.Update)
+	router.GET("/documents", dc.GetAll)
+	router.GET("/documents/versions", dc.Versions)
+	return router
+}
+
+func send(t *testing.T, router *gin.Engine, method, path, body string) models.Document {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+		t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body.String())
+	}
+	var doc models.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return doc
+}
+
+func TestUpdateKeepsACLFieldsLeftOut(t *testing.T) {
+	store := &memoryStore{docs: map[string]models.Document{
+		"1": {
+			ID: 1, TenantID: "default", Title: "Salaries", Content: []byte("..."), Author: "alice",
+			Owner: "alice", Visibility: models.VisibilityPrivate, AllowedUsers: []string{"bob"}, AllowedGroups: []string{"hr"},
+		},
+	}}
+	router := newTestRouter(store)
+
+	doc := send(t, router, http.MethodPut, "/documents/1", `{"title": "Salaries 2025", "content": "...", "author": "carol"}`)
+	if doc.Title != "Salaries 2025" || doc.Visibility != models.VisibilityPrivate || doc.Owner != "alice" ||
+		!slices.Equal(doc.AllowedUsers, []string{"bob"}) || !slices.Equal(doc.AllowedGroups, []string{"hr"}) {
+		t.Fatalf("expected the ACL to be kept, got %+v", doc)
+	}
+	if stored := store.docs["1"]; stored.Visibility != models.VisibilityPrivate {
+		t.Fatalf("expected the stored document to stay private, got %q", stored.Visibility)
+	}
+
+	doc = send(t, router, http.MethodPut, "/documents/1", `{"title": "Salaries", "content": "...", "visibility": "team", "allowed_users": []}`)
+	if doc.Visibility != models.VisibilityTeam || len(doc.AllowedUsers) != 0 || !slices.Equal(doc.AllowedGroups, []string{"hr"}) {
+		t.Fatalf("expected only the fields sent to change, got %+v", doc)
+	}
+}
+
+func TestCreateDefaultsACL(t *testing.T) {
+	router := newTestRouter(&memoryStore{docs: map[string]models.Document{}})
+
+	for _, tt := range []struct {
+		name, body, owner, visibility string
+	}{
+		{"defaults", `{"title": "Roadmap", "content": "...", "author": "alice"}`, "alice", models.VisibilityPublic},
+		{"explicit", `{"title": "Roadmap", "content": "...", "author": "alice", "owner": "bob", "visibility": "private"}`, "bob", models.VisibilityPrivate},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := send(t, router, http.MethodPost, "/documents", tt.body)
+			if doc.Owner != tt.owner || doc.Visibility != tt.visibility {
+				t.Fatalf("expected owner %q and visibility %q, got %q and %q", tt.owner, tt.visibility, doc.Owner, doc.Visibility)
+			}
+		})
+	}
+}
+
+func TestListingsCapTheLimit(t *testing.T) {
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/documents?limit=50", 50},
+		{"/documents?limit=1000000", maxPageLimit},
+		{"/documents?after_id=0&limit=50", 50},
+		{"/documents?after_id=0&limit=1000000", maxPageLimit},
+		{"/documents/versions?limit=1000000", maxPageLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			store := &memoryStore{docs: map[string]models.Document{}}
+			w := httptest.NewRecorder()
+			newTestRouter(store).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body.String())
+			}
+			if !slices.Equal(store.limits, []int{tt.want}) {
+				t.Errorf("expected a page of %d, got %v", tt.want, store.limits)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+
+	"wikidocify/file-upload-service/internal/logging"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+)
+
+// injectHeaders adds the trace context and request ID found in ctx to the
+// message headers, where the search service's consumer reads them back
+func injectHeaders(ctx context.Context, msg *kafka.Message) {
+	carrier := headerCarrier{msg: msg}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		carrier.Set(logging.RequestIDHeader, requestID)
+	}
+}
+
+// headerCarrier adapts Kafka message headers to propagation.TextMapCarrier
+// so trace context can be injected into published messages
+type headerCarrier struct {
+	msg *kafka.Message
+}
+
+func (h headerCarrier) Get(key string) string {
+	for _, hdr := range h.msg.Headers {
+		if hdr.Key == key {
+			return string(hdr.Value)
+		}
+	}
+	return ""
+}
+
+func (h headerCarrier) Set(key, value string) {
+	for i, hdr := range h.msg.Headers {
+		if hdr.Key == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, hdr := range h.msg.Headers {
+		keys[i] = hdr.Key
+	}
+	return keys
+}
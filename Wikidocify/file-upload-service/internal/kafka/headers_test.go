@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"wikidocify/file-upload-service/internal/logging"
+	"wikidocify/file-upload-service/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consumedTraceparent is the W3C trace context header the search service's
+// consumer reads back in its messageContext test, for the span below
+const consumedTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+var testSpanContext = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+	SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	TraceFlags: trace.FlagsSampled,
+})
+
+func TestInjectHeaders(t *testing.T) {
+	if _, err := tracing.InitTracer(context.Background(), "none", "test", 1); err != nil {
+		t.Fatalf("InitTracer: %v", err)
+	}
+	spanCtx := trace.ContextWithSpanContext(context.Background(), testSpanContext)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "request ID and span",
+			ctx:         logging.WithRequestID(spanCtx, "req-1"),
+			wantHeaders: map[string]string{"traceparent": consumedTraceparent, logging.RequestIDHeader: "req-1"},
+		},
+		{
+			name:        "span only",
+			ctx:         spanCtx,
+			wantHeaders: map[string]string{"traceparent": consumedTraceparent},
+		},
+		{
+			name:        "request ID only",
+			ctx:         logging.WithRequestID(context.Background(), "req-2"),
+			wantHeaders: map[string]string{logging.RequestIDHeader: "req-2"},
+		},
+		{name: "nothing to propagate", ctx: context.Background(), wantHeaders: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.Message{Key: []byte("1")}
+			injectHeaders(tt.ctx, &msg)
+
+			got := map[string]string{}
+			for _, hdr := range msg.Headers {
+				got[hdr.Key] = string(hdr.Value)
+			}
+			if len(got) != len(tt.wantHeaders) {
+				t.Errorf("expected headers %v, got %v", tt.wantHeaders, got)
+			}
+			for key, want := range tt.wantHeaders {
+				if got[key] != want {
+					t.Errorf("expected header %s %q, got %q", key, want, got[key])
+				}
+			}
+
+			// Read the headers back the way the consumer does
+			carrier := headerCarrier{msg: &msg}
+			ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+			if got, want := carrier.Get(logging.RequestIDHeader), logging.RequestIDFromContext(tt.ctx); got != want {
+				t.Errorf("expected request ID %q back, got %q", want, got)
+			}
+			if got, want := trace.SpanContextFromContext(ctx).TraceID(), trace.SpanContextFromContext(tt.ctx).TraceID(); got != want {
+				t.Errorf("expected trace ID %s back, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestHeaderCarrier(t *testing.T) {
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "a", Value: []byte("1")}}}
+	carrier := headerCarrier{msg: &msg}
+	carrier.Set("a", "2")
+	carrier.Set("b", "3")
+
+	if got := carrier.Get("a"); got != "2" {
+		t.Errorf("expected the existing header to be replaced, got %q", got)
+	}
+	if got := carrier.Get("b"); got != "3" {
+		t.Errorf("expected the new header to be added, got %q", got)
+	}
+	if got := carrier.Get("c"); got != "" {
+		t.Errorf("expected no value for a missing header, got %q", got)
+	}
+	if keys := carrier.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("expected keys [a b], got %v", keys)
+	}
+}
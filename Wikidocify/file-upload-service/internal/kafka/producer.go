@@ -3,30 +3,33 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
+	"wikidocify/file-upload-service/internal/metrics"
+	"wikidocify/file-upload-service/internal/tracing"
+
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DocEvent matches the event structure expected by the consumer
 type DocEvent struct {
-	Event   string `json:"event"`   // "created", "updated", "deleted"
-	ID      string `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Event    string `json:"event"` // "created", "updated", "deleted"
+	TenantID string `json:"tenant_id"`
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
 	// Add more fields if needed (e.g., Author, CreatedAt, etc.)
 }
 
 var KafkaWriter *kafka.Writer
 
-func InitKafkaWriter() {
-	broker := os.Getenv("KAFKA_BROKER")
-	topic := os.Getenv("KAFKA_TOPIC")
-	if broker == "" || topic == "" {
-		log.Fatal("[KAFKA] KAFKA_BROKER and KAFKA_TOPIC must be set")
-	}
+// InitKafkaWriter creates the producer publishing document events to topic
+func InitKafkaWriter(broker, topic string) {
 	KafkaWriter = &kafka.Writer{
 		Addr:         kafka.TCP(broker),
 		Topic:        topic,
@@ -35,23 +38,30 @@ func InitKafkaWriter() {
 		Async:        false,
 		BatchTimeout: 10 * time.Millisecond,
 	}
-	log.Printf("[KAFKA] Producer initialized for broker %s, topic %s", broker, topic)
+	slog.Info("Kafka producer initialized", "component", "kafka", "broker", broker, "topic", topic)
 }
 
-func PublishDocEvent(eventType, id, title, content string) error {
+// PublishDocEvent publishes a document event. The trace context from ctx is
+// injected into the message headers so the consumer can continue the trace.
+func PublishDocEvent(ctx context.Context, eventType, tenantID, id, title, content string) error {
 	if KafkaWriter == nil {
-		log.Println("[KAFKA] KafkaWriter is not initialized")
+		slog.WarnContext(ctx, "Kafka writer is not initialized", "component", "kafka",
+			"doc_id", id, "event_type", eventType)
 		return nil
 	}
 	event := DocEvent{
-		Event:   eventType,
-		ID:      id,
-		Title:   title,
-		Content: content,
+		Event:    eventType,
+		TenantID: tenantID,
+		ID:       id,
+		Title:    title,
+		Content:  content,
 	}
+	start := time.Now()
 	value, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("[KAFKA] Failed to marshal event: %v", err)
+		slog.ErrorContext(ctx, "Failed to marshal event", "component", "kafka",
+			"doc_id", id, "event_type", eventType, "error", err)
+		metrics.ObserveKafkaPublish(eventType, start, err)
 		return err
 	}
 	msg := kafka.Message{
@@ -59,11 +69,33 @@ func PublishDocEvent(eventType, id, title, content string) error {
 		Value: value,
 		Time:  time.Now(),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	ctx, span := tracing.Tracer().Start(ctx, KafkaWriter.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(KafkaWriter.Topic),
+			attribute.String("document.id", id),
+			attribute.String("tenant.id", tenantID),
+			attribute.String("event.type", eventType),
+		),
+	)
+	defer span.End()
+	injectHeaders(ctx, &msg)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	err = KafkaWriter.WriteMessages(ctx, msg)
+	metrics.ObserveKafkaPublish(eventType, start, err)
+	duration := time.Since(start).Milliseconds()
 	if err != nil {
-		log.Printf("[KAFKA] Failed to publish event: %v", err)
+		slog.ErrorContext(ctx, "Failed to publish event", "component", "kafka",
+			"doc_id", id, "event_type", eventType, "duration_ms", duration, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return err
 	}
-	return err
-}
\ No newline at end of file
+	slog.DebugContext(ctx, "Published event", "component", "kafka",
+		"doc_id", id, "event_type", eventType, "duration_ms", duration)
+	return nil
+}
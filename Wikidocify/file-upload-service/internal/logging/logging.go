@@ -0,0 +1,75 @@
+// logging/logging.go
+// This file contains the structured logger setup for the document service
+// It defines the InitLogger function and the context-aware slog handler
+// Output is JSON on stdout; the level can be changed at runtime with SetLevel
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// level is shared by every logger so it can be changed at runtime
+var level = new(slog.LevelVar)
+
+// InitLogger installs a JSON slog logger as the process default. The level
+// is one of "debug", "info", "warn" or "error".
+func InitLogger(levelName string) error {
+	if err := SetLevel(levelName); err != nil {
+		return err
+	}
+	handler := &contextHandler{
+		Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}),
+	}
+	slog.SetDefault(slog.New(handler).With("service", "file-upload-service"))
+	return nil
+}
+
+// SetLevel changes the minimum level of the default logger
+func SetLevel(levelName string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(levelName))); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", levelName, err)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Fatal logs at error level and exits, replacing log.Fatalf
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the request ID and trace/span IDs found in the
+// context to every record logged with one of the *Context functions
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
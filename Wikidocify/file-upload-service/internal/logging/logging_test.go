@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testTraceID = trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	testSpanID  = trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+)
+
+// captureLogs makes the default logger write JSON records to the returned
+// buffer through contextHandler, until the test ends
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(&contextHandler{Handler: slog.NewJSONHandler(&buf, nil)}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// lastRecord decodes the last JSON record written to buf
+func lastRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var record map[string]any
+	if err := json.Unmarshal(lines[len(lines)-1], &record); err != nil {
+		t.Fatalf("failed to decode the log record %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestContextHandler(t *testing.T) {
+	spanCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    testTraceID,
+		SpanID:     testSpanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]any
+	}{
+		{"no context values", context.Background(), map[string]any{"request_id": nil, "trace_id": nil, "span_id": nil}},
+		{"request ID", WithRequestID(context.Background(), "req-1"), map[string]any{"request_id": "req-1", "trace_id": nil}},
+		{"span", spanCtx, map[string]any{"request_id": nil, "trace_id": testTraceID.String(), "span_id": testSpanID.String()}},
+		{"request ID and span", WithRequestID(spanCtx, "req-2"), map[string]any{"request_id": "req-2", "trace_id": testTraceID.String(), "span_id": testSpanID.String()}},
+		{"empty request ID", WithRequestID(context.Background(), ""), map[string]any{"request_id": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLogs(t)
+			slog.With("component", "test").InfoContext(tt.ctx, "hello")
+			record := lastRecord(t, buf)
+			if record["component"] != "test" {
+				t.Errorf("expected the logger attributes to be kept, got %v", record)
+			}
+			for key, want := range tt.want {
+				if got := record[key]; got != want {
+					t.Errorf("expected %s %v, got %v", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	t.Cleanup(func() { level.Set(slog.LevelInfo) })
+
+	tests := []struct {
+		name    string
+		level   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", "debug", slog.LevelDebug, false},
+		{"upper case", "WARN", slog.LevelWarn, false},
+		{"error", "error", slog.LevelError, false},
+		{"unknown", "verbose", slog.LevelError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetLevel(tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got := level.Level(); got != tt.want {
+				t.Errorf("expected level %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// metrics/metrics.go
+// This file contains the Prometheus collectors for the document service
+// It defines the HTTP, document, database and Kafka metrics
+// Metric names are prefixed with the "wikidocify" namespace; the HTTP metrics
+// have the names and labels of the search service's
+
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "wikidocify"
+
+var (
+	// HTTPRequestsTotal counts handled HTTP requests by method, route and status.
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests handled.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency by method and route.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// HTTPRequestsInFlight tracks requests currently being served.
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of HTTP requests currently being served.",
+	})
+
+	// DocumentOperationsTotal counts successful document writes by operation
+	// ("create", "update", "delete").
+	DocumentOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "documents",
+		Name:      "operations_total",
+		Help:      "Total number of successful document create/update/delete operations.",
+	}, []string{"operation"})
+
+	// DocumentContentSize observes the content size of written documents.
+	DocumentContentSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "documents",
+		Name:      "content_size_bytes",
+		Help:      "Size of document content in bytes on create and update.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 8), // 256B .. 4MB
+	}, []string{"operation"})
+
+	// DBQueryDuration observes GORM statement latency by operation and table.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database query latency in seconds.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+
+	// DBQueryErrorsTotal counts failed GORM statements by operation and table.
+	DBQueryErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "Total number of failed database queries.",
+	}, []string{"operation", "table"})
+
+	// KafkaPublishTotal counts published events by event type and result
+	// ("success" or "failure").
+	KafkaPublishTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "publish_total",
+		Help:      "Total number of Kafka publish attempts by result.",
+	}, []string{"event", "result"})
+
+	// KafkaPublishDuration observes Kafka publish latency by event type.
+	KafkaPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "publish_duration_seconds",
+		Help:      "Kafka publish latency in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event"})
+)
+
+// GinMiddleware records request count, latency and in-flight requests.
+// Requests are labelled with the matched route template (e.g. /documents/:id)
+// rather than the raw path to keep label cardinality bounded.
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		HTTPRequestsInFlight.Inc()
+		defer HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		HTTPRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveDocument records a successful document operation and, for writes
+// that carry content, its size.
+func ObserveDocument(operation string, contentSize int) {
+	DocumentOperationsTotal.WithLabelValues(operation).Inc()
+	if operation != "delete" {
+		DocumentContentSize.WithLabelValues(operation).Observe(float64(contentSize))
+	}
+}
+
+// ObserveKafkaPublish records the outcome and latency of a Kafka publish.
+func ObserveKafkaPublish(event string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	KafkaPublishTotal.WithLabelValues(event, result).Inc()
+	KafkaPublishDuration.WithLabelValues(event).Observe(time.Since(start).Seconds())
+}
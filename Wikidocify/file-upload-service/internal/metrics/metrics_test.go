@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// sampleCount returns the number of observations of a histogram series
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/documents/:id", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	requests := HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/documents/:id", "404")
+	unmatched := HTTPRequestsTotal.WithLabelValues(http.MethodGet, "unmatched", "404")
+	duration := HTTPRequestDuration.WithLabelValues(http.MethodGet, "/documents/:id")
+	beforeRequests, beforeUnmatched, beforeDuration := testutil.ToFloat64(requests), testutil.ToFloat64(unmatched), sampleCount(t, duration)
+
+	for _, path := range []string{"/documents/1", "/documents/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(requests) - beforeRequests; got != 2 {
+		t.Errorf("expected both requests counted under the route template, got %v", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - beforeUnmatched; got != 1 {
+		t.Errorf("expected the unknown path counted as unmatched, got %v", got)
+	}
+	if got := sampleCount(t, duration) - beforeDuration; got != 2 {
+		t.Errorf("expected two latency observations, got %d", got)
+	}
+	if got := testutil.ToFloat64(HTTPRequestsInFlight); got != 0 {
+		t.Errorf("expected no request in flight, got %v", got)
+	}
+}
+
+func TestGormPlugin(t *testing.T) {
+	// Dry run builds the statements without a database
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	if err := db.Use(GormPlugin{}); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+
+	duration := DBQueryDuration.WithLabelValues("query", "metrics_test")
+	failures := DBQueryErrorsTotal.WithLabelValues("query", "metrics_test")
+	beforeDuration, beforeFailures := sampleCount(t, duration), testutil.ToFloat64(failures)
+
+	var rows []map[string]interface{}
+	if err := db.Table("metrics_test").Find(&rows).Error; err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if got := sampleCount(t, duration) - beforeDuration; got != 1 {
+		t.Errorf("expected the query to be timed, got %d observations", got)
+	}
+	if got := testutil.ToFloat64(failures) - beforeFailures; got != 0 {
+		t.Errorf("expected no failure, got %v", got)
+	}
+
+	// A statement that fails is counted as an error as well
+	err = db.Callback().Query().Before("gorm:query").Register("metrics_test:fail", func(db *gorm.DB) {
+		_ = db.AddError(errors.New("connection refused"))
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := db.Table("metrics_test").Find(&rows).Error; err == nil {
+		t.Fatal("expected the query to fail")
+	}
+	if got := sampleCount(t, duration) - beforeDuration; got != 2 {
+		t.Errorf("expected the failed query to be timed, got %d observations", got)
+	}
+	if got := testutil.ToFloat64(failures) - beforeFailures; got != 1 {
+		t.Errorf("expected one failure, got %v", got)
+	}
+}
+
+func TestObserveKafkaPublish(t *testing.T) {
+	success := KafkaPublishTotal.WithLabelValues("created", "success")
+	failure := KafkaPublishTotal.WithLabelValues("created", "failure")
+	beforeSuccess, beforeFailure := testutil.ToFloat64(success), testutil.ToFloat64(failure)
+
+	ObserveKafkaPublish("created", time.Now(), nil)
+	ObserveKafkaPublish("created", time.Now(), errors.New("broker unavailable"))
+
+	if testutil.ToFloat64(success)-beforeSuccess != 1 || testutil.ToFloat64(failure)-beforeFailure != 1 {
+		t.Errorf("expected one success and one failure, got %v and %v",
+			testutil.ToFloat64(success)-beforeSuccess, testutil.ToFloat64(failure)-beforeFailure)
+	}
+}
@@ -7,22 +7,56 @@
 package models
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"regexp"
 	"time"
 
 	"wikidocify/file-upload-service/internal/kafka"
+	"wikidocify/file-upload-service/internal/metrics"
 
 	"gorm.io/gorm"
 )
 
+// Document visibility levels. The search service only returns a document to
+// callers allowed by these rules:
+//   - public: every authenticated caller
+//   - team: the owner, the allowed users and members of the allowed groups
+//   - private: the owner and the allowed users
+const (
+	VisibilityPrivate = "private"
+	VisibilityTeam    = "team"
+	VisibilityPublic  = "public"
+)
+
+// DefaultTenant is the workspace used when a request does not name one
+const DefaultTenant = "default"
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidTenantID reports whether id is a usable tenant ID. Tenant IDs end up
+// in Elasticsearch index names, so they are limited to lowercase letters,
+// digits, '-' and '_'.
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
 type Document struct {
 	ID        uint32    `json:"id" gorm:"primaryKey"`
+	TenantID  string    `json:"tenant_id" gorm:"index;not null;default:default"`
 	Title     string    `json:"title" binding:"required"`
 	Content   []byte    `json:"content" binding:"required"`
 	Author    string    `json:"author"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Access control list. Rows that predate it become private when the
+	// column is added, as the search service treats a missing visibility.
+	Owner         string   `json:"owner" gorm:"index"`
+	Visibility    string   `json:"visibility" gorm:"not null;default:private"`
+	AllowedUsers  []string `json:"allowed_users" gorm:"type:jsonb;serializer:json"`
+	AllowedGroups []string `json:"allowed_groups" gorm:"type:jsonb;serializer:json"`
 }
 
 // DocumentModel handles all database operations for documents
@@ -36,75 +70,119 @@ func NewDocumentModel(db *gorm.DB) *DocumentModel {
 }
 
 // Create adds a new document to the database
-func (m *DocumentModel) Create(doc *Document) error {
+func (m *DocumentModel) Create(ctx context.Context, doc *Document) error {
 	doc.CreatedAt = time.Now()
 	doc.UpdatedAt = time.Now()
-	err := m.DB.Create(doc).Error
+	err := m.DB.WithContext(ctx).Create(doc).Error
 	if err != nil {
 		return err // don't publish Kafka event if DB write fails
 	}
-	err = kafka.PublishDocEvent("created", fmt.Sprint(doc.ID), doc.Title, string(doc.Content))
+	metrics.ObserveDocument("create", len(doc.Content))
+	err = kafka.PublishDocEvent(ctx, "created", doc.TenantID, fmt.Sprint(doc.ID), doc.Title, string(doc.Content))
 	if err != nil {
-		log.Println("[KAFKA] Failed to publish event:", err)
+		slog.ErrorContext(ctx, "Failed to publish create event", "component", "kafka", "doc_id", doc.ID, "error", err)
 	}
 	return nil
 }
 
 // FindAll retrieves all documents
-func (m *DocumentModel) FindAll() ([]Document, error) {
+func (m *DocumentModel) FindAll(ctx context.Context) ([]Document, error) {
 	var documents []Document
-	err := m.DB.Find(&documents).Error
+	err := m.DB.WithContext(ctx).Find(&documents).Error
 	return documents, err
 }
 
-// FindAllPaginated retrieves all documents with pagination
-func (m *DocumentModel) FindAllPaginated(page, limit int) ([]Document, int64, error) {
+// FindAllPaginated retrieves all documents with pagination. When tenantID is
+// not empty only that tenant's documents are returned.
+func (m *DocumentModel) FindAllPaginated(ctx context.Context, tenantID string, page, limit int) ([]Document, int64, error) {
 	var documents []Document
 	var total int64
 
+	db := m.DB.WithContext(ctx).Model(&Document{})
+	if tenantID != "" {
+		db = db.Where("tenant_id = ?", tenantID)
+	}
 	offset := (page - 1) * limit
-	if err := m.DB.Model(&Document{}).Count(&total).Error; err != nil {
+	if err := db.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
-	err := m.DB.Limit(limit).Offset(offset).Order("created_at desc").Find(&documents).Error
+	err := db.Limit(limit).Offset(offset).Order("created_at desc").Find(&documents).Error
 	return documents, total, err
 }
 
+// FindAfterID retrieves up to limit documents with an ID greater than
+// afterID, in ID order. Unlike pages, the last ID of a batch stays a valid
+// place to continue from while documents are created and deleted. When
+// tenantID is not empty only that tenant's documents are returned.
+func (m *DocumentModel) FindAfterID(ctx context.Context, tenantID string, afterID uint32, limit int) ([]Document, error) {
+	var documents []Document
+	db := m.DB.WithContext(ctx).Where("id > ?", afterID)
+	if tenantID != "" {
+		db = db.Where("tenant_id = ?", tenantID)
+	}
+	err := db.Order("id").Limit(limit).Find(&documents).Error
+	return documents, err
+}
+
+// DocumentVersion identifies the current version of a document, for
+// comparing the documents against a copy such as the search index
+type DocumentVersion struct {
+	ID        uint32    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FindVersions retrieves the versions of up to limit documents with an ID
+// greater than afterID, in ID order. When tenantID is not empty only that
+// tenant's documents are returned.
+func (m *DocumentModel) FindVersions(ctx context.Context, tenantID string, afterID uint32, limit int) ([]DocumentVersion, error) {
+	var versions []DocumentVersion
+	db := m.DB.WithContext(ctx).Model(&Document{}).Where("id > ?", afterID)
+	if tenantID != "" {
+		db = db.Where("tenant_id = ?", tenantID)
+	}
+	err := db.Select("id", "tenant_id", "updated_at").Order("id").Limit(limit).Find(&versions).Error
+	return versions, err
+}
+
 // FindByID retrieves a document by its ID
-func (m *DocumentModel) FindByID(id string) (Document, error) {
+func (m *DocumentModel) FindByID(ctx context.Context, id string) (Document, error) {
 	var document Document
-	err := m.DB.First(&document, id).Error
+	err := m.DB.WithContext(ctx).First(&document, id).Error
 	return document, err
 }
 
 // Update modifies an existing document
-func (m *DocumentModel) Update(doc *Document) error {
+func (m *DocumentModel) Update(ctx context.Context, doc *Document) error {
 	doc.UpdatedAt = time.Now()
-	err := m.DB.Save(doc).Error
+	err := m.DB.WithContext(ctx).Save(doc).Error
 	if err != nil {
 		return err
 	}
-	err = kafka.PublishDocEvent("updated", fmt.Sprint(doc.ID), doc.Title, string(doc.Content))
+	metrics.ObserveDocument("update", len(doc.Content))
+	err = kafka.PublishDocEvent(ctx, "updated", doc.TenantID, fmt.Sprint(doc.ID), doc.Title, string(doc.Content))
 	if err != nil {
-		log.Println("[KAFKA] Failed to publish event:", err)
+		slog.ErrorContext(ctx, "Failed to publish update event", "component", "kafka", "doc_id", doc.ID, "error", err)
 	}
 	return nil
 }
 
 // Delete removes a document
-func (m *DocumentModel) Delete(id string) error {
+func (m *DocumentModel) Delete(ctx context.Context, id string) error {
+	db := m.DB.WithContext(ctx)
 	var doc Document
-	if err := m.DB.First(&doc, id).Error; err != nil {
+	if err := db.First(&doc, id).Error; err != nil {
 		return err
 	}
-	err := m.DB.Delete(&Document{}, id).Error
+	err := db.Delete(&Document{}, id).Error
 	if err != nil {
 		return err
 	}
+	metrics.ObserveDocument("delete", 0)
 	// Only send ID for delete event, leave title/content empty
-	err = kafka.PublishDocEvent("deleted", fmt.Sprint(doc.ID), "", "")
+	err = kafka.PublishDocEvent(ctx, "deleted", doc.TenantID, fmt.Sprint(doc.ID), "", "")
 	if err != nil {
-		log.Println("[KAFKA] Failed to publish delete event:", err)
+		slog.ErrorContext(ctx, "Failed to publish delete event", "component", "kafka", "doc_id", doc.ID, "error", err)
 	}
 	return nil
 }
@@ -7,38 +7,76 @@
 package routes
 
 import (
-	"log"
+	"crypto/subtle"
+	"net/http"
+	"strings"
 
 	"wikidocify/file-upload-service/internal/config"
 	"wikidocify/file-upload-service/internal/handlers"
+	"wikidocify/file-upload-service/internal/logging"
+	"wikidocify/file-upload-service/internal/metrics"
+	"wikidocify/file-upload-service/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func SetupRoutes() *gin.Engine {
-	log.Println("[ROUTES] Initializing Gin router...")
-	r := gin.Default()
+// SetupRoutes registers the routes. currentConfig returns the configuration
+// in effect, which changes when it is reloaded.
+func SetupRoutes(currentConfig func() *config.Config) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(metrics.GinMiddleware())
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+	r.Use(logging.RequestID())
+	r.Use(logging.AccessLog())
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Prometheus scrape endpoint
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Configuration in effect, with the secrets redacted
+	admin := r.Group("/admin", requireAdminToken(currentConfig))
+	admin.GET("/config", func(c *gin.Context) {
+		c.JSON(200, currentConfig().Redacted())
+	})
+
 	// Initialize controllers
-	log.Println("[ROUTES] Creating document controller...")
 	documentController := handlers.NewDocumentController(config.GetDB())
 
 	// Document routes
-	log.Println("[ROUTES] Setting up document endpoints...")
 	documentRoutes := r.Group("/documents")
 	{
 		documentRoutes.POST("", documentController.Create)
 		documentRoutes.GET("", documentController.GetAll)
+		documentRoutes.GET("/versions", documentController.Versions)
 		documentRoutes.GET("/:id", documentController.GetByID)
 		documentRoutes.PUT("/:id", documentController.Update)
 		documentRoutes.DELETE("/:id", documentController.Delete)
 	}
-
-	log.Println("[ROUTES] All routes registered successfully")
 	return r
 }
+
+// requireAdminToken lets through the requests with the admin token as their
+// bearer token. Without a token configured the /admin endpoints don't exist.
+func requireAdminToken(currentConfig func() *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := currentConfig().Admin.Token
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Admin endpoints are disabled"})
+			return
+		}
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}